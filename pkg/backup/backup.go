@@ -0,0 +1,274 @@
+package backup
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+
+	"github.com/robbymilo/rgallery/pkg/config"
+	"github.com/robbymilo/rgallery/pkg/database"
+	"github.com/robbymilo/rgallery/pkg/types"
+	"zombiezen.com/go/sqlite"
+	"zombiezen.com/go/sqlite/sqlitex"
+)
+
+type Conf = types.Conf
+
+// FormatVersion is the version of the archive layout written by Backup.
+// Restore rejects archives with a newer format.
+const FormatVersion = 1
+
+const (
+	manifestName = "manifest.json"
+	databaseName = "sqlite.db"
+	cacheDir     = "cache"
+)
+
+// stepPages is the number of database pages copied at a time, releasing the
+// read lock in between so the running server is not blocked for the whole backup.
+const stepPages = 1024
+
+// Manifest describes the contents of a backup archive. It is always the first file in the archive.
+type Manifest struct {
+	Format        int       `json:"format"`
+	Created       time.Time `json:"created"`
+	Version       string    `json:"version"`
+	Commit        string    `json:"commit"`
+	SchemaVersion int       `json:"schemaVersion"`
+	Database      string    `json:"database"`
+	Cache         bool      `json:"cache"`
+}
+
+// Backup writes a snapshot of the database, and optionally the thumbnail and transcode cache,
+// to a gzipped tar archive at dest. The database may be in use while the backup runs.
+func Backup(c Conf, dest string, includeCache bool) (Manifest, error) {
+	if c.DBBackend != "" && c.DBBackend != "sqlite" {
+		return Manifest{}, fmt.Errorf("backup is only supported with the sqlite backend, use the tools of your %s server instead", c.DBBackend)
+	}
+
+	src := database.NewSqlConnectionString(c)
+	if _, err := os.Stat(src); err != nil {
+		return Manifest{}, fmt.Errorf("error opening database: %v", err)
+	}
+
+	tmp, err := os.MkdirTemp(filepath.Dir(dest), ".rgallery-backup-")
+	if err != nil {
+		return Manifest{}, fmt.Errorf("error creating temporary directory: %v", err)
+	}
+	defer func() {
+		if err := os.RemoveAll(tmp); err != nil {
+			c.Logger.Error("error removing temporary directory", "error", err)
+		}
+	}()
+
+	snapshot := filepath.Join(tmp, databaseName)
+	if err := snapshotDB(c, src, snapshot); err != nil {
+		return Manifest{}, err
+	}
+
+	version, err := schemaVersion(c, snapshot)
+	if err != nil {
+		return Manifest{}, err
+	}
+
+	manifest := Manifest{
+		Format:        FormatVersion,
+		Created:       time.Now().UTC(),
+		Version:       c.Meta.Tag,
+		Commit:        c.Meta.Commit,
+		SchemaVersion: version,
+		Database:      databaseName,
+		Cache:         includeCache,
+	}
+
+	// write to a temporary file so an interrupted backup does not leave a partial archive at dest
+	partial := filepath.Join(tmp, "archive")
+	if err := writeArchive(c, partial, manifest, snapshot); err != nil {
+		return Manifest{}, err
+	}
+
+	if err := os.Rename(partial, dest); err != nil {
+		return Manifest{}, fmt.Errorf("error moving archive into place: %v", err)
+	}
+
+	return manifest, nil
+}
+
+// snapshotDB copies a consistent snapshot of the database at src to dst using SQLite's online backup API.
+func snapshotDB(c Conf, src, dst string) error {
+	srcConn, err := sqlite.OpenConn(src, sqlite.OpenReadOnly)
+	if err != nil {
+		return fmt.Errorf("error opening database: %v", err)
+	}
+	defer func() {
+		if err := srcConn.Close(); err != nil {
+			c.Logger.Error("srcConn.Close error", "err", err)
+		}
+	}()
+
+	dstConn, err := sqlite.OpenConn(dst, sqlite.OpenReadWrite, sqlite.OpenCreate)
+	if err != nil {
+		return fmt.Errorf("error creating database snapshot: %v", err)
+	}
+	defer func() {
+		if err := dstConn.Close(); err != nil {
+			c.Logger.Error("dstConn.Close error", "err", err)
+		}
+	}()
+
+	b, err := sqlite.NewBackup(dstConn, "main", srcConn, "main")
+	if err != nil {
+		return fmt.Errorf("error starting database backup: %v", err)
+	}
+	defer func() {
+		if err := b.Close(); err != nil {
+			c.Logger.Error("b.Close error", "err", err)
+		}
+	}()
+
+	for {
+		more, err := b.Step(stepPages)
+		if err != nil && !more {
+			return fmt.Errorf("error backing up database: %v", err)
+		}
+		if !more {
+			return nil
+		}
+		if err != nil {
+			// the database is locked by a writer, try again shortly
+			time.Sleep(100 * time.Millisecond)
+		}
+	}
+}
+
+// schemaVersion returns the schema version stored in the database at path.
+func schemaVersion(c Conf, path string) (int, error) {
+	conn, err := sqlite.OpenConn(path, sqlite.OpenReadOnly)
+	if err != nil {
+		return 0, fmt.Errorf("error opening database: %v", err)
+	}
+	defer func() {
+		if err := conn.Close(); err != nil {
+			c.Logger.Error("conn.Close error", "err", err)
+		}
+	}()
+
+	version, err := sqlitex.ResultInt(conn.Prep(`SELECT value FROM schema WHERE key = 'version'`))
+	if err != nil {
+		return 0, fmt.Errorf("error getting schema version: %v", err)
+	}
+
+	return version, nil
+}
+
+func writeArchive(c Conf, dest string, manifest Manifest, snapshot string) (err error) {
+	f, err := os.Create(dest)
+	if err != nil {
+		return fmt.Errorf("error creating archive: %v", err)
+	}
+	defer func() {
+		if cerr := f.Close(); cerr != nil && err == nil {
+			err = fmt.Errorf("error closing archive: %v", cerr)
+		}
+	}()
+
+	gz := gzip.NewWriter(f)
+	tw := tar.NewWriter(gz)
+
+	m, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error encoding manifest: %v", err)
+	}
+
+	err = tw.WriteHeader(&tar.Header{
+		Name:    manifestName,
+		Mode:    0o644,
+		Size:    int64(len(m)),
+		ModTime: manifest.Created,
+	})
+	if err != nil {
+		return fmt.Errorf("error writing manifest: %v", err)
+	}
+	if _, err := tw.Write(m); err != nil {
+		return fmt.Errorf("error writing manifest: %v", err)
+	}
+
+	if err := addFile(c, tw, snapshot, databaseName); err != nil {
+		return err
+	}
+
+	if manifest.Cache {
+		root := config.CachePath(c)
+		err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				if errors.Is(err, fs.ErrNotExist) && p == root {
+					return nil
+				}
+				return err
+			}
+			if !d.Type().IsRegular() {
+				return nil
+			}
+
+			rel, err := filepath.Rel(root, p)
+			if err != nil {
+				return err
+			}
+
+			return addFile(c, tw, p, path.Join(cacheDir, filepath.ToSlash(rel)))
+		})
+		if err != nil {
+			return fmt.Errorf("error adding cache to archive: %v", err)
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return fmt.Errorf("error writing archive: %v", err)
+	}
+	if err := gz.Close(); err != nil {
+		return fmt.Errorf("error writing archive: %v", err)
+	}
+
+	return nil
+}
+
+// addFile adds the file at src to the archive as name.
+func addFile(c Conf, tw *tar.Writer, src, name string) error {
+	f, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := f.Close(); err != nil {
+			c.Logger.Error("f.Close error", "err", err)
+		}
+	}()
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	hdr, err := tar.FileInfoHeader(info, "")
+	if err != nil {
+		return err
+	}
+	hdr.Name = name
+
+	if err := tw.WriteHeader(hdr); err != nil {
+		return fmt.Errorf("error writing %s: %v", name, err)
+	}
+	if _, err := io.Copy(tw, f); err != nil {
+		return fmt.Errorf("error writing %s: %v", name, err)
+	}
+
+	return nil
+}
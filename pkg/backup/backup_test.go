@@ -0,0 +1,279 @@
+package backup
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/robbymilo/rgallery/pkg/database"
+	"zombiezen.com/go/sqlite"
+	"zombiezen.com/go/sqlite/sqlitex"
+)
+
+func testConf(t *testing.T) Conf {
+	t.Helper()
+
+	dir := t.TempDir()
+	c := Conf{
+		Data:      filepath.Join(dir, "data"),
+		Cache:     filepath.Join(dir, "cache"),
+		DBBackend: "sqlite",
+		Logger:    slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+	database.CreateDB(c)
+
+	return c
+}
+
+func exec(t *testing.T, c Conf, query string) {
+	t.Helper()
+
+	conn, err := sqlite.OpenConn(database.NewSqlConnectionString(c))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	if err := sqlitex.ExecuteTransient(conn, query, nil); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func users(t *testing.T, c Conf) []string {
+	t.Helper()
+
+	conn, err := sqlite.OpenConn(database.NewSqlConnectionString(c), sqlite.OpenReadOnly)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	var names []string
+	err = sqlitex.ExecuteTransient(conn, "SELECT username FROM users ORDER BY username", &sqlitex.ExecOptions{
+		ResultFunc: func(stmt *sqlite.Stmt) error {
+			names = append(names, stmt.ColumnText(0))
+			return nil
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return names
+}
+
+func TestBackupRestore(t *testing.T) {
+	src := testConf(t)
+	exec(t, src, "INSERT INTO users (username, password, role) VALUES ('alice', 'x', 'admin')")
+
+	thumb := filepath.Join(src.Cache, "images", "1", "400.webp")
+	if err := os.MkdirAll(filepath.Dir(thumb), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(thumb, []byte("thumbnail"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	archive := filepath.Join(t.TempDir(), "backup.tar.gz")
+	manifest, err := Backup(src, archive, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if manifest.SchemaVersion != database.LatestVersion() {
+		t.Errorf("expected schema version %d, got %d", database.LatestVersion(), manifest.SchemaVersion)
+	}
+
+	dst := testConf(t)
+	exec(t, dst, "INSERT INTO users (username, password, role) VALUES ('bob', 'x', 'admin')")
+
+	stale := filepath.Join(dst.Cache, "images", "2", "400.webp")
+	if err := os.MkdirAll(filepath.Dir(stale), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(stale, []byte("stale"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Restore(dst, archive); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := strings.Join(users(t, dst), ","); got != "alice" {
+		t.Errorf("expected restored users alice, got %s", got)
+	}
+
+	b, err := os.ReadFile(filepath.Join(dst.Cache, "images", "1", "400.webp"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "thumbnail" {
+		t.Errorf("expected restored thumbnail, got %q", b)
+	}
+
+	// the cache is replaced, so thumbnails of media items that are not in the restored database are removed
+	if _, err := os.Stat(stale); !os.IsNotExist(err) {
+		t.Errorf("expected stale thumbnail to be removed, got %v", err)
+	}
+	entries, err := os.ReadDir(filepath.Dir(dst.Cache))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Errorf("expected only the data and cache directories, got %v", entries)
+	}
+}
+
+func TestRestoreInvalidCache(t *testing.T) {
+	src := testConf(t)
+	exec(t, src, "INSERT INTO users (username, password, role) VALUES ('alice', 'x', 'admin')")
+
+	archive := filepath.Join(t.TempDir(), "backup.tar.gz")
+	if _, err := Backup(src, archive, true); err != nil {
+		t.Fatal(err)
+	}
+
+	// a file outside of the cache directory fails the restore after the database has been extracted
+	invalid := filepath.Join(t.TempDir(), "invalid.tar.gz")
+	rewriteManifest(t, archive, invalid, func(m *Manifest) {}, "cache/images/1/400.webp", "cache/../escape")
+
+	dst := testConf(t)
+	exec(t, dst, "INSERT INTO users (username, password, role) VALUES ('bob', 'x', 'admin')")
+
+	thumb := filepath.Join(dst.Cache, "images", "2", "400.webp")
+	if err := os.MkdirAll(filepath.Dir(thumb), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(thumb, []byte("thumbnail"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := Restore(dst, invalid)
+	if err == nil || !strings.Contains(err.Error(), "unexpected file") {
+		t.Fatalf("expected unexpected file error, got %v", err)
+	}
+
+	// neither the database nor the cache are touched
+	if got := strings.Join(users(t, dst), ","); got != "bob" {
+		t.Errorf("expected database to be untouched, got users %s", got)
+	}
+	if _, err := os.Stat(thumb); err != nil {
+		t.Errorf("expected cache to be untouched, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dst.Cache, "images", "1", "400.webp")); !os.IsNotExist(err) {
+		t.Errorf("expected no files to be extracted into the cache, got %v", err)
+	}
+	entries, err := os.ReadDir(filepath.Dir(dst.Cache))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Errorf("expected temporary files to be removed, got %v", entries)
+	}
+}
+
+func TestRestoreNewerSchema(t *testing.T) {
+	src := testConf(t)
+
+	archive := filepath.Join(t.TempDir(), "backup.tar.gz")
+	if _, err := Backup(src, archive, false); err != nil {
+		t.Fatal(err)
+	}
+
+	// rewrite the manifest as if the backup came from a newer rgallery
+	newer := filepath.Join(t.TempDir(), "newer.tar.gz")
+	rewriteManifest(t, archive, newer, func(m *Manifest) {
+		m.SchemaVersion = database.LatestVersion() + 1
+	})
+
+	dst := testConf(t)
+	exec(t, dst, "INSERT INTO users (username, password, role) VALUES ('bob', 'x', 'admin')")
+
+	_, err := Restore(dst, newer)
+	if err == nil || !strings.Contains(err.Error(), "upgrade rgallery") {
+		t.Fatalf("expected schema version error, got %v", err)
+	}
+
+	if got := strings.Join(users(t, dst), ","); got != "bob" {
+		t.Errorf("expected database to be untouched, got users %s", got)
+	}
+}
+
+// rewriteManifest copies the archive at src to dst, editing its manifest and appending a file for each of extra.
+func rewriteManifest(t *testing.T, src, dst string, edit func(*Manifest), extra ...string) {
+	t.Helper()
+
+	in, err := os.Open(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer in.Close()
+	gr, err := gzip.NewReader(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr := tar.NewReader(gr)
+
+	out, err := os.Create(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer out.Close()
+	gw := gzip.NewWriter(out)
+	tw := tar.NewWriter(gw)
+
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		body, err := io.ReadAll(tr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if hdr.Name == manifestName {
+			var m Manifest
+			if err := json.Unmarshal(body, &m); err != nil {
+				t.Fatal(err)
+			}
+			edit(&m)
+			if body, err = json.Marshal(m); err != nil {
+				t.Fatal(err)
+			}
+			hdr.Size = int64(len(body))
+		}
+
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write(body); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, name := range extra {
+		body := []byte(name)
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0o644, Size: int64(len(body))}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write(body); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gw.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,297 @@
+package backup
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/robbymilo/rgallery/pkg/config"
+	"github.com/robbymilo/rgallery/pkg/database"
+	"zombiezen.com/go/sqlite"
+	"zombiezen.com/go/sqlite/sqlitex"
+)
+
+// Restore replaces the database, and the cache if the archive includes it, with the contents of
+// the archive at src. The database and cache are extracted and checked next to their destinations
+// before anything is overwritten, then both are moved into place. rgallery must not be running while restoring.
+func Restore(c Conf, src string) (Manifest, error) {
+	if c.DBBackend != "" && c.DBBackend != "sqlite" {
+		return Manifest{}, fmt.Errorf("restore is only supported with the sqlite backend, use the tools of your %s server instead", c.DBBackend)
+	}
+
+	f, err := os.Open(src)
+	if err != nil {
+		return Manifest{}, fmt.Errorf("error opening archive: %v", err)
+	}
+	defer func() {
+		if err := f.Close(); err != nil {
+			c.Logger.Error("f.Close error", "err", err)
+		}
+	}()
+
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return Manifest{}, fmt.Errorf("error reading archive: %v", err)
+	}
+	tr := tar.NewReader(gz)
+
+	manifest, err := readManifest(tr)
+	if err != nil {
+		return Manifest{}, err
+	}
+
+	if err := checkCompatible(manifest); err != nil {
+		return Manifest{}, err
+	}
+
+	dataDir, err := filepath.Abs(c.Data)
+	if err != nil {
+		return Manifest{}, fmt.Errorf("error parsing data dir: %v", err)
+	}
+	if err := os.MkdirAll(dataDir, os.ModePerm); err != nil {
+		return Manifest{}, fmt.Errorf("error creating data directory: %v", err)
+	}
+
+	hdr, err := tr.Next()
+	if err != nil {
+		return Manifest{}, fmt.Errorf("error reading database from archive: %v", err)
+	}
+	if hdr.Name != manifest.Database {
+		return Manifest{}, fmt.Errorf("expected %s in archive, found %s", manifest.Database, hdr.Name)
+	}
+
+	// extract next to the database so it can be moved into place without copying
+	tmp, err := os.CreateTemp(dataDir, ".sqlite.db.restore-")
+	if err != nil {
+		return Manifest{}, fmt.Errorf("error creating temporary database: %v", err)
+	}
+	defer func() {
+		if err := os.Remove(tmp.Name()); err != nil && !errors.Is(err, os.ErrNotExist) {
+			c.Logger.Error("error removing temporary database", "error", err)
+		}
+	}()
+
+	_, err = io.Copy(tmp, tr)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return Manifest{}, fmt.Errorf("error extracting database: %v", err)
+	}
+
+	if err := checkDB(c, tmp.Name(), manifest); err != nil {
+		return Manifest{}, err
+	}
+
+	// extract the cache next to the current one, which is only replaced once the whole archive has been read
+	root, err := filepath.Abs(config.CachePath(c))
+	if err != nil {
+		return Manifest{}, fmt.Errorf("error parsing cache dir: %v", err)
+	}
+
+	var cache string
+	if manifest.Cache {
+		if err := os.MkdirAll(filepath.Dir(root), os.ModePerm); err != nil {
+			return Manifest{}, fmt.Errorf("error creating cache directory: %v", err)
+		}
+
+		cache, err = os.MkdirTemp(filepath.Dir(root), "."+filepath.Base(root)+".restore-")
+		if err != nil {
+			return Manifest{}, fmt.Errorf("error creating temporary cache directory: %v", err)
+		}
+		defer func() {
+			if err := os.RemoveAll(cache); err != nil {
+				c.Logger.Error("error removing temporary cache directory", "error", err)
+			}
+		}()
+
+		if err := restoreCache(tr, cache); err != nil {
+			return Manifest{}, err
+		}
+	}
+
+	// the archive is valid, replace the cache and then the database
+	var old string
+	if manifest.Cache {
+		old, err = replaceCache(root, cache)
+		if err != nil {
+			return Manifest{}, err
+		}
+	}
+
+	if err := replaceDB(tmp.Name(), database.NewSqlConnectionString(c)); err != nil {
+		if old != "" {
+			// put the previous cache back so it matches the previous database
+			if rerr := os.Rename(root, cache); rerr != nil {
+				c.Logger.Error("error restoring previous cache", "error", rerr)
+			} else if rerr := os.Rename(old, root); rerr != nil {
+				c.Logger.Error("error restoring previous cache", "error", rerr)
+			}
+		}
+		return Manifest{}, err
+	}
+
+	// thumbnails of the previous cache that are not in the archive are stale
+	if old != "" {
+		if err := os.RemoveAll(old); err != nil {
+			c.Logger.Error("error removing previous cache", "error", err)
+		}
+	}
+
+	return manifest, nil
+}
+
+// replaceCache moves the extracted cache to root, returning where the previous cache was moved to, if there was one.
+func replaceCache(root, extracted string) (string, error) {
+	old := ""
+	if _, err := os.Stat(root); err == nil {
+		old = extracted + ".old"
+		if err := os.Rename(root, old); err != nil {
+			return "", fmt.Errorf("error moving previous cache aside: %v", err)
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return "", fmt.Errorf("error reading cache directory: %v", err)
+	}
+
+	if err := os.Rename(extracted, root); err != nil {
+		if old != "" {
+			if rerr := os.Rename(old, root); rerr != nil {
+				return "", fmt.Errorf("error moving cache into place: %v, and restoring the previous cache: %v", err, rerr)
+			}
+		}
+		return "", fmt.Errorf("error moving cache into place: %v", err)
+	}
+
+	return old, nil
+}
+
+// replaceDB moves the extracted database to dest, removing the write-ahead log of the previous database.
+func replaceDB(extracted, dest string) error {
+	for _, suffix := range []string{"-wal", "-shm"} {
+		if err := os.Remove(dest + suffix); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("error removing %s: %v", dest+suffix, err)
+		}
+	}
+	if err := os.Rename(extracted, dest); err != nil {
+		return fmt.Errorf("error moving database into place: %v", err)
+	}
+
+	return nil
+}
+
+// readManifest reads the manifest, which is the first file of an archive.
+func readManifest(tr *tar.Reader) (Manifest, error) {
+	hdr, err := tr.Next()
+	if err != nil {
+		return Manifest{}, fmt.Errorf("error reading archive: %v", err)
+	}
+	if hdr.Name != manifestName {
+		return Manifest{}, fmt.Errorf("not an rgallery backup: %s is not the first file in the archive", manifestName)
+	}
+
+	var manifest Manifest
+	if err := json.NewDecoder(tr).Decode(&manifest); err != nil {
+		return Manifest{}, fmt.Errorf("error decoding manifest: %v", err)
+	}
+
+	return manifest, nil
+}
+
+// checkCompatible checks that this version of rgallery can restore and run an archive's database.
+// Databases from older versions are migrated when rgallery starts.
+func checkCompatible(manifest Manifest) error {
+	if manifest.Format < 1 || manifest.Format > FormatVersion {
+		return fmt.Errorf("unsupported backup format %d, this version of rgallery supports format %d", manifest.Format, FormatVersion)
+	}
+
+	if latest := database.LatestVersion(); manifest.SchemaVersion > latest {
+		return fmt.Errorf("backup schema version %d is newer than the latest schema version %d supported by this version of rgallery, upgrade rgallery before restoring", manifest.SchemaVersion, latest)
+	}
+
+	return nil
+}
+
+// checkDB checks that an extracted database is intact and matches the manifest.
+func checkDB(c Conf, path string, manifest Manifest) error {
+	conn, err := sqlite.OpenConn(path, sqlite.OpenReadOnly)
+	if err != nil {
+		return fmt.Errorf("error opening restored database: %v", err)
+	}
+	defer func() {
+		if err := conn.Close(); err != nil {
+			c.Logger.Error("conn.Close error", "err", err)
+		}
+	}()
+
+	result, err := sqlitex.ResultText(conn.Prep(`PRAGMA integrity_check`))
+	if err != nil {
+		return fmt.Errorf("error checking restored database: %v", err)
+	}
+	if result != "ok" {
+		return fmt.Errorf("restored database is corrupt: %s", result)
+	}
+
+	version, err := sqlitex.ResultInt(conn.Prep(`SELECT value FROM schema WHERE key = 'version'`))
+	if err != nil {
+		return fmt.Errorf("error getting schema version: %v", err)
+	}
+	if version != manifest.SchemaVersion {
+		return fmt.Errorf("restored database has schema version %d, but the manifest lists %d", version, manifest.SchemaVersion)
+	}
+
+	return nil
+}
+
+// restoreCache extracts the remaining cache files of the archive into root.
+func restoreCache(tr *tar.Reader, root string) error {
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("error reading archive: %v", err)
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		rel, ok := strings.CutPrefix(path.Clean(hdr.Name), cacheDir+"/")
+		if !ok || !filepath.IsLocal(rel) {
+			return fmt.Errorf("unexpected file %s in archive", hdr.Name)
+		}
+
+		dest := filepath.Join(root, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(dest), os.ModePerm); err != nil {
+			return fmt.Errorf("error creating cache directory: %v", err)
+		}
+
+		if err := extractFile(tr, dest, hdr); err != nil {
+			return err
+		}
+	}
+}
+
+func extractFile(r io.Reader, dest string, hdr *tar.Header) error {
+	f, err := os.OpenFile(dest, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, hdr.FileInfo().Mode().Perm())
+	if err != nil {
+		return fmt.Errorf("error creating %s: %v", dest, err)
+	}
+
+	_, err = io.Copy(f, r)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return fmt.Errorf("error extracting %s: %v", dest, err)
+	}
+
+	return os.Chtimes(dest, hdr.ModTime, hdr.ModTime)
+}
@@ -1,10 +1,13 @@
 package config
 
 import (
+	"fmt"
 	"html/template"
 	"log/slog"
 	"os"
 	"path/filepath"
+	"regexp"
+	"slices"
 
 	"github.com/robbymilo/rgallery/pkg/types"
 	cli "github.com/urfave/cli/v2"
@@ -14,6 +17,11 @@ import (
 type Meta = types.Meta
 type Media = types.Media
 type Conf = types.Conf
+type Library = types.Library
+
+// DefaultLibrary is the name of the library of the media directory when no libraries are configured, which media
+// items scanned before there were libraries belong to.
+const DefaultLibrary = "default"
 
 func CachePath(c Conf) string {
 	cache_path, err := filepath.Abs(c.Cache)
@@ -33,6 +41,82 @@ func MediaPath(c Conf) string {
 	return media_path
 }
 
+// Libraries returns the libraries set in the config file, or the media directory as the only library if there are
+// none.
+func Libraries(c Conf) []Library {
+	if len(c.Libraries) == 0 {
+		return []Library{{Name: DefaultLibrary, Path: c.Media}}
+	}
+
+	return c.Libraries
+}
+
+// GetLibrary returns the library with a name, or false if it is not configured.
+func GetLibrary(name string, c Conf) (Library, bool) {
+	for _, lib := range Libraries(c) {
+		if lib.Name == name {
+			return lib, true
+		}
+	}
+
+	return Library{}, false
+}
+
+// LibraryPath returns the root of the library with a name, which the paths of its media items are relative to, or
+// an empty string if it is not configured.
+func LibraryPath(name string, c Conf) string {
+	lib, ok := GetLibrary(name, c)
+	if !ok {
+		return ""
+	}
+
+	return MediaPath(ForLibrary(lib, c))
+}
+
+// OriginalPath returns the absolute path of a file in a library, or an empty string if the library is not configured
+// so the file can not be opened.
+func OriginalPath(library, path string, c Conf) string {
+	root := LibraryPath(library, c)
+	if root == "" {
+		return ""
+	}
+
+	return filepath.Join(root, path)
+}
+
+// ForLibrary returns a copy of c for scanning a library, with the root of the library as its media directory and the
+// ignore patterns of the library added to the global ones.
+func ForLibrary(lib Library, c Conf) Conf {
+	c.Media = lib.Path
+	c.Library = lib.Name
+	c.Ignore = append(slices.Clip(c.Ignore), lib.Ignore...)
+
+	return c
+}
+
+// libraryName matches the names libraries can have, which are used in scan job scopes and URLs.
+var libraryName = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// CheckLibraries returns an error if a library in the config file has no path, or a name that is invalid or used by
+// another library.
+func CheckLibraries(c Conf) error {
+	names := make(map[string]bool, len(c.Libraries))
+	for _, lib := range c.Libraries {
+		if !libraryName.MatchString(lib.Name) {
+			return fmt.Errorf("invalid library name %q, expected letters, numbers, - and _", lib.Name)
+		}
+		if names[lib.Name] {
+			return fmt.Errorf("library %s is configured more than once", lib.Name)
+		}
+		if lib.Path == "" {
+			return fmt.Errorf("library %s has no path", lib.Name)
+		}
+		names[lib.Name] = true
+	}
+
+	return nil
+}
+
 // GetConf returns a Conf struct from the config file, cli flags, and env vars.
 func GetConf(cCtx cli.Context, Commit, Tag string) Conf {
 	var c Conf
@@ -60,6 +144,8 @@ func GetConf(cCtx cli.Context, Commit, Tag string) Conf {
 
 	c.Cache = cCtx.String("cache")
 	c.Data = cCtx.String("data")
+	c.DBBackend = cCtx.String("db-backend")
+	c.PostgresURL = cCtx.String("postgres-url")
 	c.Dev = cCtx.Bool("dev")
 	c.DisableAuth = cCtx.Bool("disable-auth")
 	c.LocationService = cCtx.String("location-service")
@@ -68,11 +154,22 @@ func GetConf(cCtx cli.Context, Commit, Tag string) Conf {
 	c.Media = cCtx.String("media")
 	c.Memories = cCtx.Bool("memories")
 	c.PreGenerateThumb = cCtx.Bool("pregenerate-thumbs")
+	c.PreGenerateSprites = cCtx.Bool("pregenerate-sprites")
+	c.SpriteInterval = cCtx.Int("sprite-interval")
 	c.Quality = cCtx.Int("quality")
 	c.TranscodeResolution = cCtx.Int("transcode-resolution")
+	c.TranscodeLadder = cCtx.String("transcode-ladder")
+	c.TranscodeWorkers = cCtx.Int("transcode-workers")
+	c.VideoPlayback = cCtx.String("video-playback")
 	c.ResizeService = cCtx.String("resize_service")
+	c.RawConverter = cCtx.String("raw-converter")
+	c.WriteMetadata = cCtx.String("write-metadata")
 	c.SessionLength = cCtx.Int("session-length")
 	c.TileServer = cCtx.String("tile-server")
+	c.Watch = cCtx.Bool("watch")
+	c.WatchDebounce = cCtx.Duration("watch-debounce")
+	c.ReconcileInterval = cCtx.Duration("reconcile-interval")
+	c.ScanWorkers = cCtx.Int("scan-workers")
 
 	c.Meta = Meta{
 		Commit:     Commit,
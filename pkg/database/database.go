@@ -9,6 +9,7 @@ import (
 	"os"
 	"path/filepath"
 
+	"github.com/robbymilo/rgallery/pkg/dbpool"
 	"github.com/robbymilo/rgallery/pkg/types"
 
 	_ "modernc.org/sqlite"
@@ -20,23 +21,11 @@ var schema []byte
 type Conf = types.Conf
 
 func CreateDB(c Conf) {
-	// Create db filepath
-	path, err := filepath.Abs(c.Data)
-	if err != nil {
-		c.Logger.Error("error creating db filepath", "error", err)
+	if err := createDataDir(c); err != nil {
+		c.Logger.Error("error creating data directory", "error", err)
 		return
 	}
 
-	// Check if the data directory exists and create if it doesn't
-	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
-		c.Logger.Info("data directory does not exist, creating at: " + path)
-		err := os.MkdirAll(path, os.ModePerm)
-		if err != nil {
-			c.Logger.Error("error creating data directory", "error", err)
-			return
-		}
-	}
-
 	c.Logger.Info("database located at " + NewConnectionString(c))
 
 	db, err := sql.Open("sqlite", NewConnectionString(c))
@@ -50,18 +39,17 @@ func CreateDB(c Conf) {
 		}
 	}()
 
-	c.Logger.Info("applying sqlite schema")
-
-	if err := applySchema(db); err != nil {
+	created, err := createSchema(db, c)
+	if err != nil {
 		c.Logger.Error("error applying schema", "error", err)
 		return
 	}
 
-	c.Logger.Info("sqlite schema applied")
-
-	if err := renamePathColumn(db); err != nil {
-		c.Logger.Error("error renaming path column", "error", err)
-		return
+	if !created {
+		if _, err := migrate(db, c, false); err != nil {
+			c.Logger.Error("error migrating database", "error", err)
+			return
+		}
 	}
 
 	var journal_mode string
@@ -71,68 +59,49 @@ func CreateDB(c Conf) {
 	}
 
 	c.Logger.Info("Current journal_mode: " + journal_mode)
+}
 
-	setSchemaVersion(db, 20241120)
+// Open opens the long-lived read pool and writer shared by all queries. CreateDB must be called first.
+func Open(c Conf) (*dbpool.DB, error) {
+	return dbpool.Open(NewSqlConnectionString(c), 0)
 }
 
-// applySchema applies the schema to the database
-func applySchema(db *sql.DB) error {
-	statement, err := db.Prepare(string(schema))
+// createDataDir creates the data directory if it does not exist
+func createDataDir(c Conf) error {
+	path, err := filepath.Abs(c.Data)
 	if err != nil {
-		return fmt.Errorf("error preparing schema statement: %w", err)
+		return fmt.Errorf("error creating db filepath: %w", err)
 	}
-	defer func() {
-		if err := statement.Close(); err != nil {
-			// No context available here, so just log to standard error
-			fmt.Println("statement.Close error:", err)
-		}
-	}()
 
-	_, err = statement.Exec()
-	if err != nil {
-		return fmt.Errorf("error executing schema statement: %w", err)
+	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+		c.Logger.Info("data directory does not exist, creating at: " + path)
+		err := os.MkdirAll(path, os.ModePerm)
+		if err != nil {
+			return err
+		}
 	}
 
 	return nil
 }
 
-// setSchemaVersion updates or inserts the schema version in the schema table
-func setSchemaVersion(db *sql.DB, version int) {
-	statement, err := db.Prepare(`INSERT OR REPLACE INTO schema(key, value) VALUES (?, ?);`)
+// applySchema applies the schema to a new database
+func applySchema(db *sql.DB) error {
+	statement, err := db.Prepare(string(schema))
 	if err != nil {
-		log.Fatal("error preparing schema version statement: ", err.Error())
+		return fmt.Errorf("error preparing schema statement: %w", err)
 	}
 	defer func() {
 		if err := statement.Close(); err != nil {
+			// No context available here, so just log to standard error
 			fmt.Println("statement.Close error:", err)
 		}
 	}()
 
-	_, err = statement.Exec("version", version)
-	if err != nil {
-		log.Fatal("error executing schema version statement: ", err.Error())
-	}
-}
-
-// renamePathColumn renames the 'name' column to 'path' in the 'media' table
-func renamePathColumn(db *sql.DB) error {
-	var columnExists int
-	query := `SELECT COUNT(*) FROM pragma_table_info('media') WHERE name = 'name';`
-	err := db.QueryRow(query).Scan(&columnExists)
-	if err != nil {
-		return fmt.Errorf("failed to check for column existence: %w", err)
-	}
-
-	if columnExists == 0 {
-		return nil
-	}
-
-	_, err = db.Exec(`ALTER TABLE media RENAME COLUMN name TO path;`)
+	_, err = statement.Exec()
 	if err != nil {
-		return fmt.Errorf("failed to rename column: %w", err)
+		return fmt.Errorf("error executing schema statement: %w", err)
 	}
 
-	fmt.Println("Column 'name' successfully renamed to 'path'.")
 	return nil
 }
 
@@ -155,5 +124,5 @@ func NewSqlConnectionString(c Conf) string {
 }
 
 func Columns() string {
-	return `hash, path, subject, width, height, ratio, padding, date, modified, folder, rating, shutterspeed, aperture, iso, lens, camera, focallength, altitude, latitude, longitude, mediatype, focusdistance, focallength35, color, location, description, title, software, offset, rotation`
+	return `hash, path, subject, width, height, ratio, padding, date, modified, folder, rating, shutterspeed, aperture, iso, lens, camera, focallength, altitude, latitude, longitude, mediatype, focusdistance, focallength35, color, location, description, title, software, offset, rotation, checksum, size, motion_offset, motion_length, phash, library, container, video_codec, audio_codec, duration, frame_rate, bitrate, has_audio`
 }
@@ -0,0 +1,674 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/robbymilo/rgallery/pkg/config"
+	"github.com/robbymilo/rgallery/pkg/hash"
+)
+
+// Migration is a numbered change to the database that is applied once, in order, inside a transaction.
+type Migration struct {
+	Version     int
+	Description string
+	Applied     bool
+	AppliedAt   string
+	// up applies the migration. The returned function, if any, runs after the transaction is committed.
+	up func(tx *sql.Tx, c Conf) (func(), error)
+}
+
+// migrations are applied in order of their version. Versions are dates so that the
+// version stored by databases created before migrations were tracked is preserved.
+// Never change or remove a migration once it is released, add a new one instead.
+var migrations = []Migration{
+	{
+		Version:     20241120,
+		Description: "rename media column 'name' to 'path'",
+		up: func(tx *sql.Tx, c Conf) (func(), error) {
+			return nil, renamePathColumn(tx)
+		},
+	},
+	{
+		Version:     20261016,
+		Description: "add media columns 'checksum' and 'size'",
+		up: func(tx *sql.Tx, c Conf) (func(), error) {
+			if err := addColumn(tx, "media", "checksum", "TEXT DEFAULT ''"); err != nil {
+				return nil, err
+			}
+			return nil, addColumn(tx, "media", "size", "INTEGER DEFAULT 0")
+		},
+	},
+	{
+		Version:     20261017,
+		Description: "identify media by content hash",
+		up: func(tx *sql.Tx, c Conf) (func(), error) {
+			// the search index was rebuilt on every start before it was kept up to date by the update trigger
+			if err := execAll(tx,
+				`DROP TRIGGER IF EXISTS images_update;`,
+				updateTrigger,
+			); err != nil {
+				return nil, err
+			}
+			return migrateContentHashes(tx, c)
+		},
+	},
+	{
+		Version:     20261018,
+		Description: "add jobs table",
+		up: func(tx *sql.Tx, c Conf) (func(), error) {
+			return nil, execAll(tx,
+				`CREATE TABLE IF NOT EXISTS jobs (
+					id INTEGER PRIMARY KEY AUTOINCREMENT,
+					type TEXT NOT NULL,
+					scope TEXT DEFAULT '',
+					status TEXT NOT NULL,
+					total INTEGER DEFAULT 0,
+					processed INTEGER DEFAULT 0,
+					failed INTEGER DEFAULT 0,
+					cursor TEXT DEFAULT '',
+					error TEXT DEFAULT '',
+					created_at TEXT,
+					started_at TEXT DEFAULT '',
+					finished_at TEXT DEFAULT ''
+				);`,
+				`CREATE INDEX IF NOT EXISTS idx_jobs_status ON jobs (status);`,
+			)
+		},
+	},
+	{
+		Version:     20261019,
+		Description: "add alternates table",
+		up: func(tx *sql.Tx, c Conf) (func(), error) {
+			// created without the library column added to it by 20261022
+			return nil, execAll(tx,
+				`CREATE TABLE IF NOT EXISTS alternates (
+					path TEXT NOT NULL PRIMARY KEY,
+					hash INTEGER NOT NULL,
+					folder TEXT NOT NULL,
+					size INTEGER DEFAULT 0,
+					modified TEXT DEFAULT ''
+				);`,
+				`CREATE INDEX IF NOT EXISTS idx_alternates_hash ON alternates (hash);`,
+				`CREATE INDEX IF NOT EXISTS idx_alternates_folder ON alternates (folder);`,
+			)
+		},
+	},
+	{
+		Version:     20261020,
+		Description: "add media columns 'motion_offset' and 'motion_length'",
+		up: func(tx *sql.Tx, c Conf) (func(), error) {
+			if err := addColumn(tx, "media", "motion_offset", "INTEGER DEFAULT 0"); err != nil {
+				return nil, err
+			}
+			return nil, addColumn(tx, "media", "motion_length", "INTEGER DEFAULT 0")
+		},
+	},
+	{
+		Version:     20261021,
+		Description: "add media column 'phash'",
+		up: func(tx *sql.Tx, c Conf) (func(), error) {
+			return nil, addColumn(tx, "media", "phash", "INTEGER DEFAULT 0")
+		},
+	},
+	{
+		Version:     20261022,
+		Description: "add library columns",
+		up: func(tx *sql.Tx, c Conf) (func(), error) {
+			if err := addColumn(tx, "media", "library", "TEXT NOT NULL DEFAULT 'default'"); err != nil {
+				return nil, err
+			}
+			if _, err := tx.Exec(`CREATE INDEX IF NOT EXISTS idx_media_library ON media (library);`); err != nil {
+				return nil, fmt.Errorf("failed to create index: %w", err)
+			}
+			return nil, addLibraryKeys(tx)
+		},
+	},
+	{
+		Version:     20261023,
+		Description: "add media columns of video streams",
+		up: func(tx *sql.Tx, c Conf) (func(), error) {
+			for _, column := range videoColumns {
+				if err := addColumn(tx, "media", column.name, column.definition); err != nil {
+					return nil, err
+				}
+			}
+			return nil, nil
+		},
+	},
+	{
+		Version:     20261024,
+		Description: "add jobs columns 'owner' and 'heartbeat'",
+		up: func(tx *sql.Tx, c Conf) (func(), error) {
+			if err := addColumn(tx, "jobs", "owner", "TEXT DEFAULT ''"); err != nil {
+				return nil, err
+			}
+			return nil, addColumn(tx, "jobs", "heartbeat", "TEXT DEFAULT ''")
+		},
+	},
+	{
+		Version:     20261025,
+		Description: "mark media items without a perceptual hash with a NULL 'phash'",
+		up: func(tx *sql.Tx, c Conf) (func(), error) {
+			// 0 was stored for videos and for images scanned before perceptual hashes, and is also the hash of a
+			// uniform image, which gets its hash back with the next metadata scan
+			if _, err := tx.Exec(`UPDATE media SET phash = NULL WHERE phash = 0;`); err != nil {
+				return nil, fmt.Errorf("failed to clear perceptual hashes: %w", err)
+			}
+			return nil, nil
+		},
+	},
+}
+
+// videoColumns are the media columns of the container and streams of videos read by ffprobe.
+var videoColumns = []struct {
+	name       string
+	definition string
+}{
+	{"container", "TEXT DEFAULT ''"},
+	{"video_codec", "TEXT DEFAULT ''"},
+	{"audio_codec", "TEXT DEFAULT ''"},
+	{"duration", "REAL DEFAULT 0"},
+	{"frame_rate", "REAL DEFAULT 0"},
+	{"bitrate", "INTEGER DEFAULT 0"},
+	{"has_audio", "INTEGER DEFAULT 0"},
+}
+
+// Migrate applies all pending migrations, or only lists them if dryRun is set. A new database is created from the
+// schema instead. It returns the migrations that were, or would be, applied.
+func Migrate(c Conf, dryRun bool) ([]Migration, error) {
+	if dryRun {
+		if _, err := os.Stat(NewSqlConnectionString(c)); errors.Is(err, os.ErrNotExist) {
+			return migrations, nil
+		}
+	} else if err := createDataDir(c); err != nil {
+		return nil, fmt.Errorf("error creating data directory: %v", err)
+	}
+
+	db, err := sql.Open("sqlite", NewConnectionString(c))
+	if err != nil {
+		return nil, fmt.Errorf("error opening sqlite db: %v", err)
+	}
+	defer func() {
+		if err := db.Close(); err != nil {
+			c.Logger.Error("db.Close error", "err", err)
+		}
+	}()
+
+	if !dryRun {
+		created, err := createSchema(db, c)
+		if err != nil || created {
+			return nil, err
+		}
+	}
+
+	return migrate(db, c, dryRun)
+}
+
+// GetMigrations returns every known migration and whether it has been applied.
+func GetMigrations(c Conf) ([]Migration, error) {
+	status := make([]Migration, len(migrations))
+	copy(status, migrations)
+
+	if _, err := os.Stat(NewSqlConnectionString(c)); errors.Is(err, os.ErrNotExist) {
+		return status, nil
+	}
+
+	db, err := sql.Open("sqlite", NewConnectionString(c))
+	if err != nil {
+		return nil, fmt.Errorf("error opening sqlite db: %v", err)
+	}
+	defer func() {
+		if err := db.Close(); err != nil {
+			c.Logger.Error("db.Close error", "err", err)
+		}
+	}()
+
+	version, err := getSchemaVersion(db)
+	if err != nil {
+		return nil, err
+	}
+
+	appliedAt := make(map[string]string)
+	if version > 0 {
+		rows, err := db.Query(`SELECT key, created FROM schema WHERE key LIKE 'migration_%';`)
+		if err != nil {
+			return nil, fmt.Errorf("failed to query migrations: %w", err)
+		}
+		defer func() {
+			if err := rows.Close(); err != nil {
+				c.Logger.Error("rows.Close error", "err", err)
+			}
+		}()
+
+		for rows.Next() {
+			var key, created string
+			if err := rows.Scan(&key, &created); err != nil {
+				return nil, fmt.Errorf("failed to scan migration row: %w", err)
+			}
+			appliedAt[key] = created
+		}
+		if err := rows.Err(); err != nil {
+			return nil, fmt.Errorf("failed to read migrations: %w", err)
+		}
+	}
+
+	for i := range status {
+		status[i].Applied = status[i].Version <= version
+		status[i].AppliedAt = appliedAt[migrationKey(status[i].Version)]
+	}
+
+	return status, nil
+}
+
+// migrate applies each pending migration in its own transaction, stopping at the first failure.
+func migrate(db *sql.DB, c Conf, dryRun bool) ([]Migration, error) {
+	version, err := getSchemaVersion(db)
+	if err != nil {
+		return nil, err
+	}
+
+	var pending []Migration
+	for _, m := range migrations {
+		if m.Version > version {
+			pending = append(pending, m)
+		}
+	}
+
+	if dryRun || len(pending) == 0 {
+		return pending, nil
+	}
+
+	for i, m := range pending {
+		c.Logger.Info("applying migration", "version", m.Version, "description", m.Description)
+
+		tx, err := db.Begin()
+		if err != nil {
+			return pending[:i], fmt.Errorf("failed to begin migration %d: %w", m.Version, err)
+		}
+
+		after, err := m.up(tx, c)
+		if err != nil {
+			_ = tx.Rollback()
+			return pending[:i], fmt.Errorf("failed to apply migration %d: %w", m.Version, err)
+		}
+
+		if err := setSchemaVersion(tx, m.Version); err != nil {
+			_ = tx.Rollback()
+			return pending[:i], err
+		}
+
+		if err := tx.Commit(); err != nil {
+			return pending[:i], fmt.Errorf("failed to commit migration %d: %w", m.Version, err)
+		}
+
+		if after != nil {
+			after()
+		}
+
+		c.Logger.Info("applied migration", "version", m.Version)
+	}
+
+	return pending, nil
+}
+
+// createSchema creates the tables of a new database from the schema, and records every migration as applied as the
+// schema already includes their changes. It returns false for an existing database, which is only changed by
+// migrations.
+func createSchema(db *sql.DB, c Conf) (bool, error) {
+	var exists int
+	err := db.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'media';`).Scan(&exists)
+	if err != nil {
+		return false, fmt.Errorf("failed to check for media table: %w", err)
+	}
+
+	if exists > 0 {
+		return false, nil
+	}
+
+	c.Logger.Info("applying sqlite schema")
+
+	if err := applySchema(db); err != nil {
+		return false, err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return false, fmt.Errorf("failed to begin recording migrations: %w", err)
+	}
+
+	for _, m := range migrations {
+		if err := setSchemaVersion(tx, m.Version); err != nil {
+			_ = tx.Rollback()
+			return false, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit migrations: %w", err)
+	}
+
+	c.Logger.Info("sqlite schema applied")
+
+	return true, nil
+}
+
+// execAll executes each statement in order, stopping at the first failure.
+func execAll(tx *sql.Tx, statements ...string) error {
+	for _, stmt := range statements {
+		if _, err := tx.Exec(stmt); err != nil {
+			return fmt.Errorf("failed to execute statement: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// updateTrigger keeps the search index up to date with updated media items.
+const updateTrigger = `CREATE TRIGGER IF NOT EXISTS images_update AFTER UPDATE ON media BEGIN
+	DELETE FROM images_virtual WHERE rowid = OLD.ROWID;
+	INSERT INTO images_virtual (rowid, hash, path, subject, width, height, ratio, padding, date, modified, folder, rating, shutterspeed, aperture, iso, lens, camera, focallength, altitude, latitude, longitude, mediatype, focusdistance, focallength35, color, location, description, title, software, offset, rotation, created)
+	VALUES (new.ROWID, new.hash, new.path, new.subject, new.width, new.height, new.ratio, new.padding, new.date, new.modified, new.folder, new.rating, new.shutterspeed, new.aperture, new.iso, new.lens, new.camera, new.focallength, new.altitude, new.latitude, new.longitude, new.mediatype, new.focusdistance, new.focallength35, new.color, new.location, new.description, new.title, new.software, new.offset, new.rotation, new.created);
+END;`
+
+// LatestVersion returns the schema version of a database with every migration applied.
+func LatestVersion() int {
+	return migrations[len(migrations)-1].Version
+}
+
+func migrationKey(version int) string {
+	return "migration_" + strconv.Itoa(version)
+}
+
+// getSchemaVersion returns the schema version stored in the schema table, or 0 for a new database.
+func getSchemaVersion(db *sql.DB) (int, error) {
+	var exists int
+	err := db.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'schema';`).Scan(&exists)
+	if err != nil {
+		return 0, fmt.Errorf("failed to check for schema table: %w", err)
+	}
+
+	if exists == 0 {
+		return 0, nil
+	}
+
+	var version int
+	err = db.QueryRow(`SELECT value FROM schema WHERE key = 'version';`).Scan(&version)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to get schema version: %w", err)
+	}
+
+	return version, nil
+}
+
+// setSchemaVersion records a migration and updates the schema version in the schema table
+func setSchemaVersion(tx *sql.Tx, version int) error {
+	_, err := tx.Exec(`INSERT OR REPLACE INTO schema(key, value) VALUES (?, ?);`, "version", version)
+	if err != nil {
+		return fmt.Errorf("failed to set schema version: %w", err)
+	}
+
+	_, err = tx.Exec(`INSERT OR REPLACE INTO schema(key, value) VALUES (?, ?);`, migrationKey(version), version)
+	if err != nil {
+		return fmt.Errorf("failed to record migration: %w", err)
+	}
+
+	return nil
+}
+
+// renamePathColumn renames the 'name' column to 'path' in the 'media' table
+func renamePathColumn(tx *sql.Tx) error {
+	var columnExists int
+	query := `SELECT COUNT(*) FROM pragma_table_info('media') WHERE name = 'name';`
+	err := tx.QueryRow(query).Scan(&columnExists)
+	if err != nil {
+		return fmt.Errorf("failed to check for column existence: %w", err)
+	}
+
+	if columnExists == 0 {
+		return nil
+	}
+
+	_, err = tx.Exec(`ALTER TABLE media RENAME COLUMN name TO path;`)
+	if err != nil {
+		return fmt.Errorf("failed to rename column: %w", err)
+	}
+
+	return nil
+}
+
+// addColumn adds a column to a table of databases created before the column existed.
+func addColumn(tx *sql.Tx, table, name, definition string) error {
+	var columnExists int
+	query := `SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = ?;`
+	err := tx.QueryRow(query, table, name).Scan(&columnExists)
+	if err != nil {
+		return fmt.Errorf("failed to check for column existence: %w", err)
+	}
+
+	if columnExists > 0 {
+		return nil
+	}
+
+	_, err = tx.Exec(fmt.Sprintf(`ALTER TABLE %s ADD COLUMN %s %s;`, table, name, definition))
+	if err != nil {
+		return fmt.Errorf("failed to add column: %w", err)
+	}
+
+	return nil
+}
+
+// libraryTables are the tables keyed by path, with the statements creating them keyed by library and path.
+var libraryTables = []struct {
+	name    string
+	columns string
+	create  []string
+}{
+	{
+		name:    "alternates",
+		columns: "path, hash, folder, size, modified",
+		create: []string{
+			`CREATE TABLE alternates (
+				library TEXT NOT NULL DEFAULT 'default',
+				path TEXT NOT NULL,
+				hash INTEGER NOT NULL,
+				folder TEXT NOT NULL,
+				size INTEGER DEFAULT 0,
+				modified TEXT DEFAULT '',
+				PRIMARY KEY (library, path)
+			);`,
+			`CREATE INDEX IF NOT EXISTS idx_alternates_hash ON alternates (hash);`,
+			`CREATE INDEX IF NOT EXISTS idx_alternates_folder ON alternates (folder);`,
+		},
+	},
+	{
+		name:    "scan_errors",
+		columns: "path, modified, error, created",
+		create: []string{
+			`CREATE TABLE scan_errors (
+				"library" TEXT NOT NULL DEFAULT 'default',
+				"path" TEXT,
+				"modified" TEXT,
+				"error" TEXT,
+				"created" TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+				UNIQUE (library, path)
+			);`,
+		},
+	},
+}
+
+// addLibraryKeys rebuilds the tables keyed by path of databases created before there were libraries, so the same path
+// can be used in more than one library. Their rows belong to the default library.
+func addLibraryKeys(tx *sql.Tx) error {
+	for _, table := range libraryTables {
+		var columnExists int
+		query := `SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = 'library';`
+		if err := tx.QueryRow(query, table.name).Scan(&columnExists); err != nil {
+			return fmt.Errorf("failed to check for column existence: %w", err)
+		}
+
+		if columnExists > 0 {
+			continue
+		}
+
+		// the indexes of the old table are dropped with it, before they are created for the new table
+		if _, err := tx.Exec(fmt.Sprintf(`ALTER TABLE %[1]s RENAME TO %[1]s_old;`, table.name)); err != nil {
+			return fmt.Errorf("failed to rename table %s: %w", table.name, err)
+		}
+		if _, err := tx.Exec(table.create[0]); err != nil {
+			return fmt.Errorf("failed to create table %s: %w", table.name, err)
+		}
+		if _, err := tx.Exec(fmt.Sprintf(`INSERT INTO %[1]s (%[2]s) SELECT %[2]s FROM %[1]s_old;`, table.name, table.columns)); err != nil {
+			return fmt.Errorf("failed to copy table %s: %w", table.name, err)
+		}
+		if _, err := tx.Exec(fmt.Sprintf(`DROP TABLE %s_old;`, table.name)); err != nil {
+			return fmt.Errorf("failed to drop table %s: %w", table.name, err)
+		}
+		for _, stmt := range table.create[1:] {
+			if _, err := tx.Exec(stmt); err != nil {
+				return fmt.Errorf("failed to create index on %s: %w", table.name, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// migrateContentHashes replaces the path based hash of every media item with a hash of its content.
+// Cached thumbnails and transcodes are moved to match once the transaction is committed. Items whose file can not be
+// read keep their hash, and get their checksum from the next scan.
+func migrateContentHashes(tx *sql.Tx, c Conf) (func(), error) {
+	type legacyItem struct {
+		hash uint64
+		path string
+	}
+
+	rows, err := tx.Query(`SELECT hash, path FROM media ORDER BY path;`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query media: %w", err)
+	}
+
+	var items []legacyItem
+	for rows.Next() {
+		var item legacyItem
+		if err := rows.Scan(&item.hash, &item.path); err != nil {
+			_ = rows.Close()
+			return nil, fmt.Errorf("failed to scan media row: %w", err)
+		}
+		items = append(items, item)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, fmt.Errorf("failed to close media rows: %w", err)
+	}
+
+	if len(items) == 0 {
+		return nil, nil
+	}
+
+	c.Logger.Info("migrating " + strconv.Itoa(len(items)) + " media items to content hashes")
+
+	// images_tags references the old hash until it is updated below
+	if _, err := tx.Exec(`PRAGMA defer_foreign_keys = ON;`); err != nil {
+		return nil, fmt.Errorf("failed to defer foreign keys: %w", err)
+	}
+
+	assigned := make(map[uint64]bool)
+	moved := make(map[uint64]uint64)
+	for _, item := range items {
+		absolute_path := filepath.Join(config.MediaPath(c), item.path)
+		info, err := os.Stat(absolute_path)
+		if err != nil {
+			// missing files keep their old hash and are removed by the next scan, unreadable ones are rescanned
+			c.Logger.Warn("skipping content hash for media item", "path", item.path, "error", err)
+			continue
+		}
+
+		checksum, err := hash.GetChecksum(absolute_path)
+		if err != nil {
+			c.Logger.Warn("skipping content hash for media item", "path", item.path, "error", err)
+			continue
+		}
+
+		h := hash.GetContentHash(checksum)
+		if assigned[h] {
+			h = hash.GetUniqueHash(checksum, item.path)
+		}
+		assigned[h] = true
+
+		if _, err := tx.Exec(`UPDATE media SET hash = ?, checksum = ?, size = ? WHERE hash = ?;`, h, checksum, info.Size(), item.hash); err != nil {
+			return nil, fmt.Errorf("failed to update media hash: %w", err)
+		}
+
+		if _, err := tx.Exec(`UPDATE images_tags SET image_id = ? WHERE image_id = ?;`, h, item.hash); err != nil {
+			return nil, fmt.Errorf("failed to update tag relationships: %w", err)
+		}
+
+		moved[item.hash] = h
+	}
+
+	return func() {
+		moveCacheFiles(moved, c)
+		c.Logger.Info("migrated " + strconv.Itoa(len(moved)) + " media items to content hashes")
+	}, nil
+}
+
+// moveCacheFiles renames cached thumbnails and video transcodes from their old hash to their new hash.
+// Files that fail to move are regenerated on request.
+func moveCacheFiles(moved map[uint64]uint64, c Conf) {
+	cachePath := config.CachePath(c)
+
+	dirs, err := os.ReadDir(cachePath)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			c.Logger.Error("error reading cache dir", "error", err)
+		}
+		return
+	}
+
+	for _, dir := range dirs {
+		if !dir.IsDir() {
+			continue
+		}
+
+		entries, err := os.ReadDir(filepath.Join(cachePath, dir.Name()))
+		if err != nil {
+			c.Logger.Error("error reading cache dir", "error", err)
+			continue
+		}
+
+		// transcodes are directories named by hash, thumbnails are named by hash with a .jpg extension
+		ext := ".jpg"
+		if dir.Name() == "video" {
+			ext = ""
+		}
+
+		for _, entry := range entries {
+			name, ok := strings.CutSuffix(entry.Name(), ext)
+			if !ok {
+				continue
+			}
+			from, err := strconv.ParseUint(name, 10, 64)
+			if err != nil {
+				continue
+			}
+			to, ok := moved[from]
+			if !ok || to == from {
+				continue
+			}
+
+			oldPath := filepath.Join(cachePath, dir.Name(), entry.Name())
+			newPath := filepath.Join(cachePath, dir.Name(), strconv.FormatUint(to, 10)+ext)
+			if err := os.Rename(oldPath, newPath); err != nil {
+				c.Logger.Error("error moving cache file", "from", oldPath, "to", newPath, "error", err)
+			}
+		}
+	}
+}
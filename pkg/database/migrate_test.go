@@ -0,0 +1,254 @@
+package database
+
+import (
+	"database/sql"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/robbymilo/rgallery/pkg/hash"
+	"github.com/stretchr/testify/assert"
+)
+
+func testConf(t *testing.T) Conf {
+	t.Helper()
+
+	dir := t.TempDir()
+	return Conf{
+		Data:   filepath.Join(dir, "data"),
+		Media:  filepath.Join(dir, "media"),
+		Cache:  filepath.Join(dir, "cache"),
+		Logger: slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+}
+
+// createLegacyDB creates a database the way releases before migrations were tracked did, with one media item whose
+// hash is derived from its path.
+func createLegacyDB(t *testing.T, c Conf) {
+	t.Helper()
+
+	legacy, err := os.ReadFile("testdata/schema-20241120.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.MkdirAll(c.Data, os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(c.Media, os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(c.Media, "a.jpg"), []byte("a"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	db := openDB(t, c)
+	for _, stmt := range []string{
+		string(legacy),
+		`INSERT OR REPLACE INTO schema(key, value) VALUES ('version', 20241120);`,
+		`INSERT INTO media (hash, path, folder, subject) VALUES (1, 'a.jpg', '.', '[]');`,
+		`INSERT INTO scan_errors (path, modified, error) VALUES ('b.jpg', '', 'error');`,
+	} {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func openDB(t *testing.T, c Conf) *sql.DB {
+	t.Helper()
+
+	db, err := sql.Open("sqlite", NewConnectionString(c))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if err := db.Close(); err != nil {
+			t.Error(err)
+		}
+	})
+
+	return db
+}
+
+func versions(migrations []Migration) []int {
+	var v []int
+	for _, m := range migrations {
+		v = append(v, m.Version)
+	}
+
+	return v
+}
+
+func TestMigrateNewDatabase(t *testing.T) {
+	c := testConf(t)
+
+	// a new database is created from the schema, with every migration recorded as applied
+	applied, err := Migrate(c, false)
+	assert.NoError(t, err)
+	assert.Empty(t, applied)
+
+	status, err := GetMigrations(c)
+	assert.NoError(t, err)
+	assert.Len(t, status, len(migrations))
+	for _, m := range status {
+		assert.True(t, m.Applied, m.Version)
+		assert.NotEmpty(t, m.AppliedAt, m.Version)
+	}
+
+	version, err := getSchemaVersion(openDB(t, c))
+	assert.NoError(t, err)
+	assert.Equal(t, LatestVersion(), version)
+
+	// starting again does not rebuild the schema or reapply migrations
+	CreateDB(c)
+	applied, err = Migrate(c, false)
+	assert.NoError(t, err)
+	assert.Empty(t, applied)
+}
+
+func TestMigrateLegacyDatabase(t *testing.T) {
+	c := testConf(t)
+	createLegacyDB(t, c)
+
+	// a dry run lists the pending migrations without applying them
+	pending, err := Migrate(c, true)
+	assert.NoError(t, err)
+	if assert.NotEmpty(t, pending) {
+		assert.Equal(t, 20261016, pending[0].Version)
+	}
+
+	status, err := GetMigrations(c)
+	assert.NoError(t, err)
+	assert.True(t, status[0].Applied)
+	for _, m := range status[1:] {
+		assert.False(t, m.Applied, m.Version)
+		assert.Empty(t, m.AppliedAt, m.Version)
+	}
+
+	applied, err := Migrate(c, false)
+	assert.NoError(t, err)
+	assert.Equal(t, versions(pending), versions(applied))
+
+	status, err = GetMigrations(c)
+	assert.NoError(t, err)
+	for _, m := range status[1:] {
+		assert.True(t, m.Applied, m.Version)
+		assert.NotEmpty(t, m.AppliedAt, m.Version)
+	}
+
+	db := openDB(t, c)
+
+	// the media item is identified by its content, and is still found by search after the update
+	var h uint64
+	var checksum, library string
+	err = db.QueryRow(`SELECT hash, checksum, library FROM media WHERE path = 'a.jpg';`).Scan(&h, &checksum, &library)
+	assert.NoError(t, err)
+	assert.Equal(t, hash.GetContentHash(checksum), h)
+	assert.Equal(t, "default", library)
+
+	var found uint64
+	err = db.QueryRow(`SELECT hash FROM images_virtual WHERE path = 'a.jpg';`).Scan(&found)
+	assert.NoError(t, err)
+	assert.Equal(t, h, found)
+
+	// tables added by migrations exist, and scan errors belong to the default library
+	for _, table := range []string{"jobs", "alternates"} {
+		var exists int
+		err = db.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?;`, table).Scan(&exists)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, exists, table)
+	}
+
+	err = db.QueryRow(`SELECT library FROM scan_errors WHERE path = 'b.jpg';`).Scan(&library)
+	assert.NoError(t, err)
+	assert.Equal(t, "default", library)
+
+	// the media item was scanned before perceptual hashes, so it has none
+	var phash sql.NullInt64
+	err = db.QueryRow(`SELECT phash FROM media WHERE path = 'a.jpg';`).Scan(&phash)
+	assert.NoError(t, err)
+	assert.False(t, phash.Valid)
+
+	// nothing is pending afterwards
+	pending, err = Migrate(c, true)
+	assert.NoError(t, err)
+	assert.Empty(t, pending)
+
+	// the migrated tables match those of a new database
+	n := testConf(t)
+	CreateDB(n)
+	created := openDB(t, n)
+	for _, table := range []string{"media", "scan_errors", "jobs", "alternates"} {
+		assert.ElementsMatch(t, columns(t, created, table), columns(t, db, table), table)
+	}
+}
+
+// columns returns the names of the columns of a table.
+func columns(t *testing.T, db *sql.DB, table string) []string {
+	t.Helper()
+
+	rows, err := db.Query(`SELECT name FROM pragma_table_info(?);`, table)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+
+	var names []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, name)
+	}
+
+	return names
+}
+
+func TestMigrateDryRunWithoutDatabase(t *testing.T) {
+	c := testConf(t)
+
+	pending, err := Migrate(c, true)
+	assert.NoError(t, err)
+	assert.Equal(t, versions(migrations), versions(pending))
+
+	// the database is not created
+	_, err = os.Stat(NewSqlConnectionString(c))
+	assert.ErrorIs(t, err, os.ErrNotExist)
+
+	status, err := GetMigrations(c)
+	assert.NoError(t, err)
+	for _, m := range status {
+		assert.False(t, m.Applied, m.Version)
+	}
+}
+
+func TestMoveCacheFiles(t *testing.T) {
+	c := testConf(t)
+
+	files := []string{"400/1.jpg", "400/3.jpg", "400/notes.txt", "1280/1.jpg", "video/1/index.m3u8", "video/3/index.m3u8"}
+	for _, file := range files {
+		path := filepath.Join(c.Cache, file)
+		if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(file), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// 3 keeps its hash, and 4 has no cache files
+	moveCacheFiles(map[uint64]uint64{1: 2, 3: 3, 4: 5}, c)
+
+	for _, file := range []string{"400/2.jpg", "400/3.jpg", "400/notes.txt", "1280/2.jpg", "video/2/index.m3u8", "video/3/index.m3u8"} {
+		_, err := os.Stat(filepath.Join(c.Cache, file))
+		assert.NoError(t, err, file)
+	}
+	for _, file := range []string{"400/1.jpg", "1280/1.jpg", "video/1", "400/5.jpg"} {
+		_, err := os.Stat(filepath.Join(c.Cache, file))
+		assert.ErrorIs(t, err, os.ErrNotExist, file)
+	}
+}
@@ -1,3 +1,5 @@
+-- The schema of a new database. Existing databases are only changed by the migrations in migrate.go, so a change
+-- here needs a migration making the same change.
 PRAGMA journal_mode = WAL;
 
 PRAGMA temp_store = 2;
@@ -36,6 +38,19 @@ CREATE TABLE
       REAL DEFAULT 0,
       rotation REAL DEFAULT 0,
       "created" TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+      checksum TEXT DEFAULT '',
+      size INTEGER DEFAULT 0,
+      motion_offset INTEGER DEFAULT 0,
+      motion_length INTEGER DEFAULT 0,
+      phash INTEGER,
+      library TEXT NOT NULL DEFAULT 'default',
+      container TEXT DEFAULT '',
+      video_codec TEXT DEFAULT '',
+      audio_codec TEXT DEFAULT '',
+      duration REAL DEFAULT 0,
+      frame_rate REAL DEFAULT 0,
+      bitrate INTEGER DEFAULT 0,
+      has_audio INTEGER DEFAULT 0,
       UNIQUE (hash)
   );
 
@@ -124,9 +139,73 @@ CREATE VIRTUAL TABLE IF NOT EXISTS images_virtual USING FTS5 (
 );
 
 INSERT INTO
-  images_virtual
+  images_virtual (
+    rowid,
+    hash,
+    path,
+    subject,
+    width,
+    height,
+    ratio,
+    padding,
+    date,
+    modified,
+    folder,
+    rating,
+    shutterspeed,
+    aperture,
+    iso,
+    lens,
+    camera,
+    focallength,
+    altitude,
+    latitude,
+    longitude,
+    mediatype,
+    focusdistance,
+    focallength35,
+    color,
+    location,
+    description,
+    title,
+    software,
+    offset,
+    rotation,
+    created
+  )
 SELECT
-  *
+  rowid,
+  hash,
+  path,
+  subject,
+  width,
+  height,
+  ratio,
+  padding,
+  date,
+  modified,
+  folder,
+  rating,
+  shutterspeed,
+  aperture,
+  iso,
+  lens,
+  camera,
+  focallength,
+  altitude,
+  latitude,
+  longitude,
+  mediatype,
+  focusdistance,
+  focallength35,
+  color,
+  location,
+  description,
+  title,
+  software,
+  offset,
+  rotation,
+  created
 FROM
   media;
 
@@ -216,6 +295,87 @@ WHERE
 
 END;
 
+DROP TRIGGER IF EXISTS images_update;
+
+CREATE TRIGGER IF NOT EXISTS images_update AFTER
+UPDATE ON media BEGIN
+DELETE FROM images_virtual
+WHERE
+  rowid = OLD.ROWID;
+
+INSERT INTO
+  images_virtual (
+    rowid,
+    hash,
+    path,
+    subject,
+    width,
+    height,
+    ratio,
+    padding,
+    date,
+    modified,
+    folder,
+    rating,
+    shutterspeed,
+    aperture,
+    iso,
+    lens,
+    camera,
+    focallength,
+    altitude,
+    latitude,
+    longitude,
+    mediatype,
+    focusdistance,
+    focallength35,
+    color,
+    location,
+    description,
+    title,
+    software,
+    offset,
+    rotation,
+    created
+  )
+VALUES
+  (
+    new.ROWID,
+    new.hash,
+    new.path,
+    new.subject,
+    new.width,
+    new.height,
+    new.ratio,
+    new.padding,
+    new.date,
+    new.modified,
+    new.folder,
+    new.rating,
+    new.shutterspeed,
+    new.aperture,
+    new.iso,
+    new.lens,
+    new.camera,
+    new.focallength,
+    new.altitude,
+    new.latitude,
+    new.longitude,
+    new.mediatype,
+    new.focusdistance,
+    new.focallength35,
+    new.color,
+    new.location,
+    new.description,
+    new.title,
+    new.software,
+    new.offset,
+    new.rotation,
+    new.created
+  );
+
+END;
+
 CREATE TABLE
   IF NOT EXISTS keys (
     "name" TEXT NOT NULL PRIMARY KEY,
@@ -226,11 +386,12 @@ CREATE TABLE
 
 CREATE TABLE
   IF NOT EXISTS scan_errors (
+    "library" TEXT NOT NULL DEFAULT 'default',
     "path" TEXT,
     "modified" TEXT,
     "error" TEXT,
     "created" TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
-    UNIQUE (path)
+    UNIQUE (library, path)
   );
 
 CREATE INDEX IF NOT EXISTS idx_media_folder_date ON media (folder, date DESC);
@@ -257,3 +418,38 @@ CREATE TABLE
     FOREIGN KEY (username) REFERENCES users (username),
     UNIQUE (notification_id, username)
   );
+
+CREATE TABLE
+  IF NOT EXISTS jobs (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    type TEXT NOT NULL,
+    scope TEXT DEFAULT '',
+    status TEXT NOT NULL,
+    total INTEGER DEFAULT 0,
+    processed INTEGER DEFAULT 0,
+    failed INTEGER DEFAULT 0,
+    cursor TEXT DEFAULT '',
+    error TEXT DEFAULT '',
+    created_at TEXT,
+    started_at TEXT DEFAULT '',
+    finished_at TEXT DEFAULT '',
+    owner TEXT DEFAULT '',
+    heartbeat TEXT DEFAULT ''
+  );
+
+CREATE INDEX IF NOT EXISTS idx_jobs_status ON jobs (status);
+
+CREATE TABLE
+  IF NOT EXISTS alternates (
+    library TEXT NOT NULL DEFAULT 'default',
+    path TEXT NOT NULL,
+    hash INTEGER NOT NULL,
+    folder TEXT NOT NULL,
+    size INTEGER DEFAULT 0,
+    modified TEXT DEFAULT '',
+    PRIMARY KEY (library, path)
+  );
+
+CREATE INDEX IF NOT EXISTS idx_alternates_hash ON alternates (hash);
+
+CREATE INDEX IF NOT EXISTS idx_alternates_folder ON alternates (folder);
@@ -0,0 +1,327 @@
+package dbpool
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"zombiezen.com/go/sqlite"
+	"zombiezen.com/go/sqlite/sqlitex"
+)
+
+// stmtCacheSize is the maximum number of prepared statements kept per connection.
+const stmtCacheSize = 64
+
+// busyTimeout is how long the writer waits for locks held by other processes.
+const busyTimeout = 5 * time.Second
+
+// DB is a long-lived handle to the SQLite database.
+// Reads share a pool of read-only connections, and writes are serialized through a single connection.
+// It is safe for use by multiple goroutines concurrently.
+type DB struct {
+	read    *sqlitex.Pool
+	readers int
+	write   *sqlite.Conn
+	// writeLock holds a token while the writer is in use so that waiting for it can be canceled.
+	writeLock chan struct{}
+
+	mu     sync.Mutex
+	caches map[*sqlite.Conn]*stmtCache
+	owners map[*sqlite.Stmt]*list.Element
+	// transient holds the uncached statements prepared while the cached one was in use, finalized by Release.
+	transient map[*sqlite.Stmt]bool
+
+	readsInUse atomic.Int64
+	writeInUse atomic.Int64
+	readTakes  atomic.Uint64
+	writeTakes atomic.Uint64
+	readWait   atomic.Int64
+	writeWait  atomic.Int64
+	hits       atomic.Uint64
+	misses     atomic.Uint64
+
+	readConnections     *prometheus.Desc
+	connectionsInUse    *prometheus.Desc
+	takesTotal          *prometheus.Desc
+	waitSeconds         *prometheus.Desc
+	statementCacheHits  *prometheus.Desc
+	statementCacheMiss  *prometheus.Desc
+	statementCacheCount *prometheus.Desc
+}
+
+// stmtCache tracks the prepared statements of a connection in least recently used order.
+type stmtCache struct {
+	order *list.List
+	items map[string]*list.Element
+}
+
+type cachedStmt struct {
+	query string
+	stmt  *sqlite.Stmt
+	inUse int
+}
+
+// Open opens the writer connection and a pool of read-only connections to the database at path.
+// A readers value less than 1 sizes the pool by the number of CPUs.
+func Open(path string, readers int) (*DB, error) {
+	if readers < 1 {
+		readers = max(4, runtime.NumCPU())
+	}
+
+	write, err := sqlite.OpenConn(path, sqlite.OpenReadWrite, sqlite.OpenWAL)
+	if err != nil {
+		return nil, fmt.Errorf("error opening sqlite writer: %v", err)
+	}
+	write.SetBusyTimeout(busyTimeout)
+
+	read, err := sqlitex.NewPool(path, sqlitex.PoolOptions{
+		Flags:    sqlite.OpenReadOnly,
+		PoolSize: readers,
+	})
+	if err != nil {
+		_ = write.Close()
+		return nil, fmt.Errorf("error opening sqlite db pool: %v", err)
+	}
+
+	db := &DB{
+		read:      read,
+		readers:   readers,
+		write:     write,
+		writeLock: make(chan struct{}, 1),
+		caches:    make(map[*sqlite.Conn]*stmtCache),
+		owners:    make(map[*sqlite.Stmt]*list.Element),
+		transient: make(map[*sqlite.Stmt]bool),
+
+		readConnections: prometheus.NewDesc("rgallery_db_read_connections",
+			"Number of connections in the database read pool.",
+			nil, nil,
+		),
+		connectionsInUse: prometheus.NewDesc("rgallery_db_connections_in_use",
+			"Number of database connections currently taken.",
+			[]string{"pool"}, nil,
+		),
+		takesTotal: prometheus.NewDesc("rgallery_db_connection_takes_total",
+			"Total number of database connections taken.",
+			[]string{"pool"}, nil,
+		),
+		waitSeconds: prometheus.NewDesc("rgallery_db_connection_wait_seconds_total",
+			"Total time spent waiting for a database connection.",
+			[]string{"pool"}, nil,
+		),
+		statementCacheHits: prometheus.NewDesc("rgallery_db_statement_cache_hits_total",
+			"Total number of prepared statements reused from the cache.",
+			nil, nil,
+		),
+		statementCacheMiss: prometheus.NewDesc("rgallery_db_statement_cache_misses_total",
+			"Total number of statements prepared because they were not cached.",
+			nil, nil,
+		),
+		statementCacheCount: prometheus.NewDesc("rgallery_db_statement_cache_statements",
+			"Number of prepared statements currently cached across all connections.",
+			nil, nil,
+		),
+	}
+
+	return db, nil
+}
+
+// Take returns a read-only connection from the pool. It must be returned with Put.
+func (db *DB) Take(ctx context.Context) (*sqlite.Conn, error) {
+	start := time.Now()
+	conn, err := db.read.Take(ctx)
+	db.readWait.Add(int64(time.Since(start)))
+	if err != nil {
+		return nil, err
+	}
+
+	db.readTakes.Add(1)
+	db.readsInUse.Add(1)
+	return conn, nil
+}
+
+// TakeWriter returns the writer connection, waiting until no other caller holds it. It must be returned with Put.
+func (db *DB) TakeWriter(ctx context.Context) (*sqlite.Conn, error) {
+	start := time.Now()
+	select {
+	case db.writeLock <- struct{}{}:
+	case <-ctx.Done():
+		db.writeWait.Add(int64(time.Since(start)))
+		return nil, fmt.Errorf("get sqlite writer: %w", ctx.Err())
+	}
+	db.writeWait.Add(int64(time.Since(start)))
+
+	db.writeTakes.Add(1)
+	db.writeInUse.Add(1)
+	db.write.SetInterrupt(ctx.Done())
+	return db.write, nil
+}
+
+// Put returns a connection taken with Take or TakeWriter.
+func (db *DB) Put(conn *sqlite.Conn) {
+	if conn == nil {
+		return
+	}
+
+	if conn == db.write {
+		if query := conn.CheckReset(); query != "" {
+			panic(fmt.Sprintf("writer returned with active statement: %q", query))
+		}
+		conn.SetInterrupt(nil)
+		db.writeInUse.Add(-1)
+		<-db.writeLock
+		return
+	}
+
+	db.readsInUse.Add(-1)
+	db.read.Put(conn)
+}
+
+// Prepare returns a prepared statement for query, reusing a cached statement where possible.
+// A query whose cached statement is still being stepped, such as by a caller running a nested query on the same
+// connection, gets a new statement instead. The statement must be returned with Release instead of being finalized.
+func (db *DB) Prepare(conn *sqlite.Conn, query string) (*sqlite.Stmt, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	cache, ok := db.caches[conn]
+	if !ok {
+		cache = &stmtCache{
+			order: list.New(),
+			items: make(map[string]*list.Element),
+		}
+		db.caches[conn] = cache
+	}
+
+	if el, ok := cache.items[query]; ok {
+		cache.order.MoveToFront(el)
+		item := el.Value.(*cachedStmt)
+		if item.inUse > 0 {
+			return db.prepareTransient(conn, query)
+		}
+
+		db.hits.Add(1)
+		item.inUse++
+		return item.stmt, nil
+	}
+
+	db.misses.Add(1)
+	stmt, err := conn.Prepare(query)
+	if err != nil {
+		return nil, err
+	}
+
+	el := cache.order.PushFront(&cachedStmt{query: query, stmt: stmt, inUse: 1})
+	cache.items[query] = el
+	db.owners[stmt] = el
+
+	// finalize the least recently used statements that are not being stepped
+	for e := cache.order.Back(); e != nil && cache.order.Len() > stmtCacheSize; {
+		prev := e.Prev()
+		item := e.Value.(*cachedStmt)
+		if item.inUse == 0 {
+			cache.order.Remove(e)
+			delete(cache.items, item.query)
+			delete(db.owners, item.stmt)
+			_ = item.stmt.Finalize()
+		}
+		e = prev
+	}
+
+	return stmt, nil
+}
+
+// prepareTransient prepares a statement for query that is not cached, as the cached one is in use. db.mu must be held.
+func (db *DB) prepareTransient(conn *sqlite.Conn, query string) (*sqlite.Stmt, error) {
+	db.misses.Add(1)
+	stmt, trailingBytes, err := conn.PrepareTransient(query)
+	if err != nil {
+		return nil, err
+	}
+	if trailingBytes != 0 {
+		_ = stmt.Finalize()
+		return nil, fmt.Errorf("sqlite: prepare %q: statement has trailing bytes", query)
+	}
+
+	db.transient[stmt] = true
+	return stmt, nil
+}
+
+// Release resets a statement returned by Prepare so it can be reused.
+func (db *DB) Release(stmt *sqlite.Stmt) {
+	if stmt == nil {
+		return
+	}
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if db.transient[stmt] {
+		delete(db.transient, stmt)
+		_ = stmt.Finalize()
+		return
+	}
+
+	_ = stmt.Reset()
+	_ = stmt.ClearBindings()
+
+	if el, ok := db.owners[stmt]; ok {
+		el.Value.(*cachedStmt).inUse--
+	}
+}
+
+// Close closes every connection, waiting for taken connections to be returned.
+func (db *DB) Close() error {
+	err := db.read.Close()
+
+	db.writeLock <- struct{}{}
+	if werr := db.write.Close(); err == nil {
+		err = werr
+	}
+
+	db.mu.Lock()
+	db.caches = make(map[*sqlite.Conn]*stmtCache)
+	db.owners = make(map[*sqlite.Stmt]*list.Element)
+	db.transient = make(map[*sqlite.Stmt]bool)
+	db.mu.Unlock()
+
+	return err
+}
+
+func (db *DB) Describe(ch chan<- *prometheus.Desc) {
+	ch <- db.readConnections
+	ch <- db.connectionsInUse
+	ch <- db.takesTotal
+	ch <- db.waitSeconds
+	ch <- db.statementCacheHits
+	ch <- db.statementCacheMiss
+	ch <- db.statementCacheCount
+}
+
+func (db *DB) Collect(ch chan<- prometheus.Metric) {
+	ch <- prometheus.MustNewConstMetric(db.readConnections, prometheus.GaugeValue, float64(db.readers))
+
+	ch <- prometheus.MustNewConstMetric(db.connectionsInUse, prometheus.GaugeValue, float64(db.readsInUse.Load()), "read")
+	ch <- prometheus.MustNewConstMetric(db.connectionsInUse, prometheus.GaugeValue, float64(db.writeInUse.Load()), "write")
+
+	ch <- prometheus.MustNewConstMetric(db.takesTotal, prometheus.CounterValue, float64(db.readTakes.Load()), "read")
+	ch <- prometheus.MustNewConstMetric(db.takesTotal, prometheus.CounterValue, float64(db.writeTakes.Load()), "write")
+
+	ch <- prometheus.MustNewConstMetric(db.waitSeconds, prometheus.CounterValue, time.Duration(db.readWait.Load()).Seconds(), "read")
+	ch <- prometheus.MustNewConstMetric(db.waitSeconds, prometheus.CounterValue, time.Duration(db.writeWait.Load()).Seconds(), "write")
+
+	ch <- prometheus.MustNewConstMetric(db.statementCacheHits, prometheus.CounterValue, float64(db.hits.Load()))
+	ch <- prometheus.MustNewConstMetric(db.statementCacheMiss, prometheus.CounterValue, float64(db.misses.Load()))
+
+	db.mu.Lock()
+	var statements int
+	for _, cache := range db.caches {
+		statements += len(cache.items)
+	}
+	db.mu.Unlock()
+	ch <- prometheus.MustNewConstMetric(db.statementCacheCount, prometheus.GaugeValue, float64(statements))
+}
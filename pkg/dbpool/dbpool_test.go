@@ -0,0 +1,199 @@
+package dbpool
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"zombiezen.com/go/sqlite"
+	"zombiezen.com/go/sqlite/sqlitex"
+)
+
+func openTestDB(t *testing.T) *DB {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "sqlite.db")
+	conn, err := sqlite.OpenConn(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sqlitex.ExecuteTransient(conn, "CREATE TABLE media (hash INTEGER PRIMARY KEY, path TEXT)", nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := Open(path, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if err := db.Close(); err != nil {
+			t.Error(err)
+		}
+	})
+
+	return db
+}
+
+func TestStatementCache(t *testing.T) {
+	db := openTestDB(t)
+
+	conn, err := db.Take(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Put(conn)
+
+	first, err := db.Prepare(conn, "SELECT path FROM media WHERE hash = ?")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.Release(first)
+
+	again, err := db.Prepare(conn, "SELECT path FROM media WHERE hash = ?")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again != first {
+		t.Errorf("Prepare of a cached query returned a new statement")
+	}
+
+	// a statement in use must survive eviction
+	for i := 0; i < stmtCacheSize*2; i++ {
+		stmt, err := db.Prepare(conn, fmt.Sprintf("SELECT %d", i))
+		if err != nil {
+			t.Fatal(err)
+		}
+		db.Release(stmt)
+	}
+	if _, err := again.Step(); err != nil {
+		t.Errorf("in use statement was finalized: %v", err)
+	}
+	db.Release(again)
+
+	if n := len(db.caches[conn].items); n > stmtCacheSize {
+		t.Errorf("statement cache size = %d; want at most %d", n, stmtCacheSize)
+	}
+}
+
+func TestNestedPrepare(t *testing.T) {
+	db := openTestDB(t)
+
+	conn, err := db.TakeWriter(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sqlitex.Execute(conn, "INSERT INTO media (hash, path) VALUES (1, 'a.jpg'), (2, 'b.jpg')", nil); err != nil {
+		t.Fatal(err)
+	}
+	db.Put(conn)
+
+	conn, err = db.Take(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Put(conn)
+
+	const query = "SELECT path FROM media WHERE hash >= ? ORDER BY hash"
+	outer, err := db.Prepare(conn, query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	outer.BindInt64(1, 1)
+
+	var paths []string
+	for {
+		row, err := outer.Step()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !row {
+			break
+		}
+		paths = append(paths, outer.ColumnText(0))
+
+		// a nested caller running the same query must not reset the outer statement
+		inner, err := db.Prepare(conn, query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if inner == outer {
+			db.Release(outer)
+			t.Fatal("Prepare returned a statement that is in use")
+		}
+		inner.BindInt64(1, 2)
+		if _, err := inner.Step(); err != nil {
+			t.Fatal(err)
+		}
+		db.Release(inner)
+	}
+	db.Release(outer)
+
+	if fmt.Sprint(paths) != "[a.jpg b.jpg]" {
+		t.Errorf("outer statement returned %v; want [a.jpg b.jpg]", paths)
+	}
+
+	// the cached statement is reused once released
+	again, err := db.Prepare(conn, query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again != outer {
+		t.Errorf("Prepare of a released query returned a new statement")
+	}
+	db.Release(again)
+
+	if n := len(db.transient); n != 0 {
+		t.Errorf("%d transient statements were not finalized", n)
+	}
+}
+
+func TestWriterIsSerialized(t *testing.T) {
+	db := openTestDB(t)
+
+	conn, err := db.TakeWriter(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := db.TakeWriter(ctx); err == nil {
+		t.Fatal("TakeWriter succeeded while the writer was taken")
+	}
+
+	if err := sqlitex.Execute(conn, "INSERT INTO media (hash, path) VALUES (1, 'a.jpg')", nil); err != nil {
+		t.Fatal(err)
+	}
+	db.Put(conn)
+
+	conn, err = db.TakeWriter(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.Put(conn)
+
+	read, err := db.Take(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Put(read)
+
+	var path string
+	err = sqlitex.Execute(read, "SELECT path FROM media WHERE hash = 1", &sqlitex.ExecOptions{
+		ResultFunc: func(stmt *sqlite.Stmt) error {
+			path = stmt.ColumnText(0)
+			return nil
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if path != "a.jpg" {
+		t.Errorf("read path = %q; want %q", path, "a.jpg")
+	}
+}
@@ -0,0 +1,191 @@
+package duplicates
+
+import (
+	"cmp"
+	"fmt"
+	"image"
+	"math/bits"
+	"slices"
+	"strings"
+
+	"github.com/disintegration/imaging"
+	"github.com/robbymilo/rgallery/pkg/queries"
+	"github.com/robbymilo/rgallery/pkg/types"
+)
+
+type Conf = types.Conf
+type Media = types.Media
+type Duplicate = types.Duplicate
+type DuplicateGroup = types.DuplicateGroup
+
+// DefaultDistance is the number of bits two perceptual hashes may differ by for their images to be near duplicates.
+// Copies of an image resized or recompressed differ by a few bits at most.
+const DefaultDistance = 6
+
+// MaxDistance is the largest distance allowed, past which unrelated images are grouped.
+const MaxDistance = 16
+
+// Hash returns the difference hash of an image, made from the brightness of each of 8x8 pixels compared to the one to
+// its right in a 9x8 grayscale copy of the image. Images that look the same have hashes that differ by few bits.
+func Hash(img image.Image) uint64 {
+	small := imaging.Grayscale(imaging.Resize(img, 9, 8, imaging.Box))
+
+	var hash uint64
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			// the image is gray, so any channel is the brightness
+			if small.Pix[small.PixOffset(x, y)] > small.Pix[small.PixOffset(x+1, y)] {
+				hash |= 1 << (y*8 + x)
+			}
+		}
+	}
+
+	return hash
+}
+
+// Distance returns the number of bits that two perceptual hashes differ by.
+func Distance(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
+
+// Find returns the groups of media items in the db that are near duplicates of each other.
+func Find(distance int, c Conf) ([]DuplicateGroup, error) {
+	items, err := queries.GetMediaItems(0, "ASC", -1, c)
+	if err != nil {
+		return nil, fmt.Errorf("error getting media items: %v", err)
+	}
+
+	return Group(items, distance), nil
+}
+
+// Group returns the groups of media items whose perceptual hashes are within distance of each other, including items
+// that are only near duplicates through another item of the group. Items are sorted largest first, and items without a
+// perceptual hash, such as videos, are skipped.
+func Group(items []Media, distance int) []DuplicateGroup {
+	var tree *node
+	var hashed []Media
+	for _, item := range items {
+		if !item.PHashed {
+			continue
+		}
+		hashed = append(hashed, item)
+		tree = tree.add(item.PHash, len(hashed)-1)
+	}
+
+	// join each item with the items near it
+	parent := make([]int, len(hashed))
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+	for i, item := range hashed {
+		tree.search(item.PHash, distance, func(j int) {
+			if a, b := find(i), find(j); a != b {
+				parent[b] = a
+			}
+		})
+	}
+
+	groups := make(map[int][]Media)
+	for i, item := range hashed {
+		root := find(i)
+		groups[root] = append(groups[root], item)
+	}
+
+	result := make([]DuplicateGroup, 0)
+	for _, group := range groups {
+		if len(group) < 2 {
+			continue
+		}
+
+		slices.SortFunc(group, func(a, b Media) int {
+			return cmp.Or(
+				cmp.Compare(b.Width*b.Height, a.Width*a.Height),
+				cmp.Compare(b.Size, a.Size),
+				strings.Compare(a.Path, b.Path),
+			)
+		})
+
+		var g DuplicateGroup
+		for _, item := range group {
+			g.Items = append(g.Items, Duplicate{
+				Hash:   item.Hash,
+				Path:   item.Path,
+				Width:  item.Width,
+				Height: item.Height,
+				Size:   item.Size,
+				Date:   item.Date,
+			})
+			if d := Distance(group[0].PHash, item.PHash); d > g.Distance {
+				g.Distance = d
+			}
+		}
+		result = append(result, g)
+	}
+
+	slices.SortFunc(result, func(a, b DuplicateGroup) int {
+		return strings.Compare(a.Items[0].Path, b.Items[0].Path)
+	})
+
+	return result
+}
+
+// node is a node of a BK-tree of perceptual hashes, which finds the hashes within a distance of a hash without
+// comparing it to every other hash.
+type node struct {
+	hash     uint64
+	items    []int
+	children map[int]*node
+}
+
+// add adds the item at index i with a hash to the tree, returning the root of the tree.
+func (n *node) add(hash uint64, i int) *node {
+	if n == nil {
+		return &node{hash: hash, items: []int{i}}
+	}
+
+	for current := n; ; {
+		d := Distance(current.hash, hash)
+		if d == 0 {
+			current.items = append(current.items, i)
+			return n
+		}
+
+		child, ok := current.children[d]
+		if !ok {
+			if current.children == nil {
+				current.children = make(map[int]*node)
+			}
+			current.children[d] = &node{hash: hash, items: []int{i}}
+			return n
+		}
+		current = child
+	}
+}
+
+// search calls found with the index of each item whose hash is within distance of hash.
+func (n *node) search(hash uint64, distance int, found func(int)) {
+	if n == nil {
+		return
+	}
+
+	d := Distance(n.hash, hash)
+	if d <= distance {
+		for _, i := range n.items {
+			found(i)
+		}
+	}
+
+	// only children at a distance within distance of d can hold matches
+	for childDistance, child := range n.children {
+		if childDistance >= d-distance && childDistance <= d+distance {
+			child.search(hash, distance, found)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package duplicates
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/disintegration/imaging"
+	"github.com/stretchr/testify/assert"
+)
+
+// gradient returns an image that gets brighter from left to right, and darker from top to bottom.
+func gradient(width, height int, inverted bool) image.Image {
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			v := uint8(x*255/width/2 + (height-y)*255/height/2)
+			if inverted {
+				v = 255 - v
+			}
+			img.Set(x, y, color.NRGBA{R: v, G: v / 2, B: 255 - v, A: 255})
+		}
+	}
+	return img
+}
+
+func TestHash(t *testing.T) {
+	original := gradient(400, 300, false)
+	resized := imaging.Resize(original, 120, 90, imaging.Lanczos)
+
+	assert.LessOrEqual(t, Distance(Hash(original), Hash(resized)), 2)
+	assert.Greater(t, Distance(Hash(original), Hash(gradient(400, 300, true))), MaxDistance)
+}
+
+func TestGroup(t *testing.T) {
+	items := []Media{
+		{Hash: 1, Path: "a/small.jpg", Width: 100, Height: 75, PHash: 0b1111, PHashed: true},
+		{Hash: 2, Path: "a/large.jpg", Width: 400, Height: 300, PHash: 0b0111, PHashed: true},
+		{Hash: 3, Path: "b/copy.jpg", Width: 400, Height: 300, PHash: 0b0011, PHashed: true},
+		{Hash: 4, Path: "b/other.jpg", Width: 400, Height: 300, PHash: 0xffff0000, PHashed: true},
+		{Hash: 5, Path: "b/video.mp4", Width: 400, Height: 300},
+		{Hash: 6, Path: "c/video.mp4", Width: 400, Height: 300},
+		// uniform images have a hash of 0
+		{Hash: 7, Path: "d/black.jpg", Width: 400, Height: 300, PHashed: true},
+		{Hash: 8, Path: "d/black.png", Width: 400, Height: 300, PHashed: true},
+	}
+
+	paths := func(group DuplicateGroup) []string {
+		var paths []string
+		for _, item := range group.Items {
+			paths = append(paths, item.Path)
+		}
+		return paths
+	}
+
+	groups := Group(items, 1)
+	if assert.Len(t, groups, 2) {
+		assert.Equal(t, 1, groups[0].Distance)
+		assert.Equal(t, []string{"a/large.jpg", "b/copy.jpg", "a/small.jpg"}, paths(groups[0]))
+		assert.Equal(t, []string{"d/black.jpg", "d/black.png"}, paths(groups[1]))
+	}
+
+	groups = Group(items, 0)
+	if assert.Len(t, groups, 1) {
+		assert.Equal(t, []string{"d/black.jpg", "d/black.png"}, paths(groups[0]))
+	}
+}
+
+func TestMatches(t *testing.T) {
+	var c Conf
+	c.Aliases.Lenses = map[string]string{
+		"XF23mmF1.4 R":          "Fujifilm 23mm f/1.4",
+		"XF23mm F1.4 R":         "Fujifilm 23mm f/1.4",
+		"NIKKOR Z 50mm f/1.8 S": "Nikon 50mm f/1.8",
+	}
+	e := entry{rating: 3, mediatype: "image", folder: "a", lens: "XF23mmF1.4 R", subjects: []string{"dog"}, library: "photos"}
+
+	assert.True(t, e.matches(FilterParams{}, c))
+	assert.True(t, e.matches(FilterParams{Rating: 3, MediaType: "image", Folder: "a", Subject: "dog"}, c))
+	assert.False(t, e.matches(FilterParams{Rating: 4}, c))
+	assert.False(t, e.matches(FilterParams{MediaType: "video"}, c))
+	assert.False(t, e.matches(FilterParams{Folder: "b"}, c))
+	assert.False(t, e.matches(FilterParams{Subject: "cat"}, c))
+	assert.True(t, e.matches(FilterParams{Library: "photos"}, c))
+	assert.False(t, e.matches(FilterParams{Library: "archive"}, c))
+
+	assert.True(t, e.matches(FilterParams{Lens: "Fujifilm 23mm f/1.4"}, c))
+	assert.True(t, e.matches(FilterParams{Lens: "XF23mm F1.4 R"}, c))
+	assert.False(t, e.matches(FilterParams{Lens: "NIKKOR Z 50mm f/1.8 S"}, c))
+}
+
+func TestColorDistance(t *testing.T) {
+	assert.Equal(t, [3]int{208, 16, 255}, parseColor("#D010FF"))
+	assert.Equal(t, [3]int{}, parseColor("#D0D0"))
+	assert.Less(t, colorDistance(parseColor("#D0D0D0"), parseColor("#C0C0C0")), colorDistance(parseColor("#D0D0D0"), parseColor("#102030")))
+}
@@ -0,0 +1,220 @@
+package duplicates
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	cache "github.com/patrickmn/go-cache"
+	"github.com/robbymilo/rgallery/pkg/queries"
+	"github.com/robbymilo/rgallery/pkg/types"
+)
+
+type FilterParams = types.FilterParams
+type Similar = types.Similar
+
+// indexKey is the cache key of the similar image index, which is rebuilt once the cache is flushed after the library
+// changes.
+const indexKey = "similar-index"
+
+// indexMutex stops the index from being built by several requests at once.
+var indexMutex sync.Mutex
+
+// entry is the visual signature of an image in the index, with the fields filter params are matched against.
+type entry struct {
+	hash          uint64
+	phash         uint64
+	color         [3]int
+	rating        float64
+	mediatype     string
+	folder        string
+	camera        string
+	lens          string
+	software      string
+	focallength35 float64
+	subjects      []string
+	library       string
+}
+
+// index is a BK-tree of the perceptual hashes of every image.
+type index struct {
+	entries []entry
+	tree    *node
+}
+
+// FindSimilar returns up to limit images that look most like a media item, closest first, matching the filter params.
+// Images are ranked by the distance between their perceptual hashes, then between their dominant colors. Videos have
+// no perceptual hash, so nothing is similar to them.
+func FindSimilar(media Media, limit int, params FilterParams, c Conf, cache *cache.Cache) ([]Similar, error) {
+	similar := make([]Similar, 0)
+	if !media.PHashed {
+		return similar, nil
+	}
+
+	idx, err := getIndex(c, cache)
+	if err != nil {
+		return nil, err
+	}
+
+	hash := media.Hash
+	target := entry{hash: hash, phash: media.PHash, color: parseColor(media.Color)}
+
+	// widen the search until enough images match the filter params
+	var matches []int
+	for radius := 8; radius <= 64 && len(matches) < limit; radius += 8 {
+		matches = matches[:0]
+		idx.tree.search(target.phash, radius, func(i int) {
+			if e := idx.entries[i]; e.hash != hash && e.matches(params, c) {
+				matches = append(matches, i)
+			}
+		})
+	}
+
+	slices.SortFunc(matches, func(a, b int) int {
+		ea, eb := idx.entries[a], idx.entries[b]
+		return cmp.Or(
+			cmp.Compare(Distance(ea.phash, target.phash), Distance(eb.phash, target.phash)),
+			cmp.Compare(colorDistance(ea.color, target.color), colorDistance(eb.color, target.color)),
+			cmp.Compare(ea.hash, eb.hash),
+		)
+	})
+	if len(matches) > limit {
+		matches = matches[:limit]
+	}
+
+	for _, i := range matches {
+		item, err := queries.GetSingleMediaItem(idx.entries[i].hash, c)
+		if err != nil {
+			return nil, err
+		}
+		if item.Path == "" {
+			continue
+		}
+		similar = append(similar, Similar{Media: item, Distance: Distance(idx.entries[i].phash, target.phash)})
+	}
+
+	return similar, nil
+}
+
+// getIndex returns the similar image index from the cache, building it if the cache was flushed.
+func getIndex(c Conf, cache *cache.Cache) (*index, error) {
+	indexMutex.Lock()
+	defer indexMutex.Unlock()
+
+	if cached, found := cache.Get(indexKey); found {
+		return cached.(*index), nil
+	}
+
+	items, err := queries.GetMediaItems(0, "ASC", -1, c)
+	if err != nil {
+		return nil, fmt.Errorf("error getting media items: %v", err)
+	}
+
+	idx := &index{}
+	for _, item := range items {
+		if !item.PHashed {
+			continue
+		}
+
+		subjects := make([]string, 0, len(item.Subject))
+		for _, s := range item.Subject {
+			subjects = append(subjects, s.Key)
+		}
+
+		idx.entries = append(idx.entries, entry{
+			hash:          item.Hash,
+			phash:         item.PHash,
+			color:         parseColor(item.Color),
+			rating:        item.Rating,
+			mediatype:     item.Type,
+			folder:        item.Folder,
+			camera:        item.Camera,
+			lens:          item.Lens,
+			software:      item.Software,
+			focallength35: item.FocalLength35,
+			subjects:      subjects,
+			library:       item.Library,
+		})
+		idx.tree = idx.tree.add(item.PHash, len(idx.entries)-1)
+	}
+
+	c.Logger.Info("built similar image index", "images", len(idx.entries))
+	cache.Set(indexKey, idx, -1)
+
+	return idx, nil
+}
+
+// matches tests if an image matches the filter params of the timeline. Search terms are not matched.
+func (e entry) matches(params FilterParams, c Conf) bool {
+	if e.rating < float64(params.Rating) {
+		return false
+	}
+	if params.MediaType != "" && e.mediatype != params.MediaType {
+		return false
+	}
+	if params.Folder != "" && e.folder != params.Folder {
+		return false
+	}
+	if params.Camera != "" && e.camera != params.Camera {
+		return false
+	}
+	if params.Lens != "" && e.lens != params.Lens && !sameLens(e.lens, params.Lens, c) {
+		return false
+	}
+	if params.Software != "" && e.software != params.Software {
+		return false
+	}
+	if params.FocalLength35 != 0 && e.focallength35 != params.FocalLength35 {
+		return false
+	}
+	if params.Subject != "" && !slices.Contains(e.subjects, params.Subject) {
+		return false
+	}
+	if params.Library != "" && e.library != params.Library {
+		return false
+	}
+
+	return true
+}
+
+// sameLens tests if the lens of an image is named lens by an alias, or has the same alias as it.
+func sameLens(imageLens, lens string, c Conf) bool {
+	alias, ok := c.Aliases.Lenses[imageLens]
+	if !ok {
+		return false
+	}
+
+	return alias == lens || alias == c.Aliases.Lenses[lens]
+}
+
+// parseColor returns the red, green and blue values of a hex color, such as #D0D0D0.
+func parseColor(color string) [3]int {
+	var rgb [3]int
+	color = strings.TrimPrefix(color, "#")
+	if len(color) != 6 {
+		return rgb
+	}
+
+	for i := range rgb {
+		v, err := strconv.ParseUint(color[i*2:i*2+2], 16, 8)
+		if err != nil {
+			return [3]int{}
+		}
+		rgb[i] = int(v)
+	}
+
+	return rgb
+}
+
+// colorDistance returns the squared distance between two colors.
+func colorDistance(a, b [3]int) int {
+	var d int
+	for i := range a {
+		d += (a[i] - b[i]) * (a[i] - b[i])
+	}
+
+	return d
+}
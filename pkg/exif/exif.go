@@ -16,6 +16,7 @@ import (
 	"github.com/araddon/dateparse"
 	exiftool "github.com/barasher/go-exiftool"
 	"github.com/cenkalti/dominantcolor"
+	"github.com/robbymilo/rgallery/pkg/duplicates"
 	"github.com/robbymilo/rgallery/pkg/geo"
 	"github.com/robbymilo/rgallery/pkg/hash"
 	"github.com/robbymilo/rgallery/pkg/resize"
@@ -59,11 +60,22 @@ func GetLens(absolute_path string) (string, error) {
 
 // GetImageExif returns the exif data of a media item.
 func GetImageExif(mediatype, relative_path, absolute_path string, et *exiftool.Exiftool, h *geo.Handlers, c Conf) (Media, imaging, error) {
-	// relative_path is everything after the media dir
-	hash := hash.GetHash(relative_path)
+	// the hash is derived from the file content so it survives renames and moves
+	checksum, err := hash.GetChecksum(absolute_path)
+	if err != nil {
+		return Media{}, nil, fmt.Errorf("error getting checksum: %v", err)
+	}
+	hash := hash.GetContentHash(checksum)
 
 	exif := et.ExtractMetadata(absolute_path)
 
+	// metadata set by editors in a sidecar overrides that embedded in the file
+	if sidecar := Sidecar(absolute_path); sidecar != "" {
+		if err := mergeSidecar(exif, et.ExtractMetadata(sidecar)); err != nil {
+			c.Logger.Warn("error merging sidecar metadata", "path", sidecar, "error", err)
+		}
+	}
+
 	file, err := os.Stat(absolute_path)
 	if err != nil {
 		return Media{}, nil, fmt.Errorf("error opening file: %v", err)
@@ -75,11 +87,15 @@ func GetImageExif(mediatype, relative_path, absolute_path string, et *exiftool.E
 	var height int
 	var color string
 	var rotation float64
+	var motionOffset, motionLength int64
+	var phash uint64
+	var phashed bool
+	var video videoInfo
 
 	switch mediatype {
 	case "image":
 		// need to open separately from exiftool to get correct orientation
-		img, err = resize.DecodeImage(absolute_path)
+		img, err = resize.DecodeImage(absolute_path, c)
 		if err != nil {
 			return Media{}, nil, err
 		}
@@ -87,6 +103,9 @@ func GetImageExif(mediatype, relative_path, absolute_path string, et *exiftool.E
 		// dominant color
 		color = dominantcolor.Hex(dominantcolor.Find(img))
 
+		// perceptual hash for finding duplicates
+		phash, phashed = duplicates.Hash(img), true
+
 		// orientation
 		bounds := img.Bounds()
 		width = bounds.Dx()
@@ -98,6 +117,14 @@ func GetImageExif(mediatype, relative_path, absolute_path string, et *exiftool.E
 			}
 		}
 
+		if IsMotionPhotoFormat(absolute_path) {
+			var motionErr error
+			motionOffset, motionLength, motionErr = MotionPhoto(absolute_path)
+			if motionErr != nil {
+				c.Logger.Warn("error finding motion photo video", "path", absolute_path, "error", motionErr)
+			}
+		}
+
 	case "video":
 		for _, fileInfo := range exif {
 
@@ -124,6 +151,16 @@ func GetImageExif(mediatype, relative_path, absolute_path string, et *exiftool.E
 			}
 		}
 
+		// exiftool can not read the streams of every container, such as AVCHD
+		var probeErr error
+		video, probeErr = probeVideo(absolute_path)
+		if probeErr != nil {
+			c.Logger.Warn("error probing video", "path", absolute_path, "error", probeErr)
+		}
+		if width == 0 || height == 0 {
+			width, height = video.Width, video.Height
+		}
+
 	}
 
 	for _, fileInfo := range exif {
@@ -142,6 +179,10 @@ func GetImageExif(mediatype, relative_path, absolute_path string, et *exiftool.E
 				if date_string == "" || date_string == "<nil>" {
 					date_string = fmt.Sprint(fileInfo.Fields["TrackCreateDate"])
 
+					if (date_string == "" || date_string == "<nil>") && !video.CreationTime.IsZero() {
+						date_string = video.CreationTime.Format("2006-01-02T15:04:05.000Z")
+					}
+
 					if date_string == "" || date_string == "<nil>" {
 						d, err := stringToDate(filepath.Base(absolute_path))
 						if err != nil {
@@ -220,26 +261,11 @@ func GetImageExif(mediatype, relative_path, absolute_path string, et *exiftool.E
 		if fileInfo.Fields["Subject"] != nil {
 			switch reflect.TypeOf(fileInfo.Fields["Subject"]).Kind() {
 			case reflect.String:
-				v := fmt.Sprint(fileInfo.Fields["Subject"])
-
-				k := strings.ReplaceAll(strings.ToLower(v), " ", "-")
-				var r Subject
-				r.Key = k
-				r.Value = v
-
-				subject = append(subject, r)
+				subject = append(subject, NewSubject(fmt.Sprint(fileInfo.Fields["Subject"])))
 			case reflect.Slice:
 				s := reflect.ValueOf(fileInfo.Fields["Subject"])
 				for i := 0; i < s.Len(); i++ {
-					v := fmt.Sprint(s.Index(i))
-					k := strings.ReplaceAll(strings.ToLower(v), " ", "-")
-
-					var r Subject
-					r.Key = k
-					r.Value = v
-
-					subject = append(subject, r)
-
+					subject = append(subject, NewSubject(fmt.Sprint(s.Index(i))))
 				}
 			}
 		}
@@ -374,7 +400,7 @@ func GetImageExif(mediatype, relative_path, absolute_path string, et *exiftool.E
 			Ratio:         ratio,
 			Padding:       padding,
 			Date:          dateOriginal,
-			Modified:      file.ModTime().UTC(),
+			Modified:      ModTime(absolute_path, file).UTC(),
 			Folder:        filepath.Dir(relative_path),
 			Rating:        rating,
 			ShutterSpeed:  shutterSpeed,
@@ -396,6 +422,20 @@ func GetImageExif(mediatype, relative_path, absolute_path string, et *exiftool.E
 			Software:      software,
 			Offset:        offsetMinutes,
 			Rotation:      rotation,
+			Checksum:      checksum,
+			Size:          file.Size(),
+			MotionOffset:  motionOffset,
+			MotionLength:  motionLength,
+			PHash:         phash,
+			PHashed:       phashed,
+			Library:       c.Library,
+			Container:     video.Container,
+			VideoCodec:    video.VideoCodec,
+			AudioCodec:    video.AudioCodec,
+			Duration:      video.Duration,
+			FrameRate:     video.FrameRate,
+			Bitrate:       video.Bitrate,
+			HasAudio:      video.HasAudio,
 		}
 	}
 
@@ -405,6 +445,14 @@ func GetImageExif(mediatype, relative_path, absolute_path string, et *exiftool.E
 
 var numbersOnly = regexp.MustCompile(`[^0-9]+`)
 
+// NewSubject returns the subject of a keyword, keyed by the keyword in lowercase with spaces replaced by dashes.
+func NewSubject(value string) Subject {
+	return Subject{
+		Key:   strings.ReplaceAll(strings.ToLower(value), " ", "-"),
+		Value: value,
+	}
+}
+
 // stringToDate attempts to get a time from an arbitrary string such as a filename.
 func stringToDate(date_string string) (time.Time, error) {
 
@@ -0,0 +1,142 @@
+package exif
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// maxLiveVideoDuration is the longest video, in seconds, stored as the video of a photo with the same name without a
+// matching content identifier. The videos of live photos are about three seconds long.
+const maxLiveVideoDuration = 5
+
+// IsLivePhotoVideo tests if a video is the video of the live photo with the same name, rather than a clip of its own
+// that shares the name of a photo, such as the DSC0001.JPG and DSC0001.MP4 of a camera. Short videos are paired with
+// the photo, and longer ones only if their Apple content identifier matches the one of the photo.
+func IsLivePhotoVideo(video, photo string) bool {
+	info, err := probeVideo(video)
+	if err != nil {
+		return false
+	}
+
+	return isLivePhotoVideo(info, func() string { return contentIdentifier(photo) })
+}
+
+// isLivePhotoVideo tests if a video is the video of a live photo, reading the content identifier of the photo only if
+// the video is too long to be paired by its duration.
+func isLivePhotoVideo(info videoInfo, photoIdentifier func() string) bool {
+	if info.Duration > 0 && info.Duration <= maxLiveVideoDuration {
+		return true
+	}
+
+	return info.ContentIdentifier != "" && info.ContentIdentifier == photoIdentifier()
+}
+
+// contentIdentifier returns the Apple content identifier of a photo, or an empty string if it has none.
+func contentIdentifier(path string) string {
+	out, err := exec.Command("exiftool", "-s3", "-ContentIdentifier", path).Output()
+	if err != nil {
+		return ""
+	}
+
+	return strings.TrimSpace(string(out))
+}
+
+// IsMotionPhotoFormat returns true if the path is a format that Google and Samsung cameras embed the video of a
+// motion photo in.
+func IsMotionPhotoFormat(path string) bool {
+	ext := strings.ToLower(filepath.Ext(path))
+	return ext == ".jpg" || ext == ".jpeg"
+}
+
+// MotionPhoto returns the offset and length of the MP4 video embedded in a motion photo, which is appended to the
+// image by Google as a MicroVideo or MotionPhoto, and by Samsung after a MotionPhoto_Data marker. The length is 0 if
+// the photo has no video.
+func MotionPhoto(path string) (int64, int64, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, 0, fmt.Errorf("error reading file: %v", err)
+	}
+
+	offset, length := findMP4(data)
+	return offset, length, nil
+}
+
+// findMP4 returns the offset and length of the first MP4 file in data, found by its ftyp box.
+func findMP4(data []byte) (int64, int64) {
+	for from := 0; ; {
+		i := bytes.Index(data[from:], []byte("ftyp"))
+		if i < 0 {
+			return 0, 0
+		}
+		start := from + i - 4
+		from += i + 4
+
+		// an image can not start with a video
+		if start <= 0 {
+			continue
+		}
+
+		if length := mp4Length(data[start:]); length > 0 {
+			return int64(start), length
+		}
+	}
+}
+
+// mp4Length returns the length of the MP4 file at the start of data by walking its top level boxes, or 0 if data does
+// not start with an MP4 file that has both a moov and an mdat box.
+func mp4Length(data []byte) int64 {
+	var length int64
+	var moov, mdat bool
+	for int64(len(data))-length >= 8 {
+		box := data[length:]
+		size := int64(binary.BigEndian.Uint32(box[:4]))
+		name := string(box[4:8])
+
+		if !isBoxName(name) {
+			break
+		}
+		if length == 0 && (name != "ftyp" || size < 8 || size > 256) {
+			return 0
+		}
+
+		switch size {
+		case 0:
+			// the box runs to the end of the file
+			size = int64(len(box))
+		case 1:
+			if len(box) < 16 {
+				return 0
+			}
+			size = int64(binary.BigEndian.Uint64(box[8:16]))
+		}
+		if size < 8 || size > int64(len(box)) {
+			break
+		}
+
+		moov = moov || name == "moov"
+		mdat = mdat || name == "mdat"
+		length += size
+	}
+
+	if !moov || !mdat {
+		return 0
+	}
+
+	return length
+}
+
+// isBoxName tests if a box name is made of four printable ASCII characters.
+func isBoxName(name string) bool {
+	for _, r := range name {
+		if r < 0x20 || r > 0x7e {
+			return false
+		}
+	}
+
+	return len(name) == 4
+}
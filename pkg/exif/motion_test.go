@@ -0,0 +1,60 @@
+package exif
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// box returns an MP4 box with a name and payload.
+func box(name string, payload []byte) []byte {
+	b := make([]byte, 8, 8+len(payload))
+	binary.BigEndian.PutUint32(b, uint32(8+len(payload)))
+	copy(b[4:], name)
+	return append(b, payload...)
+}
+
+func TestFindMP4(t *testing.T) {
+	jpeg := append([]byte{0xff, 0xd8, 0xff, 0xe1}, []byte("ftyp in exif data")...)
+	jpeg = append(jpeg, 0xff, 0xd9)
+
+	var mp4 []byte
+	mp4 = append(mp4, box("ftyp", []byte("mp42\x00\x00\x00\x00isommp42"))...)
+	mp4 = append(mp4, box("mdat", make([]byte, 100))...)
+	mp4 = append(mp4, box("moov", make([]byte, 20))...)
+
+	// a Samsung motion photo has a marker before the video and a trailer after it
+	data := append(append([]byte{}, jpeg...), []byte("MotionPhoto_Data")...)
+	data = append(data, mp4...)
+	data = append(data, []byte("\x00\x00SEFH trailer")...)
+
+	offset, length := findMP4(data)
+	assert.Equal(t, int64(len(jpeg)+len("MotionPhoto_Data")), offset)
+	assert.Equal(t, int64(len(mp4)), length)
+
+	// a photo without a video
+	offset, length = findMP4(jpeg)
+	assert.Equal(t, int64(0), offset)
+	assert.Equal(t, int64(0), length)
+
+	// a video without a moov box can not be played
+	offset, length = findMP4(append(append([]byte{}, jpeg...), box("ftyp", []byte("mp42"))...))
+	assert.Equal(t, int64(0), length)
+	assert.Equal(t, int64(0), offset)
+}
+
+func TestIsLivePhotoVideo(t *testing.T) {
+	identifier := func(id string) func() string {
+		return func() string { return id }
+	}
+
+	// a video of a few seconds is paired without reading the photo
+	assert.True(t, isLivePhotoVideo(videoInfo{Duration: 2.8}, func() string { t.Fatal("photo read"); return "" }))
+
+	// a longer video is paired only if it shares the content identifier of the photo
+	assert.True(t, isLivePhotoVideo(videoInfo{Duration: 12, ContentIdentifier: "A1"}, identifier("A1")))
+	assert.False(t, isLivePhotoVideo(videoInfo{Duration: 12, ContentIdentifier: "A1"}, identifier("B2")))
+	assert.False(t, isLivePhotoVideo(videoInfo{Duration: 12}, identifier("")))
+	assert.False(t, isLivePhotoVideo(videoInfo{}, identifier("")))
+}
@@ -0,0 +1,162 @@
+package exif
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	ffmpeg "github.com/u2takey/ffmpeg-go"
+)
+
+// videoInfo is the container and streams of a video, read by ffprobe.
+type videoInfo struct {
+	Container    string
+	VideoCodec   string
+	AudioCodec   string
+	Duration     float64 // in seconds
+	FrameRate    float64 // in frames per second
+	Bitrate      int64   // in bits per second
+	HasAudio     bool
+	Width        int // of the first video stream, in its display orientation
+	Height       int
+	CreationTime time.Time // zero if the container does not record it
+	// ContentIdentifier is the Apple content identifier of the video of a live photo, which the photo shares
+	ContentIdentifier string
+}
+
+// probeOutput is the part of the JSON output of ffprobe -show_format -show_streams that is read.
+type probeOutput struct {
+	Format struct {
+		FormatName string `json:"format_name"`
+		Duration   string `json:"duration"`
+		BitRate    string `json:"bit_rate"`
+		Tags       struct {
+			CreationTime      string `json:"creation_time"`
+			ContentIdentifier string `json:"com.apple.quicktime.content.identifier"`
+		} `json:"tags"`
+	} `json:"format"`
+	Streams []struct {
+		CodecType    string `json:"codec_type"`
+		CodecName    string `json:"codec_name"`
+		Width        int    `json:"width"`
+		Height       int    `json:"height"`
+		AvgFrameRate string `json:"avg_frame_rate"`
+		RFrameRate   string `json:"r_frame_rate"`
+		Tags         struct {
+			Rotate string `json:"rotate"`
+		} `json:"tags"`
+		SideDataList []struct {
+			Rotation float64 `json:"rotation"`
+		} `json:"side_data_list"`
+		Disposition struct {
+			AttachedPic int `json:"attached_pic"`
+		} `json:"disposition"`
+	} `json:"streams"`
+}
+
+// probeVideo reads the container, codecs, duration, frame rate, bitrate and audio of a video with ffprobe.
+func probeVideo(path string) (videoInfo, error) {
+	out, err := ffmpeg.Probe(path)
+	if err != nil {
+		return videoInfo{}, fmt.Errorf("error calling ffprobe: %v", err)
+	}
+
+	return parseProbe([]byte(out), path)
+}
+
+// parseProbe reads the output of ffprobe for the video at path.
+func parseProbe(out []byte, path string) (videoInfo, error) {
+	var probe probeOutput
+	if err := json.Unmarshal(out, &probe); err != nil {
+		return videoInfo{}, fmt.Errorf("error parsing ffprobe output: %v", err)
+	}
+
+	info := videoInfo{
+		Container: containerName(probe.Format.FormatName, path),
+	}
+	info.Duration, _ = strconv.ParseFloat(probe.Format.Duration, 64)
+	info.Bitrate, _ = strconv.ParseInt(probe.Format.BitRate, 10, 64)
+	if t, err := time.Parse(time.RFC3339Nano, probe.Format.Tags.CreationTime); err == nil {
+		info.CreationTime = t.UTC()
+	}
+	info.ContentIdentifier = probe.Format.Tags.ContentIdentifier
+
+	for _, stream := range probe.Streams {
+		switch stream.CodecType {
+		case "video":
+			// cover art of some containers is a video stream of its own
+			if info.VideoCodec != "" || stream.Disposition.AttachedPic == 1 {
+				continue
+			}
+
+			info.VideoCodec = stream.CodecName
+			info.Width, info.Height = stream.Width, stream.Height
+
+			info.FrameRate = parseFrameRate(stream.AvgFrameRate)
+			if info.FrameRate == 0 {
+				info.FrameRate = parseFrameRate(stream.RFrameRate)
+			}
+
+			rotation, _ := strconv.ParseFloat(stream.Tags.Rotate, 64)
+			for _, sideData := range stream.SideDataList {
+				if sideData.Rotation != 0 {
+					rotation = sideData.Rotation
+				}
+			}
+			if math.Abs(math.Mod(rotation, 180)) == 90 {
+				info.Width, info.Height = info.Height, info.Width
+			}
+		case "audio":
+			if !info.HasAudio {
+				info.HasAudio = true
+				info.AudioCodec = stream.CodecName
+			}
+		}
+	}
+
+	if info.VideoCodec == "" {
+		return videoInfo{}, fmt.Errorf("file has no video stream")
+	}
+
+	return info, nil
+}
+
+// containerName returns the name of the container of the video at path from the format names ffprobe reports, which
+// are the same for related containers such as "mov,mp4,m4a,3gp,3g2,mj2" or "matroska,webm".
+func containerName(formatName, path string) string {
+	names := strings.Split(formatName, ",")
+
+	ext := strings.TrimPrefix(strings.ToLower(filepath.Ext(path)), ".")
+	if ext == "m4v" {
+		ext = "mp4"
+	}
+	if slices.Contains(names, ext) {
+		return ext
+	}
+
+	return names[0]
+}
+
+// parseFrameRate parses a frame rate reported by ffprobe as a fraction such as "30000/1001", to two decimals.
+func parseFrameRate(rate string) float64 {
+	num, den, ok := strings.Cut(rate, "/")
+	if !ok {
+		den = "1"
+	}
+
+	n, err := strconv.ParseFloat(num, 64)
+	if err != nil {
+		return 0
+	}
+	d, err := strconv.ParseFloat(den, 64)
+	if err != nil || d == 0 {
+		return 0
+	}
+
+	return math.Round(n/d*100) / 100
+}
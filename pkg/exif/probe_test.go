@@ -0,0 +1,77 @@
+package exif
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseProbe(t *testing.T) {
+	out := `{
+		"streams": [
+			{"codec_type": "video", "codec_name": "mjpeg", "width": 320, "height": 240, "avg_frame_rate": "0/0", "disposition": {"attached_pic": 1}},
+			{"codec_type": "video", "codec_name": "h264", "width": 1920, "height": 1080, "avg_frame_rate": "30000/1001", "r_frame_rate": "30/1", "side_data_list": [{"rotation": -90}]},
+			{"codec_type": "audio", "codec_name": "aac"},
+			{"codec_type": "audio", "codec_name": "ac3"}
+		],
+		"format": {
+			"format_name": "mov,mp4,m4a,3gp,3g2,mj2",
+			"duration": "12.345000",
+			"bit_rate": "17201520",
+			"tags": {"creation_time": "2024-05-18T10:00:00.000000Z", "com.apple.quicktime.content.identifier": "8C4C1A55-3C2E-4D5F-9A0B-2B7E1F0D6A11"}
+		}
+	}`
+
+	info, err := parseProbe([]byte(out), "2024/IMG_1234.MP4")
+	assert.NoError(t, err)
+	assert.Equal(t, videoInfo{
+		Container:    "mp4",
+		VideoCodec:   "h264",
+		AudioCodec:   "aac",
+		Duration:     12.345,
+		FrameRate:    29.97,
+		Bitrate:      17201520,
+		HasAudio:     true,
+		Width:        1080,
+		Height:       1920,
+		CreationTime: time.Date(2024, 5, 18, 10, 0, 0, 0, time.UTC),
+
+		ContentIdentifier: "8C4C1A55-3C2E-4D5F-9A0B-2B7E1F0D6A11",
+	}, info)
+
+	// an AVCHD clip without a creation time, and a frame rate only in r_frame_rate
+	out = `{
+		"streams": [{"codec_type": "video", "codec_name": "h264", "width": 1440, "height": 1080, "avg_frame_rate": "0/0", "r_frame_rate": "25/1"}],
+		"format": {"format_name": "mpegts", "duration": "3.0", "bit_rate": "16000000"}
+	}`
+
+	info, err = parseProbe([]byte(out), "AVCHD/00001.MTS")
+	assert.NoError(t, err)
+	assert.Equal(t, "mpegts", info.Container)
+	assert.Equal(t, 25.0, info.FrameRate)
+	assert.False(t, info.HasAudio)
+	assert.Empty(t, info.AudioCodec)
+	assert.True(t, info.CreationTime.IsZero())
+
+	_, err = parseProbe([]byte(`{"streams": [{"codec_type": "audio", "codec_name": "mp3"}], "format": {"format_name": "mp3"}}`), "a.mp4")
+	assert.Error(t, err)
+}
+
+func TestContainerName(t *testing.T) {
+	tests := []struct {
+		formatName, path, want string
+	}{
+		{"mov,mp4,m4a,3gp,3g2,mj2", "a.MOV", "mov"},
+		{"mov,mp4,m4a,3gp,3g2,mj2", "a.m4v", "mp4"},
+		{"mov,mp4,m4a,3gp,3g2,mj2", "a.3gp", "3gp"},
+		{"matroska,webm", "a.mkv", "matroska"},
+		{"matroska,webm", "a.webm", "webm"},
+		{"mpegts", "a.m2ts", "mpegts"},
+		{"avi", "a.avi", "avi"},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.want, containerName(test.formatName, test.path), test.path)
+	}
+}
@@ -0,0 +1,91 @@
+package exif
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	exiftool "github.com/barasher/go-exiftool"
+)
+
+// sidecarFields are the fields read from XMP sidecars, which override those embedded in the media file.
+var sidecarFields = []string{"Rating", "Subject", "Title", "Description"}
+
+// IsSidecar returns true if the path is an XMP sidecar.
+func IsSidecar(path string) bool {
+	return strings.ToLower(filepath.Ext(path)) == ".xmp"
+}
+
+// Sidecar returns the path of the XMP sidecar of a media file, or "" if it has none. Sidecars named after the whole
+// file name, such as IMG_1234.CR2.xmp, are preferred over those named without the extension, such as IMG_1234.xmp.
+func Sidecar(path string) string {
+	stem := strings.TrimSuffix(path, filepath.Ext(path))
+	for _, p := range []string{path + ".xmp", path + ".XMP", stem + ".xmp", stem + ".XMP"} {
+		if info, err := os.Stat(p); err == nil && !info.IsDir() {
+			return p
+		}
+	}
+
+	return ""
+}
+
+// SidecarMedia returns the paths of the media files that an XMP sidecar could belong to.
+func SidecarMedia(path string) []string {
+	stem := strings.TrimSuffix(path, filepath.Ext(path))
+	if _, err := os.Stat(stem); err == nil && filepath.Ext(stem) != "" {
+		return []string{stem}
+	}
+
+	entries, err := os.ReadDir(filepath.Dir(path))
+	if err != nil {
+		return nil
+	}
+
+	var media []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if !entry.IsDir() && !IsSidecar(name) && strings.TrimSuffix(name, filepath.Ext(name)) == filepath.Base(stem) {
+			media = append(media, filepath.Join(filepath.Dir(path), name))
+		}
+	}
+
+	return media
+}
+
+// ModTime returns the modification time of a media file, or of its sidecar if that was modified later, so editing
+// only the sidecar is seen as a change to the media file.
+func ModTime(path string, file os.FileInfo) time.Time {
+	modified := file.ModTime()
+	if sidecar := Sidecar(path); sidecar != "" {
+		if info, err := os.Stat(sidecar); err == nil && info.ModTime().After(modified) {
+			modified = info.ModTime()
+		}
+	}
+
+	return modified
+}
+
+// mergeSidecar overrides the fields of the metadata of a media file with those set in its XMP sidecar.
+func mergeSidecar(exif []exiftool.FileMetadata, sidecar []exiftool.FileMetadata) error {
+	for _, s := range sidecar {
+		if s.Err != nil {
+			return fmt.Errorf("error reading sidecar: %v", s.Err)
+		}
+
+		for i := range exif {
+			if exif[i].Fields == nil {
+				continue
+			}
+
+			for _, field := range sidecarFields {
+				if v, ok := s.Fields[field]; ok {
+					exif[i].Fields[field] = v
+				}
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,61 @@
+package exif
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	exiftool "github.com/barasher/go-exiftool"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSidecar(t *testing.T) {
+	dir := t.TempDir()
+	for _, f := range []string{"a.jpg", "a.xmp", "b.CR2", "b.CR2.xmp", "b.xmp", "b.jpg", "c.jpg"} {
+		if err := os.WriteFile(filepath.Join(dir, f), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	assert.Equal(t, filepath.Join(dir, "a.xmp"), Sidecar(filepath.Join(dir, "a.jpg")))
+	assert.Equal(t, filepath.Join(dir, "b.CR2.xmp"), Sidecar(filepath.Join(dir, "b.CR2")))
+	assert.Equal(t, filepath.Join(dir, "b.xmp"), Sidecar(filepath.Join(dir, "b.jpg")))
+	assert.Equal(t, "", Sidecar(filepath.Join(dir, "c.jpg")))
+
+	assert.Equal(t, []string{filepath.Join(dir, "b.CR2")}, SidecarMedia(filepath.Join(dir, "b.CR2.xmp")))
+	assert.Equal(t, []string{filepath.Join(dir, "b.CR2"), filepath.Join(dir, "b.jpg")}, SidecarMedia(filepath.Join(dir, "b.xmp")))
+
+	// a sidecar edited after its media file changes the modification time of the media file
+	file, err := os.Stat(filepath.Join(dir, "a.jpg"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	later := file.ModTime().Add(time.Hour)
+	if err := os.Chtimes(filepath.Join(dir, "a.xmp"), later, later); err != nil {
+		t.Fatal(err)
+	}
+	assert.True(t, ModTime(filepath.Join(dir, "a.jpg"), file).Equal(later))
+}
+
+func TestMergeSidecar(t *testing.T) {
+	exif := []exiftool.FileMetadata{{Fields: map[string]interface{}{
+		"Rating":      float64(2),
+		"Title":       "embedded",
+		"Description": "embedded",
+		"Model":       "camera",
+	}}}
+	sidecar := []exiftool.FileMetadata{{Fields: map[string]interface{}{
+		"Rating":  float64(5),
+		"Subject": []interface{}{"one", "two"},
+		"Title":   "sidecar",
+		"Model":   "ignored",
+	}}}
+
+	assert.NoError(t, mergeSidecar(exif, sidecar))
+	assert.Equal(t, float64(5), exif[0].Fields["Rating"])
+	assert.Equal(t, []interface{}{"one", "two"}, exif[0].Fields["Subject"])
+	assert.Equal(t, "sidecar", exif[0].Fields["Title"])
+	assert.Equal(t, "embedded", exif[0].Fields["Description"])
+	assert.Equal(t, "camera", exif[0].Fields["Model"])
+}
@@ -0,0 +1,85 @@
+package exif
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+
+	"github.com/robbymilo/rgallery/pkg/formats"
+)
+
+// emptySidecar is the XMP packet that new sidecars are created with, before exiftool writes their fields.
+const emptySidecar = `<?xpacket begin='' id='W5M0MpCehiHzreSzNTczkc9d'?>
+<x:xmpmeta xmlns:x='adobe:ns:meta/'>
+<rdf:RDF xmlns:rdf='http://www.w3.org/1999/02/22-rdf-syntax-ns#'>
+</rdf:RDF>
+</x:xmpmeta>
+<?xpacket end='w'?>
+`
+
+// WriteMetadata writes the rating, subjects, title and description of a media item to the XMP sidecar of the file at
+// path, creating one named after the whole file name if there is none. If c.WriteMetadata is "file" they are written
+// into the file itself instead, except for RAW files, and to any existing sidecar as it overrides the file when
+// scanning. All other metadata in the sidecar or file is kept.
+func WriteMetadata(path string, media Media, c Conf) error {
+	var targets []string
+	if c.WriteMetadata == "file" && !formats.IsRaw(path) {
+		targets = append(targets, path)
+	}
+
+	sidecar := Sidecar(path)
+	if sidecar == "" && len(targets) == 0 {
+		sidecar = path + ".xmp"
+		if err := os.WriteFile(sidecar, []byte(emptySidecar), 0644); err != nil {
+			return fmt.Errorf("error creating sidecar: %v", err)
+		}
+	}
+	if sidecar != "" {
+		targets = append(targets, sidecar)
+	}
+
+	for _, target := range targets {
+		args := metadataArgs(media, target != sidecar)
+		out, err := exec.Command("exiftool", append(args, target)...).CombinedOutput()
+		if err != nil {
+			return fmt.Errorf("error calling exiftool command: %v: %s", err, bytes.TrimSpace(out))
+		}
+
+		c.Logger.Info("wrote metadata to " + target)
+	}
+
+	return nil
+}
+
+// metadataArgs returns the exiftool arguments that write the edited fields of media. Sidecars only hold XMP. Files
+// also get the EXIF rating, the IPTC keywords other applications read in place of the XMP subjects, and the EXIF
+// description scans fall back to, so no stale copy of a field is left in the file.
+func metadataArgs(media Media, file bool) []string {
+	rating := strconv.FormatFloat(media.Rating, 'f', -1, 64)
+	args := []string{"-overwrite_original", "-XMP-xmp:Rating=" + rating}
+	if file {
+		args = append(args, "-EXIF:Rating="+rating)
+	}
+
+	subjectTags := []string{"XMP-dc:Subject"}
+	if file {
+		subjectTags = append(subjectTags, "IPTC:Keywords")
+	}
+	for _, tag := range subjectTags {
+		if len(media.Subject) == 0 {
+			args = append(args, "-"+tag+"=")
+		}
+		for _, subject := range media.Subject {
+			args = append(args, "-"+tag+"="+subject.Value)
+		}
+	}
+
+	args = append(args, "-XMP-dc:Title="+media.Title, "-XMP-dc:Description="+media.Description)
+	if file {
+		args = append(args, "-EXIF:ImageDescription="+media.Description)
+	}
+
+	return args
+}
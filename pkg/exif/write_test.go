@@ -0,0 +1,39 @@
+package exif
+
+import (
+	"testing"
+
+	"github.com/robbymilo/rgallery/pkg/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetadataArgs(t *testing.T) {
+	media := Media{
+		Rating:      4,
+		Subject:     []types.Subject{{Key: "beach", Value: "Beach"}, {Key: "sunset", Value: "Sunset"}},
+		Title:       "Evening",
+		Description: "",
+	}
+
+	// sidecars only hold XMP
+	assert.Equal(t, []string{
+		"-overwrite_original", "-XMP-xmp:Rating=4",
+		"-XMP-dc:Subject=Beach", "-XMP-dc:Subject=Sunset",
+		"-XMP-dc:Title=Evening", "-XMP-dc:Description=",
+	}, metadataArgs(media, false))
+
+	// files also get the EXIF and IPTC copies of the fields
+	assert.Equal(t, []string{
+		"-overwrite_original", "-XMP-xmp:Rating=4", "-EXIF:Rating=4",
+		"-XMP-dc:Subject=Beach", "-XMP-dc:Subject=Sunset", "-IPTC:Keywords=Beach", "-IPTC:Keywords=Sunset",
+		"-XMP-dc:Title=Evening", "-XMP-dc:Description=", "-EXIF:ImageDescription=",
+	}, metadataArgs(media, true))
+
+	// subjects are cleared when there are none
+	media.Subject = nil
+	assert.Equal(t, []string{
+		"-overwrite_original", "-XMP-xmp:Rating=4", "-EXIF:Rating=4",
+		"-XMP-dc:Subject=", "-IPTC:Keywords=",
+		"-XMP-dc:Title=Evening", "-XMP-dc:Description=", "-EXIF:ImageDescription=",
+	}, metadataArgs(media, true))
+}
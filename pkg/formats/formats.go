@@ -0,0 +1,66 @@
+package formats
+
+import (
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// imageExtensions are the image formats indexed as images, besides RAW formats.
+var imageExtensions = []string{".jpg", ".jpeg", ".heic", ".gif", ".png", ".webp", ".avif", ".tif", ".tiff", ".jxl"}
+
+// IsImage returns true if the path is an image or a camera RAW file.
+func IsImage(path string) bool {
+	return slices.Contains(imageExtensions, strings.ToLower(filepath.Ext(path))) || IsRaw(path)
+}
+
+// convertedExtensions are the image formats without a pure Go decoder, which are converted to JPEG with vips before
+// they are decoded.
+var convertedExtensions = []string{".heic", ".avif", ".jxl"}
+
+// IsConverted returns true if the path is an image that is converted to JPEG with vips before it is decoded.
+func IsConverted(path string) bool {
+	return slices.Contains(convertedExtensions, strings.ToLower(filepath.Ext(path)))
+}
+
+// undisplayableExtensions are the image formats, besides RAW formats, that browsers can not display.
+var undisplayableExtensions = []string{".tif", ".tiff", ".jxl"}
+
+// IsDisplayable returns true if browsers can display the original of the image at path.
+func IsDisplayable(path string) bool {
+	return !IsRaw(path) && !slices.Contains(undisplayableExtensions, strings.ToLower(filepath.Ext(path)))
+}
+
+// rawExtensions are the camera RAW formats indexed as images.
+var rawExtensions = []string{".cr2", ".cr3", ".nef", ".arw", ".raf", ".dng", ".orf"}
+
+// IsRaw returns true if the path is a camera RAW file, which browsers can not display and image decoders can not
+// read.
+func IsRaw(path string) bool {
+	return slices.Contains(rawExtensions, strings.ToLower(filepath.Ext(path)))
+}
+
+// videoExtensions are the video formats indexed as media items.
+var videoExtensions = []string{".mp4", ".mov", ".m4v", ".mts", ".m2ts", ".mkv", ".webm", ".avi", ".3gp"}
+
+// IsVideo returns true if the path is a video.
+func IsVideo(path string) bool {
+	return slices.Contains(videoExtensions, strings.ToLower(filepath.Ext(path)))
+}
+
+// liveVideoExtensions are the video formats of the videos of live photos.
+var liveVideoExtensions = []string{".mov", ".mp4"}
+
+// IsLiveVideo returns true if the path is a video format that can be the video of a live photo with the same name.
+func IsLiveVideo(path string) bool {
+	return slices.Contains(liveVideoExtensions, strings.ToLower(filepath.Ext(path)))
+}
+
+// alternateExtensions are the formats that are only stored as alternates of a media item with the same name, as they
+// can not be displayed or decoded.
+var alternateExtensions = []string{".rw2", ".pef", ".srw", ".nrw", ".3fr", ".iiq", ".x3f", ".erf", ".mrw", ".raw", ".rwl", ".sr2", ".srf", ".kdc", ".dcr", ".mos", ".psd"}
+
+// IsAlternateOnly returns true if the path is a format that is only stored as an alternate of a media item.
+func IsAlternateOnly(path string) bool {
+	return slices.Contains(alternateExtensions, strings.ToLower(filepath.Ext(path)))
+}
@@ -1,9 +1,73 @@
 package hash
 
-import "hash/fnv"
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"os"
+)
+
+// sampleSize is the number of bytes read from the start, middle and end of a file when creating a checksum.
+const sampleSize = 64 * 1024
+
+// contentHashFlag is set on every content based hash so that it can never collide with a legacy 32-bit path hash.
+const contentHashFlag = 1 << 52
 
 func GetHash(s string) uint32 {
 	h := fnv.New32a()
 	h.Write([]byte(s))
 	return h.Sum32()
 }
+
+// GetChecksum returns a hex encoded SHA-256 digest of a file's size and sampled content.
+// Files smaller than three samples are hashed in full.
+func GetChecksum(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("error opening file for checksum: %v", err)
+	}
+	defer func() {
+		if err := f.Close(); err != nil {
+			fmt.Printf("file.Close error: %v\n", err)
+		}
+	}()
+
+	info, err := f.Stat()
+	if err != nil {
+		return "", fmt.Errorf("error stating file for checksum: %v", err)
+	}
+	size := info.Size()
+
+	h := sha256.New()
+	if err := binary.Write(h, binary.BigEndian, size); err != nil {
+		return "", fmt.Errorf("error writing checksum size: %v", err)
+	}
+
+	if size <= 3*sampleSize {
+		if _, err := io.Copy(h, f); err != nil {
+			return "", fmt.Errorf("error reading file for checksum: %v", err)
+		}
+	} else {
+		for _, offset := range []int64{0, size/2 - sampleSize/2, size - sampleSize} {
+			if _, err := io.Copy(h, io.NewSectionReader(f, offset, sampleSize)); err != nil {
+				return "", fmt.Errorf("error reading file sample for checksum: %v", err)
+			}
+		}
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// GetContentHash derives a media hash from a checksum. The result fits in 53 bits so it is safe to use as a JavaScript number.
+func GetContentHash(checksum string) uint64 {
+	sum := sha256.Sum256([]byte(checksum))
+	return binary.BigEndian.Uint64(sum[:8])>>12 | contentHashFlag
+}
+
+// GetUniqueHash derives a media hash from a checksum and a relative path. It is used when identical files exist at more than one path.
+func GetUniqueHash(checksum, path string) uint64 {
+	return GetContentHash(checksum + "\x00" + path)
+}
@@ -8,3 +8,35 @@ func TestHash(t *testing.T) {
 		t.Errorf(`Hash("images/221118-N-ON904-1070_52691439736.jpg") = %d; want 1314607733`, ans)
 	}
 }
+
+func TestChecksum(t *testing.T) {
+	a, err := GetChecksum("../../testdata/media/2017/20170624-idaho/20170624-sawtooth-mountain-biking-robbymilo-0030.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := GetChecksum("../../testdata/media/2017/20170624-idaho/20170624-sawtooth-mountain-biking-robbymilo-0030 copy.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a != b {
+		t.Errorf("GetChecksum of identical files = %s, %s; want equal", a, b)
+	}
+
+	other, err := GetChecksum("../../testdata/media/misc/105-1.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a == other {
+		t.Errorf("GetChecksum of different files = %s; want different", a)
+	}
+}
+
+func TestContentHash(t *testing.T) {
+	ans := GetContentHash("abc")
+	if ans < 1<<52 || ans >= 1<<53 {
+		t.Errorf(`GetContentHash("abc") = %d; want a value between 2^52 and 2^53`, ans)
+	}
+	if ans == GetUniqueHash("abc", "a.jpg") {
+		t.Errorf(`GetUniqueHash("abc", "a.jpg") = %d; want a value different from GetContentHash`, ans)
+	}
+}
@@ -47,7 +47,7 @@ func (collector *metricsCollector) Collect(ch chan<- prometheus.Metric) {
 	if err != nil {
 		collector.c.Logger.Error("error getting total media items", "error", err)
 	}
-	totalFolders, err := queries.GetTotalFolders(collector.c)
+	totalFolders, err := queries.GetTotalFolders("", collector.c)
 	if err != nil {
 		collector.c.Logger.Error("error getting total folders", "error", err)
 	}
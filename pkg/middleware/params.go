@@ -114,6 +114,22 @@ func Params(c Conf) func(http.Handler) http.Handler {
 				}
 			}
 
+			// check library
+			library := ""
+			if r.URL.Query().Get("library") != "" {
+				library = r.URL.Query().Get("library")
+			}
+
+			// check container and codec of videos
+			container := r.URL.Query().Get("container")
+			videocodec := r.URL.Query().Get("codec")
+
+			// check audio of videos
+			audio := ""
+			if slices.Contains([]string{"yes", "no"}, r.URL.Query().Get("audio")) {
+				audio = r.URL.Query().Get("audio")
+			}
+
 			params := FilterParams{
 				PageSize:      10,
 				Json:          json,
@@ -129,6 +145,10 @@ func Params(c Conf) func(http.Handler) http.Handler {
 				Subject:       subject,
 				Software:      software,
 				FocalLength35: focallength35,
+				Library:       library,
+				Container:     container,
+				VideoCodec:    videocodec,
+				Audio:         audio,
 			}
 
 			ctx := context.WithValue(r.Context(), ParamsKey{}, params)
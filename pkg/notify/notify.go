@@ -4,11 +4,9 @@ import (
 	"context"
 	"encoding/json"
 	"sync"
-	"time"
 
 	"github.com/coder/websocket"
-	"zombiezen.com/go/sqlite"
-	"zombiezen.com/go/sqlite/sqlitex"
+	"github.com/robbymilo/rgallery/pkg/types"
 )
 
 var (
@@ -24,13 +22,7 @@ type Notice struct {
 	Status  string `json:"status"`
 }
 
-type Notification struct {
-	ID        int64  `json:"id"`
-	Username  string `json:"username,omitempty"`
-	Message   string `json:"message"`
-	IsRead    bool   `json:"is_read"`
-	CreatedAt string `json:"created_at"`
-}
+type Notification = types.Notification
 
 // Function to add a subscriber (client waiting for an event)
 func AddSubscriber() chan string {
@@ -81,44 +73,8 @@ func RemoveWebSocketConnection(conn *websocket.Conn) {
 	wsMu.Unlock()
 }
 
-// SendToAllUsers sends a notification to all users
-func SendToAllUsers(conn *sqlite.Conn, message string) error {
-	// Get all users
-	var usernames []string
-	err := sqlitex.Execute(conn, "SELECT username FROM users", &sqlitex.ExecOptions{
-		ResultFunc: func(stmt *sqlite.Stmt) error {
-			usernames = append(usernames, stmt.ColumnText(0))
-			return nil
-		},
-	})
-	if err != nil {
-		return err
-	}
-
-	createdAt := time.Now().UTC().Format("2006-01-02T15:04:05Z")
-	stmt, err := conn.Prepare("INSERT INTO notifications (message, created_at) VALUES (?, ?)")
-	if err != nil {
-		return err
-	}
-	stmt.BindText(1, message)
-	stmt.BindText(2, createdAt)
-	_, err = stmt.Step()
-	if err != nil {
-		return err
-	}
-	err = stmt.Finalize()
-	if err != nil {
-		return err
-	}
-	id := conn.LastInsertRowID()
-
-	// Broadcast to all connected websocket clients
-	payload := Notification{
-		ID:        id,
-		Message:   message,
-		IsRead:    false,
-		CreatedAt: createdAt,
-	}
+// SendToUsers broadcasts a stored notification to the websocket connections of the given users
+func SendToUsers(usernames []string, payload Notification) error {
 	payloadJSON, err := json.Marshal(payload)
 	if err != nil {
 		return err
@@ -140,3 +96,23 @@ func SendToAllUsers(conn *sqlite.Conn, message string) error {
 
 	return nil
 }
+
+// Broadcast sends a message that is not stored, such as progress, to every websocket connection
+func Broadcast(payload any) error {
+	payloadJSON, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	wsMu.Lock()
+	defer wsMu.Unlock()
+
+	for conn := range connToUser {
+		go func(c *websocket.Conn) {
+			ctx := context.Background()
+			_ = c.Write(ctx, websocket.MessageText, payloadJSON)
+		}(conn)
+	}
+
+	return nil
+}
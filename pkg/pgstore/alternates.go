@@ -0,0 +1,79 @@
+package pgstore
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/robbymilo/rgallery/pkg/types"
+)
+
+// GetAlternates returns the alternates of a media item, ordered by path.
+func (s *Store) GetAlternates(hash uint64) ([]types.Alternate, error) {
+	rows, err := s.pool.Query(context.Background(), "SELECT hash, path, folder, size, modified, library FROM alternates WHERE hash = $1 ORDER BY path", int64(hash))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	alternates := []types.Alternate{}
+	for rows.Next() {
+		var alternate types.Alternate
+		var h int64
+		var modified string
+		if err := rows.Scan(&h, &alternate.Path, &alternate.Folder, &alternate.Size, &modified, &alternate.Library); err != nil {
+			return nil, err
+		}
+
+		alternate.Hash = uint64(h)
+		alternate.Modified, err = time.Parse("2006-01-02T15:04:05.000Z", modified)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing alternate modified time: %v", err)
+		}
+
+		alternates = append(alternates, alternate)
+	}
+
+	return alternates, rows.Err()
+}
+
+// SetAlternates replaces the alternates in folders of a library, or every alternate of the library if folders is nil,
+// and removes alternates of media items that no longer exist.
+func (s *Store) SetAlternates(library string, folders []string, alternates []types.Alternate) error {
+	ctx := context.Background()
+
+	return pgx.BeginFunc(ctx, s.pool, func(tx pgx.Tx) error {
+		var err error
+		if folders == nil {
+			_, err = tx.Exec(ctx, "DELETE FROM alternates WHERE library = $1", library)
+		} else {
+			_, err = tx.Exec(ctx, "DELETE FROM alternates WHERE library = $1 AND folder = ANY($2)", library, folders)
+		}
+		if err != nil {
+			return fmt.Errorf("error deleting alternates: %v", err)
+		}
+
+		for _, alternate := range alternates {
+			_, err = tx.Exec(ctx, `INSERT INTO alternates (library, path, hash, folder, size, modified) VALUES ($1, $2, $3, $4, $5, $6)
+				ON CONFLICT (library, path) DO UPDATE SET hash = EXCLUDED.hash, folder = EXCLUDED.folder, size = EXCLUDED.size, modified = EXCLUDED.modified`,
+				library,
+				alternate.Path,
+				int64(alternate.Hash),
+				alternate.Folder,
+				alternate.Size,
+				alternate.Modified.UTC().Format("2006-01-02T15:04:05.000Z"),
+			)
+			if err != nil {
+				return fmt.Errorf("error inserting alternate: %v", err)
+			}
+		}
+
+		_, err = tx.Exec(ctx, "DELETE FROM alternates WHERE hash NOT IN (SELECT hash FROM media)")
+		if err != nil {
+			return fmt.Errorf("error deleting alternates: %v", err)
+		}
+
+		return nil
+	})
+}
@@ -0,0 +1,120 @@
+package pgstore
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/robbymilo/rgallery/pkg/types"
+)
+
+// GetFolder returns the media items where the group column equals name, in a library or in every library if library
+// is empty.
+func (s *Store) GetFolder(group, name, library string, pageSize, offset int, direction string) ([]DatabaseMedia, error) {
+	query := fmt.Sprintf(`SELECT * FROM (SELECT DISTINCT ON (date) %s FROM media WHERE %s = $1 AND ($2 = '' OR library = $2) AND date != '0001-01-01T00:00:00.000Z' ORDER BY date, hash) m ORDER BY date %s LIMIT $3 OFFSET $4`, columns, group, direction)
+
+	rows, err := s.pool.Query(context.Background(), query, name, library, pageSize, offset)
+	if err != nil {
+		return nil, fmt.Errorf("error querying folder: %v", err)
+	}
+
+	return scanMediaRows(rows)
+}
+
+// GetTotalOfFolder returns the number of media items where the group column equals name, in a library or in every
+// library if library is empty.
+func (s *Store) GetTotalOfFolder(group, name, library string) (int, error) {
+	query := fmt.Sprintf(`SELECT COUNT(DISTINCT date) FROM media WHERE %s = $1 AND ($2 = '' OR library = $2) AND date != $3`, group)
+
+	return s.count(query, name, library, "0001-01-01T00:00:00.000Z")
+}
+
+// GetFolders returns folders with their five most recent media items, in a library or in every library if library is
+// empty. Folders of other libraries are left out.
+func (s *Store) GetFolders(direction, library string, pageSize, offset int) ([]types.Directory, error) {
+	query := fmt.Sprintf(`
+      WITH FolderImageCounts AS (
+        SELECT
+          folder,
+          COUNT(*) as total_images
+        FROM media
+        WHERE $3 = '' OR library = $3
+        GROUP BY folder
+      ), RankedMedia AS (
+        SELECT
+          m.folder, m.hash, m.path, m.width, m.height, m.color, m.date,
+          ROW_NUMBER() OVER (PARTITION BY m.folder ORDER BY m.date DESC) as row_num
+        FROM media m
+        WHERE $3 = '' OR m.library = $3
+      )
+      SELECT
+          f.id,
+          f.key,
+          COALESCE(array_agg(rm.hash ORDER BY rm.row_num) FILTER (WHERE rm.hash IS NOT NULL), '{}') as hashes,
+          COALESCE(array_agg(COALESCE(rm.path, '') ORDER BY rm.row_num) FILTER (WHERE rm.hash IS NOT NULL), '{}') as paths,
+          COALESCE(array_agg(COALESCE(rm.width, 0) ORDER BY rm.row_num) FILTER (WHERE rm.hash IS NOT NULL), '{}') as widths,
+          COALESCE(array_agg(COALESCE(rm.height, 0) ORDER BY rm.row_num) FILTER (WHERE rm.hash IS NOT NULL), '{}') as heights,
+          COALESCE(array_agg(COALESCE(rm.color, '') ORDER BY rm.row_num) FILTER (WHERE rm.hash IS NOT NULL), '{}') as colors,
+          COALESCE(fic.total_images, 0) as image_count
+      FROM folders f
+      LEFT JOIN FolderImageCounts fic ON f.key = fic.folder
+      LEFT JOIN RankedMedia rm ON f.key = rm.folder AND rm.row_num <= 5
+      WHERE $3 = '' OR fic.folder IS NOT NULL
+      GROUP BY f.id, f.key, COALESCE(fic.total_images, 0)
+      ORDER BY f.key COLLATE "C" %s
+      LIMIT $1 OFFSET $2`, direction)
+
+	rows, err := s.pool.Query(context.Background(), query, pageSize, offset, library)
+	if err != nil {
+		return nil, fmt.Errorf("error querying folders: %v", err)
+	}
+	defer rows.Close()
+
+	var dirs []types.Directory
+	for rows.Next() {
+		var (
+			id             int64
+			key            string
+			hashes         []int64
+			paths, colors  []string
+			widths, height []int32
+			imageCount     int64
+		)
+		if err := rows.Scan(&id, &key, &hashes, &paths, &widths, &height, &colors, &imageCount); err != nil {
+			return nil, fmt.Errorf("error scanning folder: %v", err)
+		}
+
+		var mediaList []types.FolderMedia
+		for i := 0; i < len(hashes) && i < 5; i++ {
+			mediaList = append(mediaList, types.FolderMedia{
+				Hash:   uint64(hashes[i]),
+				Path:   paths[i],
+				Width:  int(widths[i]),
+				Height: int(height[i]),
+				Color:  colors[i],
+			})
+		}
+
+		dir := types.Directory{
+			Id:         int(id),
+			Key:        key,
+			Media:      nil,
+			ImageCount: int(imageCount),
+		}
+
+		if len(mediaList) > 0 {
+			dir.Media = &mediaList
+		}
+
+		dirs = append(dirs, dir)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error stepping through result set: %v", err)
+	}
+
+	return dirs, nil
+}
+
+// GetTotalFolders returns the number of folders in a library, or in every library if library is empty.
+func (s *Store) GetTotalFolders(library string) (int, error) {
+	return s.count(`SELECT COUNT(*) FROM folders WHERE $1 = '' OR key IN (SELECT folder FROM media WHERE library = $1)`, library)
+}
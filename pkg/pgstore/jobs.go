@@ -0,0 +1,115 @@
+package pgstore
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/robbymilo/rgallery/pkg/types"
+)
+
+const jobColumns = "id, type, scope, status, total, processed, failed, cursor, error, created_at, started_at, finished_at, owner, heartbeat"
+
+// AddJob stores a job and returns its id.
+func (s *Store) AddJob(job types.Job) (int64, error) {
+	var id int64
+	err := s.pool.QueryRow(context.Background(), "INSERT INTO jobs (type, scope, status, cursor, created_at) VALUES ($1, $2, $3, $4, $5) RETURNING id", job.Type, job.Scope, job.Status, job.Cursor, job.CreatedAt).Scan(&id)
+	if err != nil {
+		return 0, err
+	}
+
+	return id, nil
+}
+
+// UpdateJob saves the status, progress, cursor, error and timestamps of a job.
+func (s *Store) UpdateJob(job types.Job) error {
+	_, err := s.pool.Exec(context.Background(), "UPDATE jobs SET status = $1, total = $2, processed = $3, failed = $4, cursor = $5, error = $6, started_at = $7, finished_at = $8 WHERE id = $9",
+		job.Status, job.Total, job.Processed, job.Failed, job.Cursor, job.Error, job.StartedAt, job.FinishedAt, job.ID)
+	return err
+}
+
+// StartJob marks a queued job as running by owner, returning false if it is no longer queued.
+func (s *Store) StartJob(id int64, owner, startedAt string) (bool, error) {
+	tag, err := s.pool.Exec(context.Background(), "UPDATE jobs SET status = 'running', started_at = $1, owner = $2, heartbeat = $1 WHERE id = $3 AND status = 'queued'", startedAt, owner, id)
+	if err != nil {
+		return false, err
+	}
+
+	return tag.RowsAffected() > 0, nil
+}
+
+// CancelQueuedJob marks a queued job as canceled, returning false if it is no longer queued.
+func (s *Store) CancelQueuedJob(id int64, finishedAt string) (bool, error) {
+	tag, err := s.pool.Exec(context.Background(), "UPDATE jobs SET status = 'canceled', finished_at = $1 WHERE id = $2 AND status = 'queued'", finishedAt, id)
+	if err != nil {
+		return false, err
+	}
+
+	return tag.RowsAffected() > 0, nil
+}
+
+// HeartbeatJobs records that the running jobs of owner are still running.
+func (s *Store) HeartbeatJobs(owner, at string) error {
+	_, err := s.pool.Exec(context.Background(), "UPDATE jobs SET heartbeat = $1 WHERE owner = $2 AND status = 'running'", at, owner)
+	return err
+}
+
+// RequeueStaleJobs queues the running jobs whose last heartbeat is before a time again, returning them.
+func (s *Store) RequeueStaleJobs(before string) ([]types.Job, error) {
+	return s.getJobs("UPDATE jobs SET status = 'queued', total = processed, owner = '' WHERE status = 'running' AND heartbeat < $1 RETURNING "+jobColumns, before)
+}
+
+// GetJob returns a job, or types.ErrJobNotFound.
+func (s *Store) GetJob(id int64) (types.Job, error) {
+	jobs, err := s.getJobs("SELECT "+jobColumns+" FROM jobs WHERE id = $1", id)
+	if err != nil {
+		return types.Job{}, err
+	}
+	if len(jobs) == 0 {
+		return types.Job{}, types.ErrJobNotFound
+	}
+
+	return jobs[0], nil
+}
+
+// GetJobs returns the most recent jobs, newest first.
+func (s *Store) GetJobs(limit int) ([]types.Job, error) {
+	return s.getJobs("SELECT "+jobColumns+" FROM jobs ORDER BY id DESC LIMIT $1", limit)
+}
+
+// GetJobsWithStatus returns the jobs with a status, oldest first.
+func (s *Store) GetJobsWithStatus(status string) ([]types.Job, error) {
+	return s.getJobs("SELECT "+jobColumns+" FROM jobs WHERE status = $1 ORDER BY id ASC", status)
+}
+
+// GetLastJob returns the most recently started job of a type and scope, or types.ErrJobNotFound.
+func (s *Store) GetLastJob(jobType, scope string) (types.Job, error) {
+	jobs, err := s.getJobs("SELECT "+jobColumns+" FROM jobs WHERE type = $1 AND scope = $2 AND started_at != '' ORDER BY id DESC LIMIT 1", jobType, scope)
+	if err != nil {
+		return types.Job{}, err
+	}
+	if len(jobs) == 0 {
+		return types.Job{}, types.ErrJobNotFound
+	}
+
+	return jobs[0], nil
+}
+
+func (s *Store) getJobs(query string, args ...any) ([]types.Job, error) {
+	rows, err := s.pool.Query(context.Background(), query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error querying jobs: %w", err)
+	}
+
+	defer rows.Close()
+
+	jobs := []types.Job{}
+	for rows.Next() {
+		var j types.Job
+		if err := rows.Scan(&j.ID, &j.Type, &j.Scope, &j.Status, &j.Total, &j.Processed, &j.Failed, &j.Cursor, &j.Error, &j.CreatedAt, &j.StartedAt, &j.FinishedAt, &j.Owner, &j.Heartbeat); err != nil {
+			return nil, fmt.Errorf("error scanning job: %w", err)
+		}
+		jobs = append(jobs, j)
+	}
+
+	return jobs, rows.Err()
+}
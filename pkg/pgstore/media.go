@@ -0,0 +1,141 @@
+package pgstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/robbymilo/rgallery/pkg/types"
+)
+
+// GetSingleMediaItem returns a media item by hash, or an empty item if it does not exist.
+func (s *Store) GetSingleMediaItem(hash uint64) (DatabaseMedia, error) {
+	query := fmt.Sprintf(`SELECT %s FROM media WHERE hash = $1`, columns)
+
+	media, err := scanMediaRow(s.pool.QueryRow(context.Background(), query, int64(hash)))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return DatabaseMedia{}, nil
+	}
+	if err != nil {
+		return DatabaseMedia{}, fmt.Errorf("error querying media item: %v", err)
+	}
+
+	return media, nil
+}
+
+// GetMediaItems returns all media items for use in scanning.
+func (s *Store) GetMediaItems(offset int, direction string, total int) ([]DatabaseMedia, error) {
+	query := fmt.Sprintf(`SELECT %s FROM media WHERE date != '0001-01-01T00:00:00.000Z' ORDER BY date %s LIMIT $1 OFFSET $2`, columns, direction)
+
+	rows, err := s.pool.Query(context.Background(), query, total, offset)
+	if err != nil {
+		return nil, fmt.Errorf("error querying media items: %v", err)
+	}
+
+	return scanMediaRows(rows)
+}
+
+// GetTotalMediaItems returns the number of media items.
+func (s *Store) GetTotalMediaItems(rating int, from, to string) (int, error) {
+	query := `SELECT COUNT(*) FROM media WHERE rating >= $1 AND date >= $2 AND date <= $3 AND date != '0001-01-01T00:00:00.000Z'`
+
+	return s.count(query, rating, from, to)
+}
+
+// GetFavorites returns media items with at least the given rating.
+func (s *Store) GetFavorites(pageSize, offset, rating int, direction string) ([]DatabaseMedia, error) {
+	query := fmt.Sprintf(`SELECT * FROM (SELECT DISTINCT ON (date) %s FROM media WHERE rating >= $1 AND date != '0001-01-01T00:00:00.000Z' ORDER BY date, hash) m ORDER BY date %s LIMIT $2 OFFSET $3`, columns, direction)
+
+	rows, err := s.pool.Query(context.Background(), query, rating, pageSize, offset)
+	if err != nil {
+		return nil, fmt.Errorf("error querying favorites: %v", err)
+	}
+
+	return scanMediaRows(rows)
+}
+
+// GetTotalFavorites returns the number of media items with at least the given rating.
+func (s *Store) GetTotalFavorites(rating int) (int, error) {
+	return s.count(`SELECT COUNT(DISTINCT date) FROM media WHERE rating >= $1 AND date != $2`, rating, "0001-01-01T00:00:00.000Z")
+}
+
+// GetMemories returns media items that occurred on any of the given days, formatted as YYYY-MM-DD.
+func (s *Store) GetMemories(days []string) ([]DatabaseMedia, error) {
+	if len(days) == 0 {
+		return nil, nil
+	}
+
+	query := fmt.Sprintf(`SELECT %s FROM media WHERE substr(date, 1, 10) = ANY($1)`, columns)
+
+	rows, err := s.pool.Query(context.Background(), query, days)
+	if err != nil {
+		return nil, fmt.Errorf("error querying memories: %v", err)
+	}
+
+	return scanMediaRows(rows)
+}
+
+// GetMapItems returns all media items' coordinates.
+func (s *Store) GetMapItems() ([]types.MapItem, error) {
+	query := `
+			SELECT DISTINCT ON (date) hash, latitude, longitude
+			FROM media
+			WHERE latitude != 0.0
+				AND longitude != 0.0
+				AND date != '0001-01-01T00:00:00.000Z'
+			ORDER BY date, hash`
+
+	rows, err := s.pool.Query(context.Background(), query)
+	if err != nil {
+		return nil, fmt.Errorf("error querying map items: %v", err)
+	}
+	defer rows.Close()
+
+	var mapItems []types.MapItem
+	for rows.Next() {
+		var hash int64
+		var latitude, longitude float64
+		if err := rows.Scan(&hash, &latitude, &longitude); err != nil {
+			return nil, fmt.Errorf("error scanning map item: %v", err)
+		}
+
+		// the hash is sent as its signed value, matching the float read from sqlite
+		mapItems = append(mapItems, types.MapItem{latitude, longitude, float64(hash)})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error stepping through query results: %v", err)
+	}
+
+	return mapItems, nil
+}
+
+// GetGear returns the number of media items for each value of a gear column, most used first.
+func (s *Store) GetGear(column string) (types.GearItems, error) {
+	query := fmt.Sprintf(
+		`SELECT COALESCE(%s::TEXT, ''), COUNT(%s) AS total
+			 FROM media
+			 WHERE date != $1
+			 GROUP BY %s
+			 ORDER BY total DESC, %s`, column, column, column, column)
+
+	rows, err := s.pool.Query(context.Background(), query, "0001-01-01T00:00:00.000Z")
+	if err != nil {
+		return nil, fmt.Errorf("error querying gear: %v", err)
+	}
+	defer rows.Close()
+
+	var gear types.GearItems
+	for rows.Next() {
+		var item types.GearItem
+		if err := rows.Scan(&item.Name, &item.Total); err != nil {
+			return nil, fmt.Errorf("error scanning gear: %v", err)
+		}
+		gear = append(gear, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error stepping through query results: %v", err)
+	}
+
+	return gear, nil
+}
@@ -0,0 +1,446 @@
+package pgstore
+
+import (
+	"context"
+	_ "embed"
+	"fmt"
+	"strconv"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/robbymilo/rgallery/pkg/database"
+)
+
+// schema is the schema of a new database. Existing databases are only changed by migrations, so a change to
+// schema.sql needs a migration making the same change.
+//
+//go:embed schema.sql
+var schema string
+
+// migration is a numbered change to the database that is applied once, in order, inside a transaction.
+type migration struct {
+	version     int
+	description string
+	up          func(tx pgx.Tx) error
+}
+
+// migrations are applied in order of their version, which continues from the sqlite migration versions.
+// Never change or remove a migration once it is released, add a new one instead.
+var migrations = []migration{
+	{
+		version:     20261017,
+		description: "create postgres schema",
+		up: func(tx pgx.Tx) error {
+			_, err := tx.Exec(context.Background(), createInitialSchema)
+			return err
+		},
+	},
+	{
+		version:     20261018,
+		description: "add jobs table",
+		up: func(tx pgx.Tx) error {
+			_, err := tx.Exec(context.Background(), createJobsTable)
+			return err
+		},
+	},
+	{
+		version:     20261019,
+		description: "add alternates table",
+		up: func(tx pgx.Tx) error {
+			_, err := tx.Exec(context.Background(), createAlternatesTable)
+			return err
+		},
+	},
+	{
+		version:     20261020,
+		description: "add media columns 'motion_offset' and 'motion_length'",
+		up: func(tx pgx.Tx) error {
+			_, err := tx.Exec(context.Background(), `ALTER TABLE media ADD COLUMN IF NOT EXISTS motion_offset BIGINT DEFAULT 0, ADD COLUMN IF NOT EXISTS motion_length BIGINT DEFAULT 0`)
+			return err
+		},
+	},
+	{
+		version:     20261021,
+		description: "add media column 'phash'",
+		up: func(tx pgx.Tx) error {
+			_, err := tx.Exec(context.Background(), `ALTER TABLE media ADD COLUMN IF NOT EXISTS phash BIGINT DEFAULT 0`)
+			return err
+		},
+	},
+	{
+		version:     20261022,
+		description: "add library columns",
+		up: func(tx pgx.Tx) error {
+			_, err := tx.Exec(context.Background(), addLibraryColumns)
+			return err
+		},
+	},
+	{
+		version:     20261023,
+		description: "add media columns of video streams",
+		up: func(tx pgx.Tx) error {
+			_, err := tx.Exec(context.Background(), addVideoColumns)
+			return err
+		},
+	},
+	{
+		version:     20261024,
+		description: "add jobs columns 'owner' and 'heartbeat'",
+		up: func(tx pgx.Tx) error {
+			_, err := tx.Exec(context.Background(), `ALTER TABLE jobs ADD COLUMN IF NOT EXISTS owner TEXT DEFAULT '', ADD COLUMN IF NOT EXISTS heartbeat TEXT DEFAULT ''`)
+			return err
+		},
+	},
+	{
+		version:     20261025,
+		description: "mark media items without a perceptual hash with a NULL 'phash'",
+		up: func(tx pgx.Tx) error {
+			// 0 was stored for videos and for images scanned before perceptual hashes, and is also the hash of a
+			// uniform image, which gets its hash back with the next metadata scan
+			_, err := tx.Exec(context.Background(), `ALTER TABLE media ALTER COLUMN phash DROP DEFAULT;
+UPDATE media SET phash = NULL WHERE phash = 0`)
+			return err
+		},
+	},
+}
+
+// createInitialSchema is the schema of the first release with the postgres backend. Later changes are made by the
+// migrations that follow it.
+const createInitialSchema = `CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+CREATE TABLE
+  IF NOT EXISTS media (
+    "hash" BIGINT NOT NULL PRIMARY KEY,
+    "path" TEXT,
+    "subject" TEXT,
+    "width" INTEGER,
+    "height" INTEGER,
+    "ratio" DOUBLE PRECISION,
+    "padding" DOUBLE PRECISION,
+    "date" TEXT,
+    "modified" TEXT,
+    "folder" TEXT,
+    "rating" DOUBLE PRECISION,
+    shutterspeed TEXT DEFAULT '',
+    aperture DOUBLE PRECISION DEFAULT 0,
+    iso DOUBLE PRECISION DEFAULT 0,
+    lens TEXT DEFAULT '',
+    camera TEXT DEFAULT '',
+    focallength DOUBLE PRECISION DEFAULT 0,
+    altitude DOUBLE PRECISION DEFAULT 0,
+    latitude DOUBLE PRECISION DEFAULT 0,
+    longitude DOUBLE PRECISION DEFAULT 0,
+    mediatype TEXT DEFAULT '',
+    focusdistance DOUBLE PRECISION DEFAULT 0,
+    focallength35 DOUBLE PRECISION DEFAULT 0,
+    color TEXT DEFAULT '',
+    location TEXT DEFAULT '',
+    description TEXT DEFAULT '',
+    title TEXT DEFAULT '',
+    software TEXT DEFAULT '',
+    "offset" DOUBLE PRECISION DEFAULT 0,
+    rotation DOUBLE PRECISION DEFAULT 0,
+    "created" TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    checksum TEXT DEFAULT '',
+    size BIGINT DEFAULT 0,
+    -- text searched with trigram matching in place of the sqlite images_virtual table
+    search TEXT GENERATED ALWAYS AS (
+      "hash"::TEXT || ' ' || COALESCE("path", '') || ' ' || COALESCE("subject", '') || ' ' || COALESCE("date", '') || ' ' || COALESCE("modified", '') || ' ' || COALESCE("folder", '') || ' ' || COALESCE(shutterspeed, '') || ' ' || COALESCE(lens, '') || ' ' || COALESCE(camera, '') || ' ' || COALESCE(mediatype, '') || ' ' || COALESCE(color, '') || ' ' || COALESCE(location, '') || ' ' || COALESCE(description, '') || ' ' || COALESCE(title, '') || ' ' || COALESCE(software, '')
+    ) STORED
+  );
+
+CREATE TABLE
+  IF NOT EXISTS tags (
+    "id" BIGINT NOT NULL PRIMARY KEY,
+    "key" TEXT,
+    "value" TEXT,
+    "created" TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+  );
+
+CREATE TABLE
+  IF NOT EXISTS folders (
+    "id" BIGINT NOT NULL PRIMARY KEY,
+    "key" TEXT UNIQUE,
+    "created" TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+  );
+
+-- like the sqlite schema, relationships are maintained by the scanner so that
+-- media items can be deleted and inserted again in any order
+CREATE TABLE
+  IF NOT EXISTS images_tags (
+    "id" BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    "image_id" BIGINT,
+    "tag_id" BIGINT,
+    "created" TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+  );
+
+CREATE TABLE
+  IF NOT EXISTS users (
+    "username" TEXT NOT NULL PRIMARY KEY,
+    "password" TEXT,
+    "role" TEXT,
+    "created" TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+  );
+
+CREATE TABLE
+  IF NOT EXISTS keys (
+    "name" TEXT NOT NULL PRIMARY KEY,
+    "key" TEXT,
+    "created" TEXT
+  );
+
+CREATE TABLE
+  IF NOT EXISTS scan_errors (
+    "path" TEXT PRIMARY KEY,
+    "modified" TEXT,
+    "error" TEXT,
+    "created" TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+  );
+
+CREATE TABLE
+  IF NOT EXISTS notifications (
+    id BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    message TEXT NOT NULL,
+    created_at TEXT
+  );
+
+CREATE TABLE
+  IF NOT EXISTS notifications_dismissed (
+    id BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    notification_id BIGINT NOT NULL REFERENCES notifications (id) ON DELETE CASCADE,
+    username TEXT NOT NULL,
+    dismissed BOOLEAN DEFAULT FALSE,
+    dismissed_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (notification_id, username)
+  );
+
+CREATE INDEX IF NOT EXISTS idx_media_folder_date ON media (folder, date DESC);
+
+CREATE INDEX IF NOT EXISTS idx_media_folder ON media (folder);
+
+CREATE INDEX IF NOT EXISTS idx_media_date ON media (date);
+
+CREATE INDEX IF NOT EXISTS idx_images_tags_image_id ON images_tags (image_id);
+
+CREATE INDEX IF NOT EXISTS idx_images_tags_tag_id ON images_tags (tag_id);
+
+CREATE INDEX IF NOT EXISTS idx_media_search_trgm ON media USING GIN (search gin_trgm_ops)`
+
+// createJobsTable adds the jobs table to databases created before it was part of the schema.
+const createJobsTable = `CREATE TABLE IF NOT EXISTS jobs (
+  id BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+  type TEXT NOT NULL,
+  scope TEXT DEFAULT '',
+  status TEXT NOT NULL,
+  total INTEGER DEFAULT 0,
+  processed INTEGER DEFAULT 0,
+  failed INTEGER DEFAULT 0,
+  cursor TEXT DEFAULT '',
+  error TEXT DEFAULT '',
+  created_at TEXT,
+  started_at TEXT DEFAULT '',
+  finished_at TEXT DEFAULT ''
+);
+CREATE INDEX IF NOT EXISTS idx_jobs_status ON jobs (status)`
+
+// createAlternatesTable adds the alternates table to databases created before it was part of the schema.
+const createAlternatesTable = `CREATE TABLE IF NOT EXISTS alternates (
+  path TEXT PRIMARY KEY,
+  hash BIGINT NOT NULL,
+  folder TEXT NOT NULL,
+  size BIGINT DEFAULT 0,
+  modified TEXT DEFAULT ''
+);
+CREATE INDEX IF NOT EXISTS idx_alternates_hash ON alternates (hash);
+CREATE INDEX IF NOT EXISTS idx_alternates_folder ON alternates (folder)`
+
+// addLibraryColumns tags existing media items, alternates and scan errors with the default library, and keys
+// alternates and scan errors by library and path so the same path can be used in more than one library.
+const addLibraryColumns = `ALTER TABLE media ADD COLUMN IF NOT EXISTS library TEXT NOT NULL DEFAULT 'default';
+CREATE INDEX IF NOT EXISTS idx_media_library ON media (library);
+ALTER TABLE alternates ADD COLUMN IF NOT EXISTS library TEXT NOT NULL DEFAULT 'default';
+ALTER TABLE alternates DROP CONSTRAINT IF EXISTS alternates_pkey;
+ALTER TABLE alternates ADD PRIMARY KEY (library, path);
+ALTER TABLE scan_errors ADD COLUMN IF NOT EXISTS library TEXT NOT NULL DEFAULT 'default';
+ALTER TABLE scan_errors DROP CONSTRAINT IF EXISTS scan_errors_pkey;
+ALTER TABLE scan_errors ADD PRIMARY KEY (library, path)`
+
+// addVideoColumns adds the media columns of the container and streams of videos read by ffprobe.
+const addVideoColumns = `ALTER TABLE media ADD COLUMN IF NOT EXISTS container TEXT DEFAULT '';
+ALTER TABLE media ADD COLUMN IF NOT EXISTS video_codec TEXT DEFAULT '';
+ALTER TABLE media ADD COLUMN IF NOT EXISTS audio_codec TEXT DEFAULT '';
+ALTER TABLE media ADD COLUMN IF NOT EXISTS duration DOUBLE PRECISION DEFAULT 0;
+ALTER TABLE media ADD COLUMN IF NOT EXISTS frame_rate DOUBLE PRECISION DEFAULT 0;
+ALTER TABLE media ADD COLUMN IF NOT EXISTS bitrate BIGINT DEFAULT 0;
+ALTER TABLE media ADD COLUMN IF NOT EXISTS has_audio BOOLEAN DEFAULT FALSE`
+
+const createSchemaTable = `CREATE TABLE IF NOT EXISTS schema (
+  "key" TEXT PRIMARY KEY,
+  "value" INTEGER DEFAULT 0,
+  "created" TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+)`
+
+// Migrate applies all pending migrations, or only lists them if dryRun is set.
+// It returns the migrations that were, or would be, applied.
+func Migrate(c Conf, dryRun bool) ([]database.Migration, error) {
+	pool, err := connect(c)
+	if err != nil {
+		return nil, err
+	}
+	defer pool.Close()
+
+	ctx := context.Background()
+
+	if !dryRun {
+		if _, err := pool.Exec(ctx, createSchemaTable); err != nil {
+			return nil, fmt.Errorf("failed to create schema table: %w", err)
+		}
+	}
+
+	if !dryRun {
+		created, err := createSchema(ctx, pool, c)
+		if err != nil {
+			return nil, err
+		}
+		if created {
+			return nil, nil
+		}
+	}
+
+	status, err := getMigrations(ctx, pool)
+	if err != nil {
+		return nil, err
+	}
+
+	var pending []database.Migration
+	for i, m := range status {
+		if m.Applied {
+			continue
+		}
+		pending = append(pending, m)
+
+		if dryRun {
+			continue
+		}
+
+		c.Logger.Info("applying migration", "version", m.Version, "description", m.Description)
+
+		err := pgx.BeginFunc(ctx, pool, func(tx pgx.Tx) error {
+			if err := migrations[i].up(tx); err != nil {
+				return fmt.Errorf("failed to apply migration %d: %w", m.Version, err)
+			}
+			return setSchemaVersion(ctx, tx, m.Version)
+		})
+		if err != nil {
+			return pending[:len(pending)-1], err
+		}
+
+		c.Logger.Info("applied migration", "version", m.Version)
+	}
+
+	return pending, nil
+}
+
+// createSchema applies the current schema to a new database, recording every migration as applied. It reports
+// whether the database was new.
+func createSchema(ctx context.Context, pool *pgxpool.Pool, c Conf) (bool, error) {
+	var exists bool
+	err := pool.QueryRow(ctx, `SELECT to_regclass('media') IS NOT NULL`).Scan(&exists)
+	if err != nil {
+		return false, fmt.Errorf("failed to check for media table: %w", err)
+	}
+	if exists {
+		return false, nil
+	}
+
+	c.Logger.Info("applying postgres schema")
+
+	err = pgx.BeginFunc(ctx, pool, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, schema); err != nil {
+			return fmt.Errorf("failed to apply schema: %w", err)
+		}
+		for _, m := range migrations {
+			if err := setSchemaVersion(ctx, tx, m.version); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+
+	c.Logger.Info("postgres schema applied")
+
+	return true, nil
+}
+
+// GetMigrations returns every known migration and whether it has been applied.
+func GetMigrations(c Conf) ([]database.Migration, error) {
+	pool, err := connect(c)
+	if err != nil {
+		return nil, err
+	}
+	defer pool.Close()
+
+	return getMigrations(context.Background(), pool)
+}
+
+func getMigrations(ctx context.Context, q interface {
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+}) ([]database.Migration, error) {
+	status := make([]database.Migration, len(migrations))
+	for i, m := range migrations {
+		status[i] = database.Migration{Version: m.version, Description: m.description}
+	}
+
+	var exists bool
+	err := q.QueryRow(ctx, `SELECT to_regclass('schema') IS NOT NULL`).Scan(&exists)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check for schema table: %w", err)
+	}
+	if !exists {
+		return status, nil
+	}
+
+	rows, err := q.Query(ctx, `SELECT key, to_char(created AT TIME ZONE 'UTC', 'YYYY-MM-DD HH24:MI:SS') FROM schema WHERE key LIKE 'migration_%'`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query migrations: %w", err)
+	}
+	defer rows.Close()
+
+	appliedAt := make(map[string]string)
+	for rows.Next() {
+		var key, created string
+		if err := rows.Scan(&key, &created); err != nil {
+			return nil, fmt.Errorf("failed to scan migration row: %w", err)
+		}
+		appliedAt[key] = created
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read migrations: %w", err)
+	}
+
+	for i := range status {
+		status[i].AppliedAt, status[i].Applied = appliedAt[migrationKey(status[i].Version)]
+	}
+
+	return status, nil
+}
+
+func migrationKey(version int) string {
+	return "migration_" + strconv.Itoa(version)
+}
+
+// setSchemaVersion records a migration and updates the schema version in the schema table
+func setSchemaVersion(ctx context.Context, tx pgx.Tx, version int) error {
+	for _, key := range []string{"version", migrationKey(version)} {
+		_, err := tx.Exec(ctx, `INSERT INTO schema(key, value) VALUES ($1, $2) ON CONFLICT (key) DO UPDATE SET value = EXCLUDED.value, created = CURRENT_TIMESTAMP`, key, version)
+		if err != nil {
+			return fmt.Errorf("failed to set schema version: %w", err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,59 @@
+package pgstore
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/robbymilo/rgallery/pkg/types"
+)
+
+// AddNotification stores a notification and returns its id.
+func (s *Store) AddNotification(message, createdAt string) (int64, error) {
+	var id int64
+	err := s.pool.QueryRow(context.Background(), "INSERT INTO notifications (message, created_at) VALUES ($1, $2) RETURNING id", message, createdAt).Scan(&id)
+	if err != nil {
+		return 0, err
+	}
+
+	return id, nil
+}
+
+// GetNotifications returns the notifications a user has not dismissed, newest first.
+func (s *Store) GetNotifications(username string) ([]types.Notification, error) {
+	query := `SELECT n.id, n.message, COALESCE(nd.dismissed, FALSE), COALESCE(n.created_at, '')
+			  FROM notifications n
+			  LEFT JOIN notifications_dismissed nd ON nd.notification_id = n.id AND nd.username = $1
+			  WHERE NOT COALESCE(nd.dismissed, FALSE)
+			  ORDER BY n.created_at DESC`
+
+	rows, err := s.pool.Query(context.Background(), query, username)
+	if err != nil {
+		return nil, fmt.Errorf("error querying notifications: %w", err)
+	}
+	defer rows.Close()
+
+	var notifications []types.Notification
+	for rows.Next() {
+		n := types.Notification{Username: username}
+		if err := rows.Scan(&n.ID, &n.Message, &n.IsRead, &n.CreatedAt); err != nil {
+			return nil, fmt.Errorf("error scanning notification: %w", err)
+		}
+		notifications = append(notifications, n)
+	}
+
+	return notifications, rows.Err()
+}
+
+// MarkNotificationRead marks a notification as read for a user.
+func (s *Store) MarkNotificationRead(id int64, username string) error {
+	_, err := s.pool.Exec(context.Background(), `INSERT INTO notifications_dismissed (notification_id, username, dismissed, dismissed_at) VALUES ($1, $2, TRUE, CURRENT_TIMESTAMP)
+		ON CONFLICT (notification_id, username) DO UPDATE SET dismissed = TRUE, dismissed_at = CURRENT_TIMESTAMP`, id, username)
+	return err
+}
+
+// ClearAllNotifications marks all notifications as read for a user.
+func (s *Store) ClearAllNotifications(username string) error {
+	_, err := s.pool.Exec(context.Background(), `INSERT INTO notifications_dismissed (notification_id, username, dismissed, dismissed_at) SELECT id, $1::TEXT, TRUE, CURRENT_TIMESTAMP FROM notifications
+		ON CONFLICT (notification_id, username) DO UPDATE SET dismissed = TRUE, dismissed_at = CURRENT_TIMESTAMP`, username)
+	return err
+}
@@ -0,0 +1,248 @@
+package pgstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/robbymilo/rgallery/pkg/types"
+)
+
+type Conf = types.Conf
+type Media = types.Media
+type DatabaseMedia = types.DatabaseMedia
+type FilterParams = types.FilterParams
+type PrevNext = types.PrevNext
+
+// columns are the media columns in the order read by scanMediaRow.
+const columns = `hash, path, subject, width, height, ratio, padding, date, modified, folder, rating, shutterspeed, aperture, iso, lens, camera, focallength, altitude, latitude, longitude, mediatype, focusdistance, focallength35, color, location, description, title, software, "offset", rotation, checksum, size, motion_offset, motion_length, phash, library, container, video_codec, audio_codec, duration, frame_rate, bitrate, has_audio`
+
+// Store is the PostgreSQL implementation of types.Store.
+type Store struct {
+	pool   *pgxpool.Pool
+	logger *slog.Logger
+
+	maxConnections   *prometheus.Desc
+	connectionsInUse *prometheus.Desc
+	takesTotal       *prometheus.Desc
+	waitSeconds      *prometheus.Desc
+}
+
+var _ types.Store = (*Store)(nil)
+
+// Open connects to the PostgreSQL database at c.PostgresURL. Migrate must be called first.
+func Open(c Conf) (*Store, error) {
+	pool, err := connect(c)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Store{
+		pool:   pool,
+		logger: c.Logger,
+
+		maxConnections: prometheus.NewDesc("rgallery_db_max_connections",
+			"Maximum number of connections in the database pool.",
+			nil, nil,
+		),
+		connectionsInUse: prometheus.NewDesc("rgallery_db_connections_in_use",
+			"Number of database connections currently taken.",
+			[]string{"pool"}, nil,
+		),
+		takesTotal: prometheus.NewDesc("rgallery_db_connection_takes_total",
+			"Total number of database connections taken.",
+			[]string{"pool"}, nil,
+		),
+		waitSeconds: prometheus.NewDesc("rgallery_db_connection_wait_seconds_total",
+			"Total time spent waiting for a database connection.",
+			[]string{"pool"}, nil,
+		),
+	}, nil
+}
+
+// connect opens a connection pool and checks that the database can be reached.
+func connect(c Conf) (*pgxpool.Pool, error) {
+	if c.PostgresURL == "" {
+		return nil, errors.New("postgres url is required when the database backend is postgres")
+	}
+
+	pool, err := pgxpool.New(context.Background(), c.PostgresURL)
+	if err != nil {
+		return nil, fmt.Errorf("error opening postgres db: %v", err)
+	}
+
+	if err := pool.Ping(context.Background()); err != nil {
+		pool.Close()
+		return nil, fmt.Errorf("error connecting to postgres db: %v", err)
+	}
+
+	return pool, nil
+}
+
+// Close closes the database connections.
+func (s *Store) Close() error {
+	s.pool.Close()
+	return nil
+}
+
+// Optimize reclaims the space of deleted rows and updates the query planner statistics.
+func (s *Store) Optimize() error {
+	_, err := s.pool.Exec(context.Background(), "VACUUM ANALYZE")
+	return err
+}
+
+func (s *Store) Describe(ch chan<- *prometheus.Desc) {
+	ch <- s.maxConnections
+	ch <- s.connectionsInUse
+	ch <- s.takesTotal
+	ch <- s.waitSeconds
+}
+
+func (s *Store) Collect(ch chan<- prometheus.Metric) {
+	stat := s.pool.Stat()
+	ch <- prometheus.MustNewConstMetric(s.maxConnections, prometheus.GaugeValue, float64(stat.MaxConns()))
+	ch <- prometheus.MustNewConstMetric(s.connectionsInUse, prometheus.GaugeValue, float64(stat.AcquiredConns()), "postgres")
+	ch <- prometheus.MustNewConstMetric(s.takesTotal, prometheus.CounterValue, float64(stat.AcquireCount()), "postgres")
+	ch <- prometheus.MustNewConstMetric(s.waitSeconds, prometheus.CounterValue, stat.AcquireDuration().Seconds(), "postgres")
+}
+
+// count runs a query returning a single integer.
+func (s *Store) count(query string, args ...interface{}) (int, error) {
+	var total int64
+	err := s.pool.QueryRow(context.Background(), query, args...).Scan(&total)
+	if err != nil {
+		return 0, fmt.Errorf("error executing query: %v", err)
+	}
+
+	return int(total), nil
+}
+
+// queryArgs collects query arguments and returns their $n placeholders.
+type queryArgs []interface{}
+
+func (a *queryArgs) add(v interface{}) string {
+	// hashes are unsigned but stored in a signed BIGINT
+	if h, ok := v.(uint64); ok {
+		v = int64(h)
+	}
+	*a = append(*a, v)
+	return "$" + strconv.Itoa(len(*a))
+}
+
+// scanMediaRows reads every row of a query selecting the media columns.
+func scanMediaRows(rows pgx.Rows) ([]DatabaseMedia, error) {
+	defer rows.Close()
+
+	var result []DatabaseMedia
+	for rows.Next() {
+		media, err := scanMediaRow(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning media row: %v", err)
+		}
+		result = append(result, media)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error stepping through result set: %v", err)
+	}
+
+	return result, nil
+}
+
+// scanMediaRow reads the current row of a query selecting the media columns.
+func scanMediaRow(row pgx.Row) (DatabaseMedia, error) {
+	var (
+		m                                                                  DatabaseMedia
+		hash, size, motionOffset, motionLength, phash, bitrate             *int64
+		width, height                                                      *int32
+		ratio, padding                                                     *float64
+		path, subject, date, modified, folder, shutterspeed, lens, camera  *string
+		mediatype, color, location, description, title, software, checksum *string
+		library, container, videoCodec, audioCodec                         *string
+		rating, aperture, iso, focallength, altitude, latitude, longitude  *float64
+		focusdistance, focallength35, offset, rotation                     *float64
+		duration, frameRate                                                *float64
+		hasAudio                                                           *bool
+	)
+
+	err := row.Scan(
+		&hash, &path, &subject, &width, &height, &ratio, &padding, &date, &modified, &folder,
+		&rating, &shutterspeed, &aperture, &iso, &lens, &camera, &focallength, &altitude, &latitude, &longitude,
+		&mediatype, &focusdistance, &focallength35, &color, &location, &description, &title, &software, &offset, &rotation,
+		&checksum, &size, &motionOffset, &motionLength, &phash, &library,
+		&container, &videoCodec, &audioCodec, &duration, &frameRate, &bitrate, &hasAudio,
+	)
+	if err != nil {
+		return DatabaseMedia{}, err
+	}
+
+	m.Hash = uint64(deref(hash))
+	m.Path = deref(path)
+	m.Subject = deref(subject)
+	m.Width = int(deref(width))
+	m.Height = int(deref(height))
+	m.Ratio = float32(deref(ratio))
+	m.Padding = float32(deref(padding))
+	m.Date = deref(date)
+	m.Modified = deref(modified)
+	m.Folder = deref(folder)
+	m.Rating = deref(rating)
+	m.ShutterSpeed = deref(shutterspeed)
+	m.Aperture = deref(aperture)
+	m.Iso = deref(iso)
+	m.Lens = deref(lens)
+	m.Camera = deref(camera)
+	m.Focallength = deref(focallength)
+	m.Altitude = deref(altitude)
+	m.Latitude = deref(latitude)
+	m.Longitude = deref(longitude)
+	m.Mediatype = deref(mediatype)
+	m.Focusdistance = deref(focusdistance)
+	m.Focallength35 = deref(focallength35)
+	m.Color = deref(color)
+	m.Location = deref(location)
+	m.Description = deref(description)
+	m.Title = deref(title)
+	m.Software = deref(software)
+	m.Offset = deref(offset)
+	m.Rotation = deref(rotation)
+	m.Checksum = deref(checksum)
+	m.Size = deref(size)
+	m.MotionOffset = deref(motionOffset)
+	m.MotionLength = deref(motionLength)
+	m.PHash = uint64(deref(phash))
+	m.PHashed = phash != nil
+	m.Library = deref(library)
+	m.Container = deref(container)
+	m.VideoCodec = deref(videoCodec)
+	m.AudioCodec = deref(audioCodec)
+	m.Duration = deref(duration)
+	m.FrameRate = deref(frameRate)
+	m.Bitrate = deref(bitrate)
+	m.HasAudio = deref(hasAudio)
+
+	return m, nil
+}
+
+// deref returns the value of a nullable column, or the zero value for NULL like the sqlite column readers.
+func deref[T any](v *T) T {
+	var zero T
+	if v == nil {
+		return zero
+	}
+	return *v
+}
+
+// prefixColumns qualifies the media columns with a table alias.
+func prefixColumns(alias string) string {
+	cols := strings.Split(columns, ", ")
+	for i, col := range cols {
+		cols[i] = alias + "." + col
+	}
+	return strings.Join(cols, ", ")
+}
@@ -0,0 +1,176 @@
+package pgstore
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// GetNext returns the media items after the current media item in chronological order.
+func (s *Store) GetNext(date time.Time, hash uint64, total int, params FilterParams, previous []PrevNext, c Conf) ([]PrevNext, error) {
+	var args queryArgs
+
+	// Build an array of previous ids to exclude in case media items have the same datetime.
+	previousIDs := make([]int64, 0, len(previous))
+	for _, p := range previous {
+		previousIDs = append(previousIDs, int64(p.Hash))
+	}
+
+	join, where := prevNextFilters(params, c, &args)
+
+	query := fmt.Sprintf(
+		`SELECT * FROM (
+			SELECT DISTINCT ON (i.date)
+				i.hash,
+				COALESCE(i.color, '') AS color,
+				COALESCE(i.mediatype, '') AS mediatype,
+				COALESCE(i.path, '') AS path,
+				COALESCE(i.width, 0) AS width,
+				COALESCE(i.height, 0) AS height,
+				i.date
+			FROM media i
+			%s
+			WHERE i.hash != %s
+			AND i.hash <> ALL(%s)
+			AND i.rating >= %s
+			AND i.date < %s
+			AND i.date != %s
+			%s
+			ORDER BY i.date, i.hash
+		) n
+		ORDER BY date DESC LIMIT %d`,
+		join,
+		args.add(hash), args.add(previousIDs), args.add(params.Rating),
+		args.add(date.Format("2006-01-02T15:04:05.000Z")), args.add("0001-01-01T00:00:00.000Z"),
+		where, total)
+
+	return s.queryPrevNext(query, args)
+}
+
+// GetPrevious returns the media items before the current media item in chronological order.
+func (s *Store) GetPrevious(date time.Time, hash uint64, params FilterParams, c Conf) ([]PrevNext, error) {
+	var args queryArgs
+
+	join, where := prevNextFilters(params, c, &args)
+
+	query := fmt.Sprintf(
+		`SELECT * FROM (
+			SELECT * FROM (
+				SELECT DISTINCT ON (i.date)
+					i.hash,
+					COALESCE(i.color, '') AS color,
+					COALESCE(i.mediatype, '') AS mediatype,
+					COALESCE(i.path, '') AS path,
+					COALESCE(i.width, 0) AS width,
+					COALESCE(i.height, 0) AS height,
+					i.date
+				FROM media i
+				%s
+				WHERE i.hash != %s
+				AND i.rating >= %s
+				AND i.date > %s
+				AND i.date != %s
+				%s
+				ORDER BY i.date, i.hash
+			) p
+			ORDER BY date ASC LIMIT 3
+		) p
+		ORDER BY date DESC`,
+		join,
+		args.add(hash), args.add(params.Rating),
+		args.add(date.Format("2006-01-02T15:04:05.000Z")), args.add("0001-01-01T00:00:00.000Z"),
+		where)
+
+	return s.queryPrevNext(query, args)
+}
+
+// prevNextFilters returns the joins and conditions that limit adjacent media items to the current filter.
+func prevNextFilters(params FilterParams, c Conf, args *queryArgs) (string, string) {
+	var join string
+	var where []string
+
+	if params.Subject != "" {
+		join = `JOIN images_tags i_t ON i.hash = i_t.image_id
+			JOIN tags t ON i_t.tag_id = t.id`
+		where = append(where, "t.key = "+args.add(params.Subject))
+	}
+
+	if term := searchPatterns(params.Term); term != nil {
+		where = append(where, "i.search ILIKE ALL("+args.add(term)+")")
+	}
+
+	if params.Folder != "" {
+		where = append(where, "i.folder = "+args.add(params.Folder))
+	}
+
+	if params.Camera != "" {
+		where = append(where, "i.camera = "+args.add(params.Camera))
+	}
+
+	if params.Lens != "" {
+		where = append(where, "i.lens = ANY("+args.add(lensVariants(params.Lens, c))+")")
+	}
+
+	if params.MediaType != "" {
+		where = append(where, "i.mediatype = "+args.add(params.MediaType))
+	}
+
+	if params.Software != "" {
+		where = append(where, "i.software = "+args.add(params.Software))
+	}
+
+	if params.FocalLength35 != 0 {
+		where = append(where, "i.focallength35 = "+args.add(params.FocalLength35))
+	}
+
+	if params.Library != "" {
+		where = append(where, "i.library = "+args.add(params.Library))
+	}
+
+	where = append(where, videoFilters("i", params, args)...)
+
+	if len(where) == 0 {
+		return join, ""
+	}
+
+	return join, "AND " + strings.Join(where, "\n\t\t\tAND ")
+}
+
+func (s *Store) queryPrevNext(query string, args queryArgs) ([]PrevNext, error) {
+	rows, err := s.pool.Query(context.Background(), query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error querying adjacent media items: %v", err)
+	}
+	defer rows.Close()
+
+	result := make([]PrevNext, 0)
+	for rows.Next() {
+		var (
+			hash          int64
+			width, height int32
+			date          string
+			media         PrevNext
+		)
+		if err := rows.Scan(&hash, &media.Color, &media.Mediatype, &media.Path, &width, &height, &date); err != nil {
+			return nil, fmt.Errorf("error scanning media item: %v", err)
+		}
+
+		media.Hash = uint64(hash)
+		media.Width = int(width)
+		media.Height = int(height)
+
+		t, err := time.Parse("2006-01-02T15:04:05.000Z", date)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing date for media item: %v", err)
+		}
+		media.Date = t
+
+		result = append(result, media)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error stepping through result set: %v", err)
+	}
+
+	return result, nil
+}
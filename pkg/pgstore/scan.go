@@ -0,0 +1,270 @@
+package pgstore
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/robbymilo/rgallery/pkg/hash"
+)
+
+// InsertMediaItem inserts a media item, and it's tags, folders and their relationships.
+func (s *Store) InsertMediaItem(media Media) error {
+	return s.InsertMediaItems([]Media{media})
+}
+
+// InsertMediaItems inserts media items, and their tags, folders and relationships, in a single transaction.
+func (s *Store) InsertMediaItems(items []Media) error {
+	ctx := context.Background()
+
+	return pgx.BeginFunc(ctx, s.pool, func(tx pgx.Tx) error {
+		for _, media := range items {
+			if err := s.insertMediaItem(ctx, tx, media); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// insertMediaItem inserts a media item and it's tags and folder within tx.
+func (s *Store) insertMediaItem(ctx context.Context, tx pgx.Tx, media Media) error {
+	subject, err := json.Marshal(media.Subject)
+	if err != nil {
+		return fmt.Errorf("error marshaling subject: %v", err)
+	}
+
+	// add folder if it does not exist
+	tag, err := tx.Exec(ctx, "INSERT INTO folders(id, key) VALUES ($1, $2) ON CONFLICT DO NOTHING", int64(hash.GetHash(media.Folder)), media.Folder)
+	if err != nil {
+		return fmt.Errorf("error inserting folder: %v", err)
+	}
+	if tag.RowsAffected() > 0 {
+		s.logger.Info("added folder " + media.Folder)
+	}
+
+	// handle tag inserts
+	for _, t := range media.Subject {
+		tagID := int64(hash.GetHash(t.Key))
+
+		// add tag if it does not exist
+		tag, err := tx.Exec(ctx, "INSERT INTO tags(id, key, value) VALUES ($1, $2, $3) ON CONFLICT DO NOTHING", tagID, t.Key, t.Value)
+		if err != nil {
+			return fmt.Errorf("error inserting tag: %v", err)
+		}
+		if tag.RowsAffected() > 0 {
+			s.logger.Info("added tag " + t.Value)
+		}
+
+		// add tag-image many-to-many relationship
+		_, err = tx.Exec(ctx, "INSERT INTO images_tags(image_id, tag_id) VALUES ($1, $2)", int64(media.Hash), tagID)
+		if err != nil {
+			return fmt.Errorf("error inserting image-tag relationship: %v", err)
+		}
+	}
+
+	// insert image
+	placeholders := make([]string, strings.Count(columns, ",")+1)
+	for i := range placeholders {
+		placeholders[i] = fmt.Sprintf("$%d", i+1)
+	}
+	query := fmt.Sprintf("INSERT INTO media(%s) VALUES (%s)", columns, strings.Join(placeholders, ", "))
+
+	// media items without a perceptual hash have a NULL phash
+	var phash *int64
+	if media.PHashed {
+		h := int64(media.PHash)
+		phash = &h
+	}
+
+	_, err = tx.Exec(ctx, query,
+		int64(media.Hash),
+		media.Path,
+		string(subject),
+		media.Width,
+		media.Height,
+		media.Ratio,
+		media.Padding,
+		media.Date.Format("2006-01-02T15:04:05.000Z"),
+		media.Modified.Format("2006-01-02T15:04:05.000Z"),
+		media.Folder,
+		media.Rating,
+		media.ShutterSpeed,
+		media.Aperture,
+		media.Iso,
+		media.Lens,
+		media.Camera,
+		media.Focallength,
+		media.Altitude,
+		media.Latitude,
+		media.Longitude,
+		media.Type,
+		media.FocusDistance,
+		media.FocalLength35,
+		media.Color,
+		media.Location,
+		media.Description,
+		media.Title,
+		media.Software,
+		media.Offset,
+		media.Rotation,
+		media.Checksum,
+		media.Size,
+		media.MotionOffset,
+		media.MotionLength,
+		phash,
+		media.Library,
+		media.Container,
+		media.VideoCodec,
+		media.AudioCodec,
+		media.Duration,
+		media.FrameRate,
+		media.Bitrate,
+		media.HasAudio,
+	)
+	if err != nil {
+		return fmt.Errorf("error inserting image: %v", err)
+	}
+
+	return nil
+}
+
+// DeleteMediaItem removes a media item and any tags and folders no longer used by other media items.
+func (s *Store) DeleteMediaItem(media Media) error {
+	ctx := context.Background()
+
+	return pgx.BeginFunc(ctx, s.pool, func(tx pgx.Tx) error {
+		// delete image
+		if _, err := tx.Exec(ctx, "DELETE FROM media WHERE hash = $1", int64(media.Hash)); err != nil {
+			return err
+		}
+
+		// delete tag relationships
+		if _, err := tx.Exec(ctx, "DELETE FROM images_tags WHERE image_id = $1", int64(media.Hash)); err != nil {
+			return err
+		}
+
+		// delete tags no other items have
+		for _, t := range media.Subject {
+			tag, err := tx.Exec(ctx, "DELETE FROM tags WHERE id = $1 AND NOT EXISTS (SELECT 1 FROM images_tags WHERE tag_id = $1)", int64(hash.GetHash(t.Key)))
+			if err != nil {
+				return err
+			}
+			if tag.RowsAffected() > 0 {
+				s.logger.Info("deleting tag " + t.Key)
+			}
+		}
+
+		// delete the folder if no other items have it
+		tag, err := tx.Exec(ctx, "DELETE FROM folders WHERE key = $1 AND NOT EXISTS (SELECT 1 FROM media WHERE folder = $1)", media.Folder)
+		if err != nil {
+			return err
+		}
+		if tag.RowsAffected() > 0 {
+			s.logger.Info("deleting folder " + media.Folder)
+		}
+
+		return nil
+	})
+}
+
+// MoveMediaItem points an existing media item at a new path, keeping its hash and tags.
+func (s *Store) MoveMediaItem(media Media, path, folder string, modified time.Time, size int64) error {
+	ctx := context.Background()
+
+	return pgx.BeginFunc(ctx, s.pool, func(tx pgx.Tx) error {
+		// add folder if it does not exist
+		_, err := tx.Exec(ctx, "INSERT INTO folders(id, key) VALUES ($1, $2) ON CONFLICT DO NOTHING", int64(hash.GetHash(folder)), folder)
+		if err != nil {
+			return fmt.Errorf("error inserting folder: %v", err)
+		}
+
+		_, err = tx.Exec(ctx, "UPDATE media SET path = $1, folder = $2, modified = $3, size = $4 WHERE hash = $5",
+			path,
+			folder,
+			modified.UTC().Format("2006-01-02T15:04:05.000Z"),
+			size,
+			int64(media.Hash),
+		)
+		if err != nil {
+			return fmt.Errorf("error updating media path: %v", err)
+		}
+
+		// delete the previous folder if no other items have it
+		if media.Folder != folder {
+			_, err = tx.Exec(ctx, "DELETE FROM folders WHERE key = $1 AND NOT EXISTS (SELECT 1 FROM media WHERE folder = $1)", media.Folder)
+			if err != nil {
+				return fmt.Errorf("error deleting folder: %v", err)
+			}
+		}
+
+		return nil
+	})
+}
+
+// UpdateMediaItem replaces a media item and its tags in a single transaction, removing tags no other media items have.
+func (s *Store) UpdateMediaItem(media Media) error {
+	ctx := context.Background()
+
+	return pgx.BeginFunc(ctx, s.pool, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, "DELETE FROM media WHERE hash = $1", int64(media.Hash)); err != nil {
+			return fmt.Errorf("error deleting media item: %v", err)
+		}
+
+		if _, err := tx.Exec(ctx, "DELETE FROM images_tags WHERE image_id = $1", int64(media.Hash)); err != nil {
+			return fmt.Errorf("error deleting media item: %v", err)
+		}
+
+		if err := s.insertMediaItem(ctx, tx, media); err != nil {
+			return err
+		}
+
+		if _, err := tx.Exec(ctx, "DELETE FROM tags WHERE NOT EXISTS (SELECT 1 FROM images_tags WHERE tag_id = tags.id)"); err != nil {
+			return fmt.Errorf("error deleting tags: %v", err)
+		}
+
+		return nil
+	})
+}
+
+// TrackScanError records a file scanning error so the file is skipped until it is modified.
+func (s *Store) TrackScanError(library, path string, modified time.Time, message string) error {
+	_, err := s.pool.Exec(context.Background(), `INSERT INTO scan_errors (library, path, modified, error) VALUES ($1, $2, $3, $4)
+		ON CONFLICT (library, path) DO UPDATE SET modified = EXCLUDED.modified, error = EXCLUDED.error, created = CURRENT_TIMESTAMP`,
+		library, path, modified.Format(time.RFC3339), message)
+	if err != nil {
+		return fmt.Errorf("error inserting scan error record: %v", err)
+	}
+
+	return nil
+}
+
+// GetScanErrors returns the modification time of every file of a library that failed to scan.
+func (s *Store) GetScanErrors(library string) (map[string]time.Time, error) {
+	rows, err := s.pool.Query(context.Background(), "SELECT path, COALESCE(modified, '') FROM scan_errors WHERE library = $1", library)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	scanErrors := make(map[string]time.Time)
+	for rows.Next() {
+		var path, value string
+		if err := rows.Scan(&path, &value); err != nil {
+			return nil, err
+		}
+
+		modified, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing last scan time: %v", err)
+		}
+
+		scanErrors[path] = modified
+	}
+
+	return scanErrors, rows.Err()
+}
@@ -0,0 +1,169 @@
+-- The schema of a new database. Existing databases are only changed by the migrations in migrate.go, so a change
+-- here needs a migration making the same change.
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+CREATE TABLE
+  IF NOT EXISTS media (
+    "hash" BIGINT NOT NULL PRIMARY KEY,
+    "path" TEXT,
+    "subject" TEXT,
+    "width" INTEGER,
+    "height" INTEGER,
+    "ratio" DOUBLE PRECISION,
+    "padding" DOUBLE PRECISION,
+    "date" TEXT,
+    "modified" TEXT,
+    "folder" TEXT,
+    "rating" DOUBLE PRECISION,
+    shutterspeed TEXT DEFAULT '',
+    aperture DOUBLE PRECISION DEFAULT 0,
+    iso DOUBLE PRECISION DEFAULT 0,
+    lens TEXT DEFAULT '',
+    camera TEXT DEFAULT '',
+    focallength DOUBLE PRECISION DEFAULT 0,
+    altitude DOUBLE PRECISION DEFAULT 0,
+    latitude DOUBLE PRECISION DEFAULT 0,
+    longitude DOUBLE PRECISION DEFAULT 0,
+    mediatype TEXT DEFAULT '',
+    focusdistance DOUBLE PRECISION DEFAULT 0,
+    focallength35 DOUBLE PRECISION DEFAULT 0,
+    color TEXT DEFAULT '',
+    location TEXT DEFAULT '',
+    description TEXT DEFAULT '',
+    title TEXT DEFAULT '',
+    software TEXT DEFAULT '',
+    "offset" DOUBLE PRECISION DEFAULT 0,
+    rotation DOUBLE PRECISION DEFAULT 0,
+    "created" TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    checksum TEXT DEFAULT '',
+    size BIGINT DEFAULT 0,
+    motion_offset BIGINT DEFAULT 0,
+    motion_length BIGINT DEFAULT 0,
+    phash BIGINT,
+    library TEXT NOT NULL DEFAULT 'default',
+    container TEXT DEFAULT '',
+    video_codec TEXT DEFAULT '',
+    audio_codec TEXT DEFAULT '',
+    duration DOUBLE PRECISION DEFAULT 0,
+    frame_rate DOUBLE PRECISION DEFAULT 0,
+    bitrate BIGINT DEFAULT 0,
+    has_audio BOOLEAN DEFAULT FALSE,
+    -- text searched with trigram matching in place of the sqlite images_virtual table
+    search TEXT GENERATED ALWAYS AS (
+      "hash"::TEXT || ' ' || COALESCE("path", '') || ' ' || COALESCE("subject", '') || ' ' || COALESCE("date", '') || ' ' || COALESCE("modified", '') || ' ' || COALESCE("folder", '') || ' ' || COALESCE(shutterspeed, '') || ' ' || COALESCE(lens, '') || ' ' || COALESCE(camera, '') || ' ' || COALESCE(mediatype, '') || ' ' || COALESCE(color, '') || ' ' || COALESCE(location, '') || ' ' || COALESCE(description, '') || ' ' || COALESCE(title, '') || ' ' || COALESCE(software, '')
+    ) STORED
+  );
+
+CREATE TABLE
+  IF NOT EXISTS tags (
+    "id" BIGINT NOT NULL PRIMARY KEY,
+    "key" TEXT,
+    "value" TEXT,
+    "created" TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+  );
+
+CREATE TABLE
+  IF NOT EXISTS folders (
+    "id" BIGINT NOT NULL PRIMARY KEY,
+    "key" TEXT UNIQUE,
+    "created" TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+  );
+
+-- like the sqlite schema, relationships are maintained by the scanner so that
+-- media items can be deleted and inserted again in any order
+CREATE TABLE
+  IF NOT EXISTS images_tags (
+    "id" BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    "image_id" BIGINT,
+    "tag_id" BIGINT,
+    "created" TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+  );
+
+CREATE TABLE
+  IF NOT EXISTS users (
+    "username" TEXT NOT NULL PRIMARY KEY,
+    "password" TEXT,
+    "role" TEXT,
+    "created" TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+  );
+
+CREATE TABLE
+  IF NOT EXISTS keys (
+    "name" TEXT NOT NULL PRIMARY KEY,
+    "key" TEXT,
+    "created" TEXT
+  );
+
+CREATE TABLE
+  IF NOT EXISTS scan_errors (
+    "library" TEXT NOT NULL DEFAULT 'default',
+    "path" TEXT NOT NULL,
+    "modified" TEXT,
+    "error" TEXT,
+    "created" TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (library, path)
+  );
+
+CREATE TABLE
+  IF NOT EXISTS notifications (
+    id BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    message TEXT NOT NULL,
+    created_at TEXT
+  );
+
+CREATE TABLE
+  IF NOT EXISTS notifications_dismissed (
+    id BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    notification_id BIGINT NOT NULL REFERENCES notifications (id) ON DELETE CASCADE,
+    username TEXT NOT NULL,
+    dismissed BOOLEAN DEFAULT FALSE,
+    dismissed_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (notification_id, username)
+  );
+
+CREATE INDEX IF NOT EXISTS idx_media_folder_date ON media (folder, date DESC);
+
+CREATE INDEX IF NOT EXISTS idx_media_folder ON media (folder);
+
+CREATE INDEX IF NOT EXISTS idx_media_date ON media (date);
+
+CREATE INDEX IF NOT EXISTS idx_images_tags_image_id ON images_tags (image_id);
+
+CREATE INDEX IF NOT EXISTS idx_images_tags_tag_id ON images_tags (tag_id);
+
+CREATE INDEX IF NOT EXISTS idx_media_search_trgm ON media USING GIN (search gin_trgm_ops);
+
+CREATE TABLE
+  IF NOT EXISTS jobs (
+    id BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    type TEXT NOT NULL,
+    scope TEXT DEFAULT '',
+    status TEXT NOT NULL,
+    total INTEGER DEFAULT 0,
+    processed INTEGER DEFAULT 0,
+    failed INTEGER DEFAULT 0,
+    cursor TEXT DEFAULT '',
+    error TEXT DEFAULT '',
+    created_at TEXT,
+    started_at TEXT DEFAULT '',
+    finished_at TEXT DEFAULT '',
+    owner TEXT DEFAULT '',
+    heartbeat TEXT DEFAULT ''
+  );
+
+CREATE INDEX IF NOT EXISTS idx_jobs_status ON jobs (status);
+
+CREATE TABLE
+  IF NOT EXISTS alternates (
+    library TEXT NOT NULL DEFAULT 'default',
+    path TEXT NOT NULL,
+    hash BIGINT NOT NULL,
+    folder TEXT NOT NULL,
+    size BIGINT DEFAULT 0,
+    modified TEXT DEFAULT '',
+    PRIMARY KEY (library, path)
+  );
+
+CREATE INDEX IF NOT EXISTS idx_alternates_hash ON alternates (hash);
+
+CREATE INDEX IF NOT EXISTS idx_alternates_folder ON alternates (folder);
@@ -0,0 +1,74 @@
+package pgstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/robbymilo/rgallery/pkg/hash"
+	"github.com/robbymilo/rgallery/pkg/types"
+)
+
+// GetTag returns media items with a given tag.
+func (s *Store) GetTag(name string, pageSize, offset int, direction string) ([]DatabaseMedia, error) {
+	query := fmt.Sprintf(`SELECT * FROM (SELECT DISTINCT ON (i.date) %s FROM media i JOIN images_tags i_a ON i.hash = i_a.image_id WHERE i_a.tag_id = $1 ORDER BY i.date, i.hash) m ORDER BY date %s LIMIT $2 OFFSET $3`, prefixColumns("i"), direction)
+
+	rows, err := s.pool.Query(context.Background(), query, int64(hash.GetHash(name)), pageSize, offset)
+	if err != nil {
+		return nil, fmt.Errorf("error querying tag: %v", err)
+	}
+
+	return scanMediaRows(rows)
+}
+
+// GetTotalOfTag returns the number of media items with a given tag.
+func (s *Store) GetTotalOfTag(name string) (int, error) {
+	query := `SELECT COUNT(DISTINCT date) FROM media i JOIN images_tags i_a ON i.hash = i_a.image_id WHERE i_a.tag_id = $1`
+
+	return s.count(query, int64(hash.GetHash(name)))
+}
+
+// GetTagTitle returns the title of a tag given the tag's key.
+func (s *Store) GetTagTitle(key string) (string, error) {
+	var value *string
+	err := s.pool.QueryRow(context.Background(), `SELECT value FROM tags WHERE key = $1`, key).Scan(&value)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("error executing query: %v", err)
+	}
+
+	return deref(value), nil
+}
+
+// GetTags returns all tags.
+func (s *Store) GetTags(direction string) (types.Subjects, error) {
+	query := fmt.Sprintf(`SELECT COALESCE(key, ''), COALESCE(value, '') FROM tags ORDER BY key COLLATE "C" %s`, direction)
+
+	rows, err := s.pool.Query(context.Background(), query)
+	if err != nil {
+		return nil, fmt.Errorf("error querying tags: %v", err)
+	}
+	defer rows.Close()
+
+	var result types.Subjects
+	for rows.Next() {
+		var tag types.Subject
+		if err := rows.Scan(&tag.Key, &tag.Value); err != nil {
+			return nil, fmt.Errorf("error scanning tag: %v", err)
+		}
+		result = append(result, tag)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error stepping through result set: %v", err)
+	}
+
+	return result, nil
+}
+
+// GetTotalTags returns the number of tags.
+func (s *Store) GetTotalTags() (int, error) {
+	return s.count(`SELECT COUNT(*) FROM tags`)
+}
@@ -0,0 +1,266 @@
+package pgstore
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/robbymilo/rgallery/pkg/types"
+)
+
+// GetTimeline returns a page of media items with the total and, for the first page, the number of items per day.
+func (s *Store) GetTimeline(params *FilterParams, c Conf) (*types.TimelineResponse, error) {
+	response := &types.TimelineResponse{
+		Timeline: []types.TimelineItem{},
+		Photos:   []types.Photo{},
+	}
+	response.Meta.PageSize = params.PageSize
+
+	total, err := s.fetchTotalCount(params, c)
+	if err != nil {
+		return nil, err
+	}
+	response.Meta.Total = total
+
+	// calculate histogram for all items when cursor is 0
+	if params.Cursor == 0 {
+		timeline, err := s.fetchTimelineStats(params, c)
+		if err != nil {
+			return nil, err
+		}
+		if timeline != nil {
+			response.Timeline = timeline
+		}
+	}
+
+	photos, err := s.fetchPhotos(params, c)
+	if err != nil {
+		return nil, err
+	}
+	response.Photos = photos
+
+	if len(photos) >= params.PageSize {
+		next := params.Cursor + params.PageSize
+		if next < total {
+			response.Meta.NextCursor = strconv.Itoa(next)
+		}
+	}
+
+	return response, nil
+}
+
+func (s *Store) fetchTotalCount(params *FilterParams, c Conf) (int, error) {
+	var args queryArgs
+	baseQuery := buildBaseQuery(params, c, &args)
+
+	// Count unique timestamps
+	query := fmt.Sprintf("SELECT COUNT(DISTINCT m.date) %s", baseQuery)
+
+	return s.count(query, args...)
+}
+
+func (s *Store) fetchTimelineStats(params *FilterParams, c Conf) ([]types.TimelineItem, error) {
+	var args queryArgs
+	baseQuery := buildBaseQuery(params, c, &args)
+
+	query := fmt.Sprintf(`
+		SELECT substr(m.date, 1, 10) as day, COUNT(DISTINCT m.date)
+		%s
+		GROUP BY day
+		ORDER BY day DESC`, baseQuery)
+
+	rows, err := s.pool.Query(context.Background(), query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query timeline: %w", err)
+	}
+	defer rows.Close()
+
+	results := make([]types.TimelineItem, 0)
+	for rows.Next() {
+		var item types.TimelineItem
+		var count int64
+		if err := rows.Scan(&item.Date, &count); err != nil {
+			return nil, err
+		}
+		item.Count = int(count)
+		results = append(results, item)
+	}
+
+	return results, rows.Err()
+}
+
+func (s *Store) fetchPhotos(params *FilterParams, c Conf) ([]types.Photo, error) {
+	var args queryArgs
+	baseQuery := buildBaseQuery(params, c, &args)
+
+	// Fetch specific page, keeping one media item per date to deduplicate exact timestamps.
+	query := fmt.Sprintf(`
+		SELECT hash, width, height, color, date, mediatype, container, video_codec, audio_codec, has_audio FROM (
+			SELECT DISTINCT ON (m.date)
+				m.hash,
+				COALESCE(m.width, 0) AS width,
+				COALESCE(m.height, 0) AS height,
+				COALESCE(m.color, '') AS color,
+				m.date,
+				COALESCE(m.mediatype, '') AS mediatype,
+				COALESCE(m.container, '') AS container,
+				COALESCE(m.video_codec, '') AS video_codec,
+				COALESCE(m.audio_codec, '') AS audio_codec,
+				COALESCE(m.has_audio, FALSE) AS has_audio,
+				m.%s AS sort
+			%s
+			ORDER BY m.date, m.hash
+		) p
+		ORDER BY p.sort %s, p.date %s
+		LIMIT %s OFFSET %s`,
+		params.OrderBy,
+		baseQuery,
+		params.Direction, params.Direction,
+		args.add(params.PageSize), args.add(params.Cursor),
+	)
+
+	rows, err := s.pool.Query(context.Background(), query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query photos: %w", err)
+	}
+	defer rows.Close()
+
+	results := make([]types.Photo, 0)
+	for rows.Next() {
+		var (
+			hash          int64
+			width, height int32
+			mediatype     string
+		)
+		photo := types.Photo{}
+		if err := rows.Scan(&hash, &width, &height, &photo.C, &photo.D, &mediatype, &photo.Container, &photo.VideoCodec, &photo.AudioCodec, &photo.HasAudio); err != nil {
+			return nil, err
+		}
+
+		photo.Id = uint64(hash)
+		photo.W = int(width)
+		photo.H = int(height)
+		if len(photo.D) >= 10 {
+			photo.D = photo.D[:10]
+		}
+		if mediatype == "video" {
+			photo.T = "video"
+		}
+
+		results = append(results, photo)
+	}
+
+	return results, rows.Err()
+}
+
+func buildBaseQuery(params *FilterParams, c Conf, args *queryArgs) string {
+	var sb strings.Builder
+	var where []string
+
+	sb.WriteString(" FROM media m ")
+
+	if params.Subject != "" {
+		sb.WriteString(" JOIN images_tags it ON m.hash = it.image_id ")
+		sb.WriteString(" JOIN tags t ON it.tag_id = t.id ")
+		where = append(where, "t.key = "+args.add(params.Subject))
+	}
+
+	if term := searchPatterns(params.Term); term != nil {
+		where = append(where, "m.search ILIKE ALL("+args.add(term)+")")
+	}
+
+	// Filters
+	where = append(where, "m.rating >= "+args.add(params.Rating))
+
+	if params.From != "" {
+		where = append(where, "m.date >= "+args.add(params.From))
+	}
+
+	if params.To != "" {
+		where = append(where, "m.date <= "+args.add(params.To))
+	}
+
+	if params.Camera != "" {
+		where = append(where, "m.camera = "+args.add(params.Camera))
+	}
+
+	if params.Lens != "" {
+		where = append(where, "m.lens = ANY("+args.add(lensVariants(params.Lens, c))+")")
+	}
+
+	if params.Folder != "" {
+		where = append(where, "m.folder = "+args.add(params.Folder))
+	}
+
+	if params.MediaType != "" {
+		where = append(where, "m.mediatype = "+args.add(params.MediaType))
+	}
+
+	if params.Software != "" {
+		where = append(where, "m.software = "+args.add(params.Software))
+	}
+
+	if params.FocalLength35 != 0 {
+		where = append(where, "m.focallength35 = "+args.add(params.FocalLength35))
+	}
+
+	if params.Library != "" {
+		where = append(where, "m.library = "+args.add(params.Library))
+	}
+
+	where = append(where, videoFilters("m", *params, args)...)
+
+	sb.WriteString(" WHERE ")
+	sb.WriteString(strings.Join(where, " AND "))
+
+	return sb.String()
+}
+
+// videoFilters returns the conditions of the container, codec and audio filters on the columns of videos of the
+// media table aliased as table.
+func videoFilters(table string, params FilterParams, args *queryArgs) []string {
+	var where []string
+
+	if params.Container != "" {
+		where = append(where, table+".container = "+args.add(params.Container))
+	}
+
+	if params.VideoCodec != "" {
+		where = append(where, table+".video_codec = "+args.add(params.VideoCodec))
+	}
+
+	if params.Audio != "" {
+		where = append(where, table+".mediatype = 'video'", table+".has_audio = "+args.add(params.Audio == "yes"))
+	}
+
+	return where
+}
+
+// searchPatterns returns an ILIKE pattern for each word of a search term, matching
+// like the trigram tokenizer of the sqlite images_virtual table.
+func searchPatterns(term string) []string {
+	var patterns []string
+	for _, word := range strings.Fields(term) {
+		patterns = append(patterns, "%"+word+"%")
+	}
+	return patterns
+}
+
+// lensVariants returns the lens and the names of all lenses aliased with it.
+func lensVariants(lens string, c Conf) []string {
+	variants := []string{lens}
+	for k, v := range c.Aliases.Lenses {
+		if k == lens {
+			for ak, av := range c.Aliases.Lenses {
+				if v == av {
+					variants = append(variants, ak)
+				}
+			}
+			variants = append(variants, v)
+		} else if v == lens {
+			variants = append(variants, k)
+		}
+	}
+	return variants
+}
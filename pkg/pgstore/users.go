@@ -0,0 +1,168 @@
+package pgstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/robbymilo/rgallery/pkg/types"
+)
+
+// GetUser returns the stored credentials of a user, or empty credentials if the user does not exist.
+func (s *Store) GetUser(username string) (types.UserCredentials, error) {
+	var password, role *string
+	var storedCreds types.UserCredentials
+	err := s.pool.QueryRow(context.Background(), "SELECT username, password, role FROM users WHERE username = $1", username).Scan(&storedCreds.Username, &password, &role)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return types.UserCredentials{}, nil
+	}
+	if err != nil {
+		return types.UserCredentials{}, fmt.Errorf("error executing query: %v", err)
+	}
+
+	storedCreds.Password = deref(password)
+	storedCreds.Role = deref(role)
+
+	return storedCreds, nil
+}
+
+// ListUsers returns all users.
+func (s *Store) ListUsers() ([]types.User, error) {
+	rows, err := s.pool.Query(context.Background(), "SELECT username, COALESCE(role, '') FROM users")
+	if err != nil {
+		return nil, fmt.Errorf("error querying users: %v", err)
+	}
+	defer rows.Close()
+
+	users := make([]types.User, 0)
+	for rows.Next() {
+		var user types.User
+		if err := rows.Scan(&user.Username, &user.Role); err != nil {
+			return nil, fmt.Errorf("error scanning user: %v", err)
+		}
+		users = append(users, user)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error stepping through result set: %v", err)
+	}
+
+	return users, nil
+}
+
+// HasUsers tests if any user exists.
+func (s *Store) HasUsers() (bool, error) {
+	var exists bool
+	err := s.pool.QueryRow(context.Background(), "SELECT EXISTS (SELECT 1 FROM users)").Scan(&exists)
+	if err != nil {
+		return false, fmt.Errorf("error checking existing users: %w", err)
+	}
+
+	return exists, nil
+}
+
+// AddUser adds a user whose password is already hashed.
+func (s *Store) AddUser(creds types.UserCredentials) error {
+	tag, err := s.pool.Exec(context.Background(), "INSERT INTO users (username, password, role) VALUES ($1, $2, $3) ON CONFLICT (username) DO NOTHING", creds.Username, creds.Password, creds.Role)
+	if err != nil {
+		return fmt.Errorf("error inserting user: %v", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return types.ErrUserExists
+	}
+
+	return nil
+}
+
+// RemoveUser removes a user.
+func (s *Store) RemoveUser(username string) error {
+	if _, err := s.pool.Exec(context.Background(), "DELETE FROM users WHERE username = $1", username); err != nil {
+		return fmt.Errorf("error executing delete statement: %w", err)
+	}
+
+	return nil
+}
+
+// RemoveAllUsers removes every user.
+func (s *Store) RemoveAllUsers() error {
+	_, err := s.pool.Exec(context.Background(), "DELETE FROM users")
+	return err
+}
+
+// AddKey adds an API key whose key is already hashed.
+func (s *Store) AddKey(name, hashedKey string, created time.Time) error {
+	tag, err := s.pool.Exec(context.Background(), "INSERT INTO keys (name, key, created) VALUES ($1, $2, $3) ON CONFLICT (name) DO NOTHING", name, hashedKey, created.UTC().Format(time.RFC3339))
+	if err != nil {
+		return fmt.Errorf("error inserting key: %v", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return types.ErrKeyExists
+	}
+
+	return nil
+}
+
+// RemoveKey removes an API key.
+func (s *Store) RemoveKey(name string) error {
+	if _, err := s.pool.Exec(context.Background(), "DELETE FROM keys WHERE name = $1", name); err != nil {
+		return fmt.Errorf("error executing delete statement: %w", err)
+	}
+
+	return nil
+}
+
+// GetKeyNames returns the name and creation time of every API key.
+func (s *Store) GetKeyNames() ([]types.ApiCredentials, error) {
+	rows, err := s.pool.Query(context.Background(), "SELECT name, COALESCE(created, '') FROM keys")
+	if err != nil {
+		return nil, fmt.Errorf("error querying keys: %v", err)
+	}
+	defer rows.Close()
+
+	keys := make([]types.ApiCredentials, 0)
+	for rows.Next() {
+		var name, created string
+		if err := rows.Scan(&name, &created); err != nil {
+			return nil, fmt.Errorf("error scanning key: %v", err)
+		}
+
+		t, err := time.Parse("2006-01-02T15:04:05Z", created)
+		if err != nil {
+			return []types.ApiCredentials{}, fmt.Errorf("error parsing date for key: %v", err)
+		}
+
+		keys = append(keys, types.ApiCredentials{
+			Name:    name,
+			Created: t,
+		})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error stepping through result set: %v", err)
+	}
+
+	return keys, nil
+}
+
+// GetAllKeys returns every hashed API key.
+func (s *Store) GetAllKeys() ([]types.ApiCredentials, error) {
+	rows, err := s.pool.Query(context.Background(), "SELECT COALESCE(key, '') FROM keys")
+	if err != nil {
+		return nil, fmt.Errorf("error querying keys: %v", err)
+	}
+	defer rows.Close()
+
+	var keys []types.ApiCredentials
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			return nil, fmt.Errorf("error scanning key: %v", err)
+		}
+		keys = append(keys, types.ApiCredentials{Key: key})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error stepping through query results: %v", err)
+	}
+
+	return keys, nil
+}
@@ -1,102 +1,16 @@
 package queries
 
-import (
-	"context"
-	"fmt"
-
-	"github.com/robbymilo/rgallery/pkg/database"
-	"zombiezen.com/go/sqlite"
-	"zombiezen.com/go/sqlite/sqlitex"
-)
-
 // GetFavorites returns media items with a rating of 5.
 func GetFavorites(pageSize, offset, rating int, params FilterParams, c Conf) ([]Media, error) {
-	pool, err := sqlitex.NewPool(database.NewSqlConnectionString(c), sqlitex.PoolOptions{
-		Flags:    sqlite.OpenReadOnly,
-		PoolSize: 1,
-	})
-	if err != nil {
-		return nil, fmt.Errorf("error opening sqlite db pool: %v", err)
-	}
-	defer func() {
-		if err := pool.Close(); err != nil {
-			c.Logger.Error("pool.Close error", "err", err)
-		}
-	}()
-
-	conn, err := pool.Take(context.Background())
-	if err != nil {
-		return nil, fmt.Errorf("failed to take connection from pool: %w", err)
-	}
-	defer pool.Put(conn)
-
-	query := fmt.Sprintf(`SELECT DISTINCT %s FROM media WHERE rating >= ? AND DATE != '0001-01-01T00:00:00.000Z' GROUP BY date ORDER BY date %s LIMIT ? OFFSET ?`, columns, params.Direction)
-
-	stmt, err := conn.Prepare(query)
-	if err != nil {
-		return nil, fmt.Errorf("error preparing SELECT statement: %v", err)
-	}
-
-	stmt.BindInt64(1, int64(rating))
-	stmt.BindInt64(2, int64(pageSize))
-	stmt.BindInt64(3, int64(offset))
-
-	result, err := parseMediaRows(stmt, c)
+	rows, err := c.Store.GetFavorites(pageSize, offset, rating, params.Direction)
 	if err != nil {
 		return nil, err
 	}
 
-	err = stmt.Finalize()
-	if err != nil {
-		return nil, fmt.Errorf("error finalizing statement: %v", err)
-	}
-
-	return result, err
+	return parseMediaRows(rows, c)
 }
 
 // GetTotalFavorites returns the total number of media items with a rating of 5.
 func GetTotalFavorites(rating int, c Conf) (int, error) {
-	pool, err := sqlitex.NewPool(database.NewSqlConnectionString(c), sqlitex.PoolOptions{
-		Flags:    sqlite.OpenReadOnly,
-		PoolSize: 1,
-	})
-	if err != nil {
-		return 0, fmt.Errorf("error opening sqlite db pool: %v", err)
-	}
-	defer func() {
-		if err := pool.Close(); err != nil {
-			c.Logger.Error("pool.Close error", "err", err)
-		}
-	}()
-
-	conn, err := pool.Take(context.Background())
-	if err != nil {
-		return 0, fmt.Errorf("failed to take connection from pool: %w", err)
-	}
-	defer pool.Put(conn)
-
-	query := `SELECT count(distinct date) FROM media WHERE rating >= ? AND date != ?`
-	stmt, err := conn.Prepare(query)
-	if err != nil {
-		return 0, fmt.Errorf("error preparing query: %v", err)
-	}
-	defer func() {
-		if finalizeErr := stmt.Finalize(); finalizeErr != nil {
-			err = fmt.Errorf("error finalizing statement: %v", finalizeErr)
-		}
-	}()
-
-	stmt.BindInt64(1, int64(rating))
-	stmt.BindText(2, "0001-01-01T00:00:00.000Z")
-
-	var total int
-	hasRow, err := stmt.Step()
-	if err != nil {
-		return 0, fmt.Errorf("error executing query: %v", err)
-	}
-	if hasRow {
-		total = int(stmt.ColumnInt64(0))
-	}
-
-	return total, nil
+	return c.Store.GetTotalFavorites(rating)
 }
@@ -1,72 +1,25 @@
 package queries
 
 import (
-	"context"
-	"fmt"
 	"sort"
 	"strings"
-
-	"github.com/robbymilo/rgallery/pkg/database"
-	"zombiezen.com/go/sqlite"
-	"zombiezen.com/go/sqlite/sqlitex"
 )
 
 // GetGear returns the total unique counts for a specified gear column.
 func GetGear(column string, c Conf) (GearItems, error) {
-	pool, err := sqlitex.NewPool(database.NewSqlConnectionString(c), sqlitex.PoolOptions{
-		Flags:    sqlite.OpenReadOnly,
-		PoolSize: 1,
-	})
-	if err != nil {
-		return nil, fmt.Errorf("error opening sqlite db pool: %v", err)
-	}
-	defer func() {
-		if err := pool.Close(); err != nil {
-			c.Logger.Error("pool.Close error:", "err", err)
-		}
-	}()
-
-	conn, err := pool.Take(context.Background())
-	if err != nil {
-		return nil, fmt.Errorf("failed to take connection from pool: %w", err)
-	}
-	defer pool.Put(conn)
-
-	query := fmt.Sprintf(
-		`SELECT %s, count(%s) AS total
-			 FROM media
-			 WHERE date != ?
-			 GROUP BY %s
-			 ORDER BY total DESC`, column, column, column)
-
-	stmt, err := conn.Prepare(query)
+	rows, err := c.Store.GetGear(column)
 	if err != nil {
-		return nil, fmt.Errorf("error preparing query: %v", err)
+		return nil, err
 	}
-	defer func() {
-		if finalizeErr := stmt.Finalize(); finalizeErr != nil {
-			err = fmt.Errorf("error finalizing statement: %v", finalizeErr)
-		}
-	}()
-
-	stmt.BindText(1, "0001-01-01T00:00:00.000Z")
 
 	var gear GearItems
 	lensMap := make(map[string]int)
-	for {
-		hasRow, err := stmt.Step()
-		if err != nil {
-			return nil, fmt.Errorf("error stepping through query results: %v", err)
-		}
-		if !hasRow {
-			break
-		}
-
-		name := stmt.ColumnText(0)
+	for _, row := range rows {
+		name := row.Name
 		if column == "focallength35" {
 			name = strings.TrimSuffix(name, ".0")
 		}
-		total := int(stmt.ColumnInt64(1))
+		total := row.Total
 
 		if name != "" && total != 0 {
 			if column == "lens" {
@@ -1,52 +1,11 @@
 package queries
 
-import (
-	"context"
-	"fmt"
-
-	"github.com/robbymilo/rgallery/pkg/database"
-	"zombiezen.com/go/sqlite"
-	"zombiezen.com/go/sqlite/sqlitex"
-)
-
 // GetMediaItems returns all media items for use in scanning.
 func GetMediaItems(offset int, direction string, total int, c Conf) ([]Media, error) {
-	pool, err := sqlitex.NewPool(database.NewSqlConnectionString(c), sqlitex.PoolOptions{
-		Flags:    sqlite.OpenReadOnly,
-		PoolSize: 1,
-	})
-	if err != nil {
-		return nil, fmt.Errorf("error opening sqlite db pool: %v", err)
-	}
-	defer func() {
-		if err := pool.Close(); err != nil {
-			c.Logger.Error("error closing pool", "err", err)
-		}
-	}()
-
-	conn, err := pool.Take(context.Background())
-	if err != nil {
-		return nil, fmt.Errorf("failed to take connection from pool: %w", err)
-	}
-	defer pool.Put(conn)
-
-	query := fmt.Sprintf(`SELECT %s FROM media WHERE DATE != '0001-01-01T00:00:00.000Z' ORDER BY date %s LIMIT %d OFFSET %d`, columns, direction, total, offset)
-
-	stmt, err := conn.Prepare(query)
-	if err != nil {
-		return nil, fmt.Errorf("error preparing SELECT statement: %v", err)
-	}
-
-	result, err := parseMediaRows(stmt, c)
+	rows, err := c.Store.GetMediaItems(offset, direction, total)
 	if err != nil {
 		return nil, err
 	}
 
-	err = stmt.Finalize()
-	if err != nil {
-		return nil, fmt.Errorf("error finalizing statement: %v", err)
-	}
-
-	return result, nil
-
+	return parseMediaRows(rows, c)
 }
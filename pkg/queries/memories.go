@@ -1,15 +1,9 @@
 package queries
 
 import (
-	"context"
 	"fmt"
 	"sort"
-	"strings"
 	"time"
-
-	"github.com/robbymilo/rgallery/pkg/database"
-	"zombiezen.com/go/sqlite"
-	"zombiezen.com/go/sqlite/sqlitex"
 )
 
 type MediaCollection struct {
@@ -18,46 +12,20 @@ type MediaCollection struct {
 
 // GetMemories returns media items that occurred on the today's date in previous years and groups them into years.
 func GetMemories(c Conf) (Days, error) {
-	pool, err := sqlitex.NewPool(database.NewSqlConnectionString(c), sqlitex.PoolOptions{
-		Flags:    sqlite.OpenReadOnly,
-		PoolSize: 5,
-	})
-	if err != nil {
-		return nil, fmt.Errorf("error opening sqlite db pool: %v", err)
-	}
-	defer func() {
-		if err := pool.Close(); err != nil {
-			c.Logger.Error("error closing pool", "err", err)
-		}
-	}()
-
-	conn, err := pool.Take(context.Background())
-	if err != nil {
-		return nil, fmt.Errorf("failed to take connection from pool: %w", err)
-	}
-	defer pool.Put(conn)
-
 	total := 20
 	today := time.Now()
-	var dates []string
+	var days []string
 
 	for i := 1; i <= total; i++ {
-		pastDate := today.AddDate(-i, 0, 0).Format("2006-01-02")
-		dates = append(dates, fmt.Sprintf("DATE('%s')", pastDate))
+		days = append(days, today.AddDate(-i, 0, 0).Format("2006-01-02"))
 	}
 
-	query := fmt.Sprintf(`
-SELECT %s
-FROM media
-WHERE DATE(date) IN (%s)
-`, database.Columns(), strings.Join(dates, ", "))
-
-	stmt, err := conn.Prepare(query)
+	rows, err := c.Store.GetMemories(days)
 	if err != nil {
-		return nil, fmt.Errorf("error preparing SELECT statement: %v", err)
+		return nil, err
 	}
 
-	result, err := parseMediaRows(stmt, c)
+	result, err := parseMediaRows(rows, c)
 	if err != nil {
 		return nil, err
 	}
@@ -69,11 +37,6 @@ WHERE DATE(date) IN (%s)
 		return nil, fmt.Errorf("error sorting memories: %v", err)
 	}
 
-	err = stmt.Finalize()
-	if err != nil {
-		return nil, fmt.Errorf("error finalizing statement: %v", err)
-	}
-
 	return sortedDays, nil
 }
 
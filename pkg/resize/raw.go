@@ -0,0 +1,189 @@
+package resize
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"image"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/disintegration/imaging"
+)
+
+// previewTags are the exiftool tags of the JPEG previews embedded in RAW files, in the order they are tried. The
+// first is the full size preview in formats that have more than one.
+var previewTags = []string{"JpgFromRaw", "PreviewImage", "OtherImage"}
+
+// createSaveRawThumb saves and returns a thumbnail of a RAW file.
+func createSaveRawThumb(path string, media Media, size int, c Conf) ([]byte, error) {
+	if c.ResizeService != "" {
+		file, err := GetThumbFromResizeService(media, size, c)
+		if err != nil {
+			return nil, fmt.Errorf("error getting thumb from resizer: %v", err)
+		}
+
+		return file, nil
+	}
+
+	img, err := decodeRaw(path, c)
+	if err != nil {
+		return nil, fmt.Errorf("error decoding raw image: %v", err)
+	}
+
+	err = imageToThumb(img, media, size, c)
+	if err != nil {
+		return nil, fmt.Errorf("error generating thumb from image: %v", err)
+	}
+
+	// load saved file
+	file, err := os.ReadFile(CreateThumbFilePath(media.Hash, size, c))
+	if err != nil {
+		return nil, fmt.Errorf("error loading generated thumb: %v", err)
+	}
+
+	c.Logger.Info("resized image", "path", path, "size", size, "hash", media.Hash)
+
+	return file, nil
+}
+
+// decodeRaw decodes a RAW file with the raw converter if one is set, falling back to the largest JPEG preview embedded
+// in the file.
+func decodeRaw(path string, c Conf) (image.Image, error) {
+	if c.RawConverter != "" {
+		img, err := convertRaw(path, c)
+		if err == nil {
+			return img, nil
+		}
+		c.Logger.Warn("error converting raw file, using embedded preview", "path", path, "error", err)
+	}
+
+	return rawPreview(path)
+}
+
+// convertRaw converts a RAW file to a temporary JPEG with the raw converter, called with the paths of the RAW file
+// and the JPEG to write, and decodes it.
+func convertRaw(path string, c Conf) (image.Image, error) {
+	tmpDir, err := os.MkdirTemp("", "rgallery_temp_*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temporary directory: %v", err)
+	}
+	defer func() {
+		if err := os.RemoveAll(tmpDir); err != nil {
+			c.Logger.Error("os.RemoveAll error:", "err", err)
+		}
+	}()
+
+	tmpFile := filepath.Join(tmpDir, filepath.Base(path)+".jpg")
+	args := append(strings.Fields(c.RawConverter), path, tmpFile)
+	out, err := exec.Command(args[0], args[1:]...).CombinedOutput()
+	if err != nil {
+		return nil, fmt.Errorf("error calling %s: %v: %s", args[0], err, bytes.TrimSpace(out))
+	}
+
+	// the converter applies the orientation of the RAW file
+	img, err := imaging.Open(tmpFile, imaging.AutoOrientation(true))
+	if err != nil {
+		return nil, fmt.Errorf("error decoding converted raw file: %v", err)
+	}
+
+	return img, nil
+}
+
+// rawPreview decodes the JPEG preview embedded in a RAW file and rotates it to the orientation of the RAW file. The
+// previews and orientation are read with a single exiftool call.
+func rawPreview(path string) (image.Image, error) {
+	args := append([]string{"-json", "-b", "-n"}, previewArgs()...)
+	out, err := exec.Command("exiftool", append(args, "-Orientation", path)...).Output()
+	if err != nil {
+		return nil, fmt.Errorf("error calling exiftool command: %v", err)
+	}
+
+	preview, orientation, err := parseRawPreview(out)
+	if err != nil {
+		return nil, err
+	}
+
+	// previews do not have an orientation of their own, or have one that does not match the RAW file
+	img, err := imaging.Decode(bytes.NewReader(preview))
+	if err != nil {
+		return nil, fmt.Errorf("error decoding raw preview: %v", err)
+	}
+
+	return orient(img, orientation), nil
+}
+
+// previewArgs returns the exiftool arguments that extract previewTags.
+func previewArgs() []string {
+	args := make([]string, len(previewTags))
+	for i, tag := range previewTags {
+		args[i] = "-" + tag
+	}
+
+	return args
+}
+
+// parseRawPreview returns the first of previewTags, and the orientation, of the JSON output of exiftool, which
+// encodes binary tags as base64.
+func parseRawPreview(out []byte) ([]byte, int, error) {
+	var files []map[string]any
+	if err := json.Unmarshal(out, &files); err != nil {
+		return nil, 0, fmt.Errorf("error decoding exiftool output: %v", err)
+	}
+	if len(files) == 0 {
+		return nil, 0, fmt.Errorf("raw file has no embedded preview")
+	}
+
+	// no orientation is the same as the default orientation
+	orientation := 1
+	if o, ok := files[0]["Orientation"].(float64); ok {
+		orientation = int(o)
+	}
+
+	for _, tag := range previewTags {
+		value, ok := files[0][tag].(string)
+		if !ok {
+			continue
+		}
+
+		encoded, ok := strings.CutPrefix(value, "base64:")
+		if !ok {
+			continue
+		}
+
+		preview, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, 0, fmt.Errorf("error decoding %s: %v", tag, err)
+		}
+		if len(preview) > 0 {
+			return preview, orientation, nil
+		}
+	}
+
+	return nil, 0, fmt.Errorf("raw file has no embedded preview")
+}
+
+// orient transforms an image by an EXIF orientation.
+func orient(img image.Image, orientation int) image.Image {
+	switch orientation {
+	case 2:
+		return imaging.FlipH(img)
+	case 3:
+		return imaging.Rotate180(img)
+	case 4:
+		return imaging.FlipV(img)
+	case 5:
+		return imaging.Transpose(img)
+	case 6:
+		return imaging.Rotate270(img)
+	case 7:
+		return imaging.Transverse(img)
+	case 8:
+		return imaging.Rotate90(img)
+	default:
+		return img
+	}
+}
@@ -0,0 +1,78 @@
+package resize
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestOrient(t *testing.T) {
+	// a 3x2 image with a marked top left pixel
+	img := image.NewNRGBA(image.Rect(0, 0, 3, 2))
+	img.Set(0, 0, color.White)
+
+	tests := []struct {
+		orientation int
+		w, h        int
+		x, y        int
+	}{
+		{1, 3, 2, 0, 0},
+		{2, 3, 2, 2, 0},
+		{3, 3, 2, 2, 1},
+		{4, 3, 2, 0, 1},
+		{5, 2, 3, 0, 0},
+		{6, 2, 3, 1, 0},
+		{7, 2, 3, 1, 2},
+		{8, 2, 3, 0, 2},
+	}
+
+	for _, test := range tests {
+		oriented := orient(img, test.orientation)
+		bounds := oriented.Bounds()
+		if bounds.Dx() != test.w || bounds.Dy() != test.h {
+			t.Errorf("orientation %d: got %dx%d, want %dx%d", test.orientation, bounds.Dx(), bounds.Dy(), test.w, test.h)
+			continue
+		}
+
+		if r, _, _, _ := oriented.At(test.x, test.y).RGBA(); r == 0 {
+			t.Errorf("orientation %d: top left pixel is not at %d,%d", test.orientation, test.x, test.y)
+		}
+	}
+}
+
+func TestParseRawPreview(t *testing.T) {
+	preview := base64.StdEncoding.EncodeToString([]byte("preview"))
+	other := base64.StdEncoding.EncodeToString([]byte("other"))
+
+	tests := []struct {
+		out         string
+		preview     string
+		orientation int
+		err         bool
+	}{
+		// the full size preview is preferred
+		{fmt.Sprintf(`[{"SourceFile":"a.NEF","JpgFromRaw":"base64:%s","PreviewImage":"base64:%s","Orientation":6}]`, preview, other), "preview", 6, false},
+		{fmt.Sprintf(`[{"SourceFile":"a.CR3","PreviewImage":"base64:%s","Orientation":8}]`, preview), "preview", 8, false},
+		// no orientation
+		{fmt.Sprintf(`[{"SourceFile":"a.RAF","OtherImage":"base64:%s"}]`, preview), "preview", 1, false},
+		// empty previews are skipped
+		{fmt.Sprintf(`[{"SourceFile":"a.DNG","JpgFromRaw":"base64:","PreviewImage":"base64:%s"}]`, preview), "preview", 1, false},
+		{`[{"SourceFile":"a.DNG","Orientation":1}]`, "", 0, true},
+		{`[]`, "", 0, true},
+		{`not json`, "", 0, true},
+	}
+
+	for _, test := range tests {
+		got, orientation, err := parseRawPreview([]byte(test.out))
+		if (err != nil) != test.err {
+			t.Errorf("%s: got error %v", test.out, err)
+			continue
+		}
+		if !bytes.Equal(got, []byte(test.preview)) || orientation != test.orientation {
+			t.Errorf("%s: got %q with orientation %d, want %q with orientation %d", test.out, got, orientation, test.preview, test.orientation)
+		}
+	}
+}
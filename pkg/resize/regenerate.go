@@ -1,15 +1,17 @@
 package resize
 
 import (
-	"path/filepath"
+	"fmt"
+	"image"
 	"sync"
 
 	"github.com/robbymilo/rgallery/pkg/config"
 	"github.com/robbymilo/rgallery/pkg/sizes"
 )
 
-// HandleResize coordinates the parallel (go routine) generation of all non-existing thumbnails.
-func HandleResize(regenThumb bool, media Media, c Conf) (int, error) {
+// HandleResize coordinates the parallel (go routine) generation of all non-existing thumbnails. img is the decoded
+// image of an image media item, if the caller has already decoded it, and is nil otherwise.
+func HandleResize(regenThumb bool, media Media, img image.Image, c Conf) (int, error) {
 	// build a map of sizes for the thumbnail
 	var s []int
 	final := false
@@ -24,50 +26,67 @@ func HandleResize(regenThumb bool, media Media, c Conf) (int, error) {
 	}
 
 	if len(s) > 0 && c.PreGenerateThumb && regenThumb {
-		var wg sync.WaitGroup
-		errChan := make(chan error, len(s))
-
-		for _, size := range s {
-			wg.Add(1)
-			go AddImageThumb(media, size, c, &wg, errChan)
+		// If any errors occurred, return the first one
+		for _, err := range GenerateThumbs(media, img, s, c) {
+			if err != nil {
+				return len(s), err
+			}
 		}
+	}
 
-		// Start a goroutine to close error channel when all workers are done
-		go func() {
-			wg.Wait()
-			close(errChan)
-		}()
+	return len(s), nil
+}
 
-		// Collect any errors from the goroutines
-		var errors []error
-		for err := range errChan {
+// GenerateThumbs generates the thumbnails of a media item in each of sizes in parallel, returning the error of each
+// size. Images are decoded once, unless img is already decoded, and every size is resized from the decoded image, as
+// decoding RAW files and the images converted with vips takes far longer than resizing them.
+func GenerateThumbs(media Media, img image.Image, sizes []int, c Conf) []error {
+	errs := make([]error, len(sizes))
+	path := config.OriginalPath(media.Library, media.Path, c)
+
+	generate := func(size int) error {
+		_, err := GenerateSingleThumb(path, media, size, c)
+		return err
+	}
+
+	if media.Type == "image" && c.ResizeService == "" {
+		if img == nil {
+			var err error
+			img, err = DecodeImage(path, c)
 			if err != nil {
-				errors = append(errors, err)
+				for i := range errs {
+					errs[i] = fmt.Errorf("error decoding original image: %v", err)
+				}
+				c.Logger.Error("error generating thumbnails", "path", media.Path, "error", err)
+				return errs
 			}
 		}
 
-		// If any errors occurred, return the first one
-		if len(errors) > 0 {
-			return len(s), errors[0]
+		generate = func(size int) error {
+			if err := imageToThumb(img, media, size, c); err != nil {
+				return fmt.Errorf("error generating thumb from image: %v", err)
+			}
+			c.Logger.Info("resized image", "path", path, "size", size, "hash", media.Hash)
+			return nil
 		}
 	}
 
-	return len(s), nil
-}
-
-// AddImageThumb creates a request to generate a single thumbnail from the original image.
-func AddImageThumb(media Media, size int, c Conf, wg *sync.WaitGroup, errChan chan<- error) {
-	defer wg.Done()
+	var wg sync.WaitGroup
+	for i, size := range sizes {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
 
-	path := filepath.Join(config.MediaPath(c), media.Path)
-	_, err := GenerateSingleThumb(path, media, size, c)
-	if err != nil {
-		c.Logger.Error("error generating thumbnail",
-			"path", media.Path,
-			"size", size,
-			"error", err)
-		errChan <- err
-		return
+			if err := generate(size); err != nil {
+				c.Logger.Error("error generating thumbnail",
+					"path", media.Path,
+					"size", size,
+					"error", err)
+				errs[i] = err
+			}
+		}()
 	}
-	errChan <- nil
+	wg.Wait()
+
+	return errs
 }
@@ -2,22 +2,23 @@ package resize
 
 import (
 	"bytes"
-	"errors"
 	"fmt"
 	"image"
 	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
-	"strings"
 
-	"github.com/disintegration/imageorient"
 	"github.com/disintegration/imaging"
 	"github.com/robbymilo/rgallery/pkg/config"
+	"github.com/robbymilo/rgallery/pkg/formats"
 	"github.com/robbymilo/rgallery/pkg/sizes"
 
 	"github.com/robbymilo/rgallery/pkg/types"
 	ffmpeg "github.com/u2takey/ffmpeg-go"
+
+	// register the WebP decoder, TIFF is registered by imaging
+	_ "golang.org/x/image/webp"
 )
 
 type Conf = types.Conf
@@ -60,42 +61,25 @@ func GenerateSingleThumb(path string, media Media, size int, c Conf) ([]byte, er
 func CreateSaveImageThumb(path string, media Media, size int, c Conf) ([]byte, error) {
 	var file []byte
 
-	path, err := saveTempHeic(path)
-	if err != nil {
-		return nil, fmt.Errorf("error converting heic to temp jpg file: %v", err)
-	}
-
-	img, err := imaging.Open(path, imaging.AutoOrientation(true))
-	if err != nil {
-		return nil, fmt.Errorf("error decoding original image: %v", err)
+	if formats.IsRaw(path) {
+		return createSaveRawThumb(path, media, size, c)
 	}
 
-	// if media.Rotation != 0 {
-	// 	img = imaging.Rotate(img, media.Rotation, color.Transparent)
-	// }
-
 	// resize and save image
+	var err error
 	if c.ResizeService != "" {
 		file, err = GetThumbFromResizeService(media, size, c)
 		if err != nil {
 			return nil, fmt.Errorf("error getting thumb from resizer: %v", err)
 		}
-
-		// remove the temp file
-		if strings.Contains(strings.ToLower(path), ".heic") {
-
-			if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
-				return nil, fmt.Errorf("file does not exist: %v", err)
-			}
-
-			err := os.Remove(path)
-			if err != nil {
-				return nil, fmt.Errorf("error removing temp heic: %v", err)
-			}
+	} else {
+		var img image.Image
+		img, err = openImage(path)
+		if err != nil {
+			return nil, fmt.Errorf("error decoding original image: %v", err)
 		}
 
-	} else {
-		err := imageToThumb(img, media, size, c)
+		err = imageToThumb(img, media, size, c)
 		if err != nil {
 			return nil, fmt.Errorf("error generating thumb from image: %v", err)
 		}
@@ -105,20 +89,6 @@ func CreateSaveImageThumb(path string, media Media, size int, c Conf) ([]byte, e
 		if err != nil {
 			return nil, fmt.Errorf("error loading generated thumb: %v", err)
 		}
-
-		// remove the temp file
-		if strings.Contains(strings.ToLower(path), ".heic") {
-
-			if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
-				return nil, fmt.Errorf("file does not exist: %v", err)
-			}
-
-			err := os.Remove(path)
-			if err != nil {
-				return nil, fmt.Errorf("error removing temp heic: %v", err)
-			}
-		}
-
 	}
 
 	c.Logger.Info("resized image", "path", path, "size", size, "hash", media.Hash)
@@ -225,68 +195,60 @@ func SaveImageToDisk(path string, image image.Image, c Conf) error {
 	return nil
 }
 
-// saveTempHeic converts a .heic file to .jpg and saves it to /tmp/rgallery for usage.
-func saveTempHeic(path string) (string, error) {
-	if strings.ToLower(filepath.Ext(path)) == ".heic" {
-
-		// make temp dir
-		tmpDir, err := os.MkdirTemp("", "rgallery_temp_*")
-		if err != nil {
-			return "", fmt.Errorf("failed to create temporary directory: %v", err)
+// openImage decodes an image in its EXIF orientation. Images are decoded with a pure Go decoder where there is one,
+// and are converted to JPEG with vips otherwise, or if the Go decoder can not read them, such as JPEG compressed TIFF
+// files.
+func openImage(path string) (image.Image, error) {
+	if !formats.IsConverted(path) {
+		img, err := imaging.Open(path, imaging.AutoOrientation(true))
+		if err == nil {
+			return img, nil
 		}
-		defer func() {
-			if err := os.RemoveAll(tmpDir); err != nil {
-				fmt.Printf("os.RemoveAll error: %v\n", err)
-			}
-		}()
 
-		tmpFile := tmpDir + filepath.Base(path) + ".jpg"
-		cmd := exec.Command("vips", "resize", path, tmpFile, "1")
-		_, err = cmd.CombinedOutput()
-		if err != nil {
-			return "", fmt.Errorf("error calling vips command: %v", err)
+		converted, convertErr := convertImage(path)
+		if convertErr != nil {
+			return nil, fmt.Errorf("%v, and %v", err, convertErr)
 		}
-
-		path = tmpFile
+		return converted, nil
 	}
-	return path, nil
-}
-
-func DecodeImage(path string) (image.Image, error) {
 
-	var img image.Image
-	path, err := saveTempHeic(path)
-	if err != nil {
-		return nil, fmt.Errorf("error converting heic to temp jpg file: %v", err)
-	}
+	return convertImage(path)
+}
 
-	f, err := os.Open(path)
+// convertImage converts an image to a temporary JPEG with vips and decodes it.
+func convertImage(path string) (image.Image, error) {
+	tmpDir, err := os.MkdirTemp("", "rgallery_temp_*")
 	if err != nil {
-		return nil, fmt.Errorf("os.Open failed: %v", err)
+		return nil, fmt.Errorf("failed to create temporary directory: %v", err)
 	}
 	defer func() {
-		if err := f.Close(); err != nil {
-			fmt.Printf("file.Close error: %v", err)
+		if err := os.RemoveAll(tmpDir); err != nil {
+			fmt.Printf("os.RemoveAll error: %v\n", err)
 		}
 	}()
 
-	img, _, err = imageorient.Decode(f)
+	tmpFile := filepath.Join(tmpDir, filepath.Base(path)+".jpg")
+	out, err := exec.Command("vips", "resize", path, tmpFile, "1").CombinedOutput()
 	if err != nil {
-		return nil, fmt.Errorf("imageorient.Decode failed: %v", err)
+		return nil, fmt.Errorf("error calling vips command: %v: %s", err, bytes.TrimSpace(out))
 	}
 
-	// remove the temp file
-	if strings.Contains(strings.ToLower(path), ".heic") {
+	// vips keeps the EXIF orientation of the image
+	img, err := imaging.Open(tmpFile, imaging.AutoOrientation(true))
+	if err != nil {
+		return nil, fmt.Errorf("error decoding converted image: %v", err)
+	}
 
-		if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
-			return nil, fmt.Errorf("file does not exist: %v", err)
-		}
+	return img, nil
+}
 
-		err := os.Remove(path)
-		if err != nil {
-			return nil, fmt.Errorf("error removing temp heic: %v", err)
-		}
+// DecodeImage decodes an image in its EXIF orientation. Images without a pure Go decoder, such as HEIC, AVIF and JPEG
+// XL images, are converted with vips, and RAW images are decoded with the raw converter or from their embedded
+// preview.
+func DecodeImage(path string, c Conf) (image.Image, error) {
+	if formats.IsRaw(path) {
+		return decodeRaw(path, c)
 	}
 
-	return img, nil
+	return openImage(path)
 }
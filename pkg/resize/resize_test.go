@@ -0,0 +1,81 @@
+package resize
+
+import (
+	"image"
+	"image/color"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/disintegration/imaging"
+	"golang.org/x/image/tiff"
+)
+
+func TestOpenImageTIFF(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 3, 2))
+	img.Set(0, 0, color.White)
+
+	path := filepath.Join(t.TempDir(), "scan.TIF")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tiff.Encode(f, img, &tiff.Options{Compression: tiff.Deflate}); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// decoded without vips
+	t.Setenv("PATH", "")
+	decoded, err := openImage(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if bounds := decoded.Bounds(); bounds.Dx() != 3 || bounds.Dy() != 2 {
+		t.Errorf("got %dx%d, want 3x2", bounds.Dx(), bounds.Dy())
+	}
+	if r, _, _, _ := decoded.At(0, 0).RGBA(); r == 0 {
+		t.Error("top left pixel is not white")
+	}
+}
+
+func TestGenerateThumbs(t *testing.T) {
+	dir := t.TempDir()
+	c := Conf{
+		Media:   filepath.Join(dir, "media"),
+		Cache:   filepath.Join(dir, "cache"),
+		Quality: 90,
+		Logger:  slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+	media := Media{Hash: 1, Path: "a.jpg", Type: "image", Width: 40, Height: 20}
+	img := image.NewNRGBA(image.Rect(0, 0, 40, 20))
+
+	// the decoded image is resized without opening the original, which does not exist
+	for i, err := range GenerateThumbs(media, img, []int{10, 20}, c) {
+		if err != nil {
+			t.Errorf("size %d: %v", i, err)
+		}
+	}
+
+	for _, size := range []int{10, 20} {
+		thumb, err := imaging.Open(CreateThumbFilePath(media.Hash, size, c))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if bounds := thumb.Bounds(); bounds.Dx() != size || bounds.Dy() != size/2 {
+			t.Errorf("got %dx%d, want %dx%d", bounds.Dx(), bounds.Dy(), size, size/2)
+		}
+	}
+
+	// without a decoded image, every size fails when the original can not be decoded
+	for _, err := range GenerateThumbs(media, nil, []int{10, 20}, c) {
+		if err == nil {
+			t.Error("expected an error decoding a missing original")
+		}
+	}
+}
@@ -5,11 +5,13 @@ import (
 	"bytes"
 	"errors"
 	"fmt"
+	"image/jpeg"
 	"io"
 	"mime/multipart"
 	"net/http"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"sync"
 
@@ -19,6 +21,10 @@ import (
 	"github.com/robbymilo/rgallery/pkg/sizes"
 )
 
+// serviceExtensions are the image formats sent to the resize service as they are. Other images, such as RAW and TIFF
+// files, are decoded and sent as JPEG.
+var serviceExtensions = []string{".jpg", ".jpeg", ".png", ".gif", ".heic"}
+
 // SafeImageOperation executes an image operation function and recovers from panics
 func SafeImageOperation(operation func() ([]byte, error)) (file []byte, err error) {
 	defer func() {
@@ -32,7 +38,7 @@ func SafeImageOperation(operation func() ([]byte, error)) (file []byte, err erro
 }
 
 // HandleThumb determines whether to request a thumb from the resize service or check for one on disk.
-func HandleThumb(hash uint32, size int, c Conf) ([]byte, error) {
+func HandleThumb(hash uint64, size int, c Conf) ([]byte, error) {
 	return SafeImageOperation(func() ([]byte, error) {
 		var file []byte
 		var err error
@@ -65,7 +71,7 @@ func HandleThumb(hash uint32, size int, c Conf) ([]byte, error) {
 }
 
 // CreateThumbFromDisk checks for an existing thumbnail in the cache directory. If it does not exist, a new thumbnail is created and saved.
-func CreateThumbFromDisk(hash uint32, size int, c Conf) ([]byte, error) {
+func CreateThumbFromDisk(hash uint64, size int, c Conf) ([]byte, error) {
 	var file []byte
 	var err error
 
@@ -84,7 +90,7 @@ func CreateThumbFromDisk(hash uint32, size int, c Conf) ([]byte, error) {
 	if err != nil {
 		return file, fmt.Errorf("error getting media item: %v", err)
 	}
-	p := CreateOriginalFilePath(image.Path, c)
+	p := CreateOriginalFilePath(image.Library, image.Path, c)
 	file, err = GenerateSingleThumb(p, image, size, c)
 	if err != nil {
 		return file, fmt.Errorf("error generating single thumb: %v", err)
@@ -112,7 +118,7 @@ func GetThumbFromResizeService(media Media, size int, c Conf) ([]byte, error) {
 		err = errors.New("media path not found")
 		return nil, err
 	} else {
-		path := CreateOriginalFilePath(media.Path, c)
+		path := CreateOriginalFilePath(media.Library, media.Path, c)
 		url := fmt.Sprintf("%s/?size=%d&quality=%d", c.ResizeService, size, c.Quality)
 
 		if strings.ToLower(filepath.Ext(path)) == ".heic" {
@@ -123,7 +129,16 @@ func GetThumbFromResizeService(media Media, size int, c Conf) ([]byte, error) {
 
 		switch media.Type {
 		case "image":
-			res, err = uploadFileMultipart(url, path)
+			if !slices.Contains(serviceExtensions, strings.ToLower(filepath.Ext(path))) {
+				var file io.Reader
+				file, err = decodedJPEG(path, c)
+				if err != nil {
+					return nil, fmt.Errorf("error decoding image: %v", err)
+				}
+				res, err = uploadReaderFileMultipart(url, path+".jpg", file)
+			} else {
+				res, err = uploadFileMultipart(url, path)
+			}
 			if err != nil {
 				return nil, fmt.Errorf("error requesting image from resize service: %v", err)
 			}
@@ -178,13 +193,29 @@ func GetThumbFromResizeService(media Media, size int, c Conf) ([]byte, error) {
 }
 
 // createThumbFilePath creates a string of the path where the thumb will live.
-func CreateThumbFilePath(hash uint32, size int, c Conf) string {
+func CreateThumbFilePath(hash uint64, size int, c Conf) string {
 	return filepath.Join(config.CachePath(c), fmt.Sprint(size), fmt.Sprint(hash)+".jpg")
 }
 
-// CreateOriginalFilePath creates a string of the absolute path of the original media.
-func CreateOriginalFilePath(path string, c Conf) string {
-	return filepath.Join(config.MediaPath(c), path)
+// CreateOriginalFilePath creates a string of the absolute path of the original media in a library.
+func CreateOriginalFilePath(library, path string, c Conf) string {
+	return config.OriginalPath(library, path, c)
+}
+
+// decodedJPEG returns an image decoded with DecodeImage as a JPEG, to send to the resize service.
+func decodedJPEG(path string, c Conf) (io.Reader, error) {
+	img, err := DecodeImage(path, c)
+	if err != nil {
+		return nil, err
+	}
+
+	buf := bytes.NewBuffer(nil)
+	err = jpeg.Encode(buf, img, &jpeg.Options{Quality: 95})
+	if err != nil {
+		return nil, fmt.Errorf("error encoding jpeg: %v", err)
+	}
+
+	return buf, nil
 }
 
 // uploadFileMultipart opens a file to send to the resizer.
@@ -2,6 +2,7 @@ package rgallery
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -13,8 +14,8 @@ import (
 	"testing"
 	"time"
 
+	"github.com/jackc/pgx/v5"
 	cache "github.com/patrickmn/go-cache"
-	"github.com/robbymilo/rgallery/pkg/database"
 	"github.com/robbymilo/rgallery/pkg/scanner"
 	"github.com/robbymilo/rgallery/pkg/types"
 	"github.com/stretchr/testify/assert"
@@ -84,7 +85,32 @@ func TestEndpoints(t *testing.T) {
 		setModTime(i)
 	}
 
-	database.CreateDB(c)
+	t.Run("sqlite", func(t *testing.T) {
+		c.DBBackend = "sqlite"
+		testBackend(t)
+	})
+
+	// the postgres backend is only tested when a disposable database is available
+	postgresURL := os.Getenv("RGALLERY_TEST_POSTGRES_URL")
+	if postgresURL == "" {
+		return
+	}
+
+	t.Run("postgres", func(t *testing.T) {
+		c.DBBackend = "postgres"
+		c.PostgresURL = postgresURL
+		resetPostgres(t, postgresURL)
+		testBackend(t)
+	})
+}
+
+// testBackend scans the test media into the configured database backend and tests each endpoint's response.
+func testBackend(t *testing.T) {
+	createDB(c)
+	openDB(&c)
+	defer closeDB(c)
+
+	ca.Flush()
 
 	_, _ = scanner.Scan("default", c, ca)
 
@@ -102,22 +128,22 @@ func TestEndpoints(t *testing.T) {
 	testResponse(t, "/api/timeline?tag=idaho", "../../testdata/ResponseFilter-tag.json")
 	testResponse(t, "/api/memories", "../../testdata/ResponseFilter-memories.json")
 
-	testResponse(t, "/api/media/651935749", "../../testdata/ResponseImage-0.json")
-	testResponse(t, "/api/media/3455659031", "../../testdata/ResponseImage-1.json")
-	testResponse(t, "/api/media/4119775194", "../../testdata/ResponseImage-2.json")
-	testResponse(t, "/api/media/651935749?folder=2017/20170624-idaho", "../../testdata/ResponseImage-folder.json")
-	testResponse(t, "/api/media/651935749?tag=idaho", "../../testdata/ResponseImage-tag.json")
-	testResponse(t, "/api/media/4119775194?tag=%40acconfb", "../../testdata/ResponseImage-tag-acc.json")
-	testResponse(t, "/api/media/4119775194?tag=%23californiawildfires", "../../testdata/ResponseImage-tag-cal.json")
-	testResponse(t, "/api/media/651935749?rating=5", "../../testdata/ResponseImage-favorites.json")
+	testResponse(t, "/api/media/6414835514518706", "../../testdata/ResponseImage-0.json")
+	testResponse(t, "/api/media/6583783682651529", "../../testdata/ResponseImage-1.json")
+	testResponse(t, "/api/media/7461655450387420", "../../testdata/ResponseImage-2.json")
+	testResponse(t, "/api/media/6414835514518706?folder=2017/20170624-idaho", "../../testdata/ResponseImage-folder.json")
+	testResponse(t, "/api/media/6414835514518706?tag=idaho", "../../testdata/ResponseImage-tag.json")
+	testResponse(t, "/api/media/7461655450387420?tag=%40acconfb", "../../testdata/ResponseImage-tag-acc.json")
+	testResponse(t, "/api/media/7461655450387420?tag=%23californiawildfires", "../../testdata/ResponseImage-tag-cal.json")
+	testResponse(t, "/api/media/6414835514518706?rating=5", "../../testdata/ResponseImage-favorites.json")
 	// prev/next responses
-	testResponse(t, "/api/media/3455659031?camera=NIKON D800&format=json", "../../testdata/ResponseImage-camera.json")
-	testResponse(t, "/api/media/651935749?lens=AF-S Nikkor 50mm f%2f1.8G&format=json", "../../testdata/ResponseImage-lens.json")
-	testResponse(t, "/api/media/651935749?lens=123&format=json", "../../testdata/ResponseImage-lens.json")
-	testResponse(t, "/api/media/525791494?lens=Nikon Ai-s 105mm f%2f2.5&format=json", "../../testdata/ResponseImage-lens-1.json")
-	testResponse(t, "/api/media/264898052?focallength35=50&format=json", "../../testdata/ResponseImage-focallength35.json")
-	testResponse(t, "/api/media/3216513272?software=darktable 4.4.2&format=json", "../../testdata/ResponseImage-software.json")
-	testResponse(t, "/api/media/1129346697?term=bogus&format=json", "../../testdata/ResponseImage-term.json")
+	testResponse(t, "/api/media/6583783682651529?camera=NIKON D800&format=json", "../../testdata/ResponseImage-camera.json")
+	testResponse(t, "/api/media/6414835514518706?lens=AF-S Nikkor 50mm f%2f1.8G&format=json", "../../testdata/ResponseImage-lens.json")
+	testResponse(t, "/api/media/6414835514518706?lens=123&format=json", "../../testdata/ResponseImage-lens.json")
+	testResponse(t, "/api/media/8199730200453552?lens=Nikon Ai-s 105mm f%2f2.5&format=json", "../../testdata/ResponseImage-lens-1.json")
+	testResponse(t, "/api/media/5794035472933994?focallength35=50&format=json", "../../testdata/ResponseImage-focallength35.json")
+	testResponse(t, "/api/media/6476088331852144?software=darktable 4.4.2&format=json", "../../testdata/ResponseImage-software.json")
+	testResponse(t, "/api/media/5631010839587705?term=bogus&format=json", "../../testdata/ResponseImage-term.json")
 
 	testResponse(t, "/api/folders", "../../testdata/ResponseFolders.json")
 
@@ -130,11 +156,29 @@ func TestEndpoints(t *testing.T) {
 	testStatusCode(t, "/api/media/123", 404)
 
 	// test thumbnail generation
-	testThumbnail(t, "3455659031", "3000")
-	testThumbnail(t, "651935749", "4000")
-	testThumbnail(t, "651935749", "2400")
-	testThumbnail(t, "3455659031", "2400")
+	testThumbnail(t, "6583783682651529", "3000")
+	testThumbnail(t, "6414835514518706", "4000")
+	testThumbnail(t, "6414835514518706", "2400")
+	testThumbnail(t, "6583783682651529", "2400")
+
+}
+
+// resetPostgres drops every table of the test database so each run starts from an empty database.
+func resetPostgres(t *testing.T, url string) {
+	conn, err := pgx.Connect(context.Background(), url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := conn.Close(context.Background()); err != nil {
+			t.Log("conn.Close error:", err)
+		}
+	}()
 
+	_, err = conn.Exec(context.Background(), "DROP SCHEMA public CASCADE; CREATE SCHEMA public;")
+	if err != nil {
+		t.Fatal(err)
+	}
 }
 
 func testResponse(t *testing.T, path string, json_relative_path string) {
@@ -4,6 +4,7 @@ import (
 	"io/fs"
 	"net/http"
 	"os"
+	"path/filepath"
 	"strings"
 
 	chiprometheus "github.com/766b/chi-prometheus"
@@ -62,6 +63,7 @@ func SetupRouter(c Conf, cache *cache.Cache, Commit, Tag string) *chi.Mux {
 		r.Use(middleware.Auth(c))
 		r.Use(middleware.Logger(c))
 		r.Get("/{hash}/{file}", server.ServeTranscode)
+		r.Get("/{hash}/{rendition}/{file}", server.ServeRendition)
 	})
 
 	// route spa files
@@ -114,8 +116,24 @@ func SetupRouter(c Conf, cache *cache.Cache, Commit, Tag string) *chi.Mux {
 				return
 			}
 
-			// Serve the file
-			http.StripPrefix("/api/media-originals/", http.FileServer(http.Dir(config.MediaPath(c)))).ServeHTTP(w, r)
+			// Serve the file from the library in the library param, or from the first library that has it
+			root := ""
+			if library := r.URL.Query().Get("library"); library != "" {
+				root = config.LibraryPath(library, c)
+			} else {
+				for _, lib := range config.Libraries(c) {
+					root = config.LibraryPath(lib.Name, c)
+					if _, err := os.Stat(filepath.Join(root, path)); err == nil {
+						break
+					}
+				}
+			}
+			if root == "" {
+				http.Error(w, "Unknown library", http.StatusNotFound)
+				return
+			}
+
+			http.StripPrefix("/api/media-originals/", http.FileServer(http.Dir(root))).ServeHTTP(w, r)
 		}))
 
 		r.Get("/404", server.Send404)
@@ -124,6 +142,12 @@ func SetupRouter(c Conf, cache *cache.Cache, Commit, Tag string) *chi.Mux {
 		r.Get("/memories", server.ServeMemories)
 
 		r.Get("/media/{hash}", server.ServeMedia)
+		r.Patch("/media/{hash}", func(w http.ResponseWriter, r *http.Request) {
+			server.EditMedia(w, r, cache)
+		})
+		r.Get("/media/{hash}/similar", func(w http.ResponseWriter, r *http.Request) {
+			server.ServeSimilar(w, r, cache)
+		})
 
 		r.Get("/folders", server.ServeFolders)
 		r.Get("/folder*", server.ServeFolder)
@@ -131,6 +155,8 @@ func SetupRouter(c Conf, cache *cache.Cache, Commit, Tag string) *chi.Mux {
 		r.Get("/tags", server.ServeTags)
 		r.Get("/tag/{slug}", server.ServeTag)
 
+		r.Get("/duplicates", server.ServeDuplicates)
+
 		r.Get("/map", server.ServeMap)
 		r.Get("/gear", server.ServeGear)
 		r.Get("/admin", func(w http.ResponseWriter, r *http.Request) {
@@ -148,7 +174,7 @@ func SetupRouter(c Conf, cache *cache.Cache, Commit, Tag string) *chi.Mux {
 		r.NotFound(server.NotFound)
 		r.MethodNotAllowed(server.NotAllowed)
 
-		// scan only new/modified items
+		// queue a scan
 		r.Get("/scan", func(w http.ResponseWriter, r *http.Request) {
 			server.Scan(w, r, cache)
 		})
@@ -158,6 +184,11 @@ func SetupRouter(c Conf, cache *cache.Cache, Commit, Tag string) *chi.Mux {
 			server.CancelScanHandler(w, r)
 		})
 
+		// queued and recent scan, thumbnail scan and transcode jobs
+		r.Get("/jobs", server.ServeJobs)
+		r.Get("/jobs/{id}", server.ServeJob)
+		r.Post("/jobs/{id}/cancel", server.CancelJob)
+
 		r.Get("/notifications", func(w http.ResponseWriter, r *http.Request) {
 			server.ServeNotifications(w, r)
 		})
@@ -238,6 +269,9 @@ func SetupMetrics(c Conf) *chi.Mux {
 	if !strings.HasSuffix(os.Args[0], ".test") {
 		cs := metrics.MetricsCollector(c)
 		prometheus.MustRegister(cs)
+		if collector, ok := c.Store.(prometheus.Collector); ok {
+			prometheus.MustRegister(collector)
+		}
 
 		r.Handle("/metrics", promhttp.Handler())
 	}
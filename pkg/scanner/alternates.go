@@ -0,0 +1,240 @@
+package scanner
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	cache "github.com/patrickmn/go-cache"
+	"github.com/robbymilo/rgallery/pkg/config"
+	"github.com/robbymilo/rgallery/pkg/exif"
+	"github.com/robbymilo/rgallery/pkg/formats"
+	"github.com/robbymilo/rgallery/pkg/transcode"
+	"github.com/robbymilo/rgallery/pkg/types"
+)
+
+// alternateOnly is the rank of formats that are never stored as media items.
+const alternateOnly = 4
+
+// motionVideo is the rank of the formats of the videos of live photos, which are stored as an alternate of a photo
+// with the same name if they look like the video of a live photo, see exif.IsLivePhotoVideo, and otherwise as media
+// items of their own.
+const motionVideo = 5
+
+// renditionRank returns the preference of a file as the primary rendition of the files with its name, lowest first,
+// or -1 if it is never grouped with other files.
+func renditionRank(path string) int {
+	if strings.HasPrefix(filepath.Base(path), ".") {
+		return -1
+	}
+
+	if !isImage(path) {
+		if isVideo(path) && formats.IsLiveVideo(path) {
+			return motionVideo
+		}
+		if formats.IsAlternateOnly(path) {
+			return alternateOnly
+		}
+		return -1
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".jpg", ".jpeg":
+		return 0
+	case ".heic", ".avif", ".webp", ".jxl":
+		return 1
+	case ".png", ".gif", ".tif", ".tiff":
+		return 2
+	default:
+		return 3
+	}
+}
+
+// renditions groups the files in a directory that have the same name, such as DSC_0001.NEF and DSC_0001.JPG, or the
+// IMG_0001.HEIC and IMG_0001.MOV of a live photo, so they are stored as one media item. Directory listings are cached,
+// so a renditions is only used for one scan.
+type renditions struct {
+	root       string
+	scanErrors map[string]time.Time
+	ignore     *ignoreRules
+	// dirs maps each listed directory to the files in it by name without extension
+	dirs map[string]map[string][]string
+	// live tests if a video is the video of the live photo with the same name, given their absolute paths
+	live func(video, photo string) bool
+	// liveVideos caches the result of live for each video
+	liveVideos map[string]bool
+}
+
+func newRenditions(root string, scanErrors map[string]time.Time, ignore *ignoreRules) *renditions {
+	return &renditions{
+		root:       root,
+		scanErrors: scanErrors,
+		ignore:     ignore,
+		dirs:       make(map[string]map[string][]string),
+		live:       exif.IsLivePhotoVideo,
+		liveVideos: make(map[string]bool),
+	}
+}
+
+// list returns the files in a directory relative to the media directory, by name without extension.
+func (r *renditions) list(dir string) map[string][]string {
+	if names, ok := r.dirs[dir]; ok {
+		return names
+	}
+
+	names := make(map[string][]string)
+	entries, _ := os.ReadDir(filepath.Join(r.root, dir))
+	for _, entry := range entries {
+		if entry.IsDir() || renditionRank(entry.Name()) < 0 || r.ignore.ignored(filepath.Join(dir, entry.Name()), false) {
+			continue
+		}
+		stem := strings.TrimSuffix(entry.Name(), filepath.Ext(entry.Name()))
+		names[stem] = append(names[stem], filepath.Join(dir, entry.Name()))
+	}
+	r.dirs[dir] = names
+
+	return names
+}
+
+// group returns the files in the directory of relative_path with the same name, which includes relative_path if it
+// exists.
+func (r *renditions) group(relative_path string) []string {
+	if renditionRank(relative_path) < 0 {
+		return nil
+	}
+
+	name := filepath.Base(relative_path)
+	return r.list(filepath.Dir(relative_path))[strings.TrimSuffix(name, filepath.Ext(name))]
+}
+
+// primary returns the file of the group of relative_path that is stored as a media item. Files that failed to scan
+// are passed over, and relative_path is returned if no other file is preferred. A video is only stored as an
+// alternate of a photo if it is the video of a live photo.
+func (r *renditions) primary(relative_path string) string {
+	primary, rank := relative_path, renditionRank(relative_path)
+	if rank < 0 {
+		return relative_path
+	}
+	video := rank == motionVideo
+	if rank == alternateOnly || r.errored(relative_path) {
+		rank = alternateOnly
+	}
+
+	for _, p := range r.group(relative_path) {
+		pRank := renditionRank(p)
+		if pRank >= alternateOnly || r.errored(p) {
+			continue
+		}
+		if pRank < rank || pRank == rank && p < primary {
+			primary, rank = p, pRank
+		}
+	}
+
+	if video && primary != relative_path && !r.isLive(relative_path, primary) {
+		return relative_path
+	}
+
+	return primary
+}
+
+// isLive tests if a video is the video of the live photo with the same name, caching the result.
+func (r *renditions) isLive(video, photo string) bool {
+	live, ok := r.liveVideos[video]
+	if !ok {
+		live = r.live(filepath.Join(r.root, video), filepath.Join(r.root, photo))
+		r.liveVideos[video] = live
+	}
+
+	return live
+}
+
+// errored tests if a file failed to scan and has not been modified since.
+func (r *renditions) errored(relative_path string) bool {
+	lastErrorTime, ok := r.scanErrors[relative_path]
+	if !ok {
+		return false
+	}
+
+	file, err := os.Stat(filepath.Join(r.root, relative_path))
+	return err == nil && file.ModTime().Before(lastErrorTime)
+}
+
+// alternatesIn returns the alternates in directories, mapped to the file they are an alternate of.
+func (r *renditions) alternatesIn(dirs []string) map[string]string {
+	alternates := make(map[string]string)
+	for _, dir := range dirs {
+		for _, files := range r.list(dir) {
+			for _, p := range files {
+				if primary := r.primary(p); primary != p {
+					alternates[p] = primary
+				}
+			}
+		}
+	}
+
+	return alternates
+}
+
+// demoteMediaItem removes a media item that is now an alternate of another file.
+func demoteMediaItem(media Media, primary string, c Conf, cache *cache.Cache) {
+	err := deleteMediaItem(media.Path, true, media, c, cache)
+	if err != nil {
+		c.Logger.Error("error removing item", "error", err)
+		return
+	}
+
+	c.Logger.Info("stored " + media.Path + " as an alternate of " + primary)
+}
+
+// saveAlternates stores the alternates mapped to the files they are an alternate of, replacing the alternates in
+// folders of the library being scanned, or every alternate of the library if folders is nil. Alternates of files that
+// are not in the db are skipped.
+func saveAlternates(folders []string, alternates map[string]string, c Conf) error {
+	items, err := libraryItems(c)
+	if err != nil {
+		return err
+	}
+
+	hashes := make(map[string]uint64, len(items))
+	for _, item := range items {
+		hashes[item.Path] = item.Hash
+	}
+
+	var save []types.Alternate
+	for p, primary := range alternates {
+		hash, ok := hashes[primary]
+		if !ok {
+			continue
+		}
+
+		file, err := os.Stat(filepath.Join(config.MediaPath(c), p))
+		if err != nil {
+			continue
+		}
+
+		save = append(save, types.Alternate{
+			Hash:     hash,
+			Path:     p,
+			Folder:   filepath.Dir(p),
+			Size:     file.Size(),
+			Modified: file.ModTime(),
+		})
+	}
+
+	err = c.Store.SetAlternates(c.Library, folders, save)
+	if err != nil {
+		return err
+	}
+
+	// the videos of live photos are transcoded like videos scanned as media items
+	if c.PreGenerateThumb {
+		for _, alternate := range save {
+			if _, err := os.Stat(transcode.CreateHLSIndexFilePath(alternate.Hash, c)); isVideo(alternate.Path) && err != nil {
+				queueTranscode(alternate.Hash, c)
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,84 @@
+package scanner
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRenditionsPrimary(t *testing.T) {
+	root := t.TempDir()
+
+	files := []string{
+		"a/DSC_0001.NEF",
+		"a/DSC_0001.JPG",
+		"a/DSC_0002.NEF",
+		"a/DSC_0003.TIF",
+		"a/IMG_1234.HEIC",
+		"a/IMG_1234.jpg",
+		"a/IMG_1235.heic",
+		"a/IMG_1235.png",
+		"a/IMG_1235.mov",
+		"a/MVI_0001.MOV",
+		"a/DSC_0004.CR2",
+		"a/DSC_0004.tiff",
+		"a/DSC_0005.NEF",
+		"a/DSC_0005.JPG",
+		"a/SCAN_0001.tif",
+		"a/SCAN_0001.jpg",
+		"a/IMG_2001.avif",
+		"a/IMG_2001.PNG",
+		"a/MVI_0002.MTS",
+		"a/MVI_0002.JPG",
+		"a/DSC_0006.JPG",
+		"a/DSC_0006.MP4",
+		"b/DSC_0001.NEF",
+	}
+	for _, f := range files {
+		path := filepath.Join(root, f)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the JPEG of DSC_0005 failed to scan
+	scanErrors := map[string]time.Time{"a/DSC_0005.JPG": time.Now().Add(time.Hour)}
+	r := newRenditions(root, scanErrors, nil)
+	// only IMG_1235.mov is the video of a live photo, DSC_0006.MP4 is a clip that shares the name of a photo
+	r.live = func(video, photo string) bool {
+		return video == filepath.Join(root, "a/IMG_1235.mov") && photo == filepath.Join(root, "a/IMG_1235.heic")
+	}
+
+	tests := map[string]string{
+		"a/DSC_0001.NEF":  "a/DSC_0001.JPG",
+		"a/DSC_0001.JPG":  "a/DSC_0001.JPG",
+		"a/DSC_0002.NEF":  "a/DSC_0002.NEF",
+		"a/DSC_0003.TIF":  "a/DSC_0003.TIF",
+		"a/IMG_1234.HEIC": "a/IMG_1234.jpg",
+		"a/IMG_1235.png":  "a/IMG_1235.heic",
+		"a/IMG_1235.mov":  "a/IMG_1235.heic",
+		"a/MVI_0001.MOV":  "a/MVI_0001.MOV",
+		"a/DSC_0004.CR2":  "a/DSC_0004.tiff",
+		"a/SCAN_0001.tif": "a/SCAN_0001.jpg",
+		"a/IMG_2001.PNG":  "a/IMG_2001.avif",
+		"a/MVI_0002.MTS":  "a/MVI_0002.MTS",
+		"a/DSC_0005.JPG":  "a/DSC_0005.NEF",
+		"a/DSC_0006.MP4":  "a/DSC_0006.MP4",
+		"a/DSC_0006.JPG":  "a/DSC_0006.JPG",
+		"b/DSC_0001.NEF":  "b/DSC_0001.NEF",
+	}
+	for path, want := range tests {
+		if got := r.primary(path); got != want {
+			t.Errorf("primary(%q) = %q, want %q", path, got, want)
+		}
+	}
+
+	alternates := r.alternatesIn([]string{"a", "b"})
+	if len(alternates) != 8 {
+		t.Errorf("expected 8 alternates, got %v", alternates)
+	}
+}
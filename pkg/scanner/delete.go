@@ -1,7 +1,6 @@
 package scanner
 
 import (
-	"database/sql"
 	"errors"
 	"fmt"
 	"os"
@@ -10,8 +9,6 @@ import (
 
 	cache "github.com/patrickmn/go-cache"
 	"github.com/robbymilo/rgallery/pkg/config"
-	"github.com/robbymilo/rgallery/pkg/database"
-	"github.com/robbymilo/rgallery/pkg/hash"
 	"github.com/robbymilo/rgallery/pkg/middleware"
 	"github.com/robbymilo/rgallery/pkg/sizes"
 )
@@ -25,78 +22,10 @@ func deleteMediaItem(path string, removeDeletedThumbnails bool, media Media, c C
 	// check if image exists in db
 	if media.Path == path {
 
-		db, err := sql.Open("sqlite", database.NewConnectionString(c))
+		err := c.Store.DeleteMediaItem(media)
 		if err != nil {
 			return err
 		}
-		defer func() {
-			if err := db.Close(); err != nil {
-				c.Logger.Error("db.Close error", "err", err)
-			}
-		}()
-
-		// delete image
-		_, err = db.Exec("DELETE FROM media WHERE hash =?", media.Hash)
-		if err != nil {
-			return err
-		}
-
-		// delete tag relationships
-		_, err = db.Exec("DELETE FROM images_tags WHERE image_id =?", media.Hash)
-		if err != nil {
-			return err
-		}
-
-		// delete tag relationships
-		for _, tag := range media.Subject {
-			// check if other items have the tag
-			query := `SELECT t.id FROM tags t JOIN images_tags i_a ON t.id = i_a.tag_id WHERE i_a.tag_id = ?`
-			rows, err := db.Query(query, hash.GetHash(tag.Key))
-			if err != nil {
-				return err
-			}
-			defer func() {
-				if err := rows.Close(); err != nil {
-					c.Logger.Error("rows.Close error", "err", err)
-				}
-			}()
-
-			// if no other items have the tag
-			if !rows.Next() {
-				// delete tag
-				c.Logger.Info("deleting tag " + tag.Key)
-				_, err = db.Exec("DELETE FROM tags WHERE id =?", hash.GetHash(tag.Key))
-				if err != nil {
-					return err
-				}
-
-			}
-
-		}
-
-		// delete folder
-		// check if other items have the folder
-		query := `SELECT f.key FROM folders f JOIN media i ON f.key = i.folder WHERE i.folder = ?`
-		rows, err := db.Query(query, media.Folder)
-		if err != nil {
-			return err
-		}
-		defer func() {
-			if err := rows.Close(); err != nil {
-				c.Logger.Error("rows.Close error", "err", err)
-			}
-		}()
-
-		// if no other items have the folder
-		if !rows.Next() {
-			// delete folder
-			c.Logger.Info("deleting folder " + media.Folder)
-			_, err = db.Exec("DELETE FROM folders WHERE key =?", media.Folder)
-			if err != nil {
-				return err
-			}
-
-		}
 
 		if removeDeletedThumbnails {
 			err = removeThumbs(path, media, c)
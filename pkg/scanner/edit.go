@@ -0,0 +1,52 @@
+package scanner
+
+import (
+	"fmt"
+	"os"
+
+	cache "github.com/patrickmn/go-cache"
+	"github.com/robbymilo/rgallery/pkg/config"
+	"github.com/robbymilo/rgallery/pkg/exif"
+	"github.com/robbymilo/rgallery/pkg/hash"
+	"github.com/robbymilo/rgallery/pkg/middleware"
+)
+
+// UpdateMetadata writes the rating, subjects, title and description of an edited media item to its file or sidecar,
+// then saves the item with the new modification time, checksum and size of the file so it is not rescanned.
+func UpdateMetadata(media Media, c Conf, cache *cache.Cache) error {
+	absolute_path := config.OriginalPath(media.Library, media.Path, c)
+	if absolute_path == "" {
+		return fmt.Errorf("library %s is not configured", media.Library)
+	}
+
+	err := exif.WriteMetadata(absolute_path, media, c)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.Stat(absolute_path)
+	if err != nil {
+		return fmt.Errorf("error getting file info: %v", err)
+	}
+
+	checksum, err := hash.GetChecksum(absolute_path)
+	if err != nil {
+		return fmt.Errorf("error getting checksum: %v", err)
+	}
+
+	media.Modified = exif.ModTime(absolute_path, file).UTC()
+	media.Checksum = checksum
+	media.Size = file.Size()
+
+	err = c.Store.UpdateMediaItem(media)
+	if err != nil {
+		return err
+	}
+
+	cache.Flush()
+	middleware.RemoveEtags()
+
+	c.Logger.Info("updated item " + media.Path)
+
+	return nil
+}
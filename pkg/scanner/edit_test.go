@@ -0,0 +1,50 @@
+package scanner
+
+import (
+	"testing"
+	"time"
+
+	"github.com/robbymilo/rgallery/pkg/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUpdateMediaItem(t *testing.T) {
+	c := testConf(t)
+
+	media := Media{
+		Hash:     1,
+		Path:     "a/b.jpg",
+		Folder:   "a",
+		Date:     time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+		Modified: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+		Subject:  types.Subjects{{Key: "sea", Value: "Sea"}},
+		Camera:   "camera",
+	}
+	if err := c.Store.InsertMediaItem(media); err != nil {
+		t.Fatal(err)
+	}
+
+	media.Rating = 4
+	media.Title = "title"
+	media.Subject = types.Subjects{{Key: "blue-sky", Value: "Blue Sky"}}
+	if err := c.Store.UpdateMediaItem(media); err != nil {
+		t.Fatal(err)
+	}
+
+	item, err := c.Store.GetSingleMediaItem(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, float64(4), item.Rating)
+	assert.Equal(t, "title", item.Title)
+	assert.Equal(t, "camera", item.Camera)
+	assert.Equal(t, `[{"key":"blue-sky","value":"Blue Sky"}]`, item.Subject)
+
+	// tags no other media item has are removed
+	tags, err := c.Store.GetTags("asc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Len(t, tags, 1)
+	assert.Equal(t, "blue-sky", tags[0].Key)
+}
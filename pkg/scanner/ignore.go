@@ -0,0 +1,185 @@
+package scanner
+
+import (
+	"bufio"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// ignoreFile is the name of the files listing the paths to ignore in the directory they are in and below it, in the
+// format of a .gitignore file.
+const ignoreFile = ".rgalleryignore"
+
+// ignorePattern is a line of an ignore file, or a global exclude pattern of the config file.
+type ignorePattern struct {
+	// dir is the directory the pattern is relative to, relative to the media directory
+	dir      string
+	segments []string
+	negate   bool
+	dirOnly  bool
+	// anchored patterns match from dir, others match the name of a file or directory at any depth below it
+	anchored bool
+}
+
+// parseIgnorePattern parses a line of an ignore file, returning false if it is blank or a comment.
+func parseIgnorePattern(dir, line string) (ignorePattern, bool) {
+	line = strings.TrimSpace(line)
+	if line == "" || strings.HasPrefix(line, "#") {
+		return ignorePattern{}, false
+	}
+
+	p := ignorePattern{dir: dir}
+	if strings.HasPrefix(line, "!") {
+		p.negate = true
+		line = line[1:]
+	}
+	// a leading backslash escapes a pattern starting with # or !
+	line = strings.TrimPrefix(line, `\`)
+
+	if strings.HasSuffix(line, "/") {
+		p.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+	if strings.Contains(line, "/") {
+		p.anchored = true
+		line = strings.TrimLeft(line, "/")
+	}
+	if line == "" {
+		return ignorePattern{}, false
+	}
+
+	p.segments = strings.Split(line, "/")
+	return p, true
+}
+
+// match tests if the pattern matches a path relative to the media directory.
+func (p ignorePattern) match(relative_path string, isDir bool) bool {
+	if p.dirOnly && !isDir {
+		return false
+	}
+
+	if p.dir != "." {
+		if !strings.HasPrefix(relative_path, p.dir+"/") {
+			return false
+		}
+		relative_path = strings.TrimPrefix(relative_path, p.dir+"/")
+	}
+
+	if !p.anchored {
+		return matchSegments(p.segments, []string{path.Base(relative_path)})
+	}
+
+	return matchSegments(p.segments, strings.Split(relative_path, "/"))
+}
+
+// matchSegments matches the segments of a path against the segments of a pattern, where ** matches any number of
+// segments.
+func matchSegments(pattern, segments []string) bool {
+	if len(pattern) == 0 {
+		return len(segments) == 0
+	}
+
+	if pattern[0] == "**" {
+		for i := 0; i <= len(segments); i++ {
+			if matchSegments(pattern[1:], segments[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+
+	if len(segments) == 0 {
+		return false
+	}
+
+	ok, err := path.Match(pattern[0], segments[0])
+	return err == nil && ok && matchSegments(pattern[1:], segments[1:])
+}
+
+// ignoreRules decides which paths in the media directory are ignored by scans, from the global exclude patterns of
+// the config file and the ignore files in the media directory. Ignore files are read once, so an ignoreRules is only
+// used for one scan.
+type ignoreRules struct {
+	root   string
+	global []ignorePattern
+	// dirs maps each directory read to the patterns of its ignore file
+	dirs map[string][]ignorePattern
+}
+
+func newIgnoreRules(root string, c Conf) *ignoreRules {
+	rules := &ignoreRules{root: root, dirs: make(map[string][]ignorePattern)}
+	for _, line := range c.Ignore {
+		if p, ok := parseIgnorePattern(".", line); ok {
+			rules.global = append(rules.global, p)
+		}
+	}
+
+	return rules
+}
+
+// patterns returns the patterns of the ignore file in a directory relative to the media directory.
+func (r *ignoreRules) patterns(dir string) []ignorePattern {
+	if patterns, ok := r.dirs[dir]; ok {
+		return patterns
+	}
+
+	var patterns []ignorePattern
+	if f, err := os.Open(filepath.Join(r.root, dir, ignoreFile)); err == nil {
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			if p, ok := parseIgnorePattern(dir, scanner.Text()); ok {
+				patterns = append(patterns, p)
+			}
+		}
+		f.Close()
+	}
+	r.dirs[dir] = patterns
+
+	return patterns
+}
+
+// matches tests if the last pattern matching a path ignores it, without checking the directories above it. Patterns
+// of ignore files deeper in the media directory take precedence.
+func (r *ignoreRules) matches(relative_path string, isDir bool) bool {
+	var ignored bool
+	match := func(patterns []ignorePattern) {
+		for _, p := range patterns {
+			if p.match(relative_path, isDir) {
+				ignored = !p.negate
+			}
+		}
+	}
+
+	match(r.global)
+
+	dir := "."
+	match(r.patterns(dir))
+	for _, segment := range strings.Split(path.Dir(relative_path), "/") {
+		if segment == "." {
+			break
+		}
+		dir = path.Join(dir, segment)
+		match(r.patterns(dir))
+	}
+
+	return ignored
+}
+
+// ignored tests if a path relative to the media directory is ignored, either by matching a pattern or by being in an
+// ignored directory.
+func (r *ignoreRules) ignored(relative_path string, isDir bool) bool {
+	if r == nil || relative_path == "." {
+		return false
+	}
+
+	segments := strings.Split(relative_path, "/")
+	for i := 1; i < len(segments); i++ {
+		if r.matches(strings.Join(segments[:i], "/"), true) {
+			return true
+		}
+	}
+
+	return r.matches(relative_path, isDir)
+}
@@ -0,0 +1,64 @@
+package scanner
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestIgnoreRules(t *testing.T) {
+	root := t.TempDir()
+
+	ignoreFiles := map[string]string{
+		ignoreFile:                       "# exports are kept elsewhere\nexport/\n*.tmp\n/drafts\n!keep.tmp\n",
+		filepath.Join("a", ignoreFile):   "Lightroom/**/previews\nb/*.jpg\n",
+		filepath.Join("a/b", ignoreFile): "!c.jpg\n",
+	}
+	for f, content := range ignoreFiles {
+		path := filepath.Join(root, f)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var c Conf
+	c.Ignore = []string{"@eaDir/", "**/cache/*.jpg"}
+	r := newIgnoreRules(root, c)
+
+	tests := []struct {
+		path  string
+		isDir bool
+		want  bool
+	}{
+		{"IMG_0001.jpg", false, false},
+		{"export", true, true},
+		{"export", false, false},
+		{"x/export/IMG_0001.jpg", false, true},
+		{"a/IMG_0001.tmp", false, true},
+		{"a/keep.tmp", false, false},
+		{"drafts/IMG_0001.jpg", false, true},
+		{"a/drafts/IMG_0001.jpg", false, false},
+		{"a/Lightroom/previews/IMG_0001.jpg", false, true},
+		{"a/Lightroom/1/2/previews", true, true},
+		{"Lightroom/previews/IMG_0001.jpg", false, false},
+		{"a/b/IMG_0001.jpg", false, true},
+		{"a/b/c.jpg", false, false},
+		{"a/b/c/IMG_0001.jpg", false, false},
+		{"x/@eaDir/IMG_0001.jpg", false, true},
+		{"x/y/cache/IMG_0001.jpg", false, true},
+		{"x/y/cache/IMG_0001.png", false, false},
+	}
+	for _, test := range tests {
+		if got := r.ignored(test.path, test.isDir); got != test.want {
+			t.Errorf("ignored(%q, %v) = %v, want %v", test.path, test.isDir, got, test.want)
+		}
+	}
+
+	var rules *ignoreRules
+	if rules.ignored("export/IMG_0001.jpg", false) {
+		t.Error("expected nil rules to ignore nothing")
+	}
+}
@@ -0,0 +1,561 @@
+package scanner
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	cache "github.com/patrickmn/go-cache"
+	"github.com/robbymilo/rgallery/pkg/queries"
+	"github.com/robbymilo/rgallery/pkg/transcode"
+	"github.com/robbymilo/rgallery/pkg/types"
+)
+
+// job types
+const (
+	JobScan      = "scan"
+	JobThumbnail = "thumbnail"
+	JobTranscode = "transcode"
+	JobSprites   = "sprites"
+	JobCleanup   = "cleanup"
+	JobOptimize  = "optimize"
+)
+
+// job statuses
+const (
+	JobQueued    = "queued"
+	JobRunning   = "running"
+	JobCompleted = "completed"
+	JobFailed    = "failed"
+	JobCanceled  = "canceled"
+)
+
+const (
+	// jobPollInterval is how often queued jobs are checked for without being woken, to pick up jobs queued by the
+	// scan command, by other processes sharing the database, and jobs that waited for a watch batch.
+	jobPollInterval = 10 * time.Second
+	// jobSaveInterval is the longest the progress of a running job goes unsaved.
+	jobSaveInterval = time.Second
+	// jobHeartbeatInterval is how often the running jobs of a process record that they are still running.
+	jobHeartbeatInterval = 30 * time.Second
+	// jobStaleAfter is how long a running job goes without a heartbeat before its process is assumed to have stopped,
+	// and the job is queued again.
+	jobStaleAfter = 4 * jobHeartbeatInterval
+)
+
+// ErrJobNotCancelable is returned when canceling a job that has finished, or a running transcode, sprite sheet or
+// optimize.
+var ErrJobNotCancelable = errors.New("job can not be canceled")
+
+// jobRunner runs queued jobs of some types one at a time, oldest first. Scans and video jobs have runners of their own,
+// so a long scan does not hold up the transcodes it queues.
+type jobRunner struct {
+	types []string
+	// wake wakes the runner when a job is queued
+	wake chan struct{}
+}
+
+var (
+	libraryJobs = &jobRunner{types: []string{JobScan, JobThumbnail, JobCleanup, JobOptimize}, wake: make(chan struct{}, 1)}
+	videoJobs   = &jobRunner{types: []string{JobTranscode, JobSprites}, wake: make(chan struct{}, 1)}
+	jobRunners  = []*jobRunner{libraryJobs, videoJobs}
+)
+
+var (
+	// jobOwner identifies this process as the owner of the jobs it runs
+	jobOwner = newJobOwner()
+	// enqueueMutex prevents the same job being queued twice at once
+	enqueueMutex sync.Mutex
+	// runningJobs are the ids of the jobs being run by this process
+	runningJobs = make(map[int64]bool)
+	// mutex to protect access to runningJobs
+	runningJobsMutex sync.RWMutex
+)
+
+// newJobOwner returns an id for this process that is unique among the processes sharing a database.
+func newJobOwner() string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "rgallery"
+	}
+
+	b := make([]byte, 4)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%s-%d", host, os.Getpid())
+	}
+
+	return fmt.Sprintf("%s-%d-%x", host, os.Getpid(), b)
+}
+
+// jobTime formats a job timestamp in the same format as notifications.
+func jobTime() string {
+	return time.Now().UTC().Format("2006-01-02T15:04:05Z")
+}
+
+// EnqueueJob queues a job to run once the jobs queued before it have finished. If a job of the same type and scope
+// is already queued, it is returned instead. Scan jobs have a scope of default, metadata or deep, followed by the name
+// of a library to scan only that library, such as deep:photos, transcode and sprites jobs the hash of the video, and
+// other jobs no scope.
+func EnqueueJob(c Conf, jobType, scope string) (types.Job, error) {
+	switch jobType {
+	case JobScan:
+		scanType, library := parseScanScope(scope)
+		if scanType != "default" && scanType != "metadata" && scanType != "deep" {
+			return types.Job{}, fmt.Errorf("unknown scan type %s", scanType)
+		}
+		if _, err := scanLibraries(library, c); err != nil {
+			return types.Job{}, err
+		}
+	case JobThumbnail, JobCleanup, JobOptimize:
+		scope = ""
+	case JobTranscode, JobSprites:
+		if _, err := strconv.ParseUint(scope, 10, 64); err != nil {
+			return types.Job{}, fmt.Errorf("invalid video hash %s", scope)
+		}
+	default:
+		return types.Job{}, fmt.Errorf("unknown job type %s", jobType)
+	}
+
+	enqueueMutex.Lock()
+	defer enqueueMutex.Unlock()
+
+	queued, err := c.Store.GetJobsWithStatus(JobQueued)
+	if err != nil {
+		return types.Job{}, fmt.Errorf("error getting queued jobs: %v", err)
+	}
+
+	for _, job := range queued {
+		if job.Type == jobType && job.Scope == scope {
+			return job, nil
+		}
+	}
+
+	job := types.Job{Type: jobType, Scope: scope, Status: JobQueued, CreatedAt: jobTime()}
+	job.ID, err = c.Store.AddJob(job)
+	if err != nil {
+		return types.Job{}, fmt.Errorf("error adding job: %v", err)
+	}
+	c.Logger.Info("queued job", "id", job.ID, "type", job.Type, "scope", job.Scope)
+
+	runnerOf(jobType).wakeUp()
+
+	return job, nil
+}
+
+// runnerOf returns the runner of a job type.
+func runnerOf(jobType string) *jobRunner {
+	if slices.Contains(videoJobs.types, jobType) {
+		return videoJobs
+	}
+
+	return libraryJobs
+}
+
+func (r *jobRunner) wakeUp() {
+	select {
+	case r.wake <- struct{}{}:
+	default:
+	}
+}
+
+// queueTranscode queues a transcode of the video, or live or motion photo, with hash.
+func queueTranscode(hash uint64, c Conf) {
+	if _, err := EnqueueJob(c, JobTranscode, strconv.FormatUint(hash, 10)); err != nil {
+		c.Logger.Error("error queuing transcode", "error", err)
+	}
+}
+
+// queueSprites queues the sprite sheet of the video with hash.
+func queueSprites(hash uint64, c Conf) {
+	if _, err := EnqueueJob(c, JobSprites, strconv.FormatUint(hash, 10)); err != nil {
+		c.Logger.Error("error queuing sprite sheet", "error", err)
+	}
+}
+
+// RunJobs runs queued jobs until the process exits, with a runner for scans and maintenance and another for videos.
+// Jobs left running by a process that stopped are queued again, and resume where they left off. A sqlite database is
+// only used by this process, so its running jobs are queued again straight away; jobs in a postgres database shared
+// with other processes are only queued again once they have gone jobStaleAfter without a heartbeat.
+func RunJobs(c Conf, cache *cache.Cache) {
+	if c.DBBackend == "postgres" {
+		requeueStaleJobs(c, time.Now().Add(-jobStaleAfter))
+	} else {
+		requeueStaleJobs(c, time.Now().Add(time.Second))
+	}
+
+	for _, r := range jobRunners {
+		go r.run(c, cache)
+	}
+
+	ticker := time.NewTicker(jobHeartbeatInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		heartbeat(c)
+		requeueStaleJobs(c, time.Now().Add(-jobStaleAfter))
+	}
+}
+
+// RunQueuedJobs runs queued jobs until none are left or the next ones have to wait for a scan that is not a job, such
+// as a watch batch.
+func RunQueuedJobs(c Conf, cache *cache.Cache) {
+	done := make(chan struct{})
+	defer close(done)
+
+	go func() {
+		ticker := time.NewTicker(jobHeartbeatInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				heartbeat(c)
+			}
+		}
+	}()
+
+	// jobs queued by a runner, such as the transcodes queued by a scan, are run by the next round
+	for {
+		var wg sync.WaitGroup
+		ran := make([]bool, len(jobRunners))
+		for i, r := range jobRunners {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				ran[i] = r.runQueued(c, cache)
+			}()
+		}
+		wg.Wait()
+
+		if !slices.Contains(ran, true) {
+			return
+		}
+	}
+}
+
+// heartbeat records that the jobs run by this process are still running.
+func heartbeat(c Conf) {
+	if err := c.Store.HeartbeatJobs(jobOwner, jobTime()); err != nil {
+		c.Logger.Error("error saving job heartbeat", "error", err)
+	}
+}
+
+// requeueStaleJobs queues the running jobs whose last heartbeat is before a time again.
+func requeueStaleJobs(c Conf, before time.Time) {
+	stale, err := c.Store.RequeueStaleJobs(before.UTC().Format("2006-01-02T15:04:05Z"))
+	if err != nil {
+		c.Logger.Error("error queuing interrupted jobs", "error", err)
+		return
+	}
+
+	for _, job := range stale {
+		c.Logger.Info("resuming interrupted job", "id", job.ID, "type", job.Type, "scope", job.Scope)
+		runnerOf(job.Type).wakeUp()
+	}
+}
+
+// run runs queued jobs until the process exits.
+func (r *jobRunner) run(c Conf, cache *cache.Cache) {
+	ticker := time.NewTicker(jobPollInterval)
+	defer ticker.Stop()
+
+	for {
+		r.runQueued(c, cache)
+
+		select {
+		case <-r.wake:
+		case <-ticker.C:
+		}
+	}
+}
+
+// runQueued runs the queued jobs of the runner, oldest first, until none are left or the next one has to wait for a
+// scan that is not a job. It returns true if a job was run.
+func (r *jobRunner) runQueued(c Conf, cache *cache.Cache) bool {
+	var ran bool
+	for {
+		queued, err := c.Store.GetJobsWithStatus(JobQueued)
+		if err != nil {
+			c.Logger.Error("error getting queued jobs", "error", err)
+			return ran
+		}
+
+		i := slices.IndexFunc(queued, func(job types.Job) bool { return slices.Contains(r.types, job.Type) })
+		if i < 0 {
+			return ran
+		}
+
+		job := queued[i]
+		if job.Type != JobTranscode && job.Type != JobSprites && IsScanInProgress() {
+			return ran
+		}
+
+		job.StartedAt = jobTime()
+		started, err := c.Store.StartJob(job.ID, jobOwner, job.StartedAt)
+		if err != nil {
+			c.Logger.Error("error starting job", "error", err)
+			return ran
+		}
+
+		// the job was canceled, or started by another process, since it was read
+		if !started {
+			continue
+		}
+
+		job.Status = JobRunning
+		job.Owner, job.Heartbeat = jobOwner, job.StartedAt
+		if requeued := runJob(job, c, cache); requeued {
+			return ran
+		}
+		ran = true
+	}
+}
+
+// runJob runs a job and saves its outcome. It returns true if the job was queued again as a scan was running.
+func runJob(job types.Job, c Conf, cache *cache.Cache) bool {
+	c.Logger.Info("starting job", "id", job.ID, "type", job.Type, "scope", job.Scope)
+
+	setRunningJob(job.ID, true)
+	defer setRunningJob(job.ID, false)
+
+	p := &jobProgress{c: c, job: job, finished: make(map[string]bool)}
+
+	var err error
+	switch job.Type {
+	case JobScan:
+		_, err = scan(job.Scope, p, c, cache)
+	case JobThumbnail:
+		_, err = thumbScan(p, c)
+	case JobTranscode:
+		err = transcodeVideo(job.Scope, p, c)
+	case JobSprites:
+		err = createSprites(job.Scope, p, c)
+	case JobCleanup:
+		_, err = cleanCache(p, c)
+	case JobOptimize:
+		_, err = Optimize(c)
+	default:
+		err = fmt.Errorf("unknown job type %s", job.Type)
+	}
+
+	return p.end(err)
+}
+
+// transcodeVideo creates the HLS transcode of the video, or live or motion photo, with the hash in scope.
+func transcodeVideo(scope string, p *jobProgress, c Conf) error {
+	hash, err := strconv.ParseUint(scope, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid video hash %s", scope)
+	}
+
+	video, err := queries.GetSingleMediaItem(hash, c)
+	if err != nil {
+		return fmt.Errorf("error getting video: %v", err)
+	}
+	if video.Path == "" {
+		return fmt.Errorf("video %d no longer exists", hash)
+	}
+
+	p.queue(video.Path, false)
+	err = transcode.TranscodeMedia(video, c)
+	p.finish(video.Path, err != nil)
+	if err != nil {
+		return fmt.Errorf("error transcoding video: %v", err)
+	}
+
+	return nil
+}
+
+// createSprites creates the sprite sheet of the video with the hash in scope.
+func createSprites(scope string, p *jobProgress, c Conf) error {
+	hash, err := strconv.ParseUint(scope, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid video hash %s", scope)
+	}
+
+	video, err := queries.GetSingleMediaItem(hash, c)
+	if err != nil {
+		return fmt.Errorf("error getting video: %v", err)
+	}
+	if video.Path == "" {
+		return fmt.Errorf("video %d no longer exists", hash)
+	}
+
+	p.queue(video.Path, false)
+	err = transcode.CreateSprites(video, false, c)
+	p.finish(video.Path, err != nil)
+	if err != nil {
+		return fmt.Errorf("error creating sprite sheet: %v", err)
+	}
+
+	return nil
+}
+
+// CancelJob cancels a queued job, or a running scan, thumbnail scan or cache cleanup. Running transcodes, sprite sheets
+// and optimizes can not be canceled.
+func CancelJob(c Conf, id int64) (types.Job, error) {
+	job, err := c.Store.GetJob(id)
+	if err != nil {
+		return types.Job{}, err
+	}
+
+	if job.Status == JobQueued {
+		canceled, err := c.Store.CancelQueuedJob(id, jobTime())
+		if err != nil {
+			return types.Job{}, fmt.Errorf("error canceling job: %v", err)
+		}
+
+		// the job may have started since it was read
+		job, err = c.Store.GetJob(id)
+		if err != nil || canceled {
+			return job, err
+		}
+	}
+
+	if job.Status == JobRunning && job.Type != JobTranscode && job.Type != JobSprites && job.Type != JobOptimize && isRunningJob(id) {
+		CancelScan()
+		return job, nil
+	}
+
+	return job, ErrJobNotCancelable
+}
+
+func setRunningJob(id int64, running bool) {
+	runningJobsMutex.Lock()
+	defer runningJobsMutex.Unlock()
+	if running {
+		runningJobs[id] = true
+	} else {
+		delete(runningJobs, id)
+	}
+}
+
+// isRunningJob tests if a job is being run by this process.
+func isRunningJob(id int64) bool {
+	runningJobsMutex.RLock()
+	defer runningJobsMutex.RUnlock()
+	return runningJobs[id]
+}
+
+// jobProgress records the progress of a running job, saving it at most once every jobSaveInterval.
+// Its methods do nothing on a nil jobProgress, so scans run outside of a job need no checks.
+type jobProgress struct {
+	c     Conf
+	mu    sync.Mutex
+	job   types.Job
+	saved time.Time
+
+	// paths of the checkpointed files in the order they were queued, and the ones that have finished
+	order    []string
+	finished map[string]bool
+	next     int
+}
+
+// resumeAfter returns the path of the last file an interrupted job finished, or an empty string.
+func (p *jobProgress) resumeAfter() string {
+	if p == nil {
+		return ""
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.job.Cursor
+}
+
+// queue counts a file queued by the job. Checkpointed files must be queued in path order, so that the cursor can be
+// moved past them once they, and every checkpointed file before them, have finished.
+func (p *jobProgress) queue(path string, checkpoint bool) {
+	if p == nil {
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.job.Total++
+	if checkpoint {
+		p.order = append(p.order, path)
+	}
+	p.save(false)
+}
+
+// finish counts a finished file.
+func (p *jobProgress) finish(path string, failed bool) {
+	if p == nil {
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.job.Processed++
+	if failed {
+		p.job.Failed++
+	}
+
+	if p.next < len(p.order) {
+		p.finished[path] = true
+	}
+	for p.next < len(p.order) && p.finished[p.order[p.next]] {
+		p.job.Cursor = p.order[p.next]
+		p.next++
+	}
+
+	p.save(false)
+}
+
+// end saves the outcome of the job, returning true if it was queued again as a scan was running.
+func (p *jobProgress) end(err error) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.job.FinishedAt = jobTime()
+	switch {
+	case errors.Is(err, ErrScanInProgress):
+		p.job.Status = JobQueued
+		p.job.StartedAt = ""
+		p.job.FinishedAt = ""
+	case errors.Is(err, ErrScanCanceled):
+		p.job.Status = JobCanceled
+	case err != nil:
+		p.job.Status = JobFailed
+		p.job.Error = err.Error()
+	default:
+		p.job.Status = JobCompleted
+		if p.job.Failed > 0 {
+			p.job.Error = fmt.Sprintf("%d of %d files failed", p.job.Failed, p.job.Total)
+		}
+	}
+	p.save(true)
+
+	if err != nil && !errors.Is(err, ErrScanInProgress) && !errors.Is(err, ErrScanCanceled) {
+		p.c.Logger.Error("job failed", "id", p.job.ID, "type", p.job.Type, "scope", p.job.Scope, "error", err)
+		if err := queries.Notify(p.c, fmt.Sprintf("Job %d (%s) failed: %v", p.job.ID, p.job.Type, err), "complete"); err != nil {
+			p.c.Logger.Error("failed to notify", "err", err)
+		}
+	} else {
+		p.c.Logger.Info("finished job", "id", p.job.ID, "type", p.job.Type, "scope", p.job.Scope, "status", p.job.Status)
+	}
+
+	return p.job.Status == JobQueued
+}
+
+// save writes the job to the db if it has not been saved for jobSaveInterval, or if force is set. p.mu must be held.
+func (p *jobProgress) save(force bool) {
+	if !force && time.Since(p.saved) < jobSaveInterval {
+		return
+	}
+
+	if err := p.c.Store.UpdateJob(p.job); err != nil {
+		p.c.Logger.Error("error saving job", "error", err)
+	}
+	p.saved = time.Now()
+}
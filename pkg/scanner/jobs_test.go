@@ -0,0 +1,245 @@
+package scanner
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/robbymilo/rgallery/pkg/database"
+	"github.com/robbymilo/rgallery/pkg/sqlitestore"
+	"github.com/robbymilo/rgallery/pkg/types"
+)
+
+func testConf(t *testing.T) Conf {
+	t.Helper()
+
+	dir := t.TempDir()
+	c := Conf{
+		Data:      filepath.Join(dir, "data"),
+		Cache:     filepath.Join(dir, "cache"),
+		DBBackend: "sqlite",
+		Logger:    slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+	database.CreateDB(c)
+
+	store, err := sqlitestore.Open(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { store.Close() })
+	c.Store = store
+
+	return c
+}
+
+func TestEnqueueJob(t *testing.T) {
+	c := testConf(t)
+
+	first, err := EnqueueJob(c, JobScan, "deep")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	again, err := EnqueueJob(c, JobScan, "deep")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.ID != first.ID {
+		t.Errorf("expected queued job %d to be returned, got %d", first.ID, again.ID)
+	}
+
+	other, err := EnqueueJob(c, JobScan, "default")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if other.ID == first.ID {
+		t.Errorf("expected a new job for a different scope")
+	}
+
+	if _, err := EnqueueJob(c, JobScan, "everything"); err == nil {
+		t.Errorf("expected an error for an unknown scan type")
+	}
+
+	if _, err := EnqueueJob(c, JobScan, "default:photos"); err == nil {
+		t.Errorf("expected an error for an unknown library")
+	}
+
+	canceled, err := CancelJob(c, first.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if canceled.Status != JobCanceled {
+		t.Errorf("expected job to be canceled, got %s", canceled.Status)
+	}
+
+	if _, err := CancelJob(c, first.ID); !errors.Is(err, ErrJobNotCancelable) {
+		t.Errorf("expected ErrJobNotCancelable, got %v", err)
+	}
+
+	queued, err := c.Store.GetJobsWithStatus(JobQueued)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(queued) != 1 || queued[0].ID != other.ID {
+		t.Errorf("expected only job %d to be queued, got %+v", other.ID, queued)
+	}
+}
+
+func TestJobProgressCursor(t *testing.T) {
+	c := testConf(t)
+
+	job, err := EnqueueJob(c, JobScan, "metadata")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &jobProgress{c: c, job: job, finished: make(map[string]bool)}
+
+	for _, path := range []string{"a.jpg", "b.jpg", "c.jpg"} {
+		p.queue(path, true)
+	}
+	p.queue("new.jpg", false)
+
+	steps := []struct {
+		path   string
+		cursor string
+	}{
+		{"b.jpg", ""},
+		{"new.jpg", ""},
+		{"a.jpg", "b.jpg"},
+		{"c.jpg", "c.jpg"},
+	}
+
+	for _, step := range steps {
+		p.finish(step.path, step.path == "new.jpg")
+		if got := p.resumeAfter(); got != step.cursor {
+			t.Errorf("after finishing %s expected cursor %q, got %q", step.path, step.cursor, got)
+		}
+	}
+
+	if requeued := p.end(nil); requeued {
+		t.Errorf("expected completed job not to be queued again")
+	}
+
+	saved, err := c.Store.GetJob(job.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if saved.Status != JobCompleted || saved.Total != 4 || saved.Processed != 4 || saved.Failed != 1 || saved.Cursor != "c.jpg" {
+		t.Errorf("unexpected saved job %+v", saved)
+	}
+	if saved.Error != "1 of 4 files failed" {
+		t.Errorf("unexpected error summary %q", saved.Error)
+	}
+}
+
+func TestJobRunners(t *testing.T) {
+	c := testConf(t)
+	if err := os.MkdirAll(c.Cache, os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+
+	cleanup, err := EnqueueJob(c, JobCleanup, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	transcode, err := EnqueueJob(c, JobTranscode, "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the video runner skips the older cleanup, and runs the transcode of a video that does not exist
+	if ran := videoJobs.runQueued(c, nil); !ran {
+		t.Errorf("expected the video runner to run a job")
+	}
+
+	for id, want := range map[int64]string{cleanup.ID: JobQueued, transcode.ID: JobFailed} {
+		job, err := c.Store.GetJob(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if job.Status != want {
+			t.Errorf("expected job %d (%s) to be %s, got %s", id, job.Type, want, job.Status)
+		}
+	}
+
+	if ran := videoJobs.runQueued(c, nil); ran {
+		t.Errorf("expected no more jobs for the video runner")
+	}
+
+	if ran := libraryJobs.runQueued(c, nil); !ran {
+		t.Errorf("expected the library runner to run a job")
+	}
+
+	job, err := c.Store.GetJob(cleanup.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job.Status != JobCompleted || job.Owner != jobOwner {
+		t.Errorf("expected cleanup to be completed by %s, got %+v", jobOwner, job)
+	}
+}
+
+func TestRequeueStaleJobs(t *testing.T) {
+	c := testConf(t)
+
+	start := func(owner, at string) types.Job {
+		t.Helper()
+
+		id, err := c.Store.AddJob(types.Job{Type: JobScan, Scope: "default", Status: JobQueued, CreatedAt: at})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if started, err := c.Store.StartJob(id, owner, at); err != nil || !started {
+			t.Fatalf("expected job %d to start, got %v", id, err)
+		}
+
+		job, err := c.Store.GetJob(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		job.Total, job.Processed = 10, 4
+		if err := c.Store.UpdateJob(job); err != nil {
+			t.Fatal(err)
+		}
+
+		return job
+	}
+
+	// a job of a stopped process, and jobs of running processes
+	stopped := start("a", "2026-10-17T10:00:00Z")
+	running := start("b", "2026-10-17T10:00:00Z")
+	recent := start("c", "2026-10-17T10:05:00Z")
+
+	if err := c.Store.HeartbeatJobs("b", "2026-10-17T10:05:00Z"); err != nil {
+		t.Fatal(err)
+	}
+
+	stale, err := c.Store.RequeueStaleJobs("2026-10-17T10:03:00Z")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stale) != 1 || stale[0].ID != stopped.ID {
+		t.Fatalf("expected only job %d to be stale, got %+v", stopped.ID, stale)
+	}
+
+	job, err := c.Store.GetJob(stopped.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job.Status != JobQueued || job.Total != 4 || job.Processed != 4 || job.Owner != "" {
+		t.Errorf("unexpected requeued job %+v", job)
+	}
+
+	for _, id := range []int64{running.ID, recent.ID} {
+		job, err := c.Store.GetJob(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if job.Status != JobRunning {
+			t.Errorf("expected job %d to keep running, got %+v", id, job)
+		}
+	}
+}
@@ -0,0 +1,177 @@
+package scanner
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	cache "github.com/patrickmn/go-cache"
+	"github.com/robbymilo/rgallery/pkg/config"
+	"github.com/robbymilo/rgallery/pkg/queries"
+	"github.com/robbymilo/rgallery/pkg/types"
+)
+
+type Library = types.Library
+
+// ScanScope returns the scope of a scan job of a type, of one library, or of every library if library is empty.
+func ScanScope(scanType, library string) string {
+	if library == "" {
+		return scanType
+	}
+
+	return scanType + ":" + library
+}
+
+// parseScanScope splits the scope of a scan job into its type and library, which is empty for every library.
+func parseScanScope(scope string) (string, string) {
+	scanType, library, _ := strings.Cut(scope, ":")
+	return scanType, library
+}
+
+// scanLibraries returns the libraries scanned by a scan of library, or of every library if library is empty.
+func scanLibraries(library string, c Conf) ([]Library, error) {
+	if library == "" {
+		return config.Libraries(c), nil
+	}
+
+	lib, ok := config.GetLibrary(library, c)
+	if !ok {
+		return nil, fmt.Errorf("unknown library %s", library)
+	}
+
+	return []Library{lib}, nil
+}
+
+// watchScope returns the scope of a scan job queued by the watcher of the library being scanned. The library is left
+// out if it is the only one.
+func watchScope(scanType string, c Conf) string {
+	if len(c.Libraries) == 0 {
+		return scanType
+	}
+
+	return ScanScope(scanType, c.Library)
+}
+
+// libraryKey identifies a file across libraries, such as in the cursor of a scan job. Files of the default library
+// are identified by their path alone, as they were before there were libraries.
+func libraryKey(library, path string) string {
+	if library == "" || library == config.DefaultLibrary {
+		return path
+	}
+
+	return library + ":" + path
+}
+
+// libraryCursor returns the path a resumed scan of libs[i] continues after, and whether the library had been scanned
+// before the scan job was interrupted, from the cursor of the job.
+func libraryCursor(cursor string, libs []Library, i int) (string, bool) {
+	if cursor == "" {
+		return "", false
+	}
+
+	owner, path := -1, ""
+	for j, lib := range libs {
+		if key := libraryKey(lib.Name, ""); key == "" {
+			if owner == -1 {
+				owner, path = j, cursor
+			}
+		} else if strings.HasPrefix(cursor, key) {
+			owner, path = j, strings.TrimPrefix(cursor, key)
+			break
+		}
+	}
+
+	switch {
+	case owner == -1 || i > owner:
+		return "", false
+	case i < owner:
+		return "", true
+	default:
+		return path, false
+	}
+}
+
+// libraryItems returns the media items of the library being scanned.
+func libraryItems(c Conf) ([]Media, error) {
+	items, err := queries.GetMediaItems(0, "ASC", -1, c)
+	if err != nil {
+		return nil, err
+	}
+
+	return slices.DeleteFunc(items, func(item Media) bool {
+		return item.Library != c.Library
+	}), nil
+}
+
+// orphanedItems returns the media items of libraries that are no longer configured, such as a library removed from
+// or renamed in the config file.
+func orphanedItems(c Conf) ([]Media, error) {
+	items, err := queries.GetMediaItems(0, "ASC", -1, c)
+	if err != nil {
+		return nil, err
+	}
+
+	return slices.DeleteFunc(items, func(item Media) bool {
+		_, ok := config.GetLibrary(item.Library, c)
+		return ok
+	}), nil
+}
+
+// OrphanedLibraries returns the sorted names of the libraries that have media items but are no longer configured.
+// Their items are removed by the next scan of every library.
+func OrphanedLibraries(c Conf) ([]string, error) {
+	items, err := orphanedItems(c)
+	if err != nil {
+		return nil, fmt.Errorf("error getting media items: %v", err)
+	}
+
+	var names []string
+	for _, item := range items {
+		names = append(names, item.Library)
+	}
+	slices.Sort(names)
+
+	return slices.Compact(names), nil
+}
+
+// removeOrphans removes the media items and alternates of libraries that are no longer configured, returning the
+// number of items removed.
+func removeOrphans(c Conf, cache *cache.Cache) (int, error) {
+	items, err := orphanedItems(c)
+	if err != nil {
+		return 0, fmt.Errorf("error getting media items: %v", err)
+	}
+
+	removed := 0
+	libraries := make(map[string]bool)
+	for _, item := range items {
+		libraries[item.Library] = true
+		if err := deleteMediaItem(item.Path, true, item, c, cache); err != nil {
+			c.Logger.Error("error removing item", "error", err)
+			continue
+		}
+
+		removed++
+		c.Logger.Info("removed item " + item.Path + " of unconfigured library " + item.Library)
+	}
+
+	for library := range libraries {
+		if err := c.Store.SetAlternates(library, nil, nil); err != nil {
+			c.Logger.Error("error removing alternates of library "+library, "error", err)
+		}
+	}
+
+	return removed, nil
+}
+
+// libraryOffline tests if the root of a library with media items can not be read or is empty, such as a network
+// share that is not mounted, so its items are not removed as if their files were deleted.
+func libraryOffline(root string, items []Media) bool {
+	if len(items) == 0 {
+		return false
+	}
+
+	entries, err := os.ReadDir(root)
+	return err != nil || len(entries) == 0
+}
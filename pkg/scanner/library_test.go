@@ -0,0 +1,96 @@
+package scanner
+
+import (
+	"reflect"
+	"testing"
+
+	cache "github.com/patrickmn/go-cache"
+)
+
+func TestParseScanScope(t *testing.T) {
+	tests := []struct {
+		scope, scanType, library string
+	}{
+		{"default", "default", ""},
+		{"deep:photos", "deep", "photos"},
+		{ScanScope("metadata", "archive"), "metadata", "archive"},
+		{ScanScope("default", ""), "default", ""},
+	}
+
+	for _, test := range tests {
+		scanType, library := parseScanScope(test.scope)
+		if scanType != test.scanType || library != test.library {
+			t.Errorf("parseScanScope(%q) = %q, %q, want %q, %q", test.scope, scanType, library, test.scanType, test.library)
+		}
+	}
+}
+
+func TestLibraryCursor(t *testing.T) {
+	libs := []Library{{Name: "default"}, {Name: "photos"}, {Name: "archive"}}
+
+	tests := []struct {
+		cursor  string
+		i       int
+		path    string
+		scanned bool
+	}{
+		{"", 1, "", false},
+		{"2024/a.jpg", 0, "2024/a.jpg", false},
+		{"2024/a.jpg", 1, "", false},
+		{"photos:2024/a.jpg", 0, "", true},
+		{"photos:2024/a.jpg", 1, "2024/a.jpg", false},
+		{"photos:2024/a.jpg", 2, "", false},
+		{"archive:b.jpg", 1, "", true},
+		{"archive:b.jpg", 2, "b.jpg", false},
+	}
+
+	for _, test := range tests {
+		path, scanned := libraryCursor(test.cursor, libs, test.i)
+		if path != test.path || scanned != test.scanned {
+			t.Errorf("libraryCursor(%q, %d) = %q, %v, want %q, %v", test.cursor, test.i, path, scanned, test.path, test.scanned)
+		}
+	}
+
+	// a cursor of a library that is no longer configured resumes nothing
+	if path, scanned := libraryCursor("trips:c.jpg", libs[1:], 0); path != "" || scanned {
+		t.Errorf("expected unknown library cursor to be ignored, got %q, %v", path, scanned)
+	}
+}
+
+func TestRemoveOrphans(t *testing.T) {
+	c := testConf(t)
+	c.Libraries = []Library{{Name: "photos", Path: c.Media}}
+
+	kept := testMedia(1, "a/1.jpg")
+	kept.Library = "photos"
+	for _, media := range []Media{kept, testMedia(2, "a/2.jpg"), testMedia(3, "a/3.jpg")} {
+		if err := c.Store.InsertMediaItem(media); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the items of the media directory belong to the default library, which is no longer configured
+	orphaned, err := OrphanedLibraries(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(orphaned, []string{"default"}) {
+		t.Errorf("expected the default library to be orphaned, got %v", orphaned)
+	}
+
+	removed, err := removeOrphans(c, cache.New(-1, -1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if removed != 2 {
+		t.Errorf("expected 2 items removed, got %d", removed)
+	}
+
+	items, err := c.Store.GetMediaItems(0, "ASC", -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 1 || items[0].Path != kept.Path {
+		t.Errorf("expected only %s to be kept, got %+v", kept.Path, items)
+	}
+}
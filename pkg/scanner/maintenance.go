@@ -0,0 +1,145 @@
+package scanner
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/robbymilo/rgallery/pkg/config"
+	"github.com/robbymilo/rgallery/pkg/queries"
+)
+
+// CleanCache removes the thumbnails and transcodes of media items that are no longer in the library.
+func CleanCache(c Conf) (string, error) {
+	return cleanCache(nil, c)
+}
+
+// cleanCache runs a cache cleanup, recording its progress to job if it is run as a job. It runs as a scan so that
+// the thumbnails of items being added are not removed before the items are saved.
+func cleanCache(job *jobProgress, c Conf) (string, error) {
+	if !tryStartScan() {
+		c.Logger.Info("scan in progress, cache cleanup will wait")
+		return "", ErrScanInProgress
+	}
+
+	start := time.Now()
+	defer SetScanInProgress(false)
+
+	resetCancelChan(make(chan struct{}))
+	defer resetCancelChan(nil)
+
+	items, err := queries.GetMediaItems(0, "ASC", -1, c)
+	if err != nil {
+		return "", fmt.Errorf("error getting media items %v", err)
+	}
+
+	hashes := make(map[string]bool, len(items))
+	for _, item := range items {
+		hashes[strconv.FormatUint(item.Hash, 10)] = true
+	}
+
+	cachePath := config.CachePath(c)
+	dirs, err := os.ReadDir(cachePath)
+	if err != nil {
+		return "", fmt.Errorf("error reading cache dir: %v", err)
+	}
+
+	removed := 0
+	for _, dir := range dirs {
+		if !dir.IsDir() {
+			continue
+		}
+
+		// transcodes are in a directory named after the hash of the video, and thumbnails in a file named after the
+		// hash in a directory named after the size
+		ext := ".jpg"
+		if dir.Name() == "video" {
+			ext = ""
+		} else if _, err := strconv.Atoi(dir.Name()); err != nil {
+			continue
+		}
+
+		orphans, err := orphanedFiles(filepath.Join(cachePath, dir.Name()), ext, hashes)
+		if err != nil {
+			return "", err
+		}
+
+		for _, orphan := range orphans {
+			if isCanceled() {
+				c.Logger.Info("cache cleanup canceled")
+				if err := queries.Notify(c, "Cache cleanup canceled.", "canceled"); err != nil {
+					c.Logger.Error("failed to notify", "err", err)
+				}
+				return "", ErrScanCanceled
+			}
+
+			job.queue(orphan, false)
+			err := os.RemoveAll(orphan)
+			if err != nil {
+				c.Logger.Error("error removing cached file", "path", orphan, "error", err)
+			} else {
+				removed++
+			}
+			job.finish(orphan, err != nil)
+		}
+	}
+
+	status := fmt.Sprintf("Cache cleanup complete. %d unused thumbnails and transcodes removed in %s.", removed, time.Since(start).Truncate(time.Second).String())
+	c.Logger.Info(status)
+	if err := queries.Notify(c, status, "complete"); err != nil {
+		c.Logger.Error("Notify error", "err", err)
+	}
+
+	return status, nil
+}
+
+// orphanedFiles returns the paths of the entries in dir named after a hash followed by ext that is not in hashes.
+// Entries not named after a hash are left alone.
+func orphanedFiles(dir, ext string, hashes map[string]bool) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("error reading cache dir: %v", err)
+	}
+
+	var orphans []string
+	for _, entry := range entries {
+		hash, ok := strings.CutSuffix(entry.Name(), ext)
+		if !ok || entry.IsDir() != (ext == "") {
+			continue
+		}
+		if _, err := strconv.ParseUint(hash, 10, 64); err != nil {
+			continue
+		}
+		if !hashes[hash] {
+			orphans = append(orphans, filepath.Join(dir, entry.Name()))
+		}
+	}
+
+	return orphans, nil
+}
+
+// Optimize optimizes the database, once any running scan has finished.
+func Optimize(c Conf) (string, error) {
+	if !tryStartScan() {
+		c.Logger.Info("scan in progress, database optimize will wait")
+		return "", ErrScanInProgress
+	}
+
+	start := time.Now()
+	defer SetScanInProgress(false)
+
+	if err := c.Store.Optimize(); err != nil {
+		return "", fmt.Errorf("error optimizing database: %v", err)
+	}
+
+	status := fmt.Sprintf("Database optimized in %s.", time.Since(start).Truncate(time.Second).String())
+	c.Logger.Info(status)
+	if err := queries.Notify(c, status, "complete"); err != nil {
+		c.Logger.Error("Notify error", "err", err)
+	}
+
+	return status, nil
+}
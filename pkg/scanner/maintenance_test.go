@@ -0,0 +1,45 @@
+package scanner
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCleanCache(t *testing.T) {
+	c := testConf(t)
+
+	files := map[string]bool{
+		"400/123.jpg":          false,
+		"400/notes.txt":        true,
+		"video/456/index.m3u8": false,
+		"tiles/789.jpg":        true,
+	}
+	for file := range files {
+		path := filepath.Join(c.Cache, file)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := CleanCache(c); err != nil {
+		t.Fatal(err)
+	}
+
+	for file, kept := range files {
+		_, err := os.Stat(filepath.Join(c.Cache, file))
+		if kept && err != nil {
+			t.Errorf("%s was removed", file)
+		}
+		if !kept && err == nil {
+			t.Errorf("%s was not removed", file)
+		}
+	}
+
+	if _, err := Optimize(c); err != nil {
+		t.Fatal(err)
+	}
+}
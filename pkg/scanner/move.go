@@ -0,0 +1,72 @@
+package scanner
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+
+	cache "github.com/patrickmn/go-cache"
+	"github.com/robbymilo/rgallery/pkg/config"
+	"github.com/robbymilo/rgallery/pkg/exif"
+	"github.com/robbymilo/rgallery/pkg/hash"
+	"github.com/robbymilo/rgallery/pkg/middleware"
+)
+
+// findMovedItem returns the index of the missing media item with the same content as the file at absolute_path, or -1 if there is none.
+func findMovedItem(absolute_path string, file os.FileInfo, missing []Media) (int, error) {
+	if len(missing) == 0 {
+		return -1, nil
+	}
+
+	checksum, err := hash.GetChecksum(absolute_path)
+	if err != nil {
+		return -1, err
+	}
+
+	match := -1
+	for i, item := range missing {
+		if item.Checksum != checksum {
+			continue
+		}
+
+		// items scanned before sizes were recorded have a size of 0
+		if item.Size != 0 && item.Size != file.Size() {
+			continue
+		}
+
+		// prefer the item with the same modification time when identical copies were moved
+		if sameModTime(item, exif.ModTime(absolute_path, file)) {
+			return i, nil
+		}
+
+		if match == -1 {
+			match = i
+		}
+	}
+
+	return match, nil
+}
+
+// moveMediaItem points an existing media item at its new path, keeping its hash, tags and cached files.
+func moveMediaItem(media Media, relative_path string, file os.FileInfo, c Conf, cache *cache.Cache) error {
+
+	cache.Flush()
+	middleware.RemoveEtags()
+
+	folder := filepath.Dir(relative_path)
+
+	modified := exif.ModTime(filepath.Join(config.MediaPath(c), relative_path), file)
+	err := c.Store.MoveMediaItem(media, relative_path, folder, modified, file.Size())
+	if err != nil {
+		return err
+	}
+
+	c.Logger.Info("moved item " + media.Path + " to " + relative_path)
+
+	return nil
+}
+
+// sameModTime tests if a media item has the modification time of its file, as returned by exif.ModTime.
+func sameModTime(media Media, modified time.Time) bool {
+	return media.Modified.Format("2006-01-02T15:04:05") == modified.UTC().Format("2006-01-02T15:04:05")
+}
@@ -0,0 +1,152 @@
+package scanner
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	cache "github.com/patrickmn/go-cache"
+	"github.com/robbymilo/rgallery/pkg/database"
+	"github.com/robbymilo/rgallery/pkg/hash"
+	"github.com/stretchr/testify/assert"
+	"zombiezen.com/go/sqlite"
+	"zombiezen.com/go/sqlite/sqlitex"
+)
+
+// writeFile writes a file with a modification time below the media directory of c, returning its absolute path.
+func writeFile(t *testing.T, c Conf, relative_path, content string, modified time.Time) string {
+	t.Helper()
+
+	absolute_path := filepath.Join(c.Media, relative_path)
+	if err := os.MkdirAll(filepath.Dir(absolute_path), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(absolute_path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(absolute_path, modified, modified); err != nil {
+		t.Fatal(err)
+	}
+
+	return absolute_path
+}
+
+func TestFindMovedItem(t *testing.T) {
+	c := Conf{Media: t.TempDir()}
+	modified := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	absolute_path := writeFile(t, c, "b/moved.jpg", "moved", modified)
+
+	checksum, err := hash.GetChecksum(absolute_path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := os.Stat(absolute_path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	other := modified.Add(time.Hour)
+	item := func(path, checksum string, size int64, modified time.Time) Media {
+		return Media{Path: path, Checksum: checksum, Size: size, Modified: modified}
+	}
+
+	tests := []struct {
+		name    string
+		missing []Media
+		want    int
+	}{
+		{"no missing items", nil, -1},
+		{"other content", []Media{item("a/1.jpg", "other", 5, modified)}, -1},
+		{"same checksum and other size", []Media{item("a/1.jpg", checksum, 6, modified)}, -1},
+		{"same checksum and size", []Media{item("a/1.jpg", "other", 5, modified), item("a/2.jpg", checksum, 5, other)}, 1},
+		{"same checksum scanned before sizes were recorded", []Media{item("a/1.jpg", checksum, 0, other)}, 0},
+		{"identical copies prefer the same modification time", []Media{item("a/1.jpg", checksum, 5, other), item("a/2.jpg", checksum, 5, modified)}, 1},
+		{"identical copies without the same modification time", []Media{item("a/1.jpg", checksum, 5, other), item("a/2.jpg", checksum, 0, other)}, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := findMovedItem(absolute_path, file, tt.missing)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	// a file that can not be read is not a moved item
+	_, err = findMovedItem(filepath.Join(c.Media, "missing.jpg"), file, []Media{item("a/1.jpg", checksum, 5, modified)})
+	assert.Error(t, err)
+}
+
+// folders returns the keys of the folders table.
+func folders(t *testing.T, c Conf) []string {
+	t.Helper()
+
+	conn, err := sqlite.OpenConn(database.NewSqlConnectionString(c), sqlite.OpenReadOnly)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	var keys []string
+	err = sqlitex.ExecuteTransient(conn, "SELECT key FROM folders ORDER BY key", &sqlitex.ExecOptions{
+		ResultFunc: func(stmt *sqlite.Stmt) error {
+			keys = append(keys, stmt.ColumnText(0))
+			return nil
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return keys
+}
+
+func TestMoveMediaItem(t *testing.T) {
+	c := testConf(t)
+	c.Media = t.TempDir()
+
+	// a/1.jpg is moved out of a, which keeps a/2.jpg, and a/2.jpg is then moved out of a, which is left empty
+	for i, path := range []string{"a/1.jpg", "a/2.jpg"} {
+		media := testMedia(uint64(i+1), path, "sea")
+		media.Size = 1
+		if err := c.Store.InsertMediaItem(media); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		hash    uint64
+		from    string
+		path    string
+		folders []string
+	}{
+		{1, "a/1.jpg", "b/1.jpg", []string{"a", "b"}},
+		{2, "a/2.jpg", "c/d/2.jpg", []string{"b", "c/d"}},
+	}
+
+	modified := time.Date(2024, 5, 6, 7, 8, 9, 0, time.UTC)
+	for _, tt := range tests {
+		absolute_path := writeFile(t, c, tt.path, "content", modified)
+		file, err := os.Stat(absolute_path)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		media := Media{Hash: tt.hash, Path: tt.from, Folder: filepath.Dir(tt.from)}
+		err = moveMediaItem(media, tt.path, file, c, cache.New(time.Minute, time.Minute))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		moved, err := c.Store.GetSingleMediaItem(tt.hash)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, tt.path, moved.Path)
+		assert.Equal(t, filepath.Dir(tt.path), moved.Folder)
+		assert.Equal(t, `[{"key":"sea","value":"sea"}]`, moved.Subject)
+		assert.Equal(t, tt.folders, folders(t, c))
+	}
+}
@@ -0,0 +1,439 @@
+package scanner
+
+import (
+	"fmt"
+	"runtime"
+	"strconv"
+	"sync"
+	"time"
+
+	exiftool "github.com/barasher/go-exiftool"
+	cache "github.com/patrickmn/go-cache"
+	"github.com/robbymilo/rgallery/pkg/geo"
+	"github.com/robbymilo/rgallery/pkg/hash"
+	"github.com/robbymilo/rgallery/pkg/middleware"
+	"github.com/robbymilo/rgallery/pkg/queries"
+	"github.com/robbymilo/rgallery/pkg/transcode"
+)
+
+const (
+	// insertBatchSize is the number of media items inserted per transaction.
+	insertBatchSize = 100
+	// insertInterval is the longest a prepared media item waits to be inserted, so new items show up while a long scan runs.
+	insertInterval = 2 * time.Second
+)
+
+// DefaultScanWorkers returns the default number of files read and thumbnailed at once. Each worker decodes full size
+// images, so the default is kept low to bound memory use.
+func DefaultScanWorkers() int {
+	return min(runtime.NumCPU(), 4)
+}
+
+// scanTask is a media file to read, thumbnail and insert on one of the scan workers.
+type scanTask struct {
+	relative_path string
+	absolute_path string
+	// existing is the media item replaced by an update, or empty when adding a new file.
+	existing   Media
+	regenThumb bool
+	// checkpoint moves the cursor of the job past the file once it has finished, see jobProgress.queue.
+	checkpoint bool
+	// seq is the order the file was submitted in, see scanPool.claimHash.
+	seq int
+}
+
+// scanStats counts the outcome of the tasks of a scan.
+type scanStats struct {
+	added   int
+	updated int
+	failed  int
+}
+
+// scanPool reads and thumbnails media files on a fixed number of workers, each with its own exiftool,
+// and inserts the resulting media items in batched transactions.
+type scanPool struct {
+	c      Conf
+	cache  *cache.Cache
+	h      *geo.Handlers
+	job    *jobProgress
+	tasks  chan scanTask
+	wg     sync.WaitGroup
+	writer *mediaWriter
+	once   sync.Once
+
+	mu sync.Mutex
+	// hashes given out during the scan, as their items may not be inserted yet
+	hashes map[uint64]string
+	stats  scanStats
+	// submitted counts the submitted files, and claimed is the seq of the first file that has not claimed its hash
+	// yet, with the later files that have in released
+	submitted int
+	claimed   int
+	released  map[int]bool
+	turn      *sync.Cond
+}
+
+// newScanPool starts c.ScanWorkers workers. The geo handler is loaded if h is nil and no location service is set.
+// Progress is recorded to job if the scan is run as a job. The pool must be closed to wait for queued files and
+// release the workers.
+func newScanPool(c Conf, cache *cache.Cache, h *geo.Handlers, job *jobProgress) (*scanPool, error) {
+	if h == nil && c.LocationService == "" {
+		var err error
+		h, err = geo.NewGeoHandler(c)
+		if err != nil {
+			return nil, fmt.Errorf("error getting geo handler %v", err)
+		}
+	}
+
+	workers := c.ScanWorkers
+	if workers <= 0 {
+		workers = DefaultScanWorkers()
+	}
+
+	// start every exiftool up front so a missing exiftool fails the scan before any files are queued
+	ets := make([]*exiftool.Exiftool, 0, workers)
+	for range workers {
+		buf := make([]byte, 1024*1024)
+		et, err := exiftool.NewExiftool(exiftool.NoPrintConversion(), exiftool.Buffer(buf, 256*1024))
+		if err != nil {
+			for _, et := range ets {
+				if err := et.Close(); err != nil {
+					c.Logger.Error("et.Close error", "err", err)
+				}
+			}
+			return nil, fmt.Errorf("error starting exiftool %v", err)
+		}
+		ets = append(ets, et)
+	}
+
+	p := &scanPool{
+		c:        c,
+		cache:    cache,
+		h:        h,
+		job:      job,
+		tasks:    make(chan scanTask, workers),
+		writer:   newMediaWriter(c, cache),
+		hashes:   make(map[uint64]string),
+		released: make(map[int]bool),
+	}
+	p.turn = sync.NewCond(&p.mu)
+
+	for _, et := range ets {
+		p.wg.Add(1)
+		go p.work(et)
+	}
+
+	return p, nil
+}
+
+// submit queues a file, waiting for a free worker if all are busy.
+func (p *scanPool) submit(t scanTask) {
+	p.mu.Lock()
+	if t.existing.Path != "" {
+		// updated items keep their hash, claim it before new files are queued so a copy of the file gets its own
+		p.hashes[t.existing.Hash] = libraryKey(t.existing.Library, t.existing.Path)
+	}
+	t.seq = p.submitted
+	p.submitted++
+	p.mu.Unlock()
+
+	p.job.queue(libraryKey(p.c.Library, t.relative_path), t.checkpoint)
+	p.tasks <- t
+}
+
+// close waits for the queued files to be processed and their media items inserted, and returns the outcome.
+func (p *scanPool) close() scanStats {
+	p.once.Do(func() {
+		close(p.tasks)
+		p.wg.Wait()
+		p.writer.close()
+	})
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.stats
+}
+
+func (p *scanPool) work(et *exiftool.Exiftool) {
+	defer p.wg.Done()
+	defer func() {
+		if err := et.Close(); err != nil {
+			p.c.Logger.Error("et.Close error", "err", err)
+		}
+	}()
+
+	for t := range p.tasks {
+		// skip the remaining files of a canceled scan
+		if isCanceled() {
+			p.releaseHash(t.seq)
+			continue
+		}
+
+		p.process(t, et)
+	}
+}
+
+// process reads and thumbnails a file, and queues its media item for insertion. An updated file keeps its existing
+// media item until the new one replaces it, so a file that fails to be read is not removed from the library.
+func (p *scanPool) process(t scanTask, et *exiftool.Exiftool) {
+	defer func() {
+		if r := recover(); r != nil {
+			p.c.Logger.Error("recovered from panic while processing file", "file", t.absolute_path, "panic", r)
+		}
+	}()
+	// files that fail before claiming a hash must not hold up the files submitted after them
+	defer p.releaseHash(t.seq)
+
+	var media Media
+	var generated int
+	var err error
+	if isImage(t.absolute_path) {
+		media, generated, err = prepareImage(t, et, p)
+	} else {
+		media, generated, err = prepareVideo(t, et, p)
+	}
+	if err != nil {
+		p.done(t, Media{}, 0, err)
+		return
+	}
+
+	p.writer.insert(media, t.existing.Path != "", func(err error) {
+		if err != nil {
+			err = fmt.Errorf("error inserting media item: %v", err)
+		}
+		// the video embedded in a motion photo is transcoded like a video
+		if err == nil && media.MotionLength > 0 && p.c.PreGenerateThumb && t.regenThumb {
+			queueTranscode(media.Hash, p.c)
+		}
+		p.done(t, media, generated, err)
+	})
+}
+
+// done reports the outcome of a task once its media item is inserted or has failed.
+func (p *scanPool) done(t scanTask, media Media, generated int, err error) {
+	kind := "image"
+	if isVideo(t.absolute_path) {
+		kind = "video"
+	}
+	isUpdate := t.existing.Path != ""
+
+	p.mu.Lock()
+	if err != nil {
+		p.stats.failed++
+	} else if isUpdate {
+		p.stats.updated++
+	} else {
+		p.stats.added++
+	}
+	p.mu.Unlock()
+
+	p.job.finish(libraryKey(p.c.Library, t.relative_path), err != nil)
+
+	if err != nil {
+		if isUpdate {
+			p.c.Logger.Error("error updating media item "+t.absolute_path, "error", err)
+			return
+		}
+
+		p.c.Logger.Error("error adding "+kind+" "+t.absolute_path, "error", err)
+		if kind == "image" {
+			err := TrackScanError(t.relative_path, time.Now(), err, p.c)
+			if err != nil {
+				p.c.Logger.Error("error tracking scan error", "error", err)
+			}
+		}
+		return
+	}
+
+	// transcode once the scan has finished rather than holding up a worker, unless the video is played directly
+	if kind == "video" && p.c.PreGenerateThumb && t.regenThumb && !transcode.DirectPlay(media, p.c) {
+		queueTranscode(media.Hash, p.c)
+	}
+	// sprite sheets are created for new videos and those whose thumbnails are regenerated, unless already saved
+	if _, err := transcode.SpriteSheet(media, p.c); err == nil && p.c.PreGenerateSprites && (t.regenThumb || !isUpdate) {
+		queueSprites(media.Hash, p.c)
+	}
+
+	if isUpdate {
+		p.c.Logger.Info("updated " + kind + " with " + strconv.Itoa(generated) + " thumbnails: " + t.relative_path)
+		if err := queries.Notify(p.c, "Updated media: "+t.relative_path, "scanning"); err != nil {
+			p.c.Logger.Error("Notify error", "err", err)
+		}
+		return
+	}
+
+	p.c.Logger.Info("added " + kind + " with " + strconv.Itoa(generated) + " thumbnails: " + t.relative_path)
+	if err := queries.Notify(p.c, "Added "+kind+": "+t.relative_path, "scanning"); err != nil {
+		p.c.Logger.Error("Notify error", "err", err)
+	}
+}
+
+// claimHash assigns the hash of the media item of a task once every file submitted before it has claimed its hash.
+// Identical new files therefore get their hashes in the order the scan walked them, so the first copy keeps the content
+// hash however many workers read them and whichever of them finishes first.
+func (p *scanPool) claimHash(media *Media, t scanTask) error {
+	p.mu.Lock()
+	for p.claimed < t.seq {
+		p.turn.Wait()
+	}
+	p.mu.Unlock()
+
+	defer p.releaseHash(t.seq)
+
+	return p.assignHash(media, t.existing.Hash)
+}
+
+// releaseHash marks the file submitted as seq as having claimed its hash, or as never claiming one. It may be called
+// more than once for a file.
+func (p *scanPool) releaseHash(seq int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if seq < p.claimed || p.released[seq] {
+		return
+	}
+
+	p.released[seq] = true
+	for p.released[p.claimed] {
+		delete(p.released, p.claimed)
+		p.claimed++
+	}
+	p.turn.Broadcast()
+}
+
+// assignHash keeps the hash of an updated media item so its URLs and cache files stay the same, and gives identical
+// files at different paths, or in different libraries, their own hash.
+func (p *scanPool) assignHash(media *Media, previousHash uint64) error {
+	if previousHash != 0 {
+		media.Hash = previousHash
+		return nil
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	key := libraryKey(media.Library, media.Path)
+	claimedBy, claimed := p.hashes[media.Hash]
+	if !claimed {
+		existing, err := queries.GetSingleMediaItem(media.Hash, p.c)
+		if err != nil {
+			return err
+		}
+		if existing.Path != "" {
+			claimedBy = libraryKey(existing.Library, existing.Path)
+		}
+	}
+
+	if claimedBy != "" && claimedBy != key {
+		media.Hash = hash.GetUniqueHash(media.Checksum, key)
+	}
+	p.hashes[media.Hash] = key
+
+	return nil
+}
+
+// pendingInsert is a prepared media item waiting to be inserted. done is called with the result of the insert.
+type pendingInsert struct {
+	media Media
+	// replace is set for updated files, whose existing media item with the same hash is replaced
+	replace bool
+	done    func(error)
+}
+
+// mediaWriter inserts the media items prepared by the scan workers in batched transactions.
+type mediaWriter struct {
+	c       Conf
+	cache   *cache.Cache
+	items   chan pendingInsert
+	stopped chan struct{}
+}
+
+func newMediaWriter(c Conf, cache *cache.Cache) *mediaWriter {
+	w := &mediaWriter{
+		c:       c,
+		cache:   cache,
+		items:   make(chan pendingInsert, insertBatchSize),
+		stopped: make(chan struct{}),
+	}
+	go w.run()
+
+	return w
+}
+
+// insert queues a media item, calling done once it has been inserted. If replace is set, the media item with the same
+// hash is replaced in the same transaction.
+func (w *mediaWriter) insert(media Media, replace bool, done func(error)) {
+	w.items <- pendingInsert{media: media, replace: replace, done: done}
+}
+
+// close inserts the queued media items and stops the writer.
+func (w *mediaWriter) close() {
+	close(w.items)
+	<-w.stopped
+}
+
+func (w *mediaWriter) run() {
+	defer close(w.stopped)
+
+	ticker := time.NewTicker(insertInterval)
+	defer ticker.Stop()
+
+	var batch []pendingInsert
+	for {
+		select {
+		case item, ok := <-w.items:
+			if !ok {
+				w.flush(batch)
+				return
+			}
+
+			batch = append(batch, item)
+			if len(batch) >= insertBatchSize {
+				w.flush(batch)
+				batch = nil
+			}
+
+		case <-ticker.C:
+			w.flush(batch)
+			batch = nil
+		}
+	}
+}
+
+func (w *mediaWriter) flush(batch []pendingInsert) {
+	if len(batch) == 0 {
+		return
+	}
+
+	var inserts []pendingInsert
+	var items []Media
+	for _, item := range batch {
+		// updated items are replaced one at a time, so they are never missing from the library
+		if item.replace {
+			item.done(w.c.Store.UpdateMediaItem(item.media))
+			continue
+		}
+
+		inserts = append(inserts, item)
+		items = append(items, item.media)
+	}
+
+	if len(items) > 0 {
+		err := w.c.Store.InsertMediaItems(items)
+		if err != nil {
+			// insert one at a time so a single bad item does not fail the rest of the batch
+			w.c.Logger.Warn("error inserting batch of media items, inserting individually", "error", err)
+			for _, item := range inserts {
+				item.done(w.c.Store.InsertMediaItem(item.media))
+			}
+		} else {
+			for _, item := range inserts {
+				item.done(nil)
+			}
+		}
+	}
+
+	w.cache.Flush()
+	middleware.RemoveEtags()
+}
@@ -0,0 +1,209 @@
+package scanner
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	cache "github.com/patrickmn/go-cache"
+	"github.com/robbymilo/rgallery/pkg/hash"
+	"github.com/robbymilo/rgallery/pkg/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func testMedia(h uint64, path string, tags ...string) Media {
+	media := Media{
+		Hash:     h,
+		Path:     path,
+		Folder:   "a",
+		Library:  "default",
+		Date:     time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+		Modified: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+	}
+	for _, tag := range tags {
+		media.Subject = append(media.Subject, types.Subject{Key: tag, Value: tag})
+	}
+
+	return media
+}
+
+func TestMediaWriterReplace(t *testing.T) {
+	c := testConf(t)
+	if err := c.Store.InsertMediaItem(testMedia(1, "a/1.jpg", "sea")); err != nil {
+		t.Fatal(err)
+	}
+
+	var errs []error
+	done := func(err error) { errs = append(errs, err) }
+
+	w := newMediaWriter(c, cache.New(time.Minute, time.Minute))
+	updated := testMedia(1, "a/1.jpg", "sky")
+	updated.Title = "updated"
+	w.insert(updated, true, done)
+	w.insert(testMedia(2, "a/2.jpg"), false, done)
+
+	// the updated item is kept until its replacement is written
+	item, err := c.Store.GetSingleMediaItem(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, `[{"key":"sea","value":"sea"}]`, item.Subject)
+
+	w.close()
+	assert.Equal(t, []error{nil, nil}, errs)
+
+	item, err = c.Store.GetSingleMediaItem(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "updated", item.Title)
+	assert.Equal(t, `[{"key":"sky","value":"sky"}]`, item.Subject)
+
+	item, err = c.Store.GetSingleMediaItem(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "a/2.jpg", item.Path)
+
+	// tags no other media item has are removed
+	tags, err := c.Store.GetTags("asc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Len(t, tags, 1)
+	assert.Equal(t, "sky", tags[0].Key)
+}
+
+func TestMediaWriterBatch(t *testing.T) {
+	c := testConf(t)
+	if err := c.Store.InsertMediaItem(testMedia(1, "a/1.jpg")); err != nil {
+		t.Fatal(err)
+	}
+
+	w := newMediaWriter(c, cache.New(time.Minute, time.Minute))
+
+	// a full batch is written without waiting for insertInterval, after which it would be written by the timer
+	start := time.Now()
+	results := make(chan error, insertBatchSize)
+	for i := range insertBatchSize {
+		h := uint64(i + 2)
+		w.insert(testMedia(h, fmt.Sprintf("a/%d.jpg", h)), false, func(err error) { results <- err })
+	}
+
+	timeout := time.After(insertInterval - time.Since(start))
+	for range insertBatchSize {
+		select {
+		case err := <-results:
+			assert.NoError(t, err)
+		case <-timeout:
+			t.Fatal("expected a full batch to be written before the insert interval")
+		}
+	}
+
+	// an item with the hash of an existing item fails the batch, which is inserted one at a time
+	var errs []error
+	done := func(err error) { errs = append(errs, err) }
+	w.insert(testMedia(1, "b/1.jpg"), false, done)
+	w.insert(testMedia(1000, "a/1000.jpg"), false, done)
+	w.close()
+
+	assert.Len(t, errs, 2)
+	assert.Error(t, errs[0])
+	assert.NoError(t, errs[1])
+
+	total, err := c.Store.GetTotalMediaItems(0, "0001-01-01T00:00:00Z", "9999-01-01T00:00:00Z")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, insertBatchSize+2, total)
+}
+
+func TestInsertPHash(t *testing.T) {
+	c := testConf(t)
+
+	// a hash of 0 is kept apart from no hash
+	hashed := testMedia(1, "a/1.jpg")
+	hashed.PHashed = true
+	video := testMedia(2, "a/2.mp4")
+	for _, media := range []Media{hashed, video} {
+		if err := c.Store.InsertMediaItem(media); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	item, err := c.Store.GetSingleMediaItem(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.True(t, item.PHashed)
+	assert.Equal(t, uint64(0), item.PHash)
+
+	item, err = c.Store.GetSingleMediaItem(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.False(t, item.PHashed)
+}
+
+func TestAssignHash(t *testing.T) {
+	c := testConf(t)
+	if err := c.Store.InsertMediaItem(testMedia(1, "a/1.jpg")); err != nil {
+		t.Fatal(err)
+	}
+	p := &scanPool{c: c, hashes: make(map[uint64]string)}
+
+	// an updated item keeps its hash
+	media := Media{Hash: 5, Path: "a/5.jpg", Library: "default", Checksum: "5"}
+	assert.NoError(t, p.assignHash(&media, 9))
+	assert.Equal(t, uint64(9), media.Hash)
+
+	// a new file keeps the hash of its content
+	media = Media{Hash: 2, Path: "a/2.jpg", Library: "default", Checksum: "2"}
+	assert.NoError(t, p.assignHash(&media, 0))
+	assert.Equal(t, uint64(2), media.Hash)
+
+	// a copy of a file given out earlier in the scan, or already in the database, gets its own hash
+	media = Media{Hash: 2, Path: "b/2.jpg", Library: "default", Checksum: "2"}
+	assert.NoError(t, p.assignHash(&media, 0))
+	assert.Equal(t, hash.GetUniqueHash("2", libraryKey("default", "b/2.jpg")), media.Hash)
+
+	media = Media{Hash: 1, Path: "b/1.jpg", Library: "default", Checksum: "1"}
+	assert.NoError(t, p.assignHash(&media, 0))
+	assert.Equal(t, hash.GetUniqueHash("1", libraryKey("default", "b/1.jpg")), media.Hash)
+
+	// the same file in another library is a copy
+	media = Media{Hash: 1, Path: "a/1.jpg", Library: "archive", Checksum: "1"}
+	assert.NoError(t, p.assignHash(&media, 0))
+	assert.NotEqual(t, uint64(1), media.Hash)
+}
+
+func TestClaimHash(t *testing.T) {
+	c := testConf(t)
+	p := &scanPool{c: c, hashes: make(map[uint64]string), released: make(map[int]bool), submitted: 3}
+	p.turn = sync.NewCond(&p.mu)
+
+	// the copy submitted second is read first, and waits for the first to claim the content hash
+	claimed := make(chan Media)
+	go func() {
+		media := Media{Hash: 1, Path: "a/1 copy.jpg", Library: "default", Checksum: "1"}
+		assert.NoError(t, p.claimHash(&media, scanTask{seq: 2}))
+		claimed <- media
+	}()
+
+	select {
+	case <-claimed:
+		t.Fatal("expected the copy to wait for the files submitted before it")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// a file that fails before claiming a hash releases its turn
+	p.releaseHash(1)
+
+	media := Media{Hash: 1, Path: "a/1.jpg", Library: "default", Checksum: "1"}
+	assert.NoError(t, p.claimHash(&media, scanTask{seq: 0}))
+	assert.Equal(t, uint64(1), media.Hash)
+
+	other := <-claimed
+	assert.Equal(t, hash.GetUniqueHash("1", libraryKey("default", "a/1 copy.jpg")), other.Hash)
+}
@@ -6,14 +6,15 @@ import (
 	"io/fs"
 	"os"
 	"path/filepath"
+	"slices"
 	"strconv"
 	"strings"
-	"sync"
 	"time"
 
-	exiftool "github.com/barasher/go-exiftool"
 	cache "github.com/patrickmn/go-cache"
 	"github.com/robbymilo/rgallery/pkg/config"
+	"github.com/robbymilo/rgallery/pkg/exif"
+	"github.com/robbymilo/rgallery/pkg/formats"
 	"github.com/robbymilo/rgallery/pkg/geo"
 	"github.com/robbymilo/rgallery/pkg/queries"
 	"github.com/robbymilo/rgallery/pkg/resize"
@@ -24,17 +25,35 @@ import (
 type Media = types.Media
 type Conf = types.Conf
 
-// BackgroundScan allows us to call Scan as a goroutine and still report a status/error.
-func BackgroundScan(scanType string, c Conf, cache *cache.Cache) {
-	_, err := Scan(scanType, c, cache)
-	if err != nil {
-		c.Logger.Error("error background scanning", "error", err)
-		return
-	}
-}
-
 // Scan coordinates the addition, updating, and removal of all media items.
 func Scan(scanType string, c Conf, cache *cache.Cache) (string, error) {
+	return scan(scanType, nil, c, cache)
+}
+
+// libraryStats counts the outcome of scanning the libraries of a scan.
+type libraryStats struct {
+	moved       int
+	ignored     int
+	alternates  int
+	unsupported int
+	errors      int
+	offline     int
+	orphaned    int
+}
+
+func (s *libraryStats) add(other libraryStats) {
+	s.moved += other.moved
+	s.ignored += other.ignored
+	s.alternates += other.alternates
+	s.unsupported += other.unsupported
+	s.errors += other.errors
+	s.offline += other.offline
+	s.orphaned += other.orphaned
+}
+
+// scan runs a scan of every library, or of the library in its scope, recording its progress to job if it is run as a
+// job.
+func scan(scope string, job *jobProgress, c Conf, cache *cache.Cache) (string, error) {
 	// Defer a panic recovery function
 	defer func() {
 		if r := recover(); r != nil {
@@ -51,302 +70,412 @@ func Scan(scanType string, c Conf, cache *cache.Cache) (string, error) {
 	start := time.Now()
 	total := 0
 	var status string
+	scanType, library := parseScanScope(scope)
 
-	// Check if a scan is already in progress using our global variable
-	if IsScanInProgress() {
+	// Check if a scan is already in progress and claim it otherwise
+	if !tryStartScan() {
 		c.Logger.Info("scan already in progress")
 		if err := queries.Notify(c, "Scan already in progress.", "scanning"); err != nil {
 			c.Logger.Error("failed to notify", "err", err)
 		}
 
-		return "scan already in progress", nil
+		return "", ErrScanInProgress
 	} else {
+		defer SetScanInProgress(false)
 		if scanType == "thumb" {
 			return "", nil
 		}
-		// Set scan in progress to true
-		SetScanInProgress(true)
 
 		// create a cancel channel for this scan
 		resetCancelChan(make(chan struct{}))
+		defer resetCancelChan(nil)
 
-		var unsupportedPaths []string
+		libs, err := scanLibraries(library, c)
+		if err != nil {
+			return "", err
+		}
 
-		c.Logger.Info("scanning media at " + config.MediaPath(c))
-		if err := queries.Notify(c, "Scan started at "+config.MediaPath(c)+".", "scanning"); err != nil {
-			c.Logger.Error("failed to notify", "err", err)
+		if scanType == "deep" {
+			c.Logger.Info("deep scan started")
 		}
 
+		if scanType == "metadata" {
+			c.Logger.Info("metadata scan started")
+		}
+
+		// load the geo handler once rather than for every library
 		var h *geo.Handlers
 		if c.LocationService == "" {
-			var err error
 			h, err = geo.NewGeoHandler(c)
 			if err != nil {
 				return "", fmt.Errorf("error getting geo handler %v", err)
 			}
 		}
 
-		if scanType == "deep" {
-			c.Logger.Info("deep scan started")
+		var stats libraryStats
+		cursor := job.resumeAfter()
+		for i, lib := range libs {
+			libCursor, scanned := libraryCursor(cursor, libs, i)
+			s, err := scanLibrary(scanType, libCursor, scanned, h, job, config.ForLibrary(lib, c), cache)
+			if err != nil {
+				return "", err
+			}
+			stats.add(s)
 		}
 
-		if scanType == "metadata" {
-			c.Logger.Info("metadata scan started")
+		// a scan of every library removes the items of libraries that are no longer configured
+		if library == "" {
+			stats.orphaned, err = removeOrphans(c, cache)
+			if err != nil {
+				c.Logger.Error("error removing items of unconfigured libraries", "error", err)
+			}
 		}
 
-		buf := make([]byte, 1024*1024)
-		et, err := exiftool.NewExiftool(exiftool.NoPrintConversion(), exiftool.Buffer(buf, 256*1024))
+		from := time.Unix(0, 0)
+		to := time.Now()
+		total, err = queries.GetTotalMediaItems(0, from.Format(time.RFC3339), to.Format(time.RFC3339), "", "", c)
 		if err != nil {
-			return "", fmt.Errorf("error starting exiftool %v", err)
+			c.Logger.Error("error getting total media items", "error", err)
 		}
-		defer func() {
-			if err := et.Close(); err != nil {
-				c.Logger.Error("et.Close error", "err", err)
-			}
-		}()
 
-		// get all current media items
-		items, err := queries.GetMediaItems(0, "ASC", -1, c)
-		if err != nil {
-			return "", fmt.Errorf("error getting media items %v", err)
+		// Reset our global scan in progress flag
+		SetScanInProgress(false)
+		// cleanup cancel channel
+		resetCancelChan(nil)
+
+		unsupportedStatus := ""
+		if stats.unsupported > 0 {
+			unsupportedStatus = fmt.Sprintf("%d unsupported items skipped.", stats.unsupported)
 		}
 
-		scanErrors, err := GetScanErrors(c)
-		if err != nil {
-			c.Logger.Error("error getting scan errors", "error", err)
+		movedStatus := ""
+		if stats.moved > 0 {
+			movedStatus = fmt.Sprintf("%d items moved.", stats.moved)
 		}
 
-		c.Logger.Info("checking for modified and deleted items...")
-		for _, item := range items {
+		ignoredStatus := ""
+		if stats.ignored > 0 {
+			ignoredStatus = fmt.Sprintf("%d ignored items removed.", stats.ignored)
+		}
 
-			// check for cancellation
-			if isCanceled() {
-				c.Logger.Info("scan canceled by user")
-				if err := queries.Notify(c, "Scan canceled while checking for modified and deleted items.", "canceled"); err != nil {
-					c.Logger.Error("Notify error", "err", err)
-				}
-				SetScanInProgress(false)
-				// reset cancel channel
-				resetCancelChan(nil)
-				return "scan canceled", nil
+		alternatesStatus := ""
+		if stats.alternates > 0 {
+			alternatesStatus = fmt.Sprintf("%d alternates stacked.", stats.alternates)
+		}
+
+		errorsStatus := ""
+		if stats.errors > 0 {
+			errorsStatus = fmt.Sprintf("%d items with errors occurred during scan.", stats.errors)
+		}
+
+		offlineStatus := ""
+		if stats.offline > 0 {
+			offlineStatus = fmt.Sprintf("%d offline libraries skipped.", stats.offline)
+		}
+
+		orphanedStatus := ""
+		if stats.orphaned > 0 {
+			orphanedStatus = fmt.Sprintf("%d items of unconfigured libraries removed.", stats.orphaned)
+		}
+
+		status = fmt.Sprintf("Scan complete. %d media items scanned in %s. %s %s %s %s %s %s %s", total, time.Since(start).Truncate(time.Second).String(), movedStatus, ignoredStatus, alternatesStatus, unsupportedStatus, errorsStatus, offlineStatus, orphanedStatus)
+
+	}
+
+	c.Logger.Info(status)
+	time.Sleep(100 * time.Millisecond) // needed for long polling
+	if err := queries.Notify(c, status, "complete"); err != nil {
+		c.Logger.Error("Notify error", "err", err)
+	}
+
+	return status, nil
+
+}
+
+// scanLibrary scans the library c is scoped to with config.ForLibrary. A resumed scan continues after the path in
+// cursor, or after every item if the library was scanned before the scan was interrupted.
+func scanLibrary(scanType, cursor string, scanned bool, h *geo.Handlers, job *jobProgress, c Conf, cache *cache.Cache) (libraryStats, error) {
+	var unsupportedPaths []string
+
+	c.Logger.Info("scanning media at " + config.MediaPath(c))
+	if err := queries.Notify(c, "Scan started at "+config.MediaPath(c)+".", "scanning"); err != nil {
+		c.Logger.Error("failed to notify", "err", err)
+	}
+
+	// get all current media items of the library
+	items, err := libraryItems(c)
+	if err != nil {
+		return libraryStats{}, fmt.Errorf("error getting media items %v", err)
+	}
+
+	if libraryOffline(config.MediaPath(c), items) {
+		c.Logger.Warn("skipping offline library " + c.Library + " at " + config.MediaPath(c))
+		if err := queries.Notify(c, "Library "+c.Library+" is offline and was not scanned.", "scanning"); err != nil {
+			c.Logger.Error("failed to notify", "err", err)
+		}
+		return libraryStats{offline: 1}, nil
+	}
+
+	pool, err := newScanPool(c, cache, h, job)
+	if err != nil {
+		return libraryStats{}, err
+	}
+	defer pool.close()
+
+	// check items in path order so a resumed job can skip the items it already rescanned
+	slices.SortFunc(items, func(a, b Media) int {
+		return strings.Compare(a.Path, b.Path)
+	})
+	if cursor != "" && (scanType == "deep" || scanType == "metadata") {
+		c.Logger.Info("resuming " + scanType + " scan after " + cursor)
+	}
+
+	known := make(map[string]bool, len(items))
+	for _, item := range items {
+		known[item.Path] = true
+	}
+
+	scanErrors, err := GetScanErrors(c)
+	if err != nil {
+		c.Logger.Error("error getting scan errors", "error", err)
+	}
+
+	// paths excluded by the config file and ignore files in the media directory
+	ignore := newIgnoreRules(config.MediaPath(c), c)
+
+	// files with the same name in the same folder are stored as one media item and its alternates
+	r := newRenditions(config.MediaPath(c), scanErrors, ignore)
+	alternates := make(map[string]string)
+
+	// items whose path no longer exists, removed after checking for moved items
+	var missing []Media
+	var moved, ignored int
+
+	c.Logger.Info("checking for modified and deleted items...")
+	for _, item := range items {
+
+		// check for cancellation
+		if isCanceled() {
+			c.Logger.Info("scan canceled by user")
+			pool.close()
+			if err := queries.Notify(c, "Scan canceled while checking for modified and deleted items.", "canceled"); err != nil {
+				c.Logger.Error("Notify error", "err", err)
 			}
+			SetScanInProgress(false)
+			// reset cancel channel
+			resetCancelChan(nil)
+			return libraryStats{}, ErrScanCanceled
+		}
+
+		if ignore.ignored(item.Path, false) {
+
+			// the item was added before an ignore rule matched it
+			err := deleteMediaItem(item.Path, true, item, c, cache)
+			if err != nil {
+				c.Logger.Error("error removing item", "error", err)
+				continue
+			}
+
+			ignored++
+			c.Logger.Info("removed ignored item " + item.Path)
+
+		} else if _, err := os.Stat(filepath.Join(config.MediaPath(c), item.Path)); errors.Is(err, os.ErrNotExist) {
 
 			// if deleted image exists in db
-			if _, err := os.Stat(filepath.Join(config.MediaPath(c), item.Path)); errors.Is(err, os.ErrNotExist) {
 
-				// remove if deleted
-				err := deleteMediaItem(item.Path, true, item, c, cache)
-				if err != nil {
-					c.Logger.Error("error removing item", "error", err)
-				}
+			// hold on to the item in case it was moved or renamed
+			missing = append(missing, item)
 
-				c.Logger.Info("removed item " + item.Path)
-				if err := queries.Notify(c, "Removed item: "+item.Path, "scanning"); err != nil {
-					c.Logger.Error("Notify error", "err", err)
-				}
+		} else if primary := r.primary(item.Path); primary != item.Path {
+
+			// another file with the same name is now preferred
+			demoteMediaItem(item, primary, c, cache)
+
+		} else {
+
+			// check existing image for modifications
+			// recreate thumbnails
+			if mediaModified(item, c) {
+
+				pool.submit(scanTask{relative_path: item.Path, absolute_path: filepath.Join(config.MediaPath(c), item.Path), existing: item, regenThumb: true, checkpoint: true})
+
+			} else if item.Checksum == "" {
 
-			} else {
+				// the file could not be read when content hashes were added, rescan it keeping its hash
+				pool.submit(scanTask{relative_path: item.Path, absolute_path: filepath.Join(config.MediaPath(c), item.Path), existing: item, regenThumb: false, checkpoint: true})
 
-				// check existing image for modifications
+			} else if scanned || cursor != "" && item.Path <= cursor {
+
+				// already rescanned before the job was interrupted
+				continue
+
+			} else if scanType == "deep" {
+
+				// remove and add all items
 				// recreate thumbnails
-				if mediaModified(item, c) {
-
-					err = updateMediaItem(item.Path, true, et, h, c, item, cache)
-					if err != nil {
-						c.Logger.Error("error updating media item", "error", err)
-					} else {
-						if err := queries.Notify(c, "Updated media: "+item.Path, "scanning"); err != nil {
-							c.Logger.Error("Notify error", "err", err)
-						}
-					}
-
-				} else if scanType == "deep" {
-
-					// remove and add all items
-					// recreate thumbnails
-					c.Logger.Info("deepScanning: " + item.Path)
-					err = updateMediaItem(item.Path, true, et, h, c, item, cache)
-					if err != nil {
-						c.Logger.Error("error updating media item during deep scan", "error", err)
-					} else {
-						if err := queries.Notify(c, "Updated media: "+item.Path, "scanning"); err != nil {
-							c.Logger.Error("Notify error", "err", err)
-						}
-					}
-
-				} else if scanType == "metadata" {
-
-					// remove and add all items
-					// do not recreate thumbnails
-					c.Logger.Info("metadataScanning: " + item.Path)
-					err = updateMediaItem(item.Path, false, et, h, c, item, cache)
-					if err != nil {
-						c.Logger.Error("error updating media item during metadata scan", "error", err)
-					} else {
-						if err := queries.Notify(c, "Updated media: "+item.Path, "scanning"); err != nil {
-							c.Logger.Error("Notify error", "err", err)
-						}
-					}
+				c.Logger.Info("deepScanning: " + item.Path)
+				pool.submit(scanTask{relative_path: item.Path, absolute_path: filepath.Join(config.MediaPath(c), item.Path), existing: item, regenThumb: true, checkpoint: true})
 
-				}
+			} else if scanType == "metadata" {
+
+				// remove and add all items
+				// do not recreate thumbnails
+				c.Logger.Info("metadataScanning: " + item.Path)
+				pool.submit(scanTask{relative_path: item.Path, absolute_path: filepath.Join(config.MediaPath(c), item.Path), existing: item, regenThumb: false, checkpoint: true})
 
 			}
 
 		}
 
-		c.Logger.Info("checking for new items...")
-
-		err = filepath.WalkDir(config.MediaPath(c), func(p string, info fs.DirEntry, err error) error {
-			// Add panic recovery for each file processing
-			defer func() {
-				if r := recover(); r != nil {
-					c.Logger.Error("recovered from panic while processing file", "file", p, "panic", r)
-					// Continue with next file instead of aborting the whole scan
-					err = nil
-				}
-			}()
+	}
 
-			if err != nil {
-				return fmt.Errorf("error walking media directory %v", err)
+	c.Logger.Info("checking for new items...")
 
+	err = filepath.WalkDir(config.MediaPath(c), func(p string, info fs.DirEntry, err error) error {
+		// Add panic recovery for each file processing
+		defer func() {
+			if r := recover(); r != nil {
+				c.Logger.Error("recovered from panic while processing file", "file", p, "panic", r)
+				// Continue with next file instead of aborting the whole scan
+				err = nil
 			}
-			if !info.IsDir() {
+		}()
 
-				// check for cancellation inside file walk
-				if isCanceled() {
-					c.Logger.Info("scan canceled by user (during walk)")
-					return errors.New("scan canceled")
-				}
+		if err != nil {
+			return fmt.Errorf("error walking media directory %v", err)
 
-				// remove working dir from path to store a relative ref in db
-				relative_path := strings.Replace(p, config.MediaPath(c)+"/", "", 1)
-				absolute_path := filepath.Join(config.MediaPath(c), relative_path)
+		}
 
-				// check if image exists in db
-				file, err := os.Stat(absolute_path)
-				if err != nil {
-					c.Logger.Error("error stating file:", "error", err)
-				}
+		// skip ignored directories without descending into them
+		if rel, err := filepath.Rel(config.MediaPath(c), p); err == nil && ignore.ignored(rel, info.IsDir()) {
+			if info.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
 
-				// skip previously scanned items that had an error
-				erroredImage := false
-				if lastErrorTime, ok := scanErrors[relative_path]; ok {
-					if file.ModTime().Before(lastErrorTime) {
-						c.Logger.Info("skipping image " + relative_path + " as it was previously scanned and had an error.")
-						erroredImage = true
-					}
-				}
+		if !info.IsDir() {
 
-				if !mediaExists(items, relative_path) && !erroredImage {
-
-					// check if file is an image
-					if isImage(p) {
-						err = addImage(relative_path, absolute_path, false, c.PreGenerateThumb, et, h, c, cache)
-						if err != nil {
-							c.Logger.Error("error adding image "+absolute_path, "error", err)
-							err := TrackScanError(relative_path, time.Now(), err, c)
-							if err != nil {
-								c.Logger.Error("error tracking scan error", "error", err)
-							}
-						} else {
-							if err := queries.Notify(c, "Added image: "+relative_path, "scanning"); err != nil {
-								c.Logger.Error("Notify error", "err", err)
-							}
-						}
-
-					} else if isVideo(p) {
-						err = addVideo(relative_path, absolute_path, false, c.PreGenerateThumb, et, h, c, cache)
-						if err != nil {
-							c.Logger.Error("error adding video "+absolute_path, "error", err)
-						} else {
-							if err := queries.Notify(c, "Added video: "+relative_path, "scanning"); err != nil {
-								c.Logger.Error("Notify error", "err", err)
-							}
-						}
-
-					} else {
-						c.Logger.Info("skipping unsupported file " + relative_path)
-						unsupportedPaths = append(unsupportedPaths, relative_path)
-						// queries.Notify(c, "Skipping unsupported file: " + relative_path)
-					}
+			// check for cancellation inside file walk
+			if isCanceled() {
+				c.Logger.Info("scan canceled by user (during walk)")
+				return errors.New("scan canceled")
+			}
 
-				}
+			// sidecars are read with their media file, and ignore files are not media
+			if exif.IsSidecar(p) || filepath.Base(p) == ignoreFile {
+				return nil
 			}
 
-			return nil
+			// remove working dir from path to store a relative ref in db
+			relative_path := strings.Replace(p, config.MediaPath(c)+"/", "", 1)
+			absolute_path := filepath.Join(config.MediaPath(c), relative_path)
 
-		})
+			// check if image exists in db
+			file, err := os.Stat(absolute_path)
+			if err != nil {
+				c.Logger.Error("error stating file:", "error", err)
+			}
 
-		if err != nil {
-			if err.Error() == "scan canceled" {
-				SetScanInProgress(false)
-				resetCancelChan(nil)
-				if err := queries.Notify(c, "Scan canceled while checking for new items.", "canceled"); err != nil {
-					c.Logger.Error("Notify error", "err", err)
+			// skip previously scanned items that had an error
+			erroredImage := false
+			if lastErrorTime, ok := scanErrors[relative_path]; ok {
+				if file.ModTime().Before(lastErrorTime) {
+					c.Logger.Info("skipping image " + relative_path + " as it was previously scanned and had an error.")
+					erroredImage = true
 				}
-				return "scan canceled", nil
 			}
 
-			return "", fmt.Errorf("error scanning %v", err)
+			if primary := r.primary(relative_path); primary != relative_path {
+				alternates[relative_path] = primary
+			} else if !known[relative_path] && !erroredImage {
+				var result addResult
+				result, missing = addFile(relative_path, absolute_path, file, missing, pool, c, cache)
+
+				switch result {
+				case fileMoved:
+					moved++
+				case fileUnsupported:
+					unsupportedPaths = append(unsupportedPaths, relative_path)
+				}
+			}
 		}
 
-		from := time.Unix(0, 0)
-		to := time.Now()
-		total, err = queries.GetTotalMediaItems(0, from.Format(time.RFC3339), to.Format(time.RFC3339), "", "", c)
-		if err != nil {
-			c.Logger.Error("error getting total media items", "error", err)
-		}
+		return nil
 
-		// Reset our global scan in progress flag
-		SetScanInProgress(false)
-		// cleanup cancel channel
-		resetCancelChan(nil)
+	})
 
-		unsupportedStatus := ""
-		if len(unsupportedPaths) > 0 {
-			unsupportedStatus = fmt.Sprintf("%d unsupported items skipped.", len(unsupportedPaths))
-		}
+	// wait for the queued files to be added
+	stats := pool.close()
 
-		errorsStatus := ""
-		if len(scanErrors) > 0 {
-			errorsStatus = fmt.Sprintf("%d items with errors occurred during scan.", len(scanErrors))
+	if err != nil {
+		if err.Error() == "scan canceled" {
+			SetScanInProgress(false)
+			resetCancelChan(nil)
+			if err := queries.Notify(c, "Scan canceled while checking for new items.", "canceled"); err != nil {
+				c.Logger.Error("Notify error", "err", err)
+			}
+			return libraryStats{}, ErrScanCanceled
 		}
 
-		status = fmt.Sprintf("Scan complete. %d media items scanned in %s. %s %s", total, time.Since(start).Truncate(time.Second).String(), unsupportedStatus, errorsStatus)
-
+		return libraryStats{}, fmt.Errorf("error scanning %v", err)
 	}
 
-	c.Logger.Info(status)
-	time.Sleep(100 * time.Millisecond) // needed for long polling
-	if err := queries.Notify(c, status, "complete"); err != nil {
-		c.Logger.Error("Notify error", "err", err)
+	// remove items that were deleted rather than moved
+	for _, item := range missing {
+		err := deleteMediaItem(item.Path, true, item, c, cache)
+		if err != nil {
+			c.Logger.Error("error removing item", "error", err)
+		}
+
+		c.Logger.Info("removed item " + item.Path)
+		if err := queries.Notify(c, "Removed item: "+item.Path, "scanning"); err != nil {
+			c.Logger.Error("Notify error", "err", err)
+		}
 	}
 
-	return status, nil
+	err = saveAlternates(nil, alternates, c)
+	if err != nil {
+		c.Logger.Error("error saving alternates", "error", err)
+	}
 
+	return libraryStats{
+		moved:       moved,
+		ignored:     ignored,
+		alternates:  len(alternates),
+		unsupported: len(unsupportedPaths),
+		errors:      len(scanErrors) + stats.failed,
+	}, nil
 }
 
+// missingThumb is a media item with the sizes of its thumbnails that are not in the cache.
 type missingThumb struct {
-	Size  int
+	Sizes []int
 	Media Media
 }
 
 // ThumbScan checks all media items for missing thumbnails and generates any missing ones.
 func ThumbScan(c Conf) (string, error) {
+	return thumbScan(nil, c)
+}
+
+// thumbScan runs a thumbnail scan, recording its progress to job if it is run as a job.
+func thumbScan(job *jobProgress, c Conf) (string, error) {
 	var status string
-	if IsScanInProgress() {
+	if !tryStartScan() {
 		c.Logger.Info("thumbscan already in progress")
 		if err := queries.Notify(c, "Thumbscan already in progress.", "scanning"); err != nil {
 			c.Logger.Warn("Notify error", "err", err)
 		}
 
-		status = "thumbscan already in progress"
+		return "", ErrScanInProgress
 	} else {
 		start := time.Now()
-		SetScanInProgress(true)
+		defer SetScanInProgress(false)
 
 		// create a cancel channel for this scan
 		resetCancelChan(make(chan struct{}))
+		defer resetCancelChan(nil)
 
 		c.Logger.Info("scanning thumbs at " + config.CachePath(c))
 		// Notify clients immediately that a thumbscan has started
@@ -372,7 +501,7 @@ func ThumbScan(c Conf) (string, error) {
 				}
 				SetScanInProgress(false)
 				resetCancelChan(nil)
-				return "thumbscan canceled", nil
+				return "", ErrScanCanceled
 			}
 
 			// build a map of sizes for the thumbnail
@@ -392,30 +521,40 @@ func ThumbScan(c Conf) (string, error) {
 				}
 			}
 
-			if len(s) > 0 {
-				for _, size := range s {
-					thumbPath := filepath.Join(config.CachePath(c), strconv.Itoa(size), strconv.Itoa(int(item.Hash))+".jpg")
-
-					if _, err := os.Stat(thumbPath); errors.Is(err, os.ErrNotExist) {
-						missingItems = append(missingItems, missingThumb{Size: int(size), Media: item})
-					}
+			missing := missingThumb{Media: item}
+			for _, size := range s {
+				thumbPath := filepath.Join(config.CachePath(c), strconv.Itoa(size), strconv.Itoa(int(item.Hash))+".jpg")
 
-					totalItems++
+				if _, err := os.Stat(thumbPath); errors.Is(err, os.ErrNotExist) {
+					missing.Sizes = append(missing.Sizes, size)
 				}
+
+				totalItems++
+			}
+			if len(missing.Sizes) > 0 {
+				missingItems = append(missingItems, missing)
+			}
+		}
+
+		var totalMissing int
+		for _, missing := range missingItems {
+			for range missing.Sizes {
+				job.queue(missing.Media.Path, false)
 			}
+			totalMissing += len(missing.Sizes)
 		}
 
-		status = fmt.Sprintf("Generating %d missing thumbnails...", len(missingItems))
+		status = fmt.Sprintf("Generating %d missing thumbnails...", totalMissing)
 		c.Logger.Info(status)
 		if err := queries.Notify(c, status, "scanning"); err != nil {
 			c.Logger.Warn("Notify error", "err", err)
 		}
 
-		var totalErrors int
+		var totalErrors, generated int
 		for idx, missing := range missingItems {
 			// periodically notify progress so clients receive updates
 			if idx%10 == 0 {
-				if err := queries.Notify(c, fmt.Sprintf("Generating thumbnails: %d/%d", idx, len(missingItems)), "scanning"); err != nil {
+				if err := queries.Notify(c, fmt.Sprintf("Generating thumbnails: %d/%d", generated, totalMissing), "scanning"); err != nil {
 					c.Logger.Warn("Notify error", "err", err)
 				}
 			}
@@ -427,30 +566,22 @@ func ThumbScan(c Conf) (string, error) {
 				}
 				SetScanInProgress(false)
 				resetCancelChan(nil)
-				return "thumbscan canceled", nil
+				return "", ErrScanCanceled
 			}
 
-			var wg sync.WaitGroup
-			errChan := make(chan error, 1)
-
-			wg.Add(1)
-			go resize.AddImageThumb(missing.Media, missing.Size, c, &wg, errChan)
-
-			go func() {
-				wg.Wait()
-				c.Logger.Info("Thumbnail generated for " + missing.Media.Path + " with size " + strconv.Itoa(missing.Size))
-				close(errChan)
-			}()
-
-			for err := range errChan {
+			// every missing size of an item is resized from the same decoded image
+			for i, err := range resize.GenerateThumbs(missing.Media, nil, missing.Sizes, c) {
 				if err != nil {
-					c.Logger.Error("error generating thumbnail", "error", err)
 					totalErrors++
+				} else {
+					c.Logger.Info("Thumbnail generated for " + missing.Media.Path + " with size " + strconv.Itoa(missing.Sizes[i]))
 				}
+				job.finish(missing.Media.Path, err != nil)
 			}
+			generated += len(missing.Sizes)
 		}
 
-		status = fmt.Sprintf("Scan complete. %d thumbnails checked in %s. %d missing. %d errors occurred.", totalItems, time.Since(start).Truncate(time.Second).String(), len(missingItems), totalErrors)
+		status = fmt.Sprintf("Scan complete. %d thumbnails checked in %s. %d missing. %d errors occurred.", totalItems, time.Since(start).Truncate(time.Second).String(), totalMissing, totalErrors)
 		c.Logger.Info(status)
 		time.Sleep(100 * time.Millisecond) // needed for long polling
 		if err := queries.Notify(c, status, "complete"); err != nil {
@@ -467,29 +598,55 @@ func ThumbScan(c Conf) (string, error) {
 
 }
 
-// mediaExists tests if a media item exists in the db.
-func mediaExists(media []Media, path string) bool {
-	for _, item := range media {
-		if item.Path == path {
-			return true
+// addResult is the outcome of adding a file that is not in the db.
+type addResult int
+
+const (
+	fileQueued addResult = iota
+	fileMoved
+	fileUnsupported
+)
+
+// addFile adds a file that is not in the db by queuing it on the scan pool. If the file matches one of the missing
+// items it was moved or renamed, and the item is updated in place and removed from the returned missing items.
+func addFile(relative_path, absolute_path string, file os.FileInfo, missing []Media, pool *scanPool, c Conf, cache *cache.Cache) (addResult, []Media) {
+	if !isImage(absolute_path) && !isVideo(absolute_path) {
+		c.Logger.Info("skipping unsupported file " + relative_path)
+		return fileUnsupported, missing
+	}
+
+	// check if file is a missing item that was moved or renamed
+	idx, err := findMovedItem(absolute_path, file, missing)
+	if err != nil {
+		c.Logger.Error("error checking for moved item "+absolute_path, "error", err)
+	} else if idx >= 0 {
+		err = moveMediaItem(missing[idx], relative_path, file, c, cache)
+		if err != nil {
+			c.Logger.Error("error moving item "+absolute_path, "error", err)
+		} else {
+			if err := queries.Notify(c, "Moved item: "+missing[idx].Path+" to "+relative_path, "scanning"); err != nil {
+				c.Logger.Error("Notify error", "err", err)
+			}
+			missing = append(missing[:idx], missing[idx+1:]...)
+			return fileMoved, missing
 		}
 	}
-	return false
+
+	pool.submit(scanTask{relative_path: relative_path, absolute_path: absolute_path, regenThumb: c.PreGenerateThumb})
+	return fileQueued, missing
 }
 
 // mediaModified tests if a media item has been modified after its addition to the db.
 func mediaModified(media Media, c Conf) bool {
 
-	media_path := config.MediaPath(c)
-	file, err := os.Stat(filepath.Join(media_path, media.Path))
+	absolute_path := filepath.Join(config.MediaPath(c), media.Path)
+	file, err := os.Stat(absolute_path)
 	if err != nil {
 		c.Logger.Error("error stating file:", "err", err)
 	}
 
-	db_modified := media.Modified.Format("2006-01-02T15:04:05")
-	file_modified := file.ModTime().UTC().Format("2006-01-02T15:04:05")
-
-	return db_modified != file_modified
+	// a sidecar modified after the file is a modification of the media item
+	return !sameModTime(media, exif.ModTime(absolute_path, file))
 }
 
 func isImage(path string) bool {
@@ -497,13 +654,7 @@ func isImage(path string) bool {
 		return false
 	}
 
-	accepted := []string{".jpg", ".jpeg", ".heic", ".gif", ".png"}
-	for _, ext := range accepted {
-		if strings.HasSuffix(path, ext) || strings.HasSuffix(path, strings.ToUpper(ext)) && !strings.HasPrefix(path, ".") {
-			return true
-		}
-	}
-	return false
+	return formats.IsImage(path)
 }
 
 func isVideo(path string) bool {
@@ -511,12 +662,5 @@ func isVideo(path string) bool {
 		return false
 	}
 
-	accepted := []string{".mp4", ".mov"}
-	for _, ext := range accepted {
-		if strings.HasSuffix(path, ext) || strings.HasSuffix(path, strings.ToUpper(ext)) && !strings.HasPrefix(path, ".") {
-			return true
-		}
-	}
-	return false
-
+	return formats.IsVideo(path)
 }
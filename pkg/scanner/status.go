@@ -1,9 +1,17 @@
 package scanner
 
 import (
+	"errors"
 	"sync"
 )
 
+var (
+	// ErrScanInProgress is returned when a scan is started while another is running.
+	ErrScanInProgress = errors.New("scan already in progress")
+	// ErrScanCanceled is returned by a scan that was canceled with CancelScan.
+	ErrScanCanceled = errors.New("scan canceled")
+)
+
 var (
 	// ScanInProgress tracks whether a scan is currently in progress
 	scanInProgress bool
@@ -29,25 +37,32 @@ func SetScanInProgress(status bool) {
 	scanInProgress = status
 }
 
-// CancelScan requests cancellation of a running scan.
+// tryStartScan marks a scan as in progress, returning false if one already is.
+func tryStartScan() bool {
+	scanMutex.Lock()
+	defer scanMutex.Unlock()
+	if scanInProgress {
+		return false
+	}
+	scanInProgress = true
+	return true
+}
+
+// CancelScan requests cancellation of a running scan. The scan clears its in progress status once it has stopped.
 func CancelScan() bool {
-	scanCancelMutex.RLock()
-	ch := scanCancel
-	scanCancelMutex.RUnlock()
+	scanCancelMutex.Lock()
+	defer scanCancelMutex.Unlock()
 
-	if ch == nil {
+	if scanCancel == nil {
 		return false
 	}
 
-	// Protect write to avoid races with resetCancelChan
-	scanCancelMutex.Lock()
-	if scanCancel != nil {
+	// close the channel only once, as the scan may be canceled again before it has stopped
+	select {
+	case <-scanCancel:
+	default:
 		close(scanCancel)
 	}
-	scanCancelMutex.Unlock()
-
-	// Immediately set scan in progress to false so UI updates right away
-	SetScanInProgress(false)
 
 	return true
 }
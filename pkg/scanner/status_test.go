@@ -0,0 +1,69 @@
+package scanner
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestTryStartScan(t *testing.T) {
+	t.Cleanup(func() { SetScanInProgress(false) })
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	started := 0
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if tryStartScan() {
+				mu.Lock()
+				started++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if started != 1 {
+		t.Errorf("expected one scan to start, got %d", started)
+	}
+	if !IsScanInProgress() {
+		t.Errorf("expected a scan to be in progress")
+	}
+
+	SetScanInProgress(false)
+	if !tryStartScan() {
+		t.Errorf("expected a scan to start once the previous one finished")
+	}
+}
+
+func TestCancelScan(t *testing.T) {
+	t.Cleanup(func() {
+		resetCancelChan(nil)
+		SetScanInProgress(false)
+	})
+
+	if CancelScan() {
+		t.Errorf("expected no scan to cancel")
+	}
+
+	if !tryStartScan() {
+		t.Fatal("expected the scan to start")
+	}
+	resetCancelChan(make(chan struct{}))
+
+	// canceling twice must not close the channel twice
+	for range 2 {
+		if !CancelScan() {
+			t.Errorf("expected the scan to be canceled")
+		}
+	}
+	if !isCanceled() {
+		t.Errorf("expected the scan to see the cancellation")
+	}
+
+	// the scan clears its own status once it has stopped
+	if !IsScanInProgress() {
+		t.Errorf("expected the scan to stay in progress until it stops")
+	}
+}
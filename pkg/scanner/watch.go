@@ -0,0 +1,457 @@
+package scanner
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	cache "github.com/patrickmn/go-cache"
+	"github.com/robbymilo/rgallery/pkg/config"
+	"github.com/robbymilo/rgallery/pkg/exif"
+	"github.com/robbymilo/rgallery/pkg/geo"
+	"github.com/robbymilo/rgallery/pkg/queries"
+)
+
+// Watch watches the root of every library and scans only the files that changed, once changes have settled for
+// c.WatchDebounce. A full scan is queued every c.ReconcileInterval to catch changes the watcher missed, such as
+// those made while rgallery was stopped. A library that can not be watched is logged without stopping the others.
+func Watch(c Conf, cache *cache.Cache) error {
+	// load the geo handler once rather than for every batch
+	var h *geo.Handlers
+	if c.LocationService == "" {
+		var err error
+		h, err = geo.NewGeoHandler(c)
+		if err != nil {
+			return fmt.Errorf("error getting geo handler %v", err)
+		}
+	}
+
+	var wg sync.WaitGroup
+	for _, lib := range config.Libraries(c) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := watchLibrary(h, config.ForLibrary(lib, c), cache); err != nil {
+				c.Logger.Error("error watching library "+lib.Name, "error", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	return nil
+}
+
+// watchLibrary watches the library c is scoped to with config.ForLibrary.
+func watchLibrary(h *geo.Handlers, c Conf, cache *cache.Cache) error {
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("error creating watcher: %v", err)
+	}
+	defer w.Close()
+
+	root := config.MediaPath(c)
+	if _, err := watchDir(w, root, newIgnoreRules(root, c)); err != nil {
+		return fmt.Errorf("error watching library %s: %v", c.Library, err)
+	}
+	c.Logger.Info("watching media at " + root)
+
+	debounce := c.WatchDebounce
+	if debounce <= 0 {
+		debounce = time.Second
+	}
+
+	timer := time.NewTimer(debounce)
+	timer.Stop()
+
+	var reconcile <-chan time.Time
+	if c.ReconcileInterval > 0 {
+		ticker := time.NewTicker(c.ReconcileInterval)
+		defer ticker.Stop()
+		reconcile = ticker.C
+	}
+
+	// paths changed since the last batch, and paths of a batch that could not run yet
+	pending := make(map[string]struct{})
+	running := false
+	finished := make(chan []string)
+
+	for {
+		select {
+		case event, ok := <-w.Events:
+			if !ok {
+				return nil
+			}
+
+			if event.Has(fsnotify.Create) {
+				// watch new directories, and scan any files copied into them before the watch was added
+				files, err := watchDir(w, event.Name, newIgnoreRules(root, c))
+				if err != nil {
+					c.Logger.Error("error watching directory "+event.Name, "error", err)
+				}
+				for _, f := range files {
+					pending[f] = struct{}{}
+				}
+			}
+
+			// chmod events are included as they report modification time changes, scanPaths skips unchanged files
+			pending[event.Name] = struct{}{}
+			timer.Reset(debounce)
+
+		case err, ok := <-w.Errors:
+			if !ok {
+				return nil
+			}
+
+			c.Logger.Error("error watching media directory", "error", err)
+			if errors.Is(err, fsnotify.ErrEventOverflow) {
+				// changes were dropped, fall back to a full scan
+				if _, err := EnqueueJob(c, JobScan, watchScope("default", c)); err != nil {
+					c.Logger.Error("error queuing scan", "error", err)
+				}
+			}
+
+		case <-timer.C:
+			if running || len(pending) == 0 {
+				continue
+			}
+
+			ignore := newIgnoreRules(root, c)
+			paths := make([]string, 0, len(pending))
+			for p := range pending {
+				paths = append(paths, p)
+
+				// watch the directories a changed ignore file no longer ignores, their files are scanned by the full
+				// scan scanPaths queues for it
+				if filepath.Base(p) == ignoreFile {
+					if _, err := watchDir(w, filepath.Dir(p), ignore); err != nil {
+						c.Logger.Error("error watching directory "+filepath.Dir(p), "error", err)
+					}
+					continue
+				}
+
+				// watch directories again, as the watch added for a directory moved into the media directory
+				// is removed when the move event of its old path arrives
+				files, err := watchDir(w, p, ignore)
+				if err != nil {
+					c.Logger.Error("error watching directory "+p, "error", err)
+				}
+				paths = append(paths, files...)
+			}
+			clear(pending)
+
+			running = true
+			go func() {
+				_, err := scanPaths(paths, h, c, cache)
+				if errors.Is(err, ErrScanInProgress) {
+					finished <- paths
+					return
+				}
+				if err != nil {
+					c.Logger.Error("error scanning changed files", "error", err)
+				}
+				finished <- nil
+			}()
+
+		case retry := <-finished:
+			running = false
+
+			// a full scan was running, try the batch again once it has finished
+			for _, p := range retry {
+				pending[p] = struct{}{}
+			}
+			if len(pending) > 0 {
+				timer.Reset(debounce)
+			}
+
+		case <-reconcile:
+			c.Logger.Info("queuing reconciliation scan")
+			if _, err := EnqueueJob(c, JobScan, watchScope("default", c)); err != nil {
+				c.Logger.Error("error queuing scan", "error", err)
+			}
+		}
+	}
+}
+
+// watchDir adds a watch to dir and every directory below it, returning the files found. Directories ignored by the
+// rules of the library are skipped, as are hidden directories, such as those of file managers and sync tools, to save
+// watches; changes in them are found by the reconciliation scan.
+// It returns nothing if dir is not a directory.
+func watchDir(w *fsnotify.Watcher, dir string, ignore *ignoreRules) ([]string, error) {
+	info, err := os.Stat(dir)
+	if err != nil || !info.IsDir() {
+		return nil, nil
+	}
+
+	var files []string
+	err = filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		relative_path, err := filepath.Rel(ignore.root, p)
+		if err != nil || !filepath.IsLocal(relative_path) && relative_path != "." {
+			return fs.SkipDir
+		}
+
+		if d.IsDir() {
+			if isHidden(relative_path) || ignore.ignored(relative_path, true) {
+				return fs.SkipDir
+			}
+			return w.Add(p)
+		}
+
+		files = append(files, p)
+		return nil
+	})
+
+	return files, err
+}
+
+type changedFile struct {
+	relative_path string
+	absolute_path string
+	file          os.FileInfo
+}
+
+// scanPaths adds, updates, moves, and removes the media items at the given absolute paths, without walking the
+// rest of the media directory. A path that no longer exists removes every media item under it. It returns
+// ErrScanInProgress without scanning while another scan is running, so that the caller can retry the paths later.
+func scanPaths(paths []string, h *geo.Handlers, c Conf, cache *cache.Cache) (string, error) {
+	if !tryStartScan() {
+		return "", ErrScanInProgress
+	}
+	defer SetScanInProgress(false)
+
+	start := time.Now()
+	root := config.MediaPath(c)
+
+	items, err := libraryItems(c)
+	if err != nil {
+		return "", fmt.Errorf("error getting media items %v", err)
+	}
+
+	// the files of an unmounted share are reported as removed, keep its items until it is back
+	if libraryOffline(root, items) {
+		c.Logger.Warn("skipping changes of offline library " + c.Library + " at " + root)
+		return "", nil
+	}
+
+	scanErrors, err := GetScanErrors(c)
+	if err != nil {
+		c.Logger.Error("error getting scan errors", "error", err)
+	}
+
+	byPath := make(map[string]Media, len(items))
+	for _, item := range items {
+		byPath[item.Path] = item
+	}
+
+	// a changed ignore file can change which files are ignored anywhere below it, so they are checked by a full scan
+	for _, p := range paths {
+		if filepath.Base(p) == ignoreFile {
+			c.Logger.Info("queuing scan for changed ignore file " + p)
+			if _, err := EnqueueJob(c, JobScan, watchScope("default", c)); err != nil {
+				c.Logger.Error("error queuing scan", "error", err)
+			}
+			break
+		}
+	}
+
+	// a changed sidecar rescans the media files it belongs to, including when it was removed
+	sidecarChanged := make(map[string]bool)
+	for _, p := range paths {
+		if exif.IsSidecar(p) {
+			for _, m := range exif.SidecarMedia(p) {
+				paths = append(paths, m)
+				sidecarChanged[m] = true
+			}
+		}
+	}
+
+	// files with the same name as a changed file may be stored differently, such as a RAW file becoming an alternate
+	// of a JPEG file added next to it
+	ignore := newIgnoreRules(root, c)
+	r := newRenditions(root, scanErrors, ignore)
+	for _, p := range paths {
+		if relative_path, err := filepath.Rel(root, p); err == nil && filepath.IsLocal(relative_path) {
+			for _, f := range r.group(relative_path) {
+				paths = append(paths, filepath.Join(root, f))
+			}
+		}
+	}
+
+	sort.Strings(paths)
+	paths = slices.Compact(paths)
+
+	var files []changedFile
+	// items whose path no longer exists, removed after checking for moved items
+	var missing []Media
+	// items whose path is now ignored
+	var ignored []Media
+	seen := make(map[uint64]bool)
+	// folders of the changed files, in which alternates are updated
+	var folders []string
+	var demoted int
+
+	for _, p := range paths {
+		relative_path, err := filepath.Rel(root, p)
+		if err != nil || !filepath.IsLocal(relative_path) {
+			continue
+		}
+		folders = append(folders, filepath.Dir(relative_path))
+
+		if ignore.ignored(relative_path, isDir(p)) {
+			for _, item := range itemsUnder(items, relative_path) {
+				if !seen[item.Hash] {
+					seen[item.Hash] = true
+					ignored = append(ignored, item)
+				}
+			}
+			continue
+		}
+
+		file, err := os.Stat(p)
+		if errors.Is(err, os.ErrNotExist) {
+			for _, item := range itemsUnder(items, relative_path) {
+				if !seen[item.Hash] {
+					seen[item.Hash] = true
+					missing = append(missing, item)
+				}
+			}
+			continue
+		}
+		if err != nil {
+			c.Logger.Error("error stating file:", "error", err)
+			continue
+		}
+
+		if file.IsDir() || !isImage(p) && !isVideo(p) {
+			continue
+		}
+
+		if primary := r.primary(relative_path); primary != relative_path {
+			if item, ok := byPath[relative_path]; ok {
+				demoteMediaItem(item, primary, c, cache)
+				demoted++
+			}
+			continue
+		}
+
+		// skip files that were touched without being modified
+		if item, ok := byPath[relative_path]; ok && !sidecarChanged[p] && sameModTime(item, exif.ModTime(p, file)) {
+			continue
+		}
+
+		files = append(files, changedFile{relative_path: relative_path, absolute_path: p, file: file})
+	}
+
+	slices.Sort(folders)
+	folders = slices.Compact(folders)
+
+	for _, item := range ignored {
+		err := deleteMediaItem(item.Path, true, item, c, cache)
+		if err != nil {
+			c.Logger.Error("error removing item", "error", err)
+			continue
+		}
+
+		c.Logger.Info("removed ignored item " + item.Path)
+	}
+
+	if len(files) == 0 && len(missing) == 0 && demoted == 0 && len(ignored) == 0 {
+		return "", saveAlternates(folders, r.alternatesIn(folders), c)
+	}
+
+	c.Logger.Info(fmt.Sprintf("scanning %d changed files...", len(files)+len(missing)))
+
+	pool, err := newScanPool(c, cache, h, nil)
+	if err != nil {
+		return "", err
+	}
+	defer pool.close()
+
+	var moved int
+	for _, f := range files {
+		if item, ok := byPath[f.relative_path]; ok {
+			pool.submit(scanTask{relative_path: f.relative_path, absolute_path: f.absolute_path, existing: item, regenThumb: true})
+			continue
+		}
+
+		// skip previously scanned items that had an error
+		if lastErrorTime, ok := scanErrors[f.relative_path]; ok && f.file.ModTime().Before(lastErrorTime) {
+			continue
+		}
+
+		var result addResult
+		result, missing = addFile(f.relative_path, f.absolute_path, f.file, missing, pool, c, cache)
+		if result == fileMoved {
+			moved++
+		}
+	}
+
+	// wait for the queued files to be added
+	stats := pool.close()
+
+	// remove items that were deleted rather than moved
+	for _, item := range missing {
+		err := deleteMediaItem(item.Path, true, item, c, cache)
+		if err != nil {
+			c.Logger.Error("error removing item", "error", err)
+		}
+
+		c.Logger.Info("removed item " + item.Path)
+		if err := queries.Notify(c, "Removed item: "+item.Path, "scanning"); err != nil {
+			c.Logger.Error("Notify error", "err", err)
+		}
+	}
+
+	err = saveAlternates(folders, r.alternatesIn(folders), c)
+	if err != nil {
+		c.Logger.Error("error saving alternates", "error", err)
+	}
+
+	status := fmt.Sprintf("Changes scanned in %s. %d added, %d updated, %d moved, %d removed.", time.Since(start).Truncate(time.Millisecond).String(), stats.added, stats.updated, moved, len(missing)+demoted+len(ignored))
+	c.Logger.Info(status)
+	if err := queries.Notify(c, status, "complete"); err != nil {
+		c.Logger.Error("Notify error", "err", err)
+	}
+
+	return status, nil
+}
+
+// isHidden tests if a path relative to the media directory is in or is a directory or file starting with a dot.
+func isHidden(relative_path string) bool {
+	for segment := range strings.SplitSeq(relative_path, "/") {
+		if strings.HasPrefix(segment, ".") && segment != "." {
+			return true
+		}
+	}
+
+	return false
+}
+
+// isDir tests if path is a directory.
+func isDir(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
+
+// itemsUnder returns the media items at path, or in the directory at path.
+func itemsUnder(items []Media, path string) []Media {
+	var under []Media
+	for _, item := range items {
+		if item.Path == path || strings.HasPrefix(item.Path, path+"/") {
+			under = append(under, item)
+		}
+	}
+
+	return under
+}
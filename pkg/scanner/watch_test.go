@@ -0,0 +1,135 @@
+package scanner
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+func TestItemsUnder(t *testing.T) {
+	items := []Media{
+		{Path: "2024/a.jpg", Hash: 1},
+		{Path: "2024/trip/b.jpg", Hash: 2},
+		{Path: "2024-old/c.jpg", Hash: 3},
+		{Path: "d.jpg", Hash: 4},
+	}
+
+	tests := []struct {
+		path string
+		want []uint64
+	}{
+		{"2024", []uint64{1, 2}},
+		{"2024/trip", []uint64{2}},
+		{"d.jpg", []uint64{4}},
+		{"missing", nil},
+	}
+
+	for _, tt := range tests {
+		var got []uint64
+		for _, item := range itemsUnder(items, tt.path) {
+			got = append(got, item.Hash)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("itemsUnder(%q) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestWatchDir(t *testing.T) {
+	root := t.TempDir()
+	for _, p := range []string{"a.jpg", "2024/b.jpg", "2024/trip/c.mp4", ".trash/d.jpg", "2024/@eaDir/b.jpg", "2024/export/e.jpg"} {
+		p = filepath.Join(root, p)
+		if err := os.MkdirAll(filepath.Dir(p), os.ModePerm); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	var c Conf
+	c.Ignore = []string{"@eaDir/", "export/"}
+	ignore := newIgnoreRules(root, c)
+
+	// hidden and ignored directories are not watched
+	files, err := watchDir(w, root, ignore)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		filepath.Join(root, "2024/b.jpg"),
+		filepath.Join(root, "2024/trip/c.mp4"),
+		filepath.Join(root, "a.jpg"),
+	}
+	if !reflect.DeepEqual(files, want) {
+		t.Errorf("expected files %v, got %v", want, files)
+	}
+
+	watched := w.WatchList()
+	sort.Strings(watched)
+	wantWatched := []string{root, filepath.Join(root, "2024"), filepath.Join(root, "2024/trip")}
+	if !reflect.DeepEqual(watched, wantWatched) {
+		t.Errorf("expected watches %v, got %v", wantWatched, watched)
+	}
+
+	// files are not watched on their own
+	files, err = watchDir(w, filepath.Join(root, "a.jpg"), ignore)
+	if err != nil || files != nil {
+		t.Errorf("expected no files for a file, got %v, %v", files, err)
+	}
+
+	// nor are new directories in them
+	for _, dir := range []string{".trash", "2024/export"} {
+		files, err = watchDir(w, filepath.Join(root, dir), ignore)
+		if err != nil || files != nil {
+			t.Errorf("expected no files for %s, got %v, %v", dir, files, err)
+		}
+	}
+	if len(w.WatchList()) != len(wantWatched) {
+		t.Errorf("expected watches %v, got %v", wantWatched, w.WatchList())
+	}
+}
+
+func TestIsHidden(t *testing.T) {
+	tests := map[string]bool{
+		".":              false,
+		"2024/a.jpg":     false,
+		".trash":         true,
+		"2024/.thumbs/a": true,
+		"2024/a.b/c.jpg": false,
+		"2024/.DS_Store": true,
+	}
+	for path, want := range tests {
+		if got := isHidden(path); got != want {
+			t.Errorf("isHidden(%q) = %v, want %v", path, got, want)
+		}
+	}
+}
+
+func TestScanPathsInProgress(t *testing.T) {
+	c := testConf(t)
+	if !tryStartScan() {
+		t.Fatal("expected the scan to start")
+	}
+	t.Cleanup(func() { SetScanInProgress(false) })
+
+	// the watcher queues the paths again when another scan is running
+	if _, err := scanPaths([]string{filepath.Join(c.Media, "a.jpg")}, nil, c, nil); !errors.Is(err, ErrScanInProgress) {
+		t.Errorf("expected ErrScanInProgress, got %v", err)
+	}
+	if !IsScanInProgress() {
+		t.Errorf("expected the running scan to stay in progress")
+	}
+}
@@ -0,0 +1,176 @@
+package schedule
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Cron is a parsed cron expression with the five standard fields: minute, hour, day of month, month and day of
+// week. Each field is a set of the values it matches.
+type Cron struct {
+	minute, hour, dom, month, dow uint64
+	// domAny and dowAny are set when the day of month or day of week starts with *, as a day matches if either of the
+	// two matches when both are restricted.
+	domAny, dowAny bool
+}
+
+var macros = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// ParseCron parses a cron expression such as "30 2 * * *" or "*/15 * * * 1-5", or one of @yearly, @monthly, @weekly,
+// @daily and @hourly. Days of the week are 0 to 7, with both 0 and 7 being Sunday.
+func ParseCron(expr string) (*Cron, error) {
+	expr = strings.TrimSpace(expr)
+	if macro, ok := macros[expr]; ok {
+		expr = macro
+	}
+
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("cron expression %q must have 5 fields", expr)
+	}
+
+	var cron Cron
+	var err error
+	for i, f := range []struct {
+		set      *uint64
+		min, max int
+	}{
+		{&cron.minute, 0, 59},
+		{&cron.hour, 0, 23},
+		{&cron.dom, 1, 31},
+		{&cron.month, 1, 12},
+		{&cron.dow, 0, 7},
+	} {
+		*f.set, err = parseField(fields[i], f.min, f.max)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing cron expression %q: %v", expr, err)
+		}
+	}
+
+	// Sunday is 0 and 7
+	if cron.dow&(1<<7) != 0 {
+		cron.dow |= 1
+	}
+	cron.domAny = strings.HasPrefix(fields[2], "*")
+	cron.dowAny = strings.HasPrefix(fields[4], "*")
+
+	return &cron, nil
+}
+
+// parseField parses a comma separated list of *, values, ranges and steps into the set of values it matches.
+func parseField(field string, min, max int) (uint64, error) {
+	var set uint64
+	for _, part := range strings.Split(field, ",") {
+		rng, step, hasStep := strings.Cut(part, "/")
+
+		every := 1
+		if hasStep {
+			var err error
+			every, err = strconv.Atoi(step)
+			if err != nil || every < 1 {
+				return 0, fmt.Errorf("invalid step %q", step)
+			}
+		}
+
+		start, end := min, max
+		if rng != "*" {
+			from, to, isRange := strings.Cut(rng, "-")
+
+			var err error
+			start, err = parseValue(from, min, max)
+			if err != nil {
+				return 0, err
+			}
+
+			end = start
+			if isRange {
+				end, err = parseValue(to, min, max)
+				if err != nil {
+					return 0, err
+				}
+				if end < start {
+					return 0, fmt.Errorf("invalid range %q", rng)
+				}
+			} else if hasStep {
+				// a single value with a step, such as 5/15, runs from the value to the end of the range
+				end = max
+			}
+		}
+
+		for v := start; v <= end; v += every {
+			set |= 1 << v
+		}
+	}
+
+	return set, nil
+}
+
+func parseValue(value string, min, max int) (int, error) {
+	v, err := strconv.Atoi(value)
+	if err != nil || v < min || v > max {
+		return 0, fmt.Errorf("%q is not between %d and %d", value, min, max)
+	}
+
+	return v, nil
+}
+
+// Next returns the first time after t that matches the expression, in the location of t, or the zero time if there
+// is none in the next five years, such as for the 30th of February.
+func (cron *Cron) Next(t time.Time) time.Time {
+	loc := t.Location()
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+
+	for t.Before(limit) {
+		if !has(cron.month, int(t.Month())) {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, loc)
+			continue
+		}
+
+		if !cron.matchesDay(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc)
+			continue
+		}
+
+		if !has(cron.hour, t.Hour()) {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, loc)
+			continue
+		}
+
+		if !has(cron.minute, t.Minute()) {
+			t = t.Add(time.Minute)
+			continue
+		}
+
+		return t
+	}
+
+	return time.Time{}
+}
+
+// matchesDay returns true if the day of t matches the day of month and day of week. If both are restricted, a day
+// matching either of them matches.
+func (cron *Cron) matchesDay(t time.Time) bool {
+	dom := has(cron.dom, t.Day())
+	dow := has(cron.dow, int(t.Weekday()))
+
+	if cron.domAny || cron.dowAny {
+		return dom && dow
+	}
+
+	return dom || dow
+}
+
+func has(set uint64, v int) bool {
+	return set&(1<<v) != 0
+}
@@ -0,0 +1,45 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCronNext(t *testing.T) {
+	// a Wednesday
+	now := time.Date(2026, time.October, 14, 10, 17, 30, 0, time.UTC)
+
+	tests := []struct {
+		expr string
+		next time.Time
+	}{
+		{"* * * * *", time.Date(2026, time.October, 14, 10, 18, 0, 0, time.UTC)},
+		{"30 2 * * *", time.Date(2026, time.October, 15, 2, 30, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2026, time.October, 14, 10, 30, 0, 0, time.UTC)},
+		{"5/20 10,12 * * *", time.Date(2026, time.October, 14, 10, 25, 0, 0, time.UTC)},
+		{"0 9-17 * * 1-5", time.Date(2026, time.October, 14, 11, 0, 0, 0, time.UTC)},
+		{"0 0 * * 7", time.Date(2026, time.October, 18, 0, 0, 0, 0, time.UTC)},
+		{"@weekly", time.Date(2026, time.October, 18, 0, 0, 0, 0, time.UTC)},
+		{"@monthly", time.Date(2026, time.November, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2028, time.February, 29, 0, 0, 0, 0, time.UTC)},
+		// the 1st of the month or any Friday
+		{"0 0 1 * 5", time.Date(2026, time.October, 16, 0, 0, 0, 0, time.UTC)},
+		{"0 0 30 2 *", time.Time{}},
+	}
+
+	for _, test := range tests {
+		cron, err := ParseCron(test.expr)
+		if assert.NoError(t, err, test.expr) {
+			assert.Equal(t, test.next, cron.Next(now), test.expr)
+		}
+	}
+}
+
+func TestParseCronErrors(t *testing.T) {
+	for _, expr := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "* * 0 * *", "* * * 13 *", "* * * * 8", "*/0 * * * *", "5-1 * * * *", "a * * * *", "@often"} {
+		_, err := ParseCron(expr)
+		assert.Error(t, err, expr)
+	}
+}
@@ -0,0 +1,123 @@
+package schedule
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/robbymilo/rgallery/pkg/scanner"
+	"github.com/robbymilo/rgallery/pkg/types"
+)
+
+type Conf = types.Conf
+
+// Task is a job queued on a cron schedule.
+type Task struct {
+	Name     string
+	JobType  string
+	Scope    string
+	Schedule string
+	cron     *Cron
+}
+
+// Tasks returns the tasks with a schedule in the config file, including the scan schedule of each library, or an
+// error if a schedule is not a valid cron expression.
+func Tasks(c Conf) ([]Task, error) {
+	all := []Task{
+		{Name: "scan", JobType: scanner.JobScan, Scope: "default", Schedule: c.Schedule.Scan},
+		{Name: "metadata_scan", JobType: scanner.JobScan, Scope: "metadata", Schedule: c.Schedule.MetadataScan},
+		{Name: "thumbnail_scan", JobType: scanner.JobThumbnail, Schedule: c.Schedule.ThumbnailScan},
+		{Name: "cache_cleanup", JobType: scanner.JobCleanup, Schedule: c.Schedule.CacheCleanup},
+		{Name: "db_optimize", JobType: scanner.JobOptimize, Schedule: c.Schedule.DBOptimize},
+	}
+	for _, lib := range c.Libraries {
+		all = append(all, Task{Name: "scan_" + lib.Name, JobType: scanner.JobScan, Scope: scanner.ScanScope("default", lib.Name), Schedule: lib.Schedule})
+	}
+
+	var tasks []Task
+	for _, task := range all {
+		if task.Schedule == "" {
+			continue
+		}
+
+		cron, err := ParseCron(task.Schedule)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing %s schedule: %v", task.Name, err)
+		}
+		task.cron = cron
+		tasks = append(tasks, task)
+	}
+
+	return tasks, nil
+}
+
+// Next returns when the task is next queued after t, or the zero time if it never is.
+func (task Task) Next(t time.Time) time.Time {
+	return task.cron.Next(t)
+}
+
+// Run queues each task as a job when its schedule matches, until the process exits. Runs missed while rgallery was
+// not running are skipped.
+func Run(c Conf, tasks []Task) {
+	if len(tasks) == 0 {
+		return
+	}
+
+	next := make([]time.Time, len(tasks))
+	now := time.Now()
+	for i, task := range tasks {
+		next[i] = task.Next(now)
+		c.Logger.Info("scheduled task", "task", task.Name, "schedule", task.Schedule, "next", next[i])
+	}
+
+	for {
+		var wake time.Time
+		for _, t := range next {
+			if !t.IsZero() && (wake.IsZero() || t.Before(wake)) {
+				wake = t
+			}
+		}
+		if wake.IsZero() {
+			return
+		}
+
+		time.Sleep(time.Until(wake))
+
+		now = time.Now()
+		for i, task := range tasks {
+			if next[i].IsZero() || next[i].After(now) {
+				continue
+			}
+
+			if _, err := scanner.EnqueueJob(c, task.JobType, task.Scope); err != nil {
+				c.Logger.Error("error queuing scheduled task", "task", task.Name, "error", err)
+			}
+			next[i] = task.Next(now)
+		}
+	}
+}
+
+// Status returns when each task is next queued and the last job that ran it, whether it was queued by the schedule
+// or not.
+func Status(c Conf, tasks []Task) []types.ScheduledTask {
+	now := time.Now()
+	status := make([]types.ScheduledTask, 0, len(tasks))
+	for _, task := range tasks {
+		s := types.ScheduledTask{Name: task.Name, Schedule: task.Schedule}
+
+		if next := task.Next(now); !next.IsZero() {
+			s.NextRun = next.UTC().Format("2006-01-02T15:04:05Z")
+		}
+
+		job, err := c.Store.GetLastJob(task.JobType, task.Scope)
+		if err == nil {
+			s.LastRun = &job
+		} else if !errors.Is(err, types.ErrJobNotFound) {
+			c.Logger.Error("error getting last run of scheduled task", "task", task.Name, "error", err)
+		}
+
+		status = append(status, s)
+	}
+
+	return status
+}
@@ -4,12 +4,23 @@ import (
 	"net/http"
 
 	"github.com/robbymilo/rgallery/pkg/render"
+	"github.com/robbymilo/rgallery/pkg/schedule"
 	"github.com/robbymilo/rgallery/pkg/types"
 	"github.com/robbymilo/rgallery/pkg/users"
 )
 
 type UserKey = types.UserKey
 
+// isAdmin returns true if the user is an admin or uses an API key, who can run jobs and edit media items.
+func isAdmin(r *http.Request, c Conf) bool {
+	var user UserKey
+	if r.Context().Value(UserKey{}) != nil {
+		user = r.Context().Value(UserKey{}).(UserKey)
+	}
+
+	return c.DisableAuth || user.UserRole == "admin" || user.UserRole == "key"
+}
+
 // ServeAdmin serves the admin page.
 func ServeAdmin(w http.ResponseWriter, r *http.Request, c Conf) {
 	var keys []users.ApiCredentials
@@ -30,11 +41,18 @@ func ServeAdmin(w http.ResponseWriter, r *http.Request, c Conf) {
 		user = r.Context().Value(UserKey{}).(UserKey)
 	}
 
+	// the schedules were checked when rgallery started
+	tasks, err := schedule.Tasks(c)
+	if err != nil {
+		c.Logger.Error("error parsing schedule", "error", err)
+	}
+
 	response := ResponseAdmin{
 		Keys:     keys,
 		Users:    users,
 		UserName: user.UserName,
 		UserRole: user.UserRole,
+		Schedule: schedule.Status(c, tasks),
 		Meta:     c.Meta,
 	}
 
@@ -0,0 +1,39 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/robbymilo/rgallery/pkg/duplicates"
+	"github.com/robbymilo/rgallery/pkg/types"
+)
+
+// ServeDuplicates returns the groups of media items that are near duplicates of each other, for an admin to review.
+// The distance parameter sets how many bits their perceptual hashes may differ by.
+func ServeDuplicates(w http.ResponseWriter, r *http.Request) {
+	c := r.Context().Value(ConfigKey{}).(Conf)
+
+	if !isAdmin(r, c) {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	distance := duplicates.DefaultDistance
+	if d := r.URL.Query().Get("distance"); d != "" {
+		var err error
+		distance, err = strconv.Atoi(d)
+		if err != nil || distance < 0 || distance > duplicates.MaxDistance {
+			http.Error(w, "Distance must be between 0 and "+strconv.Itoa(duplicates.MaxDistance), http.StatusBadRequest)
+			return
+		}
+	}
+
+	groups, err := duplicates.Find(distance, c)
+	if err != nil {
+		c.Logger.Error("error finding duplicates", "error", err)
+		http.Error(w, "Error finding duplicates", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, c, types.ResponseDuplicates{Distance: distance, Groups: groups})
+}
@@ -0,0 +1,105 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi"
+	cache "github.com/patrickmn/go-cache"
+	"github.com/robbymilo/rgallery/pkg/exif"
+	"github.com/robbymilo/rgallery/pkg/queries"
+	"github.com/robbymilo/rgallery/pkg/scanner"
+)
+
+// mediaEdit is the body of a media item edit. Fields left out are not changed.
+type mediaEdit struct {
+	Rating      *float64  `json:"rating"`
+	Subjects    *[]string `json:"subjects"`
+	Title       *string   `json:"title"`
+	Description *string   `json:"description"`
+}
+
+// EditMedia changes the rating, subjects, title or description of a media item and writes them back to its file.
+func EditMedia(w http.ResponseWriter, r *http.Request, cache *cache.Cache) {
+	c := r.Context().Value(ConfigKey{}).(Conf)
+
+	if !isAdmin(r, c) {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	var edit mediaEdit
+	if err := json.NewDecoder(r.Body).Decode(&edit); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if edit.Rating != nil && (*edit.Rating < 0 || *edit.Rating > 5) {
+		http.Error(w, "Rating must be between 0 and 5", http.StatusBadRequest)
+		return
+	}
+
+	// a scan could overwrite the edit with what it read from the file before it was written
+	if scanner.IsScanInProgress() {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusConflict)
+		_, _ = w.Write([]byte(`{"msg":"a scan is in progress, try again when it has finished"}`))
+		return
+	}
+
+	h, err := DecodeURL(chi.URLParam(r, "hash"))
+	if err != nil {
+		c.Logger.Error("error decoding hash", "error", err)
+	}
+	hash := GetHash(h)
+
+	media, err := queries.GetSingleMediaItem(hash, c)
+	if err != nil {
+		c.Logger.Error("error getting single media item", "error", err)
+		http.Error(w, "Error getting media item", http.StatusInternalServerError)
+		return
+	}
+	if media.Path == "" {
+		http.Error(w, "Media item not found", http.StatusNotFound)
+		return
+	}
+
+	if edit.Rating != nil {
+		media.Rating = *edit.Rating
+	}
+	if edit.Subjects != nil {
+		subjects := make(Subjects, 0)
+		seen := make(map[string]bool)
+		for _, value := range *edit.Subjects {
+			subject := exif.NewSubject(strings.TrimSpace(value))
+			if subject.Key == "" || seen[subject.Key] {
+				continue
+			}
+			seen[subject.Key] = true
+			subjects = append(subjects, subject)
+		}
+		media.Subject = subjects
+	}
+	if edit.Title != nil {
+		media.Title = *edit.Title
+	}
+	if edit.Description != nil {
+		media.Description = *edit.Description
+	}
+
+	err = scanner.UpdateMetadata(media, c, cache)
+	if err != nil {
+		c.Logger.Error("error updating media item", "error", err)
+		http.Error(w, "Error updating media item", http.StatusInternalServerError)
+		return
+	}
+
+	media, err = queries.GetSingleMediaItem(hash, c)
+	if err != nil {
+		c.Logger.Error("error getting single media item", "error", err)
+		http.Error(w, "Error getting media item", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, c, media)
+}
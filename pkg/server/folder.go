@@ -34,7 +34,7 @@ func ServeFolder(w http.ResponseWriter, r *http.Request) {
 		c.Logger.Error("error getting folder", "error", err)
 	}
 
-	total, err := queries.GetTotalOfFolder("folder", folder, c)
+	total, err := queries.GetTotalOfFolder("folder", folder, params.Library, c)
 	if err != nil {
 		c.Logger.Error("error getting total of folder", "error", err)
 	}
@@ -32,7 +32,7 @@ func ServeFolders(w http.ResponseWriter, r *http.Request) {
 		c.Logger.Error("error getting folders", "error", err)
 	}
 
-	total, err := queries.GetTotalFolders(c)
+	total, err := queries.GetTotalFolders(params.Library, c)
 	if err != nil {
 		c.Logger.Error("error getting total of folders", "error", err)
 	}
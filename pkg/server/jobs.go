@@ -0,0 +1,105 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi"
+	"github.com/robbymilo/rgallery/pkg/scanner"
+	"github.com/robbymilo/rgallery/pkg/types"
+)
+
+// jobsLimit is the number of recent jobs listed.
+const jobsLimit = 100
+
+// ServeJobs returns the most recent scan, thumbnail scan and transcode jobs, newest first.
+func ServeJobs(w http.ResponseWriter, r *http.Request) {
+	c := r.Context().Value(ConfigKey{}).(Conf)
+
+	if !isAdmin(r, c) {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	jobs, err := c.Store.GetJobs(jobsLimit)
+	if err != nil {
+		c.Logger.Error("error getting jobs", "error", err)
+		http.Error(w, "Error getting jobs", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, c, jobs)
+}
+
+// ServeJob returns a single job.
+func ServeJob(w http.ResponseWriter, r *http.Request) {
+	c := r.Context().Value(ConfigKey{}).(Conf)
+
+	if !isAdmin(r, c) {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+
+	job, err := c.Store.GetJob(id)
+	if errors.Is(err, types.ErrJobNotFound) {
+		http.Error(w, "Job not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		c.Logger.Error("error getting job", "error", err)
+		http.Error(w, "Error getting job", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, c, job)
+}
+
+// CancelJob cancels a queued job, or stops a running scan or thumbnail scan.
+func CancelJob(w http.ResponseWriter, r *http.Request) {
+	c := r.Context().Value(ConfigKey{}).(Conf)
+
+	if !isAdmin(r, c) {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+
+	job, err := scanner.CancelJob(c, id)
+	if errors.Is(err, types.ErrJobNotFound) {
+		http.Error(w, "Job not found", http.StatusNotFound)
+		return
+	}
+	if errors.Is(err, scanner.ErrJobNotCancelable) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusConflict)
+		_, _ = w.Write([]byte(`{"msg":"job is ` + job.Status + ` and can not be canceled"}`))
+		return
+	}
+	if err != nil {
+		c.Logger.Error("error canceling job", "error", err)
+		http.Error(w, "Error canceling job", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, c, job)
+}
+
+func writeJSON(w http.ResponseWriter, c Conf, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		c.Logger.Error("failed to encode response", "err", err)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"github.com/go-chi/chi"
 	"github.com/robbymilo/rgallery/pkg/queries"
 	"github.com/robbymilo/rgallery/pkg/render"
+	"github.com/robbymilo/rgallery/pkg/transcode"
 	"github.com/robbymilo/rgallery/pkg/types"
 )
 
@@ -48,10 +49,18 @@ func ServeMedia(w http.ResponseWriter, r *http.Request) {
 		c.Logger.Error("error getting next media items", "error", err)
 	}
 
+	alternates, err := c.Store.GetAlternates(hash)
+	if err != nil {
+		c.Logger.Error("error getting alternates", "error", err)
+	}
+
 	response := ResponseMedia{
-		Media:    media,
-		Previous: previous,
-		Next:     next,
+		Media:      media,
+		Alternates: alternates,
+		Motion:     transcode.Motion(media, alternates),
+		Stream:     transcode.Stream(media, c),
+		Previous:   previous,
+		Next:       next,
 	}
 
 	err = render.Render(w, r, response, "media")
@@ -4,7 +4,9 @@ import (
 	"net/http"
 
 	cache "github.com/patrickmn/go-cache"
+	"github.com/robbymilo/rgallery/pkg/config"
 	"github.com/robbymilo/rgallery/pkg/scanner"
+	"github.com/robbymilo/rgallery/pkg/types"
 )
 
 // IsScanInProgress returns true if a scan is currently in progress
@@ -12,6 +14,8 @@ func IsScanInProgress() bool {
 	return scanner.IsScanInProgress()
 }
 
+// Scan queues a scan job of every library, or of the library in the library param, or a thumbnail scan job if the
+// type is thumbnail.
 func Scan(w http.ResponseWriter, r *http.Request, cache *cache.Cache) {
 	c := r.Context().Value(ConfigKey{}).(Conf)
 	scanType := r.URL.Query().Get("type")
@@ -21,25 +25,20 @@ func Scan(w http.ResponseWriter, r *http.Request, cache *cache.Cache) {
 		return
 	}
 
-	var user UserKey
-	if r.Context().Value(UserKey{}) != nil {
-		user = r.Context().Value(UserKey{}).(UserKey)
+	// the ui starts a default scan with a type of scan
+	if scanType != "metadata" && scanType != "deep" {
+		scanType = "default"
 	}
 
 	// disable scanning for viewers
-	if c.DisableAuth || (!c.DisableAuth && user.UserRole == "admin") || (!c.DisableAuth && user.UserRole == "key") {
-		status, err := scanner.Scan(scanType, c, cache)
-		if err != nil {
-			w.WriteHeader(http.StatusInternalServerError)
-			_, err := w.Write([]byte("503\n"))
-			c.Logger.Error("error starting scan", "error", err)
+	if isAdmin(r, c) {
+		library := r.URL.Query().Get("library")
+		if _, ok := config.GetLibrary(library, c); library != "" && !ok {
+			http.Error(w, "Unknown library", http.StatusBadRequest)
+			return
 		}
 
-		w.Header().Set("Content-Type", "application/json")
-		_, err = w.Write([]byte(`{"msg":` + `"` + status + `"}`))
-		if err != nil {
-			c.Logger.Error("error writing scan status", "error", err)
-		}
+		queueJob(w, c, scanner.JobScan, scanner.ScanScope(scanType, library), "Scan queued.")
 	} else {
 		w.WriteHeader(http.StatusUnauthorized)
 		return
@@ -47,32 +46,31 @@ func Scan(w http.ResponseWriter, r *http.Request, cache *cache.Cache) {
 
 }
 
+// ThumbScan queues a thumbnail scan job.
 func ThumbScan(w http.ResponseWriter, r *http.Request) {
 	c := r.Context().Value(ConfigKey{}).(Conf)
 
-	var user UserKey
-	if r.Context().Value(UserKey{}) != nil {
-		user = r.Context().Value(UserKey{}).(UserKey)
+	if isAdmin(r, c) {
+		queueJob(w, c, scanner.JobThumbnail, "", "Thumbscan queued.")
+	} else {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
 	}
 
-	if c.DisableAuth || (!c.DisableAuth && user.UserRole == "admin") || (!c.DisableAuth && user.UserRole == "key") {
-		status, err := scanner.ThumbScan(c)
-		if err != nil {
-			w.WriteHeader(http.StatusInternalServerError)
-			_, err := w.Write([]byte("503\n"))
-			c.Logger.Error("error starting thumbscan", "error", err)
-		}
-
-		_, err = w.Write([]byte(status))
-		if err != nil {
-			c.Logger.Error("error writing thumbscan status", "error", err)
-		}
+}
 
-	} else {
-		w.WriteHeader(http.StatusUnauthorized)
+func queueJob(w http.ResponseWriter, c Conf, jobType, scope, msg string) {
+	job, err := scanner.EnqueueJob(c, jobType, scope)
+	if err != nil {
+		c.Logger.Error("error queuing job", "error", err)
+		http.Error(w, "Error queuing job", http.StatusInternalServerError)
 		return
 	}
 
+	writeJSON(w, c, struct {
+		Msg string    `json:"msg"`
+		Job types.Job `json:"job"`
+	}{msg, job})
 }
 
 // CancelScanHandler cancels a currently running scan.
@@ -20,11 +20,11 @@ func DecodeURL(s string) (string, error) {
 	return decoded, nil
 }
 
-func GetHash(hash string) uint32 {
-	h, err := strconv.ParseUint(hash, 10, 32)
+func GetHash(hash string) uint64 {
+	h, err := strconv.ParseUint(hash, 10, 64)
 	if err != nil {
 		fmt.Println("error parsing hash:", err)
 	}
 
-	return uint32(h)
+	return h
 }
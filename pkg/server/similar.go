@@ -0,0 +1,58 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi"
+	cache "github.com/patrickmn/go-cache"
+	"github.com/robbymilo/rgallery/pkg/duplicates"
+	"github.com/robbymilo/rgallery/pkg/queries"
+	"github.com/robbymilo/rgallery/pkg/types"
+)
+
+// maxSimilar is the most similar media items returned at once.
+const maxSimilar = 100
+
+// ServeSimilar returns the images that look most like a media item, closest first. The limit parameter sets how many
+// are returned, and the filter params of the timeline narrow them down.
+func ServeSimilar(w http.ResponseWriter, r *http.Request, cache *cache.Cache) {
+	c := r.Context().Value(ConfigKey{}).(Conf)
+	params := r.Context().Value(ParamsKey{}).(FilterParams)
+
+	limit := 12
+	if l := r.URL.Query().Get("limit"); l != "" {
+		var err error
+		limit, err = strconv.Atoi(l)
+		if err != nil || limit < 1 || limit > maxSimilar {
+			http.Error(w, "Limit must be between 1 and "+strconv.Itoa(maxSimilar), http.StatusBadRequest)
+			return
+		}
+	}
+
+	h, err := DecodeURL(chi.URLParam(r, "hash"))
+	if err != nil {
+		c.Logger.Error("error decoding hash", "error", err)
+	}
+	hash := GetHash(h)
+
+	media, err := queries.GetSingleMediaItem(hash, c)
+	if err != nil {
+		c.Logger.Error("error getting single media item", "error", err)
+		http.Error(w, "Error getting media item", http.StatusInternalServerError)
+		return
+	}
+	if media.Path == "" {
+		http.Error(w, "Media item not found", http.StatusNotFound)
+		return
+	}
+
+	similar, err := duplicates.FindSimilar(media, limit, params, c, cache)
+	if err != nil {
+		c.Logger.Error("error finding similar media items", "error", err)
+		http.Error(w, "Error finding similar media items", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, c, types.ResponseSimilar{Hash: hash, Items: similar})
+}
@@ -4,15 +4,19 @@ import (
 	"bytes"
 	"net/http"
 	"os"
+	"path/filepath"
+	"slices"
 	"time"
 
 	"github.com/go-chi/chi"
+	"github.com/robbymilo/rgallery/pkg/config"
 	"github.com/robbymilo/rgallery/pkg/queries"
-	"github.com/robbymilo/rgallery/pkg/resize"
 	"github.com/robbymilo/rgallery/pkg/transcode"
 )
 
-// ServeTranscode serves cached transcode files, otherwise serves generated transcode files on demand.
+// ServeTranscode serves cached transcode files, otherwise serves generated transcode files on demand. The file
+// "index.m3u8" is the master playlist of the renditions of a video, "original" is the original file of a video,
+// for videos that are played directly, and "thumbnails.vtt" and "sprite.jpg" are its seek preview.
 func ServeTranscode(w http.ResponseWriter, r *http.Request) {
 	c := r.Context().Value(ConfigKey{}).(Conf)
 	var file []byte
@@ -25,16 +29,26 @@ func ServeTranscode(w http.ResponseWriter, r *http.Request) {
 	hash := GetHash(h)
 	index_file := transcode.CreateHLSIndexFilePath(hash, c)
 
+	if chi.URLParam(r, "file") == "original" {
+		serveOriginalVideo(w, r, hash, c)
+		return
+	}
+
+	if file := chi.URLParam(r, "file"); file == "thumbnails.vtt" || file == "sprite.jpg" {
+		serveSprites(w, r, hash, file, c)
+		return
+	}
+
 	if chi.URLParam(r, "file") == "index.m3u8" {
 
-		// check if index.m3u8 files exist in cache
+		// check if the master playlist exists in cache
 		if fileExists(index_file) {
 			// serve file
 			file, err = os.ReadFile(index_file)
 			if err != nil {
 				w.WriteHeader(http.StatusInternalServerError)
 				_, err := w.Write([]byte("503\n"))
-				c.Logger.Error("error reading saved index.m3u8 file:", "err", err)
+				c.Logger.Error("error reading saved master playlist:", "err", err)
 			}
 
 		} else {
@@ -48,13 +62,12 @@ func ServeTranscode(w http.ResponseWriter, r *http.Request) {
 				return
 			}
 
-			// Use TranscodeWithLock to ensure only one transcoding process runs at a time
-			original := resize.CreateOriginalFilePath(video.Path, c)
-			err = transcode.TranscodeWithLock(original, index_file, hash, c)
+			// renditions are transcoded when their variant playlist is requested
+			_, err = transcode.WriteMasterPlaylist(video, c)
 			if err != nil {
 				w.WriteHeader(http.StatusInternalServerError)
 				_, err := w.Write([]byte("503\n"))
-				c.Logger.Error("error transcoding video:", "err", err)
+				c.Logger.Error("error writing master playlist:", "err", err)
 				return
 			}
 
@@ -62,7 +75,7 @@ func ServeTranscode(w http.ResponseWriter, r *http.Request) {
 			if err != nil {
 				w.WriteHeader(http.StatusInternalServerError)
 				_, err := w.Write([]byte("503\n"))
-				c.Logger.Error("error reading saved index.m3u8 file:", "err", err)
+				c.Logger.Error("error reading saved master playlist:", "err", err)
 			}
 			w.Header().Set("Content-Type", "audio/mpegurl")
 		}
@@ -85,3 +98,148 @@ func ServeTranscode(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Cache-Control", "private, max-age=0, must-revalidate")
 	http.ServeContent(w, r, "thumbnail", time.Now(), bytes.NewReader(file))
 }
+
+// ServeRendition serves the variant playlist and TS files of a rendition of a video, transcoding the rendition when
+// its variant playlist is first requested.
+func ServeRendition(w http.ResponseWriter, r *http.Request) {
+	c := r.Context().Value(ConfigKey{}).(Conf)
+
+	h, err := DecodeURL(chi.URLParam(r, "hash"))
+	if err != nil {
+		c.Logger.Error("error decoding hash", "error", err)
+	}
+	hash := GetHash(h)
+
+	video, err := queries.GetSingleMediaItem(hash, c)
+	if err != nil {
+		c.Logger.Error("error getting single media item:", "err", err)
+	}
+
+	renditions, err := transcode.Renditions(video, c)
+	if err != nil {
+		c.Logger.Error("error getting renditions:", "err", err)
+	}
+
+	i := slices.IndexFunc(renditions, func(rendition transcode.Rendition) bool {
+		return rendition.Name == chi.URLParam(r, "rendition")
+	})
+	if video.Path == "" || i < 0 {
+		w.WriteHeader(http.StatusNotFound)
+		_, err := w.Write([]byte("404\n"))
+		if err != nil {
+			c.Logger.Error("error writing rendition 404 response", "error", err)
+		}
+		return
+	}
+	rendition := renditions[i]
+
+	path := transcode.CreateRenditionIndexFilePath(hash, rendition.Name, c)
+	if chi.URLParam(r, "file") == "index.m3u8" {
+		w.Header().Set("Content-Type", "audio/mpegurl")
+
+		// playback waits for the transcode, ahead of pre-generation
+		err = transcode.TranscodeRendition(video, rendition, true, c)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			_, err := w.Write([]byte("503\n"))
+			c.Logger.Error("error transcoding video:", "err", err)
+			return
+		}
+	} else {
+		w.Header().Set("Content-Type", "application/octet-stream")
+		path = filepath.Join(filepath.Dir(path), filepath.Base(chi.URLParam(r, "file")))
+	}
+
+	file, err := os.ReadFile(path)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		_, err := w.Write([]byte("503\n"))
+		c.Logger.Error("error reading saved transcode file:", "err", err)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "private, max-age=0, must-revalidate")
+	http.ServeContent(w, r, "transcode", time.Now(), bytes.NewReader(file))
+}
+
+// serveOriginalVideo serves the original file of a video, with support for range requests so browsers can seek
+// without downloading the whole file.
+func serveOriginalVideo(w http.ResponseWriter, r *http.Request, hash uint64, c Conf) {
+	video, err := queries.GetSingleMediaItem(hash, c)
+	if err != nil {
+		c.Logger.Error("error getting single media item:", "err", err)
+	}
+	if video.Type != "video" {
+		w.WriteHeader(http.StatusNotFound)
+		_, err := w.Write([]byte("404\n"))
+		if err != nil {
+			c.Logger.Error("error writing original video 404 response", "error", err)
+		}
+		return
+	}
+
+	file, err := os.Open(config.OriginalPath(video.Library, video.Path, c))
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		_, err := w.Write([]byte("503\n"))
+		c.Logger.Error("error opening original video:", "err", err)
+		return
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		_, err := w.Write([]byte("503\n"))
+		c.Logger.Error("error reading original video:", "err", err)
+		return
+	}
+
+	if stream := transcode.Stream(video, c); stream.Direct && stream.MimeType != "" {
+		w.Header().Set("Content-Type", stream.MimeType)
+	}
+	w.Header().Set("Cache-Control", "private, max-age=0, must-revalidate")
+	http.ServeContent(w, r, info.Name(), info.ModTime(), file)
+}
+
+// serveSprites serves the WebVTT thumbnails track or sprite sheet of a video, creating them when either is first
+// requested.
+func serveSprites(w http.ResponseWriter, r *http.Request, hash uint64, file string, c Conf) {
+	video, err := queries.GetSingleMediaItem(hash, c)
+	if err != nil {
+		c.Logger.Error("error getting single media item:", "err", err)
+	}
+	if _, err := transcode.SpriteSheet(video, c); err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		_, err := w.Write([]byte("404\n"))
+		if err != nil {
+			c.Logger.Error("error writing sprites 404 response", "error", err)
+		}
+		return
+	}
+
+	// playback waits for the sprite sheet, ahead of pre-generation
+	err = transcode.CreateSprites(video, true, c)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		_, err := w.Write([]byte("503\n"))
+		c.Logger.Error("error creating sprite sheet:", "err", err)
+		return
+	}
+
+	content, err := os.ReadFile(transcode.CreateTSFilePath(hash, file, c))
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		_, err := w.Write([]byte("503\n"))
+		c.Logger.Error("error reading saved sprite file:", "err", err)
+		return
+	}
+
+	if file == "thumbnails.vtt" {
+		w.Header().Set("Content-Type", "text/vtt")
+	} else {
+		w.Header().Set("Content-Type", "image/jpeg")
+	}
+	w.Header().Set("Cache-Control", "private, max-age=0, must-revalidate")
+	http.ServeContent(w, r, file, time.Now(), bytes.NewReader(content))
+}
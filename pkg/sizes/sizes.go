@@ -8,12 +8,13 @@ import (
 	"slices"
 	"strings"
 
+	"github.com/robbymilo/rgallery/pkg/formats"
 	"github.com/robbymilo/rgallery/pkg/types"
 )
 
 type Conf = types.Conf
 
-func Srcset(hash uint32, width int, path string, c Conf) template.Srcset {
+func Srcset(hash uint64, width int, path string, c Conf) template.Srcset {
 	var srcset string
 	final := false
 
@@ -27,7 +28,8 @@ func Srcset(hash uint32, width int, path string, c Conf) template.Srcset {
 		}
 	}
 
-	if c.IncludeOriginals {
+	// browsers can not display RAW, TIFF or JPEG XL files
+	if c.IncludeOriginals && formats.IsDisplayable(path) {
 		url := template.HTMLEscapeString(filepath.Join("/api/media-originals", path))
 		srcset = fmt.Sprintf(`%s%s %dw`, srcset, url, width)
 	}
@@ -0,0 +1,114 @@
+package sqlitestore
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/robbymilo/rgallery/pkg/types"
+	"zombiezen.com/go/sqlite"
+	"zombiezen.com/go/sqlite/sqlitex"
+)
+
+// GetAlternates returns the alternates of a media item, ordered by path.
+func (s *Store) GetAlternates(hash uint64) ([]types.Alternate, error) {
+	conn, err := s.db.Take(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("failed to take connection from pool: %w", err)
+	}
+	defer s.db.Put(conn)
+
+	alternates := []types.Alternate{}
+	err = sqlitex.Execute(conn, "SELECT hash, path, folder, size, modified, library FROM alternates WHERE hash = ? ORDER BY path", &sqlitex.ExecOptions{
+		Args: []interface{}{hash},
+		ResultFunc: func(stmt *sqlite.Stmt) error {
+			modified, err := time.Parse("2006-01-02T15:04:05.000Z", stmt.ColumnText(4))
+			if err != nil {
+				return fmt.Errorf("error parsing alternate modified time: %v", err)
+			}
+
+			alternates = append(alternates, types.Alternate{
+				Hash:     uint64(stmt.ColumnInt64(0)),
+				Path:     stmt.ColumnText(1),
+				Folder:   stmt.ColumnText(2),
+				Size:     stmt.ColumnInt64(3),
+				Modified: modified,
+				Library:  stmt.ColumnText(5),
+			})
+			return nil
+		},
+	})
+
+	return alternates, err
+}
+
+// SetAlternates replaces the alternates in folders of a library, or every alternate of the library if folders is nil,
+// and removes alternates of media items that no longer exist.
+func (s *Store) SetAlternates(library string, folders []string, alternates []types.Alternate) error {
+	conn, err := s.db.TakeWriter(context.Background())
+	if err != nil {
+		return fmt.Errorf("failed to take connection from pool: %w", err)
+	}
+	defer s.db.Put(conn)
+
+	err = sqlitex.Execute(conn, "BEGIN TRANSACTION", nil)
+	if err != nil {
+		return fmt.Errorf("error beginning transaction: %v", err)
+	}
+
+	rollback := func() {
+		if err := sqlitex.Execute(conn, "ROLLBACK", nil); err != nil {
+			s.logger.Error("error executing rollback", "error", err)
+		}
+	}
+
+	if folders == nil {
+		err = sqlitex.Execute(conn, "DELETE FROM alternates WHERE library = ?", &sqlitex.ExecOptions{
+			Args: []interface{}{library},
+		})
+		if err != nil {
+			rollback()
+			return fmt.Errorf("error deleting alternates: %v", err)
+		}
+	}
+	for _, folder := range folders {
+		err = sqlitex.Execute(conn, "DELETE FROM alternates WHERE library = ? AND folder = ?", &sqlitex.ExecOptions{
+			Args: []interface{}{library, folder},
+		})
+		if err != nil {
+			rollback()
+			return fmt.Errorf("error deleting alternates: %v", err)
+		}
+	}
+
+	for _, alternate := range alternates {
+		err = sqlitex.Execute(conn, "INSERT OR REPLACE INTO alternates (library, path, hash, folder, size, modified) VALUES (?, ?, ?, ?, ?, ?)", &sqlitex.ExecOptions{
+			Args: []interface{}{
+				library,
+				alternate.Path,
+				alternate.Hash,
+				alternate.Folder,
+				alternate.Size,
+				alternate.Modified.UTC().Format("2006-01-02T15:04:05.000Z"),
+			},
+		})
+		if err != nil {
+			rollback()
+			return fmt.Errorf("error inserting alternate: %v", err)
+		}
+	}
+
+	err = sqlitex.ExecuteTransient(conn, "DELETE FROM alternates WHERE hash NOT IN (SELECT hash FROM media)", nil)
+	if err != nil {
+		rollback()
+		return fmt.Errorf("error deleting alternates: %v", err)
+	}
+
+	err = sqlitex.Execute(conn, "COMMIT", nil)
+	if err != nil {
+		rollback()
+		return fmt.Errorf("error committing transaction: %v", err)
+	}
+
+	return nil
+}
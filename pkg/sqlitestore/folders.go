@@ -0,0 +1,177 @@
+package sqlitestore
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/robbymilo/rgallery/pkg/types"
+)
+
+// GetFolder returns the media items where the group column equals name, in a library or in every library if library
+// is empty.
+func (s *Store) GetFolder(group, name, library string, pageSize, offset int, direction string) ([]DatabaseMedia, error) {
+	conn, err := s.db.Take(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("failed to take connection from pool: %w", err)
+	}
+	defer s.db.Put(conn)
+
+	query := fmt.Sprintf(`SELECT DISTINCT %s FROM media WHERE %s =? AND (? = '' OR library = ?) AND DATE != '0001-01-01T00:00:00.000Z' GROUP BY date ORDER BY date %s LIMIT ? OFFSET ?`, columns, group, direction)
+
+	stmt, err := s.db.Prepare(conn, query)
+	if err != nil {
+		return nil, fmt.Errorf("error preparing SELECT statement: %v", err)
+	}
+	defer s.db.Release(stmt)
+
+	stmt.BindText(1, name)
+	stmt.BindText(2, library)
+	stmt.BindText(3, library)
+	stmt.BindInt64(4, int64(pageSize))
+	stmt.BindInt64(5, int64(offset))
+
+	return scanMediaRows(stmt)
+}
+
+// GetTotalOfFolder returns the number of media items where the group column equals name, in a library or in every
+// library if library is empty.
+func (s *Store) GetTotalOfFolder(group, name, library string) (int, error) {
+	conn, err := s.db.Take(context.Background())
+	if err != nil {
+		return 0, fmt.Errorf("failed to take connection from pool: %w", err)
+	}
+	defer s.db.Put(conn)
+
+	query := fmt.Sprintf(`SELECT count(distinct date) FROM media WHERE %s =? AND (? = '' OR library = ?) AND DATE != ?`, group)
+
+	return s.count(conn, query, name, library, library, "0001-01-01T00:00:00.000Z")
+}
+
+// GetFolders returns folders with their five most recent media items, in a library or in every library if library is
+// empty. Folders of other libraries are left out.
+func (s *Store) GetFolders(direction, library string, pageSize, offset int) ([]types.Directory, error) {
+	conn, err := s.db.Take(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("failed to take connection from pool: %w", err)
+	}
+	defer s.db.Put(conn)
+
+	query := fmt.Sprintf(`
+      WITH FolderImageCounts AS (
+        SELECT
+          folder,
+          COUNT(*) as total_images
+        FROM media
+        WHERE ? = '' OR library = ?
+        GROUP BY folder
+      ), RankedMedia AS (
+        SELECT
+          m.folder, m.hash, m.path, m.width, m.height, m.color, m.date,
+          ROW_NUMBER() OVER (PARTITION BY m.folder ORDER BY m.date DESC) as row_num
+        FROM media m
+        WHERE ? = '' OR m.library = ?
+      )
+      SELECT
+          f.id,
+          f.key,
+          GROUP_CONCAT(CASE WHEN rm.row_num <= 5 THEN rm.hash ELSE NULL END) as hashes,
+          GROUP_CONCAT(CASE WHEN rm.row_num <= 5 THEN rm.path ELSE NULL END) as paths,
+          GROUP_CONCAT(CASE WHEN rm.row_num <= 5 THEN rm.width ELSE NULL END) as widths,
+          GROUP_CONCAT(CASE WHEN rm.row_num <= 5 THEN rm.height ELSE NULL END) as heights,
+          GROUP_CONCAT(CASE WHEN rm.row_num <= 5 THEN rm.color ELSE NULL END) as colors,
+          COALESCE(fic.total_images, 0) as image_count,
+          GROUP_CONCAT(CASE WHEN rm.row_num <= 5 THEN rm.date ELSE NULL END) as dates
+      FROM folders f
+      LEFT JOIN FolderImageCounts fic ON f.key = fic.folder
+      LEFT JOIN RankedMedia rm ON f.key = rm.folder AND rm.row_num <= 5
+      WHERE ? = '' OR fic.folder IS NOT NULL
+      GROUP BY f.id, f.key, COALESCE(fic.total_images, 0)
+      ORDER BY f.key %s
+      LIMIT ? OFFSET ?`, direction)
+
+	stmt, err := s.db.Prepare(conn, query)
+	if err != nil {
+		return nil, fmt.Errorf("error preparing SELECT statement: %v", err)
+	}
+	defer s.db.Release(stmt)
+
+	for i := 1; i <= 5; i++ {
+		stmt.BindText(i, library)
+	}
+	stmt.BindInt64(6, int64(pageSize))
+	stmt.BindInt64(7, int64(offset))
+
+	var dirs []types.Directory
+	for {
+		hasRow, err := stmt.Step()
+		if err != nil {
+			return nil, fmt.Errorf("error stepping through result set: %v", err)
+		}
+		if !hasRow {
+			break
+		}
+
+		dirs = append(dirs, parseDirectory(
+			stmt.ColumnInt(0),
+			stmt.ColumnText(1),
+			stmt.ColumnText(2),
+			stmt.ColumnText(3),
+			stmt.ColumnText(4),
+			stmt.ColumnText(5),
+			stmt.ColumnText(6),
+			stmt.ColumnInt(7),
+		))
+	}
+
+	return dirs, nil
+}
+
+// parseDirectory builds a folder from comma separated lists of the hashes, paths, widths, heights and colors of its recent media items.
+func parseDirectory(id int, key, hashList, pathList, widthList, heightList, colorList string, imageCount int) types.Directory {
+	hashes := strings.Split(hashList, ",")
+	paths := strings.Split(pathList, ",")
+	widths := strings.Split(widthList, ",")
+	heights := strings.Split(heightList, ",")
+	colors := strings.Split(colorList, ",")
+
+	var mediaList []types.FolderMedia
+	for i := 0; i < len(hashes) && i < 5 && hashes[0] != ""; i++ {
+		width, _ := strconv.Atoi(widths[i])
+		height, _ := strconv.Atoi(heights[i])
+		hash, _ := strconv.ParseUint(hashes[i], 10, 64)
+
+		mediaList = append(mediaList, types.FolderMedia{
+			Hash:   hash,
+			Path:   paths[i],
+			Width:  width,
+			Height: height,
+			Color:  colors[i],
+		})
+	}
+
+	dir := types.Directory{
+		Id:         id,
+		Key:        key,
+		Media:      nil,
+		ImageCount: imageCount,
+	}
+
+	if len(mediaList) > 0 {
+		dir.Media = &mediaList
+	}
+
+	return dir
+}
+
+// GetTotalFolders returns the number of folders in a library, or in every library if library is empty.
+func (s *Store) GetTotalFolders(library string) (int, error) {
+	conn, err := s.db.Take(context.Background())
+	if err != nil {
+		return 0, fmt.Errorf("failed to take connection from pool: %w", err)
+	}
+	defer s.db.Put(conn)
+
+	return s.count(conn, `SELECT COUNT(*) FROM folders WHERE ? = '' OR key IN (SELECT folder FROM media WHERE library = ?)`, library, library)
+}
@@ -0,0 +1,169 @@
+package sqlitestore
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/robbymilo/rgallery/pkg/types"
+	"zombiezen.com/go/sqlite"
+	"zombiezen.com/go/sqlite/sqlitex"
+)
+
+const jobColumns = "id, type, scope, status, total, processed, failed, cursor, error, created_at, started_at, finished_at, owner, heartbeat"
+
+// AddJob stores a job and returns its id.
+func (s *Store) AddJob(job types.Job) (int64, error) {
+	conn, err := s.db.TakeWriter(context.Background())
+	if err != nil {
+		return 0, err
+	}
+	defer s.db.Put(conn)
+
+	err = sqlitex.Execute(conn, "INSERT INTO jobs (type, scope, status, cursor, created_at) VALUES (?, ?, ?, ?, ?)", &sqlitex.ExecOptions{
+		Args: []interface{}{job.Type, job.Scope, job.Status, job.Cursor, job.CreatedAt},
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return conn.LastInsertRowID(), nil
+}
+
+// UpdateJob saves the status, progress, cursor, error and timestamps of a job.
+func (s *Store) UpdateJob(job types.Job) error {
+	conn, err := s.db.TakeWriter(context.Background())
+	if err != nil {
+		return err
+	}
+	defer s.db.Put(conn)
+
+	return sqlitex.Execute(conn, "UPDATE jobs SET status = ?, total = ?, processed = ?, failed = ?, cursor = ?, error = ?, started_at = ?, finished_at = ? WHERE id = ?", &sqlitex.ExecOptions{
+		Args: []interface{}{job.Status, job.Total, job.Processed, job.Failed, job.Cursor, job.Error, job.StartedAt, job.FinishedAt, job.ID},
+	})
+}
+
+// StartJob marks a queued job as running by owner, returning false if it is no longer queued.
+func (s *Store) StartJob(id int64, owner, startedAt string) (bool, error) {
+	return s.setQueuedJobStatus("UPDATE jobs SET status = 'running', started_at = ?, owner = ?, heartbeat = ? WHERE id = ? AND status = 'queued'", startedAt, owner, startedAt, id)
+}
+
+// CancelQueuedJob marks a queued job as canceled, returning false if it is no longer queued.
+func (s *Store) CancelQueuedJob(id int64, finishedAt string) (bool, error) {
+	return s.setQueuedJobStatus("UPDATE jobs SET status = 'canceled', finished_at = ? WHERE id = ? AND status = 'queued'", finishedAt, id)
+}
+
+func (s *Store) setQueuedJobStatus(query string, args ...interface{}) (bool, error) {
+	conn, err := s.db.TakeWriter(context.Background())
+	if err != nil {
+		return false, err
+	}
+	defer s.db.Put(conn)
+
+	err = sqlitex.Execute(conn, query, &sqlitex.ExecOptions{
+		Args: args,
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return conn.Changes() > 0, nil
+}
+
+// HeartbeatJobs records that the running jobs of owner are still running.
+func (s *Store) HeartbeatJobs(owner, at string) error {
+	conn, err := s.db.TakeWriter(context.Background())
+	if err != nil {
+		return err
+	}
+	defer s.db.Put(conn)
+
+	return sqlitex.Execute(conn, "UPDATE jobs SET heartbeat = ? WHERE owner = ? AND status = 'running'", &sqlitex.ExecOptions{
+		Args: []interface{}{at, owner},
+	})
+}
+
+// RequeueStaleJobs queues the running jobs whose last heartbeat is before a time again, returning them.
+func (s *Store) RequeueStaleJobs(before string) ([]types.Job, error) {
+	conn, err := s.db.TakeWriter(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	defer s.db.Put(conn)
+
+	return scanJobs(conn, "UPDATE jobs SET status = 'queued', total = processed, owner = '' WHERE status = 'running' AND heartbeat < ? RETURNING "+jobColumns, before)
+}
+
+// GetJob returns a job, or types.ErrJobNotFound.
+func (s *Store) GetJob(id int64) (types.Job, error) {
+	jobs, err := s.getJobs("SELECT "+jobColumns+" FROM jobs WHERE id = ?", id)
+	if err != nil {
+		return types.Job{}, err
+	}
+	if len(jobs) == 0 {
+		return types.Job{}, types.ErrJobNotFound
+	}
+
+	return jobs[0], nil
+}
+
+// GetJobs returns the most recent jobs, newest first.
+func (s *Store) GetJobs(limit int) ([]types.Job, error) {
+	return s.getJobs("SELECT "+jobColumns+" FROM jobs ORDER BY id DESC LIMIT ?", limit)
+}
+
+// GetJobsWithStatus returns the jobs with a status, oldest first.
+func (s *Store) GetJobsWithStatus(status string) ([]types.Job, error) {
+	return s.getJobs("SELECT "+jobColumns+" FROM jobs WHERE status = ? ORDER BY id ASC", status)
+}
+
+// GetLastJob returns the most recently started job of a type and scope, or types.ErrJobNotFound.
+func (s *Store) GetLastJob(jobType, scope string) (types.Job, error) {
+	jobs, err := s.getJobs("SELECT "+jobColumns+" FROM jobs WHERE type = ? AND scope = ? AND started_at != '' ORDER BY id DESC LIMIT 1", jobType, scope)
+	if err != nil {
+		return types.Job{}, err
+	}
+	if len(jobs) == 0 {
+		return types.Job{}, types.ErrJobNotFound
+	}
+
+	return jobs[0], nil
+}
+
+func (s *Store) getJobs(query string, args ...interface{}) ([]types.Job, error) {
+	conn, err := s.db.Take(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("failed to take connection from pool: %w", err)
+	}
+	defer s.db.Put(conn)
+
+	return scanJobs(conn, query, args...)
+}
+
+// scanJobs runs a query returning the columns of jobColumns.
+func scanJobs(conn *sqlite.Conn, query string, args ...interface{}) ([]types.Job, error) {
+	jobs := []types.Job{}
+	err := sqlitex.Execute(conn, query, &sqlitex.ExecOptions{
+		Args: args,
+		ResultFunc: func(stmt *sqlite.Stmt) error {
+			jobs = append(jobs, types.Job{
+				ID:         stmt.ColumnInt64(0),
+				Type:       stmt.ColumnText(1),
+				Scope:      stmt.ColumnText(2),
+				Status:     stmt.ColumnText(3),
+				Total:      stmt.ColumnInt(4),
+				Processed:  stmt.ColumnInt(5),
+				Failed:     stmt.ColumnInt(6),
+				Cursor:     stmt.ColumnText(7),
+				Error:      stmt.ColumnText(8),
+				CreatedAt:  stmt.ColumnText(9),
+				StartedAt:  stmt.ColumnText(10),
+				FinishedAt: stmt.ColumnText(11),
+				Owner:      stmt.ColumnText(12),
+				Heartbeat:  stmt.ColumnText(13),
+			})
+			return nil
+		},
+	})
+
+	return jobs, err
+}
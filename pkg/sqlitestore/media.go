@@ -0,0 +1,227 @@
+package sqlitestore
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/robbymilo/rgallery/pkg/types"
+)
+
+// GetSingleMediaItem returns a media item by hash, or an empty item if it does not exist.
+func (s *Store) GetSingleMediaItem(hash uint64) (DatabaseMedia, error) {
+	conn, err := s.db.Take(context.Background())
+	if err != nil {
+		return DatabaseMedia{}, fmt.Errorf("failed to take connection from pool: %w", err)
+	}
+	defer s.db.Put(conn)
+
+	query := fmt.Sprintf(`SELECT %s FROM media WHERE hash = ?;`, columns)
+	stmt, err := s.db.Prepare(conn, query)
+	if err != nil {
+		return DatabaseMedia{}, fmt.Errorf("error preparing query for media item: %v", err)
+	}
+	defer s.db.Release(stmt)
+
+	stmt.BindInt64(1, int64(hash))
+
+	hasRow, err := stmt.Step()
+	if err != nil {
+		return DatabaseMedia{}, fmt.Errorf("error stepping through result set: %v", err)
+	}
+	if !hasRow {
+		return DatabaseMedia{}, nil
+	}
+
+	return scanMediaRow(stmt), nil
+}
+
+// GetMediaItems returns all media items for use in scanning.
+func (s *Store) GetMediaItems(offset int, direction string, total int) ([]DatabaseMedia, error) {
+	conn, err := s.db.Take(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("failed to take connection from pool: %w", err)
+	}
+	defer s.db.Put(conn)
+
+	query := fmt.Sprintf(`SELECT %s FROM media WHERE DATE != '0001-01-01T00:00:00.000Z' ORDER BY date %s LIMIT ? OFFSET ?`, columns, direction)
+
+	stmt, err := s.db.Prepare(conn, query)
+	if err != nil {
+		return nil, fmt.Errorf("error preparing SELECT statement: %v", err)
+	}
+	defer s.db.Release(stmt)
+
+	stmt.BindInt64(1, int64(total))
+	stmt.BindInt64(2, int64(offset))
+
+	return scanMediaRows(stmt)
+}
+
+// GetTotalMediaItems returns the number of media items.
+func (s *Store) GetTotalMediaItems(rating int, from, to string) (int, error) {
+	conn, err := s.db.Take(context.Background())
+	if err != nil {
+		return 0, fmt.Errorf("failed to take connection from pool: %w", err)
+	}
+	defer s.db.Put(conn)
+
+	query := `SELECT COUNT(*) FROM media WHERE rating >=? AND date >=? AND date <=? AND DATE != '0001-01-01T00:00:00.000Z'`
+
+	return s.count(conn, query, rating, from, to)
+}
+
+// GetFavorites returns media items with at least the given rating.
+func (s *Store) GetFavorites(pageSize, offset, rating int, direction string) ([]DatabaseMedia, error) {
+	conn, err := s.db.Take(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("failed to take connection from pool: %w", err)
+	}
+	defer s.db.Put(conn)
+
+	query := fmt.Sprintf(`SELECT DISTINCT %s FROM media WHERE rating >= ? AND DATE != '0001-01-01T00:00:00.000Z' GROUP BY date ORDER BY date %s LIMIT ? OFFSET ?`, columns, direction)
+
+	stmt, err := s.db.Prepare(conn, query)
+	if err != nil {
+		return nil, fmt.Errorf("error preparing SELECT statement: %v", err)
+	}
+	defer s.db.Release(stmt)
+
+	stmt.BindInt64(1, int64(rating))
+	stmt.BindInt64(2, int64(pageSize))
+	stmt.BindInt64(3, int64(offset))
+
+	return scanMediaRows(stmt)
+}
+
+// GetTotalFavorites returns the number of media items with at least the given rating.
+func (s *Store) GetTotalFavorites(rating int) (int, error) {
+	conn, err := s.db.Take(context.Background())
+	if err != nil {
+		return 0, fmt.Errorf("failed to take connection from pool: %w", err)
+	}
+	defer s.db.Put(conn)
+
+	return s.count(conn, `SELECT count(distinct date) FROM media WHERE rating >= ? AND date != ?`, rating, "0001-01-01T00:00:00.000Z")
+}
+
+// GetMemories returns media items that occurred on any of the given days, formatted as YYYY-MM-DD.
+func (s *Store) GetMemories(days []string) ([]DatabaseMedia, error) {
+	if len(days) == 0 {
+		return nil, nil
+	}
+
+	conn, err := s.db.Take(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("failed to take connection from pool: %w", err)
+	}
+	defer s.db.Put(conn)
+
+	placeholders := make([]string, len(days))
+	args := make([]interface{}, len(days))
+	for i, day := range days {
+		placeholders[i] = "DATE(?)"
+		args[i] = day
+	}
+
+	query := fmt.Sprintf(`
+SELECT %s
+FROM media
+WHERE DATE(date) IN (%s)
+`, columns, strings.Join(placeholders, ", "))
+
+	stmt, err := s.db.Prepare(conn, query)
+	if err != nil {
+		return nil, fmt.Errorf("error preparing SELECT statement: %v", err)
+	}
+	defer s.db.Release(stmt)
+
+	bindArgs(stmt, args)
+
+	return scanMediaRows(stmt)
+}
+
+// GetMapItems returns all media items' coordinates.
+func (s *Store) GetMapItems() ([]types.MapItem, error) {
+	conn, err := s.db.Take(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("failed to take connection from pool: %w", err)
+	}
+	defer s.db.Put(conn)
+
+	query := `
+			SELECT DISTINCT hash, latitude, longitude, date
+			FROM media
+			WHERE latitude != 0.0
+				AND longitude != 0.0
+				AND date != '0001-01-01T00:00:00.000Z'
+			GROUP BY date`
+
+	stmt, err := s.db.Prepare(conn, query)
+	if err != nil {
+		return nil, fmt.Errorf("error preparing query: %v", err)
+	}
+	defer s.db.Release(stmt)
+
+	var mapItems []types.MapItem
+	for {
+		hasRow, err := stmt.Step()
+		if err != nil {
+			return nil, fmt.Errorf("error stepping through query results: %v", err)
+		}
+		if !hasRow {
+			break
+		}
+
+		var item types.MapItem
+		item = append(item, stmt.ColumnFloat(1))
+		item = append(item, stmt.ColumnFloat(2))
+		item = append(item, stmt.ColumnFloat(0))
+
+		mapItems = append(mapItems, item)
+	}
+
+	return mapItems, nil
+}
+
+// GetGear returns the number of media items for each value of a gear column, most used first.
+func (s *Store) GetGear(column string) (types.GearItems, error) {
+	conn, err := s.db.Take(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("failed to take connection from pool: %w", err)
+	}
+	defer s.db.Put(conn)
+
+	query := fmt.Sprintf(
+		`SELECT %s, count(%s) AS total
+			 FROM media
+			 WHERE date != ?
+			 GROUP BY %s
+			 ORDER BY total DESC`, column, column, column)
+
+	stmt, err := s.db.Prepare(conn, query)
+	if err != nil {
+		return nil, fmt.Errorf("error preparing query: %v", err)
+	}
+	defer s.db.Release(stmt)
+
+	stmt.BindText(1, "0001-01-01T00:00:00.000Z")
+
+	var gear types.GearItems
+	for {
+		hasRow, err := stmt.Step()
+		if err != nil {
+			return nil, fmt.Errorf("error stepping through query results: %v", err)
+		}
+		if !hasRow {
+			break
+		}
+
+		gear = append(gear, types.GearItem{
+			Name:  stmt.ColumnText(0),
+			Total: int(stmt.ColumnInt64(1)),
+		})
+	}
+
+	return gear, nil
+}
@@ -0,0 +1,86 @@
+package sqlitestore
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/robbymilo/rgallery/pkg/types"
+	"zombiezen.com/go/sqlite"
+	"zombiezen.com/go/sqlite/sqlitex"
+)
+
+// AddNotification stores a notification and returns its id.
+func (s *Store) AddNotification(message, createdAt string) (int64, error) {
+	conn, err := s.db.TakeWriter(context.Background())
+	if err != nil {
+		return 0, err
+	}
+	defer s.db.Put(conn)
+
+	err = sqlitex.Execute(conn, "INSERT INTO notifications (message, created_at) VALUES (?, ?)", &sqlitex.ExecOptions{
+		Args: []interface{}{message, createdAt},
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return conn.LastInsertRowID(), nil
+}
+
+// GetNotifications returns the notifications a user has not dismissed, newest first.
+func (s *Store) GetNotifications(username string) ([]types.Notification, error) {
+	conn, err := s.db.Take(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("failed to take connection from pool: %w", err)
+	}
+	defer s.db.Put(conn)
+
+	var notifications []types.Notification
+	query := `SELECT n.id, ?, n.message, nd.dismissed, n.created_at
+			  FROM notifications n
+			  LEFT JOIN notifications_dismissed nd ON nd.notification_id = n.id AND nd.username = ?
+			  WHERE IFNULL(nd.dismissed, 0) = 0
+			  ORDER BY n.created_at DESC`
+	err = sqlitex.Execute(conn, query, &sqlitex.ExecOptions{
+		Args: []interface{}{username, username},
+		ResultFunc: func(stmt *sqlite.Stmt) error {
+			n := types.Notification{
+				ID:        stmt.ColumnInt64(0),
+				Username:  stmt.ColumnText(1),
+				Message:   stmt.ColumnText(2),
+				IsRead:    stmt.ColumnBool(3),
+				CreatedAt: stmt.ColumnText(4),
+			}
+			notifications = append(notifications, n)
+			return nil
+		},
+	})
+
+	return notifications, err
+}
+
+// MarkNotificationRead marks a notification as read for a user.
+func (s *Store) MarkNotificationRead(id int64, username string) error {
+	conn, err := s.db.TakeWriter(context.Background())
+	if err != nil {
+		return err
+	}
+	defer s.db.Put(conn)
+
+	return sqlitex.Execute(conn, "INSERT OR REPLACE INTO notifications_dismissed (notification_id, username, dismissed, dismissed_at) VALUES (?, ?, 1, CURRENT_TIMESTAMP)", &sqlitex.ExecOptions{
+		Args: []interface{}{id, username},
+	})
+}
+
+// ClearAllNotifications marks all notifications as read for a user.
+func (s *Store) ClearAllNotifications(username string) error {
+	conn, err := s.db.TakeWriter(context.Background())
+	if err != nil {
+		return err
+	}
+	defer s.db.Put(conn)
+
+	return sqlitex.Execute(conn, "INSERT OR REPLACE INTO notifications_dismissed (notification_id, username, dismissed, dismissed_at) SELECT id, ?, 1, CURRENT_TIMESTAMP FROM notifications", &sqlitex.ExecOptions{
+		Args: []interface{}{username},
+	})
+}
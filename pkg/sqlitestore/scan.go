@@ -0,0 +1,463 @@
+package sqlitestore
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/robbymilo/rgallery/pkg/hash"
+	"zombiezen.com/go/sqlite"
+	"zombiezen.com/go/sqlite/sqlitex"
+)
+
+// InsertMediaItem inserts a media item, and it's tags, folders and their relationships.
+func (s *Store) InsertMediaItem(media Media) error {
+	return s.InsertMediaItems([]Media{media})
+}
+
+// InsertMediaItems inserts media items, and their tags, folders and relationships, in a single transaction.
+func (s *Store) InsertMediaItems(items []Media) error {
+	conn, err := s.db.TakeWriter(context.Background())
+	if err != nil {
+		return fmt.Errorf("failed to take connection from pool: %w", err)
+	}
+	defer s.db.Put(conn)
+
+	// Retry logic for transaction
+	maxRetries := 3
+	for retryCount := 1; ; retryCount++ {
+		err = s.insertMediaItems(conn, items)
+		if err == nil {
+			return nil
+		}
+
+		// If we get a database locked error, retry
+		if sqlite.ErrCode(err).ToPrimary() != sqlite.ResultBusy || retryCount == maxRetries {
+			return err
+		}
+		s.logger.Warn("transaction failed due to database lock, retrying", "attempt", retryCount)
+	}
+}
+
+func (s *Store) insertMediaItems(conn *sqlite.Conn, items []Media) error {
+	err := sqlitex.Execute(conn, "BEGIN TRANSACTION", nil)
+	if err != nil {
+		return fmt.Errorf("error beginning transaction: %w", err)
+	}
+
+	rollback := func() {
+		if err := sqlitex.Execute(conn, "ROLLBACK", nil); err != nil {
+			s.logger.Error("error executing rollback", "error", err)
+		}
+	}
+
+	for _, media := range items {
+		if err := s.insertMediaItem(conn, media); err != nil {
+			rollback()
+			return err
+		}
+	}
+
+	err = sqlitex.Execute(conn, "COMMIT", nil)
+	if err != nil {
+		rollback()
+		return fmt.Errorf("error committing transaction: %w", err)
+	}
+
+	return nil
+}
+
+// insertMediaItem inserts a media item and it's tags and folder within the open transaction of conn.
+func (s *Store) insertMediaItem(conn *sqlite.Conn, media Media) error {
+	folder_id := hash.GetHash(media.Folder)
+
+	// check if folder exists
+	var folderExists bool
+	err := sqlitex.ExecuteTransient(conn, "SELECT id FROM folders WHERE id = ?", &sqlitex.ExecOptions{
+		Args: []interface{}{folder_id},
+		ResultFunc: func(stmt *sqlite.Stmt) error {
+			folderExists = true
+			return nil
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("error querying folders: %w", err)
+	}
+
+	// add folder if it does not exist
+	if !folderExists {
+		err = sqlitex.ExecuteTransient(conn, "INSERT INTO folders(id, key) VALUES (?, ?)", &sqlitex.ExecOptions{
+			Args: []interface{}{folder_id, media.Folder},
+		})
+		if err != nil {
+			return fmt.Errorf("error inserting folder: %v", err)
+		}
+
+		s.logger.Info("added folder " + media.Folder)
+	}
+
+	// handle tag inserts
+	for _, tag := range media.Subject {
+		tag_id := hash.GetHash(tag.Key)
+
+		// check if tag exists
+		var tagExists bool
+		err = sqlitex.ExecuteTransient(conn, "SELECT id FROM tags WHERE id = ?", &sqlitex.ExecOptions{
+			Args: []interface{}{tag_id},
+			ResultFunc: func(stmt *sqlite.Stmt) error {
+				tagExists = true
+				return nil
+			},
+		})
+		if err != nil {
+			return fmt.Errorf("error checking tag existence: %v", err)
+		}
+
+		// add tag if it does not exist
+		if !tagExists {
+			err = sqlitex.ExecuteTransient(conn, "INSERT INTO tags(id, key, value) VALUES (?, ?, ?)", &sqlitex.ExecOptions{
+				Args: []interface{}{tag_id, tag.Key, tag.Value},
+			})
+			if err != nil {
+				return fmt.Errorf("error inserting tag: %v", err)
+			}
+
+			s.logger.Info("added tag " + tag.Value)
+		}
+
+		// add tag-image many-to-many relationship
+		err = sqlitex.ExecuteTransient(conn, "INSERT INTO images_tags(image_id, tag_id) VALUES (?, ?)", &sqlitex.ExecOptions{
+			Args: []interface{}{media.Hash, tag_id},
+		})
+		if err != nil {
+			return fmt.Errorf("error inserting image-tag relationship: %v", err)
+		}
+	}
+
+	// insert image
+	subject, err := json.Marshal(media.Subject)
+	if err != nil {
+		return fmt.Errorf("error marshaling subject: %v", err)
+	}
+
+	// media items without a perceptual hash have a NULL phash
+	var phash any
+	if media.PHashed {
+		phash = int64(media.PHash)
+	}
+
+	query := fmt.Sprintf("INSERT INTO media(%s) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)", columns)
+	err = sqlitex.ExecuteTransient(conn, query, &sqlitex.ExecOptions{
+		Args: []interface{}{
+			media.Hash,
+			media.Path,
+			string(subject),
+			media.Width,
+			media.Height,
+			media.Ratio,
+			media.Padding,
+			string(media.Date.Format("2006-01-02T15:04:05.000Z")),
+			string(media.Modified.Format("2006-01-02T15:04:05.000Z")),
+			media.Folder,
+			media.Rating,
+			media.ShutterSpeed,
+			media.Aperture,
+			media.Iso,
+			media.Lens,
+			media.Camera,
+			media.Focallength,
+			media.Altitude,
+			media.Latitude,
+			media.Longitude,
+			media.Type,
+			media.FocusDistance,
+			media.FocalLength35,
+			media.Color,
+			media.Location,
+			media.Description,
+			media.Title,
+			media.Software,
+			media.Offset,
+			media.Rotation,
+			media.Checksum,
+			media.Size,
+			media.MotionOffset,
+			media.MotionLength,
+			phash,
+			media.Library,
+			media.Container,
+			media.VideoCodec,
+			media.AudioCodec,
+			media.Duration,
+			media.FrameRate,
+			media.Bitrate,
+			media.HasAudio,
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("error inserting image: %v", err)
+	}
+
+	return nil
+}
+
+// DeleteMediaItem removes a media item and any tags and folders no longer used by other media items.
+func (s *Store) DeleteMediaItem(media Media) error {
+	conn, err := s.db.TakeWriter(context.Background())
+	if err != nil {
+		return err
+	}
+	defer s.db.Put(conn)
+
+	// delete image
+	err = sqlitex.Execute(conn, "DELETE FROM media WHERE hash =?", &sqlitex.ExecOptions{
+		Args: []interface{}{media.Hash},
+	})
+	if err != nil {
+		return err
+	}
+
+	// delete tag relationships
+	err = sqlitex.Execute(conn, "DELETE FROM images_tags WHERE image_id =?", &sqlitex.ExecOptions{
+		Args: []interface{}{media.Hash},
+	})
+	if err != nil {
+		return err
+	}
+
+	// delete tag relationships
+	for _, tag := range media.Subject {
+		// check if other items have the tag
+		var tagUsed bool
+		query := `SELECT t.id FROM tags t JOIN images_tags i_a ON t.id = i_a.tag_id WHERE i_a.tag_id = ? LIMIT 1`
+		err = sqlitex.Execute(conn, query, &sqlitex.ExecOptions{
+			Args: []interface{}{hash.GetHash(tag.Key)},
+			ResultFunc: func(stmt *sqlite.Stmt) error {
+				tagUsed = true
+				return nil
+			},
+		})
+		if err != nil {
+			return err
+		}
+
+		// if no other items have the tag
+		if !tagUsed {
+			// delete tag
+			s.logger.Info("deleting tag " + tag.Key)
+			err = sqlitex.Execute(conn, "DELETE FROM tags WHERE id =?", &sqlitex.ExecOptions{
+				Args: []interface{}{hash.GetHash(tag.Key)},
+			})
+			if err != nil {
+				return err
+			}
+
+		}
+
+	}
+
+	// delete folder
+	// check if other items have the folder
+	var folderUsed bool
+	query := `SELECT f.key FROM folders f JOIN media i ON f.key = i.folder WHERE i.folder = ? LIMIT 1`
+	err = sqlitex.Execute(conn, query, &sqlitex.ExecOptions{
+		Args: []interface{}{media.Folder},
+		ResultFunc: func(stmt *sqlite.Stmt) error {
+			folderUsed = true
+			return nil
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	// if no other items have the folder
+	if !folderUsed {
+		// delete folder
+		s.logger.Info("deleting folder " + media.Folder)
+		err = sqlitex.Execute(conn, "DELETE FROM folders WHERE key =?", &sqlitex.ExecOptions{
+			Args: []interface{}{media.Folder},
+		})
+		if err != nil {
+			return err
+		}
+
+	}
+
+	return nil
+}
+
+// MoveMediaItem points an existing media item at a new path, keeping its hash and tags.
+func (s *Store) MoveMediaItem(media Media, path, folder string, modified time.Time, size int64) error {
+	conn, err := s.db.TakeWriter(context.Background())
+	if err != nil {
+		return fmt.Errorf("failed to take connection from pool: %w", err)
+	}
+	defer s.db.Put(conn)
+
+	err = sqlitex.Execute(conn, "BEGIN TRANSACTION", nil)
+	if err != nil {
+		return fmt.Errorf("error beginning transaction: %v", err)
+	}
+
+	rollback := func() {
+		if err := sqlitex.Execute(conn, "ROLLBACK", nil); err != nil {
+			s.logger.Error("error executing rollback", "error", err)
+		}
+	}
+
+	// add folder if it does not exist
+	err = sqlitex.Execute(conn, "INSERT OR IGNORE INTO folders(id, key) VALUES (?, ?)", &sqlitex.ExecOptions{
+		Args: []interface{}{hash.GetHash(folder), folder},
+	})
+	if err != nil {
+		rollback()
+		return fmt.Errorf("error inserting folder: %v", err)
+	}
+
+	err = sqlitex.Execute(conn, "UPDATE media SET path = ?, folder = ?, modified = ?, size = ? WHERE hash = ?", &sqlitex.ExecOptions{
+		Args: []interface{}{
+			path,
+			folder,
+			modified.UTC().Format("2006-01-02T15:04:05.000Z"),
+			size,
+			media.Hash,
+		},
+	})
+	if err != nil {
+		rollback()
+		return fmt.Errorf("error updating media path: %v", err)
+	}
+
+	// delete the previous folder if no other items have it
+	if media.Folder != folder {
+		err = sqlitex.Execute(conn, "DELETE FROM folders WHERE key = ? AND NOT EXISTS (SELECT 1 FROM media WHERE folder = ?)", &sqlitex.ExecOptions{
+			Args: []interface{}{media.Folder, media.Folder},
+		})
+		if err != nil {
+			rollback()
+			return fmt.Errorf("error deleting folder: %v", err)
+		}
+	}
+
+	err = sqlitex.Execute(conn, "COMMIT", nil)
+	if err != nil {
+		rollback()
+		return fmt.Errorf("error committing transaction: %v", err)
+	}
+
+	return nil
+}
+
+// UpdateMediaItem replaces a media item and its tags in a single transaction, removing tags no other media items have.
+func (s *Store) UpdateMediaItem(media Media) error {
+	conn, err := s.db.TakeWriter(context.Background())
+	if err != nil {
+		return fmt.Errorf("failed to take connection from pool: %w", err)
+	}
+	defer s.db.Put(conn)
+
+	err = sqlitex.Execute(conn, "BEGIN TRANSACTION", nil)
+	if err != nil {
+		return fmt.Errorf("error beginning transaction: %v", err)
+	}
+
+	rollback := func() {
+		if err := sqlitex.Execute(conn, "ROLLBACK", nil); err != nil {
+			s.logger.Error("error executing rollback", "error", err)
+		}
+	}
+
+	for _, query := range []string{"DELETE FROM media WHERE hash = ?", "DELETE FROM images_tags WHERE image_id = ?"} {
+		err = sqlitex.Execute(conn, query, &sqlitex.ExecOptions{
+			Args: []interface{}{media.Hash},
+		})
+		if err != nil {
+			rollback()
+			return fmt.Errorf("error deleting media item: %v", err)
+		}
+	}
+
+	if err := s.insertMediaItem(conn, media); err != nil {
+		rollback()
+		return err
+	}
+
+	err = sqlitex.ExecuteTransient(conn, "DELETE FROM tags WHERE NOT EXISTS (SELECT 1 FROM images_tags WHERE tag_id = tags.id)", nil)
+	if err != nil {
+		rollback()
+		return fmt.Errorf("error deleting tags: %v", err)
+	}
+
+	err = sqlitex.Execute(conn, "COMMIT", nil)
+	if err != nil {
+		rollback()
+		return fmt.Errorf("error committing transaction: %v", err)
+	}
+
+	return nil
+}
+
+// TrackScanError records a file scanning error so the file is skipped until it is modified.
+func (s *Store) TrackScanError(library, path string, modified time.Time, message string) error {
+	conn, err := s.db.TakeWriter(context.Background())
+	if err != nil {
+		return fmt.Errorf("failed to take connection from pool: %w", err)
+	}
+	defer s.db.Put(conn)
+
+	stmt, err := s.db.Prepare(conn, "INSERT OR REPLACE INTO scan_errors (library, path, modified, error) VALUES (?, ?, ?, ?)")
+	if err != nil {
+		return fmt.Errorf("error preparing insert statement: %v", err)
+	}
+	defer s.db.Release(stmt)
+
+	stmt.BindText(1, library)
+	stmt.BindText(2, path)
+	stmt.BindText(3, modified.Format(time.RFC3339))
+	stmt.BindText(4, message)
+
+	if _, err := stmt.Step(); err != nil {
+		return fmt.Errorf("error inserting scan error record: %v", err)
+	}
+
+	return nil
+}
+
+// GetScanErrors returns the modification time of every file of a library that failed to scan.
+func (s *Store) GetScanErrors(library string) (map[string]time.Time, error) {
+	conn, err := s.db.Take(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("failed to take connection from pool: %w", err)
+	}
+	defer s.db.Put(conn)
+
+	stmt, err := s.db.Prepare(conn, "SELECT path, modified FROM scan_errors WHERE library = ?")
+	if err != nil {
+		return nil, err
+	}
+	defer s.db.Release(stmt)
+
+	stmt.BindText(1, library)
+
+	scanErrors := make(map[string]time.Time)
+	for {
+		hasRow, err := stmt.Step()
+		if err != nil {
+			return nil, err
+		}
+		if !hasRow {
+			break
+		}
+
+		modified, err := time.Parse(time.RFC3339, stmt.ColumnText(1))
+		if err != nil {
+			return nil, fmt.Errorf("error parsing last scan time: %v", err)
+		}
+
+		scanErrors[stmt.ColumnText(0)] = modified
+	}
+
+	return scanErrors, nil
+}
@@ -0,0 +1,192 @@
+package sqlitestore
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/robbymilo/rgallery/pkg/database"
+	"github.com/robbymilo/rgallery/pkg/dbpool"
+	"github.com/robbymilo/rgallery/pkg/types"
+	"zombiezen.com/go/sqlite"
+	"zombiezen.com/go/sqlite/sqlitex"
+)
+
+type Conf = types.Conf
+type Media = types.Media
+type DatabaseMedia = types.DatabaseMedia
+type FilterParams = types.FilterParams
+type PrevNext = types.PrevNext
+
+var columns = database.Columns()
+
+// Store is the SQLite implementation of types.Store.
+type Store struct {
+	db     *dbpool.DB
+	logger *slog.Logger
+}
+
+var _ types.Store = (*Store)(nil)
+
+// Open opens the SQLite database created by database.CreateDB.
+func Open(c Conf) (*Store, error) {
+	db, err := database.Open(c)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Store{db: db, logger: c.Logger}, nil
+}
+
+// Close closes the database connections.
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// Optimize updates the query planner statistics, merges the search index, rebuilds the database file to reclaim
+// unused space and truncates the write-ahead log.
+func (s *Store) Optimize() error {
+	conn, err := s.db.TakeWriter(context.Background())
+	if err != nil {
+		return err
+	}
+	defer s.db.Put(conn)
+
+	for _, query := range []string{
+		"PRAGMA optimize;",
+		"INSERT INTO images_virtual(images_virtual) VALUES('optimize');",
+		"VACUUM;",
+		"PRAGMA wal_checkpoint(TRUNCATE);",
+	} {
+		if err := sqlitex.ExecuteTransient(conn, query, nil); err != nil {
+			return fmt.Errorf("error running %s: %v", query, err)
+		}
+	}
+
+	return nil
+}
+
+func (s *Store) Describe(ch chan<- *prometheus.Desc) {
+	s.db.Describe(ch)
+}
+
+func (s *Store) Collect(ch chan<- prometheus.Metric) {
+	s.db.Collect(ch)
+}
+
+// count runs a query returning a single integer.
+func (s *Store) count(conn *sqlite.Conn, query string, args ...interface{}) (int, error) {
+	stmt, err := s.db.Prepare(conn, query)
+	if err != nil {
+		return 0, fmt.Errorf("error preparing query: %v", err)
+	}
+	defer s.db.Release(stmt)
+
+	bindArgs(stmt, args)
+
+	var total int
+	hasRow, err := stmt.Step()
+	if err != nil {
+		return 0, fmt.Errorf("error executing query: %v", err)
+	}
+	if hasRow {
+		total = int(stmt.ColumnInt64(0))
+	}
+
+	return total, nil
+}
+
+func bindArgs(stmt *sqlite.Stmt, args []interface{}) {
+	for i, arg := range args {
+		bindArg(stmt, i+1, arg)
+	}
+}
+
+// bindArg binds an argument of a statement by its type.
+func bindArg(stmt *sqlite.Stmt, idx int, arg interface{}) {
+	switch v := arg.(type) {
+	case int:
+		stmt.BindInt64(idx, int64(v))
+	case int64:
+		stmt.BindInt64(idx, v)
+	case uint64:
+		stmt.BindInt64(idx, int64(v))
+	case float64:
+		stmt.BindFloat(idx, v)
+	case string:
+		stmt.BindText(idx, v)
+	case bool:
+		stmt.BindBool(idx, v)
+	default:
+		stmt.BindText(idx, fmt.Sprintf("%v", v))
+	}
+}
+
+// scanMediaRows reads every row of a statement selecting the media columns.
+func scanMediaRows(stmt *sqlite.Stmt) ([]DatabaseMedia, error) {
+	var result []DatabaseMedia
+	for {
+		hasRow, err := stmt.Step()
+		if err != nil {
+			return nil, fmt.Errorf("error stepping through result set: %v", err)
+		}
+		if !hasRow {
+			break
+		}
+
+		result = append(result, scanMediaRow(stmt))
+	}
+
+	return result, nil
+}
+
+// scanMediaRow reads the current row of a statement selecting the media columns.
+func scanMediaRow(stmt *sqlite.Stmt) DatabaseMedia {
+	return DatabaseMedia{
+		Hash:          uint64(stmt.ColumnInt64(0)),
+		Path:          stmt.ColumnText(1),
+		Subject:       stmt.ColumnText(2),
+		Width:         int(stmt.ColumnInt64(3)),
+		Height:        int(stmt.ColumnInt64(4)),
+		Ratio:         float32(stmt.ColumnFloat(5)),
+		Padding:       float32(stmt.ColumnFloat(6)),
+		Date:          stmt.ColumnText(7),
+		Modified:      stmt.ColumnText(8),
+		Folder:        stmt.ColumnText(9),
+		Rating:        stmt.ColumnFloat(10),
+		ShutterSpeed:  stmt.ColumnText(11),
+		Aperture:      stmt.ColumnFloat(12),
+		Iso:           stmt.ColumnFloat(13),
+		Lens:          stmt.ColumnText(14),
+		Camera:        stmt.ColumnText(15),
+		Focallength:   stmt.ColumnFloat(16),
+		Altitude:      stmt.ColumnFloat(17),
+		Latitude:      stmt.ColumnFloat(18),
+		Longitude:     stmt.ColumnFloat(19),
+		Mediatype:     stmt.ColumnText(20),
+		Focusdistance: stmt.ColumnFloat(21),
+		Focallength35: stmt.ColumnFloat(22),
+		Color:         stmt.ColumnText(23),
+		Location:      stmt.ColumnText(24),
+		Description:   stmt.ColumnText(25),
+		Title:         stmt.ColumnText(26),
+		Software:      stmt.ColumnText(27),
+		Offset:        stmt.ColumnFloat(28),
+		Rotation:      stmt.ColumnFloat(29),
+		Checksum:      stmt.ColumnText(30),
+		Size:          stmt.ColumnInt64(31),
+		MotionOffset:  stmt.ColumnInt64(32),
+		MotionLength:  stmt.ColumnInt64(33),
+		PHash:         uint64(stmt.ColumnInt64(34)),
+		PHashed:       stmt.ColumnType(34) != sqlite.TypeNull,
+		Library:       stmt.ColumnText(35),
+		Container:     stmt.ColumnText(36),
+		VideoCodec:    stmt.ColumnText(37),
+		AudioCodec:    stmt.ColumnText(38),
+		Duration:      stmt.ColumnFloat(39),
+		FrameRate:     stmt.ColumnFloat(40),
+		Bitrate:       stmt.ColumnInt64(41),
+		HasAudio:      stmt.ColumnBool(42),
+	}
+}
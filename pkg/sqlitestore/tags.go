@@ -0,0 +1,121 @@
+package sqlitestore
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/robbymilo/rgallery/pkg/hash"
+	"github.com/robbymilo/rgallery/pkg/types"
+)
+
+// GetTag returns media items with a given tag.
+func (s *Store) GetTag(name string, pageSize, offset int, direction string) ([]DatabaseMedia, error) {
+	conn, err := s.db.Take(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("failed to take connection from pool: %w", err)
+	}
+	defer s.db.Put(conn)
+
+	query := fmt.Sprintf(`SELECT DISTINCT hash, i.path, i.subject, i.width, i.height, i.ratio, i.padding, i.date, i.modified, i.folder, i.rating, i.shutterspeed, i.aperture, i.iso, i.lens, i.camera, i.focallength, i.altitude, i.latitude, i.longitude, i.mediatype, i.focusdistance, i.focallength35, i.color, i.location, i.description, i.title, i.software, i.offset, i.rotation, i.checksum, i.size, i.motion_offset, i.motion_length, i.phash, i.library, i.container, i.video_codec, i.audio_codec, i.duration, i.frame_rate, i.bitrate, i.has_audio FROM media i JOIN images_tags i_a ON i.hash = i_a.image_id WHERE i_a.tag_id =? GROUP BY i.date ORDER BY i.date %s LIMIT ? OFFSET ?`, direction)
+
+	stmt, err := s.db.Prepare(conn, query)
+	if err != nil {
+		return nil, fmt.Errorf("error preparing SELECT statement: %v", err)
+	}
+	defer s.db.Release(stmt)
+
+	stmt.BindInt64(1, int64(hash.GetHash(name)))
+	stmt.BindInt64(2, int64(pageSize))
+	stmt.BindInt64(3, int64(offset))
+
+	return scanMediaRows(stmt)
+}
+
+// GetTotalOfTag returns the number of media items with a given tag.
+func (s *Store) GetTotalOfTag(name string) (int, error) {
+	conn, err := s.db.Take(context.Background())
+	if err != nil {
+		return 0, fmt.Errorf("failed to take connection from pool: %w", err)
+	}
+	defer s.db.Put(conn)
+
+	query := `SELECT COUNT(distinct date) FROM media i JOIN images_tags i_a ON i.hash = i_a.image_id WHERE i_a.tag_id =?`
+
+	return s.count(conn, query, hash.GetHash(name))
+}
+
+// GetTagTitle returns the title of a tag given the tag's key.
+func (s *Store) GetTagTitle(key string) (string, error) {
+	conn, err := s.db.Take(context.Background())
+	if err != nil {
+		return "", fmt.Errorf("failed to take connection from pool: %w", err)
+	}
+	defer s.db.Put(conn)
+
+	stmt, err := s.db.Prepare(conn, `SELECT value FROM tags WHERE key =?`)
+	if err != nil {
+		return "", fmt.Errorf("error preparing query: %v", err)
+	}
+	defer s.db.Release(stmt)
+
+	stmt.BindText(1, key)
+
+	var value string
+	hasRow, err := stmt.Step()
+	if err != nil {
+		return "", fmt.Errorf("error executing query: %v", err)
+	}
+	if hasRow {
+		value = stmt.ColumnText(0)
+	}
+
+	return value, nil
+}
+
+// GetTags returns all tags.
+func (s *Store) GetTags(direction string) (types.Subjects, error) {
+	conn, err := s.db.Take(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("failed to take connection from pool: %w", err)
+	}
+	defer s.db.Put(conn)
+
+	query := fmt.Sprintf(`SELECT key, value FROM tags ORDER BY key %s`, direction)
+
+	stmt, err := s.db.Prepare(conn, query)
+	if err != nil {
+		return nil, fmt.Errorf("error preparing SELECT statement: %v", err)
+	}
+	defer s.db.Release(stmt)
+
+	var result types.Subjects
+	for {
+		hasRow, err := stmt.Step()
+		if err != nil {
+			return nil, fmt.Errorf("error stepping through result set: %v", err)
+		}
+		if !hasRow {
+			break
+		}
+
+		result = append(
+			result,
+			types.Subject{
+				Key:   stmt.ColumnText(0),
+				Value: stmt.ColumnText(1),
+			})
+	}
+
+	return result, nil
+}
+
+// GetTotalTags returns the number of tags.
+func (s *Store) GetTotalTags() (int, error) {
+	conn, err := s.db.Take(context.Background())
+	if err != nil {
+		return 0, fmt.Errorf("failed to take connection from pool: %w", err)
+	}
+	defer s.db.Put(conn)
+
+	return s.count(conn, `SELECT COUNT(*) FROM tags`)
+}
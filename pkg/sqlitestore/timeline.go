@@ -0,0 +1,326 @@
+package sqlitestore
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/robbymilo/rgallery/pkg/types"
+	"zombiezen.com/go/sqlite"
+)
+
+// GetTimeline returns a page of media items with the total and, for the first page, the number of items per day.
+func (s *Store) GetTimeline(params *FilterParams, c Conf) (*types.TimelineResponse, error) {
+	conn, err := s.db.Take(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("failed to take connection from pool: %w", err)
+	}
+	defer s.db.Put(conn)
+
+	response := &types.TimelineResponse{
+		Timeline: []types.TimelineItem{},
+		Photos:   []types.Photo{},
+	}
+	response.Meta.PageSize = params.PageSize
+
+	total, err := s.fetchTotalCount(conn, params, c)
+	if err != nil {
+		return nil, err
+	}
+	response.Meta.Total = total
+
+	// calculate histogram for all items when cursor is 0
+	if params.Cursor == 0 {
+		timeline, err := s.fetchTimelineStats(conn, params, c)
+		if err != nil {
+			return nil, err
+		}
+		if timeline != nil {
+			response.Timeline = timeline
+		}
+	}
+
+	photos, err := s.fetchPhotos(conn, params, c)
+	if err != nil {
+		return nil, err
+	}
+	response.Photos = photos
+
+	// 4. Next Cursor Logic
+	if len(photos) >= params.PageSize {
+		next := params.Cursor + params.PageSize
+		if next < total {
+			response.Meta.NextCursor = strconv.Itoa(next)
+		}
+	}
+
+	return response, nil
+}
+
+func (s *Store) fetchTotalCount(conn *sqlite.Conn, params *FilterParams, c Conf) (int, error) {
+	baseQuery, args, err := buildBaseQuery(params, c)
+	if err != nil {
+		return 0, err
+	}
+
+	// Count unique timestamps
+	query := fmt.Sprintf("SELECT COUNT(DISTINCT m.date) %s", baseQuery)
+
+	stmt, err := s.db.Prepare(conn, query)
+	if err != nil {
+		return 0, fmt.Errorf("prepare count: %w (query: %s)", err, query)
+	}
+	defer s.db.Release(stmt)
+
+	bindArgs(stmt, args)
+
+	hasRow, err := stmt.Step()
+	if err != nil {
+		return 0, err
+	}
+	if hasRow {
+		return int(stmt.ColumnInt64(0)), nil
+	}
+	return 0, nil
+}
+
+func (s *Store) fetchTimelineStats(conn *sqlite.Conn, params *FilterParams, c Conf) ([]types.TimelineItem, error) {
+	baseQuery, args, err := buildBaseQuery(params, c)
+	if err != nil {
+		return nil, err
+	}
+
+	query := fmt.Sprintf(`
+		SELECT substr(m.date, 1, 10) as day, COUNT(DISTINCT m.date)
+		%s
+		GROUP BY day
+		ORDER BY day DESC`, baseQuery)
+
+	stmt, err := s.db.Prepare(conn, query)
+	if err != nil {
+		return nil, fmt.Errorf("prepare timeline: %w", err)
+	}
+	defer s.db.Release(stmt)
+
+	bindArgs(stmt, args)
+
+	results := make([]types.TimelineItem, 0)
+	for {
+		hasRow, err := stmt.Step()
+		if err != nil {
+			return nil, err
+		}
+		if !hasRow {
+			break
+		}
+		results = append(results, types.TimelineItem{
+			Date:  stmt.ColumnText(0),
+			Count: int(stmt.ColumnInt64(1)),
+		})
+	}
+	return results, nil
+}
+
+func (s *Store) fetchPhotos(conn *sqlite.Conn, params *FilterParams, c Conf) ([]types.Photo, error) {
+	baseQuery, args, err := buildBaseQuery(params, c)
+	if err != nil {
+		return nil, err
+	}
+
+	// Fetch specific page, grouping by date to deduplicate exact timestamps.
+	query := fmt.Sprintf(`
+		SELECT DISTINCT
+			m.hash,
+			m.width,
+			m.height,
+			m.color,
+			m.date,
+			m.mediatype,
+			m.container,
+			m.video_codec,
+			m.audio_codec,
+			m.has_audio
+
+		%s
+		GROUP BY m.date
+		ORDER BY m.%s %s
+		LIMIT ? OFFSET ?`,
+		baseQuery,
+		params.OrderBy, params.Direction,
+	)
+
+	stmt, err := s.db.Prepare(conn, query)
+	if err != nil {
+		return nil, fmt.Errorf("prepare photos: %w", err)
+	}
+	defer s.db.Release(stmt)
+
+	bindArgs(stmt, append(args, params.PageSize, params.Cursor))
+
+	results := make([]types.Photo, 0)
+	for {
+		hasRow, err := stmt.Step()
+		if err != nil {
+			return nil, err
+		}
+		if !hasRow {
+			break
+		}
+
+		rawDate := stmt.ColumnText(4)
+		shortDate := rawDate
+		if len(rawDate) >= 10 {
+			shortDate = rawDate[:10]
+		}
+
+		photo := types.Photo{
+			Id: uint64(stmt.ColumnInt64(0)),
+			W:  int(stmt.ColumnInt64(1)),
+			H:  int(stmt.ColumnInt64(2)),
+			C:  stmt.ColumnText(3),
+			D:  shortDate,
+		}
+
+		t := stmt.ColumnText(5)
+		if t == "video" {
+			photo.T = "video"
+			photo.Container = stmt.ColumnText(6)
+			photo.VideoCodec = stmt.ColumnText(7)
+			photo.AudioCodec = stmt.ColumnText(8)
+			photo.HasAudio = stmt.ColumnBool(9)
+		}
+
+		results = append(results, photo)
+
+	}
+	return results, nil
+}
+
+func buildBaseQuery(params *FilterParams, c Conf) (string, []interface{}, error) {
+	var sb strings.Builder
+	var args []interface{}
+	var where []string
+
+	term := params.Term
+	if term != "" {
+		// join virtual table to main table
+		sb.WriteString(" FROM media m ")
+		sb.WriteString(" INNER JOIN images_virtual v ON m.hash = v.hash ")
+		where = append(where, "images_virtual MATCH ?")
+		args = append(args, term)
+	} else {
+		sb.WriteString(" FROM media m ")
+	}
+
+	if params.Subject != "" {
+		sb.WriteString(" JOIN images_tags it ON m.hash = it.image_id ")
+		sb.WriteString(" JOIN tags t ON it.tag_id = t.id ")
+		where = append(where, "t.key = ?")
+		args = append(args, params.Subject)
+	}
+
+	// Filters
+	where = append(where, "m.rating >= ?")
+	args = append(args, params.Rating)
+
+	if params.From != "" {
+		where = append(where, "m.date >= ?")
+		args = append(args, params.From)
+	}
+
+	if params.To != "" {
+		where = append(where, "m.date <= ?")
+		args = append(args, params.To)
+	}
+
+	if params.Camera != "" {
+		where = append(where, "m.camera = ?")
+		args = append(args, params.Camera)
+	}
+
+	if params.Lens != "" {
+		lensVariants := []string{params.Lens}
+		for k, v := range c.Aliases.Lenses {
+			if k == params.Lens {
+				for ak, av := range c.Aliases.Lenses {
+					if v == av {
+						lensVariants = append(lensVariants, ak)
+					}
+				}
+				lensVariants = append(lensVariants, v)
+			} else if v == params.Lens {
+				lensVariants = append(lensVariants, k)
+			}
+		}
+
+		placeholders := make([]string, len(lensVariants))
+		for i, v := range lensVariants {
+			placeholders[i] = "?"
+			args = append(args, v)
+		}
+		where = append(where, fmt.Sprintf("m.lens IN (%s)", strings.Join(placeholders, ",")))
+	}
+
+	if params.Folder != "" {
+		where = append(where, "m.folder = ?")
+		args = append(args, params.Folder)
+	}
+
+	if params.MediaType != "" {
+		where = append(where, "m.mediatype = ?")
+		args = append(args, params.MediaType)
+	}
+
+	if params.Software != "" {
+		where = append(where, "m.software = ?")
+		args = append(args, params.Software)
+	}
+
+	if params.FocalLength35 != 0 {
+		where = append(where, "m.focallength35 = ?")
+		args = append(args, params.FocalLength35)
+	}
+
+	if params.Library != "" {
+		where = append(where, "m.library = ?")
+		args = append(args, params.Library)
+	}
+
+	conditions, videoArgs := videoFilters(*params)
+	for _, condition := range conditions {
+		where = append(where, "m."+condition)
+	}
+	args = append(args, videoArgs...)
+
+	if len(where) > 0 {
+		sb.WriteString(" WHERE ")
+		sb.WriteString(strings.Join(where, " AND "))
+	}
+
+	return sb.String(), args, nil
+}
+
+// videoFilters returns the conditions of the container, codec and audio filters on the columns of videos, and their
+// arguments.
+func videoFilters(params FilterParams) ([]string, []interface{}) {
+	var conditions []string
+	var args []interface{}
+
+	if params.Container != "" {
+		conditions = append(conditions, "container = ?")
+		args = append(args, params.Container)
+	}
+
+	if params.VideoCodec != "" {
+		conditions = append(conditions, "video_codec = ?")
+		args = append(args, params.VideoCodec)
+	}
+
+	if params.Audio != "" {
+		conditions = append(conditions, "mediatype = 'video'", "has_audio = ?")
+		args = append(args, params.Audio == "yes")
+	}
+
+	return conditions, args
+}
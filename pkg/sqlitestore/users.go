@@ -0,0 +1,297 @@
+package sqlitestore
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/robbymilo/rgallery/pkg/types"
+	"zombiezen.com/go/sqlite"
+	"zombiezen.com/go/sqlite/sqlitex"
+)
+
+// GetUser returns the stored credentials of a user, or empty credentials if the user does not exist.
+func (s *Store) GetUser(username string) (types.UserCredentials, error) {
+	conn, err := s.db.Take(context.Background())
+	if err != nil {
+		return types.UserCredentials{}, fmt.Errorf("failed to take connection from pool: %w", err)
+	}
+	defer s.db.Put(conn)
+
+	stmt, err := s.db.Prepare(conn, "SELECT username, password, role FROM users WHERE username=?")
+	if err != nil {
+		return types.UserCredentials{}, fmt.Errorf("error preparing SELECT statement: %v", err)
+	}
+	defer s.db.Release(stmt)
+
+	stmt.BindText(1, username)
+
+	var storedCreds types.UserCredentials
+	hasRow, err := stmt.Step()
+	if err != nil {
+		return types.UserCredentials{}, fmt.Errorf("error executing query: %v", err)
+	}
+	if hasRow {
+		storedCreds = types.UserCredentials{
+			Username: stmt.ColumnText(0),
+			Password: stmt.ColumnText(1),
+			Role:     stmt.ColumnText(2),
+		}
+	}
+
+	return storedCreds, nil
+}
+
+// ListUsers returns all users.
+func (s *Store) ListUsers() ([]types.User, error) {
+	conn, err := s.db.Take(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("failed to take connection from pool: %w", err)
+	}
+	defer s.db.Put(conn)
+
+	stmt, err := s.db.Prepare(conn, "SELECT username, role FROM users")
+	if err != nil {
+		return nil, fmt.Errorf("error preparing SELECT statement: %v", err)
+	}
+	defer s.db.Release(stmt)
+
+	users := make([]types.User, 0)
+	for {
+		hasRow, err := stmt.Step()
+		if err != nil {
+			return nil, fmt.Errorf("error stepping through result set: %v", err)
+		}
+		if !hasRow {
+			break
+		}
+
+		users = append(users, types.User{
+			Username: stmt.ColumnText(0),
+			Role:     stmt.ColumnText(1),
+		})
+	}
+
+	return users, nil
+}
+
+// HasUsers tests if any user exists.
+func (s *Store) HasUsers() (bool, error) {
+	conn, err := s.db.Take(context.Background())
+	if err != nil {
+		return false, fmt.Errorf("failed to take connection from pool: %w", err)
+	}
+	defer s.db.Put(conn)
+
+	stmt, err := s.db.Prepare(conn, "SELECT 1 FROM users LIMIT 1")
+	if err != nil {
+		return false, fmt.Errorf("error preparing select statement: %w", err)
+	}
+	defer s.db.Release(stmt)
+
+	hasRow, err := stmt.Step()
+	if err != nil {
+		return false, fmt.Errorf("error checking existing users: %w", err)
+	}
+
+	return hasRow, nil
+}
+
+// AddUser adds a user whose password is already hashed.
+func (s *Store) AddUser(creds types.UserCredentials) error {
+	conn, err := s.db.TakeWriter(context.Background())
+	if err != nil {
+		return fmt.Errorf("error opening sqlite db: %w", err)
+	}
+	defer s.db.Put(conn)
+
+	// check for existing username
+	stmt, err := s.db.Prepare(conn, "SELECT 1 FROM users WHERE username = ?")
+	if err != nil {
+		return fmt.Errorf("error preparing select statement: %w", err)
+	}
+	defer s.db.Release(stmt)
+
+	stmt.BindText(1, creds.Username)
+
+	if hasRow, err := stmt.Step(); err != nil {
+		return fmt.Errorf("error checking for existing user: %w", err)
+	} else if hasRow {
+		return types.ErrUserExists
+	}
+
+	insertStmt, err := s.db.Prepare(conn, "INSERT INTO users (username, password, role) VALUES (?, ?, ?)")
+	if err != nil {
+		return fmt.Errorf("error preparing insert statement: %w", err)
+	}
+	defer s.db.Release(insertStmt)
+
+	insertStmt.BindText(1, creds.Username)
+	insertStmt.BindText(2, creds.Password)
+	insertStmt.BindText(3, creds.Role)
+
+	if _, err := insertStmt.Step(); err != nil {
+		return fmt.Errorf("error inserting user: %v", err)
+	}
+
+	return nil
+}
+
+// RemoveUser removes a user.
+func (s *Store) RemoveUser(username string) error {
+	conn, err := s.db.TakeWriter(context.Background())
+	if err != nil {
+		return fmt.Errorf("failed to take connection from pool: %w", err)
+	}
+	defer s.db.Put(conn)
+
+	stmt, err := s.db.Prepare(conn, "DELETE FROM users WHERE username = ?")
+	if err != nil {
+		return fmt.Errorf("error preparing delete statement: %w", err)
+	}
+	defer s.db.Release(stmt)
+
+	stmt.BindText(1, username)
+
+	if _, err := stmt.Step(); err != nil {
+		return fmt.Errorf("error executing delete statement: %w", err)
+	}
+
+	return nil
+}
+
+// RemoveAllUsers removes every user.
+func (s *Store) RemoveAllUsers() error {
+	conn, err := s.db.TakeWriter(context.Background())
+	if err != nil {
+		return fmt.Errorf("failed to take connection from pool: %w", err)
+	}
+	defer s.db.Put(conn)
+
+	return sqlitex.Execute(conn, "DELETE FROM users;", nil)
+}
+
+// AddKey adds an API key whose key is already hashed.
+func (s *Store) AddKey(name, hashedKey string, created time.Time) error {
+	conn, err := s.db.TakeWriter(context.Background())
+	if err != nil {
+		return fmt.Errorf("error opening sqlite db: %w", err)
+	}
+	defer s.db.Put(conn)
+
+	// check for existing key
+	stmt, err := s.db.Prepare(conn, "SELECT 1 FROM keys WHERE name=?")
+	if err != nil {
+		return fmt.Errorf("error preparing select statement: %w", err)
+	}
+	defer s.db.Release(stmt)
+
+	stmt.BindText(1, name)
+
+	if hasRow, err := stmt.Step(); err != nil {
+		return fmt.Errorf("error checking for existing key: %w", err)
+	} else if hasRow {
+		return types.ErrKeyExists
+	}
+
+	insertStmt, err := s.db.Prepare(conn, "INSERT INTO keys VALUES (?, ?, ?)")
+	if err != nil {
+		return fmt.Errorf("error preparing insert statement: %w", err)
+	}
+	defer s.db.Release(insertStmt)
+
+	insertStmt.BindText(1, name)
+	insertStmt.BindText(2, hashedKey)
+	insertStmt.BindText(3, created.UTC().Format(time.RFC3339))
+
+	if _, err := insertStmt.Step(); err != nil {
+		return fmt.Errorf("error inserting key: %v", err)
+	}
+
+	return nil
+}
+
+// RemoveKey removes an API key.
+func (s *Store) RemoveKey(name string) error {
+	conn, err := s.db.TakeWriter(context.Background())
+	if err != nil {
+		return fmt.Errorf("error opening sqlite db: %w", err)
+	}
+	defer s.db.Put(conn)
+
+	stmt, err := s.db.Prepare(conn, "DELETE FROM keys WHERE name =?")
+	if err != nil {
+		return fmt.Errorf("error preparing delete statement: %w", err)
+	}
+	defer s.db.Release(stmt)
+
+	stmt.BindText(1, name)
+
+	if _, err := stmt.Step(); err != nil {
+		return fmt.Errorf("error executing delete statement: %w", err)
+	}
+
+	return nil
+}
+
+// GetKeyNames returns the name and creation time of every API key.
+func (s *Store) GetKeyNames() ([]types.ApiCredentials, error) {
+	conn, err := s.db.Take(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("failed to take connection from pool: %w", err)
+	}
+	defer s.db.Put(conn)
+
+	stmt, err := s.db.Prepare(conn, "SELECT name, created FROM keys")
+	if err != nil {
+		return nil, fmt.Errorf("error preparing SELECT statement: %v", err)
+	}
+	defer s.db.Release(stmt)
+
+	keys := make([]types.ApiCredentials, 0)
+	for {
+		hasRow, err := stmt.Step()
+		if err != nil {
+			return nil, fmt.Errorf("error stepping through result set: %v", err)
+		}
+		if !hasRow {
+			break
+		}
+
+		t, err := time.Parse("2006-01-02T15:04:05Z", stmt.ColumnText(1))
+		if err != nil {
+			return []types.ApiCredentials{}, fmt.Errorf("error parsing date for key: %v", err)
+		}
+
+		keys = append(keys, types.ApiCredentials{
+			Name:    stmt.ColumnText(0),
+			Created: t,
+		})
+	}
+
+	return keys, nil
+}
+
+// GetAllKeys returns every hashed API key.
+func (s *Store) GetAllKeys() ([]types.ApiCredentials, error) {
+	conn, err := s.db.Take(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("failed to take connection from pool: %w", err)
+	}
+	defer s.db.Put(conn)
+
+	var keys []types.ApiCredentials
+	err = sqlitex.Execute(conn, "SELECT key FROM keys", &sqlitex.ExecOptions{
+		ResultFunc: func(stmt *sqlite.Stmt) error {
+			keys = append(keys, types.ApiCredentials{
+				Key: stmt.ColumnText(0),
+			})
+			return nil
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error stepping through query results: %v", err)
+	}
+
+	return keys, nil
+}
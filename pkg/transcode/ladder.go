@@ -0,0 +1,128 @@
+package transcode
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// originalRendition is the name of the rendition of a video whose size is not known, at its own resolution.
+const originalRendition = "original"
+
+// audioBitrate is the bitrate of the audio of every rendition, in kbps.
+const audioBitrate = 96
+
+// Rendition is one rung of the HLS ladder of a video, transcoded to its own variant playlist.
+type Rendition struct {
+	Name    string // directory of the variant playlist, the short side of the rendition such as 720p
+	Width   int    // zero for originalRendition
+	Height  int
+	Maxrate int // in kbps
+}
+
+// ParseLadder parses the comma separated rungs of the transcode-ladder flag, which are the short side of each
+// rendition in pixels, or "original" for the resolution of the video, which is returned as 0.
+func ParseLadder(ladder string) ([]int, error) {
+	var rungs []int
+	for _, rung := range strings.Split(ladder, ",") {
+		rung = strings.TrimSuffix(strings.TrimSpace(strings.ToLower(rung)), "p")
+		if rung == "" {
+			continue
+		}
+		if rung == originalRendition {
+			rungs = append(rungs, 0)
+			continue
+		}
+
+		size, err := strconv.Atoi(rung)
+		if err != nil || size <= 0 {
+			return nil, fmt.Errorf("invalid transcode ladder rung %s", rung)
+		}
+		rungs = append(rungs, size)
+	}
+
+	return rungs, nil
+}
+
+// Renditions returns the renditions of the ladder a video is transcoded to, smallest first. Rungs larger than the
+// video are left out, and every rendition is scaled down to fit c.TranscodeResolution on its long side. Videos whose
+// size is not known, such as those of live and motion photos, have one rendition at their own resolution.
+func Renditions(media Media, c Conf) ([]Rendition, error) {
+	if media.Type != "video" || media.Width <= 0 || media.Height <= 0 {
+		return []Rendition{{Name: originalRendition, Maxrate: 1500}}, nil
+	}
+
+	rungs, err := ParseLadder(c.TranscodeLadder)
+	if err != nil {
+		return nil, err
+	}
+
+	short, long := min(media.Width, media.Height), max(media.Width, media.Height)
+
+	var renditions []Rendition
+	add := func(rung int) {
+		renditionShort, renditionLong := rung, long*rung/short
+		if c.TranscodeResolution > 0 && renditionLong > c.TranscodeResolution {
+			renditionShort, renditionLong = short*c.TranscodeResolution/long, c.TranscodeResolution
+		}
+		// x264 needs even dimensions
+		renditionShort, renditionLong = renditionShort&^1, renditionLong&^1
+
+		rendition := Rendition{Name: fmt.Sprintf("%dp", renditionShort), Width: renditionLong, Height: renditionShort}
+		if media.Width < media.Height {
+			rendition.Width, rendition.Height = rendition.Height, rendition.Width
+		}
+		// about 0.07 bits per pixel at 30 frames per second
+		rendition.Maxrate = rendition.Width * rendition.Height * 21 / 10000
+
+		if !slices.ContainsFunc(renditions, func(r Rendition) bool { return r.Name == rendition.Name }) {
+			renditions = append(renditions, rendition)
+		}
+	}
+
+	for _, rung := range rungs {
+		if rung == 0 {
+			rung = short
+		}
+		if rung <= short {
+			add(rung)
+		}
+	}
+	// a video smaller than every rung is transcoded at its own resolution
+	if len(renditions) == 0 {
+		add(short)
+	}
+
+	slices.SortFunc(renditions, func(a, b Rendition) int { return a.Height*a.Width - b.Height*b.Width })
+
+	return renditions, nil
+}
+
+// scaleFilter returns the ffmpeg filter that scales a video to a rendition, or an empty string if it keeps the size of
+// the video.
+func scaleFilter(rendition Rendition, c Conf) string {
+	if rendition.Width > 0 {
+		return fmt.Sprintf("scale=%d:%d", rendition.Width, rendition.Height)
+	}
+	if c.TranscodeResolution > 0 {
+		return fmt.Sprintf("scale=w='min(%d,iw)':h='min(%d,ih)':force_original_aspect_ratio=decrease:force_divisible_by=2", c.TranscodeResolution, c.TranscodeResolution)
+	}
+
+	return ""
+}
+
+// MasterPlaylist returns the HLS master playlist of a video, which lists the variant playlist of each rendition.
+func MasterPlaylist(renditions []Rendition) []byte {
+	var sb strings.Builder
+	sb.WriteString("#EXTM3U\n#EXT-X-VERSION:3\n")
+	for _, rendition := range renditions {
+		fmt.Fprintf(&sb, "#EXT-X-STREAM-INF:BANDWIDTH=%d", (rendition.Maxrate+audioBitrate)*1000)
+		if rendition.Width > 0 {
+			fmt.Fprintf(&sb, ",RESOLUTION=%dx%d", rendition.Width, rendition.Height)
+		}
+		fmt.Fprintf(&sb, "\n%s/index.m3u8\n", rendition.Name)
+	}
+
+	return []byte(sb.String())
+}
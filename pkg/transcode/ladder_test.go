@@ -0,0 +1,68 @@
+package transcode
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseLadder(t *testing.T) {
+	rungs, err := ParseLadder("360, 720p,1080,Original")
+	assert.NoError(t, err)
+	assert.Equal(t, []int{360, 720, 1080, 0}, rungs)
+
+	rungs, err = ParseLadder("")
+	assert.NoError(t, err)
+	assert.Empty(t, rungs)
+
+	_, err = ParseLadder("360,hd")
+	assert.Error(t, err)
+
+	_, err = ParseLadder("-720")
+	assert.Error(t, err)
+}
+
+func TestRenditions(t *testing.T) {
+	c := Conf{TranscodeLadder: "360,720,1080,original", TranscodeResolution: 3840}
+
+	// rungs larger than the video are left out, and the original is kept
+	renditions, err := Renditions(Media{Type: "video", Width: 1920, Height: 1080}, c)
+	assert.NoError(t, err)
+	assert.Equal(t, []Rendition{
+		{Name: "360p", Width: 640, Height: 360, Maxrate: 483},
+		{Name: "720p", Width: 1280, Height: 720, Maxrate: 1935},
+		{Name: "1080p", Width: 1920, Height: 1080, Maxrate: 4354},
+	}, renditions)
+
+	// a portrait 4k video capped at 1280 on its long side
+	renditions, err = Renditions(Media{Type: "video", Width: 2160, Height: 3840}, Conf{TranscodeLadder: c.TranscodeLadder, TranscodeResolution: 1280})
+	assert.NoError(t, err)
+	assert.Equal(t, []Rendition{
+		{Name: "360p", Width: 360, Height: 640, Maxrate: 483},
+		{Name: "720p", Width: 720, Height: 1280, Maxrate: 1935},
+	}, renditions)
+
+	// a video smaller than every rung
+	renditions, err = Renditions(Media{Type: "video", Width: 320, Height: 240}, Conf{TranscodeLadder: "360,720"})
+	assert.NoError(t, err)
+	assert.Equal(t, []Rendition{{Name: "240p", Width: 320, Height: 240, Maxrate: 161}}, renditions)
+
+	// the video of a live photo, whose size is not known
+	renditions, err = Renditions(Media{Type: "image", Width: 4032, Height: 3024}, c)
+	assert.NoError(t, err)
+	assert.Equal(t, []Rendition{{Name: "original", Maxrate: 1500}}, renditions)
+}
+
+func TestMasterPlaylist(t *testing.T) {
+	playlist := MasterPlaylist([]Rendition{
+		{Name: "360p", Width: 640, Height: 360, Maxrate: 483},
+		{Name: "original", Maxrate: 1500},
+	})
+	assert.Equal(t, `#EXTM3U
+#EXT-X-VERSION:3
+#EXT-X-STREAM-INF:BANDWIDTH=579000,RESOLUTION=640x360
+360p/index.m3u8
+#EXT-X-STREAM-INF:BANDWIDTH=1596000
+original/index.m3u8
+`, string(playlist))
+}
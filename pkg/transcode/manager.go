@@ -0,0 +1,253 @@
+package transcode
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/robbymilo/rgallery/pkg/config"
+	"github.com/robbymilo/rgallery/pkg/notify"
+	"github.com/robbymilo/rgallery/pkg/types"
+)
+
+// task is the transcode of a rendition of a video, or the creation of its sprite sheet, shared by every request for it while it is queued or running.
+type task struct {
+	media     Media
+	rendition Rendition
+	c         Conf
+	// interactive tasks are waited on by playback, and run before the others
+	interactive bool
+	// percent is the last progress sent, in whole percent
+	percent int
+	done    chan struct{}
+	err     error
+}
+
+// manager runs transcodes on c.TranscodeWorkers workers, started by the first transcode.
+var manager = struct {
+	sync.Mutex
+	once sync.Once
+	wake *sync.Cond
+	// tasks are the queued and running tasks by hash and rendition
+	tasks       map[string]*task
+	interactive []*task
+	background  []*task
+}{tasks: make(map[string]*task)}
+
+func init() {
+	manager.wake = sync.NewCond(&manager.Mutex)
+}
+
+// TranscodeRendition transcodes a rendition of the video of a media item on a transcode worker, unless it is already
+// transcoded, and waits for it. Concurrent requests for the same rendition wait for one transcode. Interactive
+// transcodes, for playback, run before background ones.
+func TranscodeRendition(media Media, rendition Rendition, interactive bool, c Conf) error {
+	return wait(media, rendition, interactive, c)
+}
+
+// CreateSprites saves the sprite sheet and WebVTT thumbnails track of a video on a transcode worker, unless they are
+// already saved, and waits for them.
+func CreateSprites(media Media, interactive bool, c Conf) error {
+	if _, err := SpriteSheet(media, c); err != nil {
+		return err
+	}
+
+	return wait(media, Rendition{Name: spriteRendition}, interactive, c)
+}
+
+// wait queues a task unless its output is already saved, and waits for it.
+func wait(media Media, rendition Rendition, interactive bool, c Conf) error {
+	if _, err := os.Stat(outputPath(media.Hash, rendition.Name, c)); err == nil {
+		return nil
+	}
+
+	t := submit(media, rendition, interactive, c)
+	<-t.done
+
+	return t.err
+}
+
+// outputPath returns the file a task saves last, which is in the cache once the task has finished.
+func outputPath(hash uint64, rendition string, c Conf) string {
+	if rendition == spriteRendition {
+		return CreateThumbnailsVTTFilePath(hash, c)
+	}
+
+	return CreateRenditionIndexFilePath(hash, rendition, c)
+}
+
+// submit queues the transcode of a rendition, or returns the queued or running task for it.
+func submit(media Media, rendition Rendition, interactive bool, c Conf) *task {
+	manager.once.Do(func() {
+		workers := max(c.TranscodeWorkers, 1)
+		for range workers {
+			go work()
+		}
+	})
+
+	key := fmt.Sprintf("%d/%s", media.Hash, rendition.Name)
+
+	manager.Lock()
+	defer manager.Unlock()
+
+	if t, ok := manager.tasks[key]; ok {
+		// playing a video queued for pre-generation moves it ahead of the other background transcodes
+		if interactive && !t.interactive {
+			t.interactive = true
+			if i := slices.Index(manager.background, t); i >= 0 {
+				manager.background = slices.Delete(manager.background, i, i+1)
+				manager.interactive = append(manager.interactive, t)
+			}
+		}
+		return t
+	}
+
+	t := &task{media: media, rendition: rendition, c: c, interactive: interactive, done: make(chan struct{})}
+	manager.tasks[key] = t
+	if interactive {
+		manager.interactive = append(manager.interactive, t)
+	} else {
+		manager.background = append(manager.background, t)
+	}
+	t.send("queued", 0)
+	manager.wake.Signal()
+
+	return t
+}
+
+// work runs queued tasks, interactive ones first.
+func work() {
+	for {
+		manager.Lock()
+		for len(manager.interactive) == 0 && len(manager.background) == 0 {
+			manager.wake.Wait()
+		}
+
+		var t *task
+		if len(manager.interactive) > 0 {
+			t, manager.interactive = manager.interactive[0], manager.interactive[1:]
+		} else {
+			t, manager.background = manager.background[0], manager.background[1:]
+		}
+		manager.Unlock()
+
+		t.err = t.run()
+		if t.err != nil {
+			t.send("failed", float64(t.percent)/100)
+		} else {
+			t.send("completed", 1)
+		}
+
+		manager.Lock()
+		delete(manager.tasks, fmt.Sprintf("%d/%s", t.media.Hash, t.rendition.Name))
+		manager.Unlock()
+		close(t.done)
+	}
+}
+
+// run transcodes the rendition of the task, or creates the sprite sheet, unless it was saved while the task was
+// queued.
+func (t *task) run() (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("recovered from panic while transcoding: %v", r)
+		}
+	}()
+
+	media, c := t.media, t.c
+	output := outputPath(media.Hash, t.rendition.Name, c)
+	if _, err := os.Stat(output); err == nil {
+		return nil
+	}
+
+	original := config.OriginalPath(media.Library, media.Path, c)
+	if media.Type != "video" {
+		alternates, err := c.Store.GetAlternates(media.Hash)
+		if err != nil {
+			return fmt.Errorf("error getting alternates: %v", err)
+		}
+
+		motion := Motion(media, alternates)
+		if motion == nil {
+			return fmt.Errorf("media item %d has no video", media.Hash)
+		}
+
+		original = config.OriginalPath(media.Library, motion.Path, c)
+		if motion.Embedded {
+			original, err = extractMotion(media, c)
+			if err != nil {
+				return err
+			}
+			defer os.Remove(original) //nolint:errcheck
+		}
+	}
+
+	t.send("running", 0)
+	progress := &progressWriter{duration: media.Duration, report: func(progress float64) {
+		if percent := int(progress * 100); percent > t.percent {
+			t.percent = percent
+			t.send("running", progress)
+		}
+	}}
+
+	if t.rendition.Name == spriteRendition {
+		return createSprites(original, media, progress, c)
+	}
+
+	return TranscodeVideo(original, output, t.rendition, progress, c)
+}
+
+// send sends the status and progress of the task to every websocket connection.
+func (t *task) send(status string, progress float64) {
+	err := notify.Broadcast(types.TranscodeProgress{
+		Type:      "transcode",
+		Hash:      t.media.Hash,
+		Rendition: t.rendition.Name,
+		Status:    status,
+		Progress:  progress,
+	})
+	if err != nil {
+		t.c.Logger.Error("error sending transcode progress", "error", err)
+	}
+}
+
+// progressWriter parses the output of ffmpeg -progress, reporting the share of the video transcoded.
+type progressWriter struct {
+	duration float64 // in seconds, or 0 if not known
+	report   func(progress float64)
+	buf      []byte
+}
+
+func (w *progressWriter) Write(p []byte) (int, error) {
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			break
+		}
+		w.parse(string(w.buf[:i]))
+		w.buf = w.buf[i+1:]
+	}
+
+	return len(p), nil
+}
+
+// parse reads a key=value line of progress. out_time_ms is in microseconds like out_time_us, which older versions of
+// ffmpeg do not write.
+func (w *progressWriter) parse(line string) {
+	key, value, _ := strings.Cut(strings.TrimSpace(line), "=")
+	if (key != "out_time_us" && key != "out_time_ms") || w.duration <= 0 {
+		return
+	}
+
+	us, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || us < 0 {
+		return
+	}
+
+	w.report(min(float64(us)/1e6/w.duration, 1))
+}
@@ -0,0 +1,52 @@
+package transcode
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProgressWriter(t *testing.T) {
+	var reported []float64
+	w := &progressWriter{duration: 10, report: func(progress float64) { reported = append(reported, progress) }}
+
+	// lines can be split across writes
+	_, _ = w.Write([]byte("frame=30\nout_time_us=2500000\nout_time_ms=5000"))
+	_, _ = w.Write([]byte("000\nout_time=00:00:05.000000\nout_time_us=N/A\n"))
+	_, _ = w.Write([]byte("out_time_us=12000000\nprogress=end\n"))
+	assert.Equal(t, []float64{0.25, 0.5, 1}, reported)
+
+	// without a duration nothing is reported
+	reported = nil
+	w = &progressWriter{report: func(progress float64) { reported = append(reported, progress) }}
+	_, _ = w.Write([]byte("out_time_us=2500000\n"))
+	assert.Empty(t, reported)
+}
+
+func TestSubmit(t *testing.T) {
+	// keep the workers from starting so the queues can be inspected
+	manager.once.Do(func() {})
+	defer func() {
+		manager.tasks = make(map[string]*task)
+		manager.interactive, manager.background = nil, nil
+	}()
+
+	c := Conf{}
+	low, high := Rendition{Name: "360p"}, Rendition{Name: "720p"}
+
+	scan := submit(Media{Hash: 1}, low, false, c)
+	other := submit(Media{Hash: 2}, low, false, c)
+	play := submit(Media{Hash: 3}, high, true, c)
+	assert.Equal(t, []*task{play}, manager.interactive)
+	assert.Equal(t, []*task{scan, other}, manager.background)
+
+	// a second request for a queued rendition shares its task
+	assert.Same(t, play, submit(Media{Hash: 3}, high, true, c))
+	assert.Len(t, manager.tasks, 3)
+
+	// playing a video queued for pre-generation moves it ahead of the background transcodes
+	assert.Same(t, other, submit(Media{Hash: 2}, low, true, c))
+	assert.True(t, other.interactive)
+	assert.Equal(t, []*task{play, other}, manager.interactive)
+	assert.Equal(t, []*task{scan}, manager.background)
+}
@@ -0,0 +1,151 @@
+package transcode
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/robbymilo/rgallery/pkg/config"
+	ffmpeg "github.com/u2takey/ffmpeg-go"
+)
+
+const (
+	// spriteRendition is the name the sprite sheet of a video is queued under by the transcode workers.
+	spriteRendition = "sprites"
+	// spriteColumns is the number of tiles in each row of a sprite sheet.
+	spriteColumns = 10
+	// spriteTileSize is the long side of each tile in pixels.
+	spriteTileSize = 160
+	// maxSpriteTiles keeps the sprite sheets of long videos to a size browsers decode quickly, by spacing their
+	// tiles further apart than the sprite-interval flag.
+	maxSpriteTiles = 1000
+)
+
+// Sprite is the layout of the sprite sheet of a video, a grid of frames taken every Interval seconds.
+type Sprite struct {
+	Interval   float64 // in seconds
+	Tiles      int
+	Columns    int
+	Rows       int
+	TileWidth  int
+	TileHeight int
+}
+
+// SpriteSheet returns the layout of the sprite sheet of a video. Videos whose duration is not known, and the videos of
+// live and motion photos, have none.
+func SpriteSheet(media Media, c Conf) (Sprite, error) {
+	if media.Type != "video" || media.Duration <= 0 {
+		return Sprite{}, fmt.Errorf("media item %d has no seek preview", media.Hash)
+	}
+
+	if c.SpriteInterval <= 0 {
+		return Sprite{}, fmt.Errorf("invalid sprite interval %d", c.SpriteInterval)
+	}
+	interval := max(float64(c.SpriteInterval), media.Duration/maxSpriteTiles)
+
+	sprite := Sprite{
+		Interval:   interval,
+		Tiles:      int(math.Ceil(media.Duration / interval)),
+		TileWidth:  spriteTileSize,
+		TileHeight: spriteTileSize * 9 / 16,
+	}
+	sprite.Columns = min(sprite.Tiles, spriteColumns)
+	sprite.Rows = (sprite.Tiles + sprite.Columns - 1) / sprite.Columns
+
+	if media.Width > 0 && media.Height > 0 {
+		short := spriteTileSize * min(media.Width, media.Height) / max(media.Width, media.Height) &^ 1
+		sprite.TileWidth, sprite.TileHeight = spriteTileSize, short
+		if media.Width < media.Height {
+			sprite.TileWidth, sprite.TileHeight = short, spriteTileSize
+		}
+	}
+
+	return sprite, nil
+}
+
+// ThumbnailsVTT returns the WebVTT thumbnails track of a sprite sheet, with a cue for each tile that points at its
+// region of sprite.jpg.
+func ThumbnailsVTT(sprite Sprite, duration float64) []byte {
+	var sb strings.Builder
+	sb.WriteString("WEBVTT\n")
+	for i := range sprite.Tiles {
+		start := float64(i) * sprite.Interval
+		end := min(start+sprite.Interval, duration)
+		x, y := i%sprite.Columns*sprite.TileWidth, i/sprite.Columns*sprite.TileHeight
+		fmt.Fprintf(&sb, "\n%s --> %s\nsprite.jpg#xywh=%d,%d,%d,%d\n", vttTime(start), vttTime(end), x, y, sprite.TileWidth, sprite.TileHeight)
+	}
+
+	return []byte(sb.String())
+}
+
+// vttTime formats seconds as a WebVTT timestamp.
+func vttTime(seconds float64) string {
+	ms := int(math.Round(seconds * 1000))
+	return fmt.Sprintf("%02d:%02d:%02d.%03d", ms/3600000, ms/60000%60, ms/1000%60, ms%1000)
+}
+
+// createSprites saves the sprite sheet of a video and its WebVTT thumbnails track, writing the progress of ffmpeg to
+// progress. Both are written to temporary files that are renamed into place, the track last, so a track in the cache
+// always has its complete sprite sheet.
+func createSprites(original string, media Media, progress io.Writer, c Conf) error {
+	sprite, err := SpriteSheet(media, c)
+	if err != nil {
+		return err
+	}
+
+	c.Logger.Info("starting sprite sheet", "file", original)
+
+	track := CreateThumbnailsVTTFilePath(media.Hash, c)
+	err = os.MkdirAll(filepath.Dir(track), os.ModePerm)
+	if err != nil {
+		return err
+	}
+
+	// the image2 muxer picks the format by extension, so the temporary file keeps .jpg
+	sheet := CreateTSFilePath(media.Hash, "sprite.jpg", c)
+	tmp := CreateTSFilePath(media.Hash, "sprite.tmp.jpg", c)
+	err = ffmpeg.Input(original).
+		Output(tmp, ffmpeg.KwArgs{
+			"vf":       fmt.Sprintf("fps=1/%g,scale=%d:%d,tile=%dx%d", sprite.Interval, sprite.TileWidth, sprite.TileHeight, sprite.Columns, sprite.Rows),
+			"frames:v": 1,
+			"q:v":      5,
+			"update":   1,
+		}).
+		GlobalArgs("-progress", "pipe:1", "-nostats").
+		WithOutput(progress).
+		Run()
+	if err != nil {
+		if err := os.Remove(tmp); err != nil && !os.IsNotExist(err) {
+			c.Logger.Error("error removing partial sprite sheet", "error", err)
+		}
+		return fmt.Errorf("error creating sprite sheet: %v", err)
+	}
+
+	err = os.Rename(tmp, sheet)
+	if err != nil {
+		return fmt.Errorf("error saving sprite sheet: %v", err)
+	}
+
+	err = os.WriteFile(track+".tmp", ThumbnailsVTT(sprite, media.Duration), 0644)
+	if err != nil {
+		return fmt.Errorf("error writing thumbnails track: %v", err)
+	}
+
+	err = os.Rename(track+".tmp", track)
+	if err != nil {
+		return fmt.Errorf("error saving thumbnails track: %v", err)
+	}
+
+	c.Logger.Info("finished sprite sheet", "file", original)
+
+	return nil
+}
+
+// CreateThumbnailsVTTFilePath creates a string of the path where the WebVTT thumbnails track of a video lives, next to
+// its sprite sheet and master playlist.
+func CreateThumbnailsVTTFilePath(hash uint64, c Conf) string {
+	return filepath.Join(config.CachePath(c), "video", fmt.Sprint(hash), "thumbnails.vtt")
+}
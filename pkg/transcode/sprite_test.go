@@ -0,0 +1,49 @@
+package transcode
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSpriteSheet(t *testing.T) {
+	c := Conf{SpriteInterval: 10}
+
+	sprite, err := SpriteSheet(Media{Type: "video", Width: 1920, Height: 1080, Duration: 125}, c)
+	assert.NoError(t, err)
+	assert.Equal(t, Sprite{Interval: 10, Tiles: 13, Columns: 10, Rows: 2, TileWidth: 160, TileHeight: 90}, sprite)
+
+	// a short portrait video
+	sprite, err = SpriteSheet(Media{Type: "video", Width: 1080, Height: 1920, Duration: 4.5}, c)
+	assert.NoError(t, err)
+	assert.Equal(t, Sprite{Interval: 10, Tiles: 1, Columns: 1, Rows: 1, TileWidth: 90, TileHeight: 160}, sprite)
+
+	// the tiles of long videos are spaced further apart
+	sprite, err = SpriteSheet(Media{Type: "video", Duration: 4 * 60 * 60}, c)
+	assert.NoError(t, err)
+	assert.Equal(t, 14.4, sprite.Interval)
+	assert.Equal(t, maxSpriteTiles, sprite.Tiles)
+
+	_, err = SpriteSheet(Media{Type: "video"}, c)
+	assert.Error(t, err)
+
+	_, err = SpriteSheet(Media{Type: "image", Duration: 3}, c)
+	assert.Error(t, err)
+}
+
+func TestThumbnailsVTT(t *testing.T) {
+	sprite := Sprite{Interval: 10, Tiles: 3, Columns: 2, Rows: 2, TileWidth: 160, TileHeight: 90}
+	assert.Equal(t, `WEBVTT
+
+00:00:00.000 --> 00:00:10.000
+sprite.jpg#xywh=0,0,160,90
+
+00:00:10.000 --> 00:00:20.000
+sprite.jpg#xywh=160,0,160,90
+
+00:00:20.000 --> 00:00:25.500
+sprite.jpg#xywh=0,90,160,90
+`, string(ThumbnailsVTT(sprite, 25.5)))
+
+	assert.Equal(t, "01:02:03.450", vttTime(3723.45))
+}
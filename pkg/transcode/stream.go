@@ -0,0 +1,78 @@
+package transcode
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/robbymilo/rgallery/pkg/types"
+)
+
+// Video playback policies, set with the video-playback flag.
+const (
+	// PlaybackAuto plays videos browsers support directly, and transcodes the others to HLS.
+	PlaybackAuto = "auto"
+	// PlaybackDirect plays every video directly.
+	PlaybackDirect = "direct"
+	// PlaybackHLS transcodes every video to HLS.
+	PlaybackHLS = "hls"
+)
+
+// directCodecs are the video and audio codecs browsers play natively in each container, with the MIME type the
+// container is served as. QuickTime files are served as MP4, which browsers play when the codecs are supported.
+var directCodecs = map[string]struct {
+	mimeType string
+	video    []string
+	audio    []string
+}{
+	"mp4":  {mimeType: "video/mp4", video: []string{"h264"}, audio: []string{"aac", "mp3"}},
+	"mov":  {mimeType: "video/mp4", video: []string{"h264"}, audio: []string{"aac", "mp3"}},
+	"webm": {mimeType: "video/webm", video: []string{"vp8", "vp9", "av1"}, audio: []string{"opus", "vorbis"}},
+}
+
+// DirectPlay tests if a video is played from the original file rather than transcoded to HLS. Videos scanned before
+// their codecs were stored are transcoded unless the policy is PlaybackDirect.
+func DirectPlay(media Media, c Conf) bool {
+	if media.Type != "video" {
+		return false
+	}
+
+	switch c.VideoPlayback {
+	case PlaybackDirect:
+		return true
+	case PlaybackHLS:
+		return false
+	}
+
+	codecs, ok := directCodecs[media.Container]
+	if !ok || !slices.Contains(codecs.video, media.VideoCodec) {
+		return false
+	}
+
+	return !media.HasAudio || slices.Contains(codecs.audio, media.AudioCodec)
+}
+
+// Stream returns where the video of a media item is streamed from, or nil if it is not a video. Videos whose duration
+// is known have a thumbnails track for previews while seeking.
+func Stream(media Media, c Conf) *types.Stream {
+	if media.Type != "video" {
+		return nil
+	}
+
+	stream := &types.Stream{
+		Src:      fmt.Sprintf("/api/transcode/%d/index.m3u8", media.Hash),
+		MimeType: "application/vnd.apple.mpegurl",
+	}
+	if DirectPlay(media, c) {
+		stream = &types.Stream{
+			Src:      fmt.Sprintf("/api/transcode/%d/original", media.Hash),
+			Direct:   true,
+			MimeType: directCodecs[media.Container].mimeType,
+		}
+	}
+
+	if _, err := SpriteSheet(media, c); err == nil {
+		stream.Thumbnails = fmt.Sprintf("/api/transcode/%d/thumbnails.vtt", media.Hash)
+	}
+
+	return stream
+}
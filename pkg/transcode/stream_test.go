@@ -0,0 +1,56 @@
+package transcode
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDirectPlay(t *testing.T) {
+	tests := []struct {
+		name     string
+		media    Media
+		playback string
+		want     bool
+	}{
+		{"h264 aac mp4", Media{Type: "video", Container: "mp4", VideoCodec: "h264", AudioCodec: "aac", HasAudio: true}, PlaybackAuto, true},
+		{"h264 mov without audio", Media{Type: "video", Container: "mov", VideoCodec: "h264"}, PlaybackAuto, true},
+		{"hevc mov", Media{Type: "video", Container: "mov", VideoCodec: "hevc", AudioCodec: "aac", HasAudio: true}, PlaybackAuto, false},
+		{"h264 mp4 with pcm audio", Media{Type: "video", Container: "mp4", VideoCodec: "h264", AudioCodec: "pcm_s16le", HasAudio: true}, PlaybackAuto, false},
+		{"vp9 opus webm", Media{Type: "video", Container: "webm", VideoCodec: "vp9", AudioCodec: "opus", HasAudio: true}, PlaybackAuto, true},
+		{"h264 matroska", Media{Type: "video", Container: "matroska", VideoCodec: "h264"}, PlaybackAuto, false},
+		{"not probed", Media{Type: "video"}, PlaybackAuto, false},
+		{"forced direct", Media{Type: "video", Container: "mpegts", VideoCodec: "h264"}, PlaybackDirect, true},
+		{"forced hls", Media{Type: "video", Container: "mp4", VideoCodec: "h264"}, PlaybackHLS, false},
+		{"image", Media{Type: "image"}, PlaybackDirect, false},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.want, DirectPlay(test.media, Conf{VideoPlayback: test.playback}), test.name)
+	}
+}
+
+func TestStream(t *testing.T) {
+	c := Conf{VideoPlayback: PlaybackAuto}
+
+	stream := Stream(Media{Hash: 1, Type: "video", Container: "mov", VideoCodec: "h264"}, c)
+	assert.Equal(t, "/api/transcode/1/original", stream.Src)
+	assert.True(t, stream.Direct)
+	assert.Equal(t, "video/mp4", stream.MimeType)
+
+	stream = Stream(Media{Hash: 2, Type: "video", Container: "matroska", VideoCodec: "hevc"}, c)
+	assert.Equal(t, "/api/transcode/2/index.m3u8", stream.Src)
+	assert.False(t, stream.Direct)
+
+	// forced direct play of a container without a MIME type
+	stream = Stream(Media{Hash: 3, Type: "video", Container: "matroska", VideoCodec: "hevc"}, Conf{VideoPlayback: PlaybackDirect})
+	assert.True(t, stream.Direct)
+	assert.Empty(t, stream.MimeType)
+
+	// only videos whose duration is known have a seek preview
+	assert.Empty(t, stream.Thumbnails)
+	stream = Stream(Media{Hash: 5, Type: "video", Duration: 30}, Conf{SpriteInterval: 10})
+	assert.Equal(t, "/api/transcode/5/thumbnails.vtt", stream.Thumbnails)
+
+	assert.Nil(t, Stream(Media{Hash: 4, Type: "image"}, c))
+}
@@ -2,67 +2,184 @@ package transcode
 
 import (
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
-	"sync"
 
 	"github.com/robbymilo/rgallery/pkg/config"
+	"github.com/robbymilo/rgallery/pkg/formats"
 	"github.com/robbymilo/rgallery/pkg/types"
 	ffmpeg "github.com/u2takey/ffmpeg-go"
 )
 
 type Conf = types.Conf
+type Media = types.Media
 
-var transcodeLock sync.Mutex
+// TranscodeVideo splits a rendition of a video into .ts files and saves the output, writing the progress of ffmpeg to
+// progress. ffmpeg writes the variant playlist as it goes, so the rendition is transcoded into a temporary directory
+// that is renamed into place once it has finished, and removed if ffmpeg fails.
+func TranscodeVideo(original, cache string, rendition Rendition, progress io.Writer, c Conf) error {
+	c.Logger.Info("starting transcoding", "file", original, "rendition", rendition.Name)
 
-// TranscodeVideo splits a video into .ts files and saves the output.
-func TranscodeVideo(original, cache string, hash uint32, c Conf) error {
-	c.Logger.Info("starting transcoding", "file", original)
-
-	err := os.MkdirAll(filepath.Dir(cache), os.ModePerm)
+	dir := filepath.Dir(cache)
+	// remove the output of a transcode that was interrupted
+	tmp := dir + ".tmp"
+	err := os.RemoveAll(tmp)
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(tmp, os.ModePerm)
 	if err != nil {
 		return err
 	}
 
+	args := ffmpeg.KwArgs{
+		"c:v":           "libx264",
+		"crf":           "23",
+		"preset":        "veryfast",
+		"maxrate":       fmt.Sprintf("%dk", rendition.Maxrate),
+		"bufsize":       fmt.Sprintf("%dk", rendition.Maxrate*2),
+		"c:a":           "aac",
+		"b:a":           fmt.Sprintf("%dk", audioBitrate),
+		"movflags":      "+faststart",
+		"start_number":  0,
+		"hls_time":      1,
+		"hls_list_size": 0,
+		"f":             "hls",
+	}
+	if filter := scaleFilter(rendition, c); filter != "" {
+		args["vf"] = filter
+	}
+
 	err = ffmpeg.Input(original).
-		Output(cache, ffmpeg.KwArgs{
-			"c:v":           "libx264",
-			"crf":           "23",
-			"preset":        "veryfast",
-			"maxrate":       "1500k",
-			"bufsize":       "3000k",
-			"vf":            fmt.Sprintf("scale=-2:%d", c.TranscodeResolution),
-			"c:a":           "aac",
-			"b:a":           "96k",
-			"movflags":      "+faststart",
-			"start_number":  0,
-			"hls_time":      1,
-			"hls_list_size": 0,
-			"f":             "hls",
-		}).
+		Output(filepath.Join(tmp, filepath.Base(cache)), args).
+		GlobalArgs("-progress", "pipe:1", "-nostats").
+		WithOutput(progress).
 		Run()
+	if err != nil {
+		if err := os.RemoveAll(tmp); err != nil {
+			c.Logger.Error("error removing partial transcode", "error", err)
+		}
+		return fmt.Errorf("error transcoding video: %v", err)
+	}
+
+	err = os.RemoveAll(dir)
+	if err != nil {
+		return err
+	}
+	err = os.Rename(tmp, dir)
+	if err != nil {
+		return fmt.Errorf("error saving transcode: %v", err)
+	}
+
+	c.Logger.Info("finished transcoding", "file", original, "rendition", rendition.Name)
+
+	return nil
+}
 
-	c.Logger.Info("finished transcoding", "file", original)
+// TranscodeMedia writes the master playlist of the video of a media item, which is either a video or a live or motion
+// photo, and transcodes its smallest rendition in the background. The other renditions are transcoded when they are
+// first played.
+func TranscodeMedia(media Media, c Conf) error {
+	renditions, err := WriteMasterPlaylist(media, c)
+	if err != nil {
+		return err
+	}
 
-	return err
+	return TranscodeRendition(media, renditions[0], false, c)
 }
 
-// TranscodeWithLock ensures only one video is transcoded at a time.
-func TranscodeWithLock(original, cache string, hash uint32, c Conf) error {
-	// Acquire the lock
-	transcodeLock.Lock()
-	defer transcodeLock.Unlock()
+// WriteMasterPlaylist writes the master playlist of the video of a media item to the cache, returning its renditions.
+// Transcodes of a single rendition made by earlier versions of rgallery are removed.
+func WriteMasterPlaylist(media Media, c Conf) ([]Rendition, error) {
+	renditions, err := Renditions(media, c)
+	if err != nil {
+		return nil, err
+	}
+
+	master := CreateHLSIndexFilePath(media.Hash, c)
+	err = os.MkdirAll(filepath.Dir(master), os.ModePerm)
+	if err != nil {
+		return nil, err
+	}
+
+	legacy, _ := filepath.Glob(CreateTSFilePath(media.Hash, "index*", c))
+	for _, file := range legacy {
+		if err := os.Remove(file); err != nil {
+			c.Logger.Error("error removing transcode file", "error", err)
+		}
+	}
+
+	err = os.WriteFile(master, MasterPlaylist(renditions), 0644)
+	if err != nil {
+		return nil, fmt.Errorf("error writing master playlist: %v", err)
+	}
+
+	return renditions, nil
+}
+
+// Motion returns the video of a live or motion photo, either embedded in the photo or an alternate of it, or nil if
+// the media item has none.
+func Motion(media Media, alternates []types.Alternate) *types.Motion {
+	if media.Type == "video" {
+		return nil
+	}
+
+	src := fmt.Sprintf("/api/transcode/%d/index.m3u8", media.Hash)
+	if media.MotionLength > 0 {
+		return &types.Motion{Path: media.Path, Embedded: true, Src: src}
+	}
+
+	for _, alternate := range alternates {
+		if formats.IsVideo(alternate.Path) {
+			return &types.Motion{Path: alternate.Path, Src: src}
+		}
+	}
+
+	return nil
+}
+
+// extractMotion writes the video embedded in a motion photo to the transcode directory of the photo, returning its
+// path.
+func extractMotion(media Media, c Conf) (string, error) {
+	file, err := os.Open(config.OriginalPath(media.Library, media.Path, c))
+	if err != nil {
+		return "", fmt.Errorf("error opening motion photo: %v", err)
+	}
+	defer file.Close()
+
+	extracted := CreateTSFilePath(media.Hash, "motion.mp4", c)
+	err = os.MkdirAll(filepath.Dir(extracted), os.ModePerm)
+	if err != nil {
+		return "", err
+	}
+
+	out, err := os.Create(extracted)
+	if err != nil {
+		return "", fmt.Errorf("error creating motion photo video: %v", err)
+	}
+	defer out.Close()
+
+	_, err = io.Copy(out, io.NewSectionReader(file, media.MotionOffset, media.MotionLength))
+	if err != nil {
+		return "", fmt.Errorf("error extracting motion photo video: %v", err)
+	}
+
+	return extracted, nil
+}
 
-	// Perform the transcoding
-	return TranscodeVideo(original, cache, hash, c)
+// CreateHLSIndexFilePath creates a string of the path where the HLS master playlist lives.
+func CreateHLSIndexFilePath(hash uint64, c Conf) string {
+	return filepath.Join(config.CachePath(c), "video", fmt.Sprint(hash), "master.m3u8")
 }
 
-// CreateHLSIndexFilePath creates a string of the path where the HLS index file lives.
-func CreateHLSIndexFilePath(hash uint32, c Conf) string {
-	return filepath.Join(config.CachePath(c), "video", fmt.Sprint(hash), "index.m3u8")
+// CreateRenditionIndexFilePath creates a string of the path where the variant playlist of a rendition lives, next to
+// its TS files.
+func CreateRenditionIndexFilePath(hash uint64, rendition string, c Conf) string {
+	return filepath.Join(config.CachePath(c), "video", fmt.Sprint(hash), rendition, "index.m3u8")
 }
 
 // CreateTSFilePath creates a string of the path where the TS files live.
-func CreateTSFilePath(hash uint32, file string, c Conf) string {
+func CreateTSFilePath(hash uint64, file string, c Conf) string {
 	return filepath.Join(config.CachePath(c), "video", fmt.Sprint(hash), file)
 }
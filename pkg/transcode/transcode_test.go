@@ -0,0 +1,51 @@
+package transcode
+
+import (
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeFFmpeg puts an ffmpeg on PATH that writes the start of an HLS playlist to its output, and finishes it unless
+// FAKE_FFMPEG_FAIL is set.
+func fakeFFmpeg(t *testing.T) {
+	dir := t.TempDir()
+	script := `#!/bin/sh
+prev=""
+for a in "$@"; do
+  [ "$a" = "-progress" ] && out="$prev"
+  prev="$a"
+done
+printf '#EXTM3U\n' > "$out"
+[ -n "$FAKE_FFMPEG_FAIL" ] && exit 1
+printf '#EXT-X-ENDLIST\n' >> "$out"
+`
+	if err := os.WriteFile(filepath.Join(dir, "ffmpeg"), []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir)
+}
+
+func TestTranscodeVideo(t *testing.T) {
+	fakeFFmpeg(t)
+	c := Conf{Cache: t.TempDir(), Logger: slog.New(slog.NewTextHandler(io.Discard, nil))}
+	rendition := Rendition{Name: "360p", Width: 640, Height: 360, Maxrate: 483}
+	index := CreateRenditionIndexFilePath(1, rendition.Name, c)
+
+	// a failed transcode leaves no playlist behind
+	t.Setenv("FAKE_FFMPEG_FAIL", "1")
+	assert.Error(t, TranscodeVideo("video.mkv", index, rendition, io.Discard, c))
+	assert.NoFileExists(t, index)
+	assert.NoDirExists(t, filepath.Dir(index)+".tmp")
+
+	t.Setenv("FAKE_FFMPEG_FAIL", "")
+	assert.NoError(t, TranscodeVideo("video.mkv", index, rendition, io.Discard, c))
+	playlist, err := os.ReadFile(index)
+	assert.NoError(t, err)
+	assert.Equal(t, "#EXTM3U\n#EXT-X-ENDLIST\n", string(playlist))
+	assert.NoDirExists(t, filepath.Dir(index)+".tmp")
+}
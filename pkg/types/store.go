@@ -0,0 +1,166 @@
+package types
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	ErrUserExists  = errors.New("user exists")
+	ErrKeyExists   = errors.New("key exists")
+	ErrJobNotFound = errors.New("job not found")
+)
+
+// Store reads and writes the library, users, API keys, notifications and jobs in a database backend.
+// Media items are returned as they are stored and are parsed into Media by the queries package.
+// It is safe for use by multiple goroutines concurrently.
+type Store interface {
+	// media
+	GetTimeline(params *FilterParams, c Conf) (*TimelineResponse, error)
+	GetNext(date time.Time, hash uint64, total int, params FilterParams, previous []PrevNext, c Conf) ([]PrevNext, error)
+	GetPrevious(date time.Time, hash uint64, params FilterParams, c Conf) ([]PrevNext, error)
+	GetSingleMediaItem(hash uint64) (DatabaseMedia, error)
+	GetMediaItems(offset int, direction string, total int) ([]DatabaseMedia, error)
+	GetTotalMediaItems(rating int, from, to string) (int, error)
+	GetFavorites(pageSize, offset, rating int, direction string) ([]DatabaseMedia, error)
+	GetTotalFavorites(rating int) (int, error)
+	GetMemories(days []string) ([]DatabaseMedia, error)
+	GetMapItems() ([]MapItem, error)
+	GetGear(column string) (GearItems, error)
+
+	// folders and tags, in a library or in every library if library is empty
+	GetFolder(group, name, library string, pageSize, offset int, direction string) ([]DatabaseMedia, error)
+	GetTotalOfFolder(group, name, library string) (int, error)
+	GetFolders(direction, library string, pageSize, offset int) ([]Directory, error)
+	GetTotalFolders(library string) (int, error)
+	GetTag(name string, pageSize, offset int, direction string) ([]DatabaseMedia, error)
+	GetTotalOfTag(name string) (int, error)
+	GetTagTitle(key string) (string, error)
+	GetTags(direction string) (Subjects, error)
+	GetTotalTags() (int, error)
+
+	// scanning
+	InsertMediaItem(media Media) error
+	// InsertMediaItems inserts several media items in a single transaction.
+	InsertMediaItems(media []Media) error
+	DeleteMediaItem(media Media) error
+	MoveMediaItem(media Media, path, folder string, modified time.Time, size int64) error
+	// UpdateMediaItem replaces a media item and its tags in a single transaction.
+	UpdateMediaItem(media Media) error
+	TrackScanError(library, path string, modified time.Time, message string) error
+	// GetScanErrors returns the modification time of the files of a library that failed to scan, by path.
+	GetScanErrors(library string) (map[string]time.Time, error)
+	// GetAlternates returns the alternates of a media item, ordered by path.
+	GetAlternates(hash uint64) ([]Alternate, error)
+	// SetAlternates replaces the alternates in folders of a library, or every alternate of the library if folders is
+	// nil, and removes alternates of media items that no longer exist.
+	SetAlternates(library string, folders []string, alternates []Alternate) error
+
+	// users and API keys
+	GetUser(username string) (UserCredentials, error)
+	ListUsers() ([]User, error)
+	HasUsers() (bool, error)
+	// AddUser adds a user with an already hashed password, returning ErrUserExists if the username is taken.
+	AddUser(creds UserCredentials) error
+	RemoveUser(username string) error
+	RemoveAllUsers() error
+	// AddKey adds an API key with an already hashed key, returning ErrKeyExists if the name is taken.
+	AddKey(name, hashedKey string, created time.Time) error
+	RemoveKey(name string) error
+	GetKeyNames() ([]ApiCredentials, error)
+	GetAllKeys() ([]ApiCredentials, error)
+
+	// notifications
+	AddNotification(message, createdAt string) (int64, error)
+	GetNotifications(username string) ([]Notification, error)
+	MarkNotificationRead(id int64, username string) error
+	ClearAllNotifications(username string) error
+
+	// jobs
+	AddJob(job Job) (int64, error)
+	// UpdateJob saves the status, progress, cursor, error and timestamps of a job.
+	UpdateJob(job Job) error
+	// StartJob marks a queued job as running by owner, returning false if it is no longer queued.
+	StartJob(id int64, owner, startedAt string) (bool, error)
+	// HeartbeatJobs records that the running jobs of owner are still running.
+	HeartbeatJobs(owner, at string) error
+	// RequeueStaleJobs queues the running jobs whose last heartbeat is before a time again, returning them. Their
+	// Total is reset to Processed, as files that were queued but not finished are queued again when they resume.
+	RequeueStaleJobs(before string) ([]Job, error)
+	// CancelQueuedJob marks a queued job as canceled, returning false if it is no longer queued.
+	CancelQueuedJob(id int64, finishedAt string) (bool, error)
+	// GetJob returns ErrJobNotFound if there is no job with the id.
+	GetJob(id int64) (Job, error)
+	// GetJobs returns the most recent jobs, newest first.
+	GetJobs(limit int) ([]Job, error)
+	// GetJobsWithStatus returns the jobs with a status, oldest first.
+	GetJobsWithStatus(status string) ([]Job, error)
+	// GetLastJob returns the most recently started job of a type and scope, or ErrJobNotFound.
+	GetLastJob(jobType, scope string) (Job, error)
+
+	// Optimize updates the query planner statistics and reclaims unused space.
+	Optimize() error
+
+	Close() error
+}
+
+type TimelineResponse struct {
+	Meta     TimelineMeta   `json:"meta"`
+	Timeline []TimelineItem `json:"timeline"`
+	Photos   []Photo        `json:"photos"`
+}
+
+type TimelineMeta struct {
+	Total      int    `json:"total"`
+	PageSize   int    `json:"pagesize"`
+	NextCursor string `json:"nextCursor"`
+}
+
+type TimelineItem struct {
+	Date  string `json:"date"`
+	Count int    `json:"count"`
+}
+
+type Photo struct {
+	Id uint64 `json:"id"`
+	W  int    `json:"w"`
+	H  int    `json:"h"`
+	C  string `json:"c"`
+	T  string `json:"t,omitempty"`
+	D  string `json:"d"` // YYYY-MM-DD
+	// S is the src of the stream of a video, decided by its codecs, which are not sent
+	S          string `json:"s,omitempty"`
+	Container  string `json:"-"`
+	VideoCodec string `json:"-"`
+	AudioCodec string `json:"-"`
+	HasAudio   bool   `json:"-"`
+}
+
+type Notification struct {
+	ID        int64  `json:"id"`
+	Username  string `json:"username,omitempty"`
+	Message   string `json:"message"`
+	IsRead    bool   `json:"is_read"`
+	CreatedAt string `json:"created_at"`
+}
+
+// Job is a scan, thumbnail scan, transcode or maintenance task that is queued and run in the background.
+type Job struct {
+	ID     int64  `json:"id"`
+	Type   string `json:"type"`
+	Scope  string `json:"scope"`
+	Status string `json:"status"`
+	// Total is the number of files queued by the job so far, Processed and Failed the number finished.
+	Total     int `json:"total"`
+	Processed int `json:"processed"`
+	Failed    int `json:"failed"`
+	// Cursor is the last path a resumed job can skip to.
+	Cursor     string `json:"cursor,omitempty"`
+	Error      string `json:"error,omitempty"`
+	CreatedAt  string `json:"created_at"`
+	StartedAt  string `json:"started_at,omitempty"`
+	FinishedAt string `json:"finished_at,omitempty"`
+	// Owner identifies the process running the job, which records a Heartbeat while it runs.
+	Owner     string `json:"owner,omitempty"`
+	Heartbeat string `json:"heartbeat,omitempty"`
+}
@@ -14,20 +14,75 @@ type Conf struct {
 	Data                string
 	Quality             int
 	TranscodeResolution int
-	PreGenerateThumb    bool
-	ResizeService       string
-	LocationService     string
-	LocationDataset     string
-	Logger              *slog.Logger
-	TileServer          string
-	SessionLength       int
-	IncludeOriginals    bool
-	Aliases             struct {
+	// TranscodeLadder is the comma separated short sides of the HLS renditions videos are transcoded to, or
+	// "original" for the resolution of the video.
+	TranscodeLadder string
+	// TranscodeWorkers is the number of renditions transcoded at once.
+	TranscodeWorkers int
+	PreGenerateThumb bool
+	// PreGenerateSprites is whether the sprite sheets of videos are created during scan rather than when first played.
+	PreGenerateSprites bool
+	// SpriteInterval is the seconds between the frames of the sprite sheet of a video.
+	SpriteInterval int
+	ResizeService  string
+	// VideoPlayback is whether videos are played from the original file or transcoded to HLS, either "auto" to
+	// decide by the codecs of each video, "direct" or "hls".
+	VideoPlayback string
+	// RawConverter is the command RAW files are converted to JPEG with, in place of their embedded preview.
+	RawConverter string
+	// WriteMetadata is where edited metadata is written, either "sidecar" for an XMP sidecar or "file" for the file itself.
+	WriteMetadata    string
+	LocationService  string
+	LocationDataset  string
+	Logger           *slog.Logger
+	TileServer       string
+	SessionLength    int
+	IncludeOriginals bool
+	Aliases          struct {
 		Lenses map[string]string `yaml:"lenses"`
 	} `yaml:"aliases"`
-	CustomHTML template.HTML `yaml:"custom_html"`
-	Meta       Meta
-	Memories   bool
+	CustomHTML  template.HTML `yaml:"custom_html"`
+	Meta        Meta
+	Memories    bool
+	DBBackend   string
+	PostgresURL string
+	Store       Store `yaml:"-"`
+	// Watch enables scanning changed files as they are written to the media directory.
+	Watch             bool
+	WatchDebounce     time.Duration
+	ReconcileInterval time.Duration
+	// ScanWorkers is the number of files read and thumbnailed at once during a scan.
+	ScanWorkers int
+	// Schedule is when scans and maintenance tasks are queued, read from the config file.
+	Schedule Schedule `yaml:"schedule"`
+	// Ignore are patterns of paths in the media directory that scans skip, in the format of a .gitignore file.
+	Ignore []string `yaml:"ignore"`
+	// Libraries are the media directories, read from the config file. Without any, the media directory is the only
+	// library.
+	Libraries []Library `yaml:"libraries"`
+	// Library is the name of the library a scan is run for, whose root is the media directory of a Conf returned by
+	// config.ForLibrary.
+	Library string `yaml:"-"`
+}
+
+// Library is a media directory with its own ignore patterns and scan schedule. Paths of its media items are relative
+// to its root.
+type Library struct {
+	Name   string   `yaml:"name" json:"name"`
+	Path   string   `yaml:"path" json:"-"`
+	Ignore []string `yaml:"ignore" json:"-"`
+	// Schedule is when a default scan of the library is queued, as a cron expression.
+	Schedule string `yaml:"schedule" json:"-"`
+}
+
+// Schedule has a cron expression for each task queued on a schedule. Tasks with an empty expression are not
+// scheduled.
+type Schedule struct {
+	Scan          string `yaml:"scan"`
+	MetadataScan  string `yaml:"metadata_scan"`
+	ThumbnailScan string `yaml:"thumbnail_scan"`
+	CacheCleanup  string `yaml:"cache_cleanup"`
+	DBOptimize    string `yaml:"db_optimize"`
 }
 
 type MediaItems []Media
@@ -35,7 +90,7 @@ type MediaItems []Media
 type Media struct {
 	Path          string          `json:"path"`
 	Subject       Subjects        `json:"subjects"`
-	Hash          uint32          `json:"hash"`
+	Hash          uint64          `json:"hash"`
 	Width         int             `json:"width"`
 	Height        int             `json:"height"`
 	Ratio         float32         `json:"ratio"`
@@ -64,10 +119,63 @@ type Media struct {
 	Software      string          `json:"software"`
 	Offset        float64         `json:"offset"`
 	Rotation      float64         `json:"-"` // only used for HEIC thumbnail creation
+	Checksum      string          `json:"-"`
+	Size          int64           `json:"-"`
+	MotionOffset  int64           `json:"-"`                    // offset of the video embedded in a motion photo
+	MotionLength  int64           `json:"-"`                    // length of the video embedded in a motion photo, 0 if it has none
+	PHash         uint64          `json:"-"`                    // perceptual hash of an image
+	PHashed       bool            `json:"-"`                    // whether PHash is set, which it is not for videos, as 0 is a valid hash
+	Library       string          `json:"library"`              // name of the library the path is relative to
+	Container     string          `json:"container,omitempty"`  // container of a video, such as mp4 or matroska
+	VideoCodec    string          `json:"videoCodec,omitempty"` // codec of the video stream of a video
+	AudioCodec    string          `json:"audioCodec,omitempty"` // codec of the audio stream of a video, empty if it has none
+	Duration      float64         `json:"duration,omitempty"`   // duration of a video in seconds
+	FrameRate     float64         `json:"frameRate,omitempty"`  // frames per second of a video
+	Bitrate       int64           `json:"bitrate,omitempty"`    // bits per second of a video
+	HasAudio      bool            `json:"hasAudio,omitempty"`   // whether a video has an audio stream
+}
+
+// Motion is the video of a live or motion photo, either a video file with the same name as the photo, or a video
+// embedded in the photo itself.
+type Motion struct {
+	Path     string `json:"path"`
+	Embedded bool   `json:"embedded"`
+	Src      string `json:"src"` // HLS index of the transcoded video
+}
+
+// TranscodeProgress is sent over the websocket as the transcode of a rendition of a video is queued, runs and
+// finishes.
+type TranscodeProgress struct {
+	Type      string  `json:"type"` // always "transcode"
+	Hash      uint64  `json:"hash"`
+	Rendition string  `json:"rendition"`
+	Status    string  `json:"status"`   // queued, running, completed or failed
+	Progress  float64 `json:"progress"` // share of the video transcoded, from 0 to 1, or 0 if its duration is not known
+}
+
+// Stream is where the video of a media item is streamed from, either the original file or the HLS index of its
+// transcode.
+type Stream struct {
+	Src      string `json:"src"`
+	Direct   bool   `json:"direct"`             // Src is the original file, which supports range requests
+	MimeType string `json:"mimeType,omitempty"` // empty if a directly played container has no known MIME type
+	// Thumbnails is the WebVTT thumbnails track of the sprite sheet of the video, for previews while seeking
+	Thumbnails string `json:"thumbnails,omitempty"`
+}
+
+// Alternate is another rendition of a media item in the same folder with the same name, such as the RAW file of a
+// JPEG. It can be downloaded but is not shown as a media item of its own.
+type Alternate struct {
+	Hash     uint64    `json:"-"` // hash of the media item it is an alternate of
+	Library  string    `json:"-"`
+	Path     string    `json:"path"`
+	Folder   string    `json:"-"`
+	Size     int64     `json:"size"`
+	Modified time.Time `json:"modified"`
 }
 
 type DatabaseMedia struct {
-	Hash          uint32
+	Hash          uint64
 	Path          string
 	Subject       string
 	Width         int
@@ -97,6 +205,20 @@ type DatabaseMedia struct {
 	Software      string
 	Offset        float64
 	Rotation      float64 // only used for HEIC thumbnail creation
+	Checksum      string
+	Size          int64
+	MotionOffset  int64
+	MotionLength  int64
+	PHash         uint64
+	PHashed       bool
+	Library       string
+	Container     string
+	VideoCodec    string
+	AudioCodec    string
+	Duration      float64
+	FrameRate     float64
+	Bitrate       int64
+	HasAudio      bool
 }
 
 type Subjects []Subject
@@ -134,13 +256,48 @@ type Filter struct {
 }
 
 type ResponseMedia struct {
-	Media    Media      `json:"media"`
-	Previous []PrevNext `json:"previous"`
-	Next     []PrevNext `json:"next"`
+	Media      Media       `json:"media"`
+	Alternates []Alternate `json:"alternates,omitempty"`
+	Motion     *Motion     `json:"motion,omitempty"`
+	Stream     *Stream     `json:"stream,omitempty"`
+	Previous   []PrevNext  `json:"previous"`
+	Next       []PrevNext  `json:"next"`
+}
+
+// Duplicate is a media item that looks the same as the other media items of its DuplicateGroup.
+type Duplicate struct {
+	Hash   uint64    `json:"hash"`
+	Path   string    `json:"path"`
+	Width  int       `json:"width"`
+	Height int       `json:"height"`
+	Size   int64     `json:"size"`
+	Date   time.Time `json:"date"`
+}
+
+// DuplicateGroup is a group of media items with perceptual hashes within a distance of each other, largest first.
+type DuplicateGroup struct {
+	Distance int         `json:"distance"` // largest distance of an item from the first item
+	Items    []Duplicate `json:"items"`
+}
+
+type ResponseDuplicates struct {
+	Distance int              `json:"distance"`
+	Groups   []DuplicateGroup `json:"groups"`
+}
+
+// Similar is a media item that looks like another media item.
+type Similar struct {
+	Media
+	Distance int `json:"distance"` // number of bits its perceptual hash differs by
+}
+
+type ResponseSimilar struct {
+	Hash  uint64    `json:"hash"`
+	Items []Similar `json:"items"`
 }
 
 type PrevNext struct {
-	Hash      uint32          `json:"hash"`
+	Hash      uint64          `json:"hash"`
 	Color     string          `json:"color"`
 	Mediatype string          `json:"type"`
 	Path      string          `json:"path"`
@@ -209,7 +366,7 @@ type RawMinimalMedia struct {
 	Width     *int     `json:"width"`
 	Height    *int     `json:"height"`
 	Date      *string  `json:"date"`
-	Hash      *uint32  `json:"hash"`
+	Hash      *uint64  `json:"hash"`
 	Color     *string  `json:"color"`
 	MediaType *string  `json:"mediatype"`
 	Offset    *float64 `json:"-"`
@@ -244,9 +401,18 @@ type ResponseAdmin struct {
 	Users    []User
 	UserName string
 	UserRole string
+	Schedule []ScheduledTask
 	Meta     Meta `json:"-"`
 }
 
+// ScheduledTask is a task queued on a schedule, with when it runs next and the last job that ran it.
+type ScheduledTask struct {
+	Name     string `json:"name"`
+	Schedule string `json:"schedule"`
+	NextRun  string `json:"next_run,omitempty"`
+	LastRun  *Job   `json:"last_run,omitempty"`
+}
+
 type ResponseProfile struct {
 	UserName string `json:"userName"`
 	UserRole string `json:"userRole"`
@@ -276,6 +442,10 @@ type FilterParams struct {
 	Subject       string
 	Software      string
 	FocalLength35 float64
+	Library       string
+	Container     string
+	VideoCodec    string
+	Audio         string // "yes" for videos with audio, "no" for videos without
 }
 
 type Folder struct {
@@ -294,7 +464,7 @@ type TreeNode struct {
 }
 
 type FolderMedia struct {
-	Hash   uint32          `json:"hash"`
+	Hash   uint64          `json:"hash"`
 	Path   string          `json:"path"`
 	Width  int             `json:"width"`
 	Height int             `json:"height"`
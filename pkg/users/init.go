@@ -3,38 +3,17 @@ package users
 import (
 	"fmt"
 
-	"github.com/robbymilo/rgallery/pkg/database"
 	"golang.org/x/crypto/bcrypt"
-	"zombiezen.com/go/sqlite"
 )
 
 // InitUser adds a user with username "admin" and password "admin" if no users exist.
 func InitUser(c Conf) error {
-	conn, err := sqlite.OpenConn(database.NewSqlConnectionString(c), sqlite.OpenReadWrite)
-	if err != nil {
-		return fmt.Errorf("error opening sqlite db: %w", err)
-	}
-	defer func() {
-		if err := conn.Close(); err != nil {
-			c.Logger.Error("conn.Close error", "err", err)
-		}
-	}()
-
 	// check if admin user already exists
-	stmt, err := conn.Prepare("SELECT 1 FROM users LIMIT 1")
+	hasUsers, err := c.Store.HasUsers()
 	if err != nil {
-		return fmt.Errorf("error preparing select statement: %w", err)
+		return err
 	}
-
-	defer func() {
-		if finalizeErr := stmt.Finalize(); finalizeErr != nil {
-			err = fmt.Errorf("error finalizing statement: %v", finalizeErr)
-		}
-	}()
-
-	if hasRow, err := stmt.Step(); err != nil {
-		return fmt.Errorf("error checking existing users: %w", err)
-	} else if hasRow {
+	if hasUsers {
 		return nil
 	}
 
@@ -45,21 +24,12 @@ func InitUser(c Conf) error {
 	}
 
 	// insert "admin" user into the database
-	insertStmt, err := conn.Prepare("INSERT INTO users (username, password, role) VALUES (?, ?, ?)")
+	err = c.Store.AddUser(UserCredentials{
+		Username: "admin",
+		Password: string(hashedPassword),
+		Role:     "admin",
+	})
 	if err != nil {
-		return fmt.Errorf("error preparing insert statement: %w", err)
-	}
-
-	defer func() {
-		if finalizeErr := insertStmt.Finalize(); finalizeErr != nil {
-			err = fmt.Errorf("error finalizing statement: %v", finalizeErr)
-		}
-	}()
-	insertStmt.BindText(1, "admin")
-	insertStmt.BindText(2, string(hashedPassword))
-	insertStmt.BindText(3, "admin")
-
-	if _, err := insertStmt.Step(); err != nil {
 		return fmt.Errorf("error inserting admin user: %w", err)
 	}
 
@@ -1,10 +1,11 @@
 import React from 'react';
-import { MediaItem } from '../types';
+import { MediaAlternate, MediaItem } from '../types';
 import Star from '../svg/star.svg?react';
 import MapComponent from './MapComponent';
 
 interface ExifPanelProps {
   media: MediaItem;
+  alternates?: MediaAlternate[];
 }
 
 const DetailRow = ({
@@ -70,7 +71,7 @@ const ColorRow = ({ color }: { color?: string }) => {
   );
 };
 
-const ExifPanel: React.FC<ExifPanelProps> = ({ media }) => {
+const ExifPanel: React.FC<ExifPanelProps> = ({ media, alternates }) => {
   // displayDate takes a local time and offset and returns a date string in RFC1123Z format.
   const displayDate = (d: string, o: number) => {
     // https://stackoverflow.com/questions/7403486/add-or-subtract-timezone-difference-to-javascript-date
@@ -125,6 +126,16 @@ const ExifPanel: React.FC<ExifPanelProps> = ({ media }) => {
   const modifiedDate = displayDate(media.modified, 0);
   const megapixels = media.width && media.height ? ((media.width * media.height) / 1000000).toFixed(2) : undefined;
 
+  // duration of a video as m:ss, or h:mm:ss for videos of an hour or more
+  const displayDuration = (seconds?: number) => {
+    if (!seconds) return undefined;
+    const total = Math.round(seconds);
+    const h = Math.floor(total / 3600);
+    const m = Math.floor((total % 3600) / 60);
+    const s = String(total % 60).padStart(2, '0');
+    return h > 0 ? `${h}:${String(m).padStart(2, '0')}:${s}` : `${m}:${s}`;
+  };
+
   return (
     <div className="mx-auto mt-8 mb-16 w-[90vw] bg-white md:w-[80vw] dark:bg-zinc-900">
       {/* Media header */}
@@ -150,9 +161,33 @@ const ExifPanel: React.FC<ExifPanelProps> = ({ media }) => {
           <DetailRow label="Folder" value={media.folder} fullWidth />
           <DetailRow label="Resolution" value={`${media.width} x ${media.height}`} />
           <DetailRow label="Megapixels" value={megapixels} />
+          <DetailRow label="Duration" value={displayDuration(media.duration)} />
+          <DetailRow label="Container" value={media.container} />
+          <DetailRow label="Video codec" value={media.videoCodec} />
+          <DetailRow label="Audio codec" value={media.videoCodec ? media.audioCodec || 'None' : undefined} />
+          <DetailRow label="Frame rate" value={media.frameRate ? `${media.frameRate} fps` : undefined} />
+          <DetailRow
+            label="Bitrate"
+            value={media.bitrate ? `${(media.bitrate / 1000000).toFixed(1)} Mbps` : undefined}
+          />
           <DetailRow label="Software" value={media.software} />
           <DetailRow label="UTC offset" value={Math.round(media.offset)} />
           <ColorRow color={media.color} />
+          {alternates && alternates.length > 0 && (
+            <div className="-mx-3 flex flex-col border-b border-zinc-300 px-3 py-3 dark:border-zinc-800">
+              <span className="mb-1 text-sm font-medium text-zinc-700 dark:text-zinc-500">Alternates</span>
+              {alternates.map((alternate) => (
+                <a
+                  key={alternate.path}
+                  href={`/api/media-originals/${alternate.path}`}
+                  download
+                  className="text-primary-700 dark:text-primary-200 font-mono text-sm break-all hover:underline"
+                >
+                  {alternate.path.split('/').pop()} ({(alternate.size / 1000000).toFixed(1)} MB)
+                </a>
+              ))}
+            </div>
+          )}
           {media.subjects && media.subjects.length > 0 && (
             <div className="-mx-3 flex flex-col border-b border-zinc-300 px-3 py-3 dark:border-zinc-800">
               <span className="mb-1 text-sm font-medium text-zinc-700 dark:text-zinc-500">Tags</span>
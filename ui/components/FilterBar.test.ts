@@ -51,6 +51,19 @@ describe('FilterBar token parsing', () => {
     assert.strictEqual(result.searchQuery, '');
   });
 
+  it('should parse library token', () => {
+    const result = parseSearchTokens('sunset library:archive');
+    assert.strictEqual(result.library, 'archive');
+    assert.strictEqual(result.searchQuery, 'sunset');
+  });
+
+  it('should parse video tokens', () => {
+    assert.strictEqual(parseSearchTokens('container:matroska').container, 'matroska');
+    assert.strictEqual(parseSearchTokens('codec:hevc').codec, 'hevc');
+    assert.strictEqual(parseSearchTokens('audio:no').audio, 'no');
+    assert.strictEqual(parseSearchTokens('audio:maybe').audio, undefined);
+  });
+
   it('should handle empty string', () => {
     const result = parseSearchTokens('');
     assert.strictEqual(result.searchQuery, '');
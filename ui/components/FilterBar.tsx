@@ -44,6 +44,10 @@ const FilterBar: React.FC<FilterBarProps> = ({ filters, onFilterChange, totalIte
       lens: undefined,
       software: undefined,
       focallength35: undefined,
+      library: undefined,
+      container: undefined,
+      codec: undefined,
+      audio: undefined,
       mediaType: 'all',
       sortBy: 'date-desc',
       __forceUpdate: Date.now(),
@@ -60,6 +64,10 @@ const FilterBar: React.FC<FilterBarProps> = ({ filters, onFilterChange, totalIte
     Boolean(filters.lens) ||
     Boolean(filters.software) ||
     Boolean(filters.focallength35) ||
+    Boolean(filters.library) ||
+    Boolean(filters.container) ||
+    Boolean(filters.codec) ||
+    Boolean(filters.audio) ||
     filters.mediaType !== 'all' ||
     filters.sortBy !== 'date-desc';
 
@@ -189,6 +197,10 @@ const FilterBar: React.FC<FilterBarProps> = ({ filters, onFilterChange, totalIte
                 if (filters.lens) parts.push(`lens:${filters.lens}`);
                 if (filters.software) parts.push(`software:${filters.software}`);
                 if (filters.focallength35) parts.push(`focallength35:${filters.focallength35}`);
+                if (filters.library) parts.push(`library:${filters.library}`);
+                if (filters.container) parts.push(`container:${filters.container}`);
+                if (filters.codec) parts.push(`codec:${filters.codec}`);
+                if (filters.audio) parts.push(`audio:${filters.audio}`);
                 const inputValue = parts.join(' ').trim();
 
                 return (
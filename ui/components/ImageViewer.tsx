@@ -1,5 +1,7 @@
 import React, { useState, useRef, useEffect, useCallback, useLayoutEffect } from 'react';
-import { MediaItem, ViewMode } from '../types';
+import { MediaItem, MediaMotion, VideoStream, ViewMode } from '../types';
+import { useWebSocket } from '../context/WebSocketContext';
+import SeekPreview from './SeekPreview';
 import ZoomIn from '../svg/zoom-in.svg?react';
 import ZoomOut from '../svg/zoom-out.svg?react';
 import Left from '../svg/left.svg?react';
@@ -10,6 +12,8 @@ import Download from '../svg/download.svg?react';
 
 interface ImageViewerProps {
   media: MediaItem;
+  motion?: MediaMotion;
+  stream?: VideoStream;
   previous: MediaItem[];
   next: MediaItem[];
   viewMode: ViewMode;
@@ -20,6 +24,8 @@ interface ImageViewerProps {
 
 interface MediaSlideProps {
   item: MediaItem | null;
+  motion?: MediaMotion;
+  stream?: VideoStream; // only known for the current slide
   isActive: boolean;
   zoomLevel: number;
   pan: { x: number; y: number };
@@ -27,14 +33,64 @@ interface MediaSlideProps {
   isDragging?: boolean;
 }
 
-const MediaSlide: React.FC<MediaSlideProps> = ({ item, isActive, zoomLevel, pan, suppressTransition, isDragging }) => {
+const MediaSlide: React.FC<MediaSlideProps> = ({
+  item,
+  motion,
+  stream,
+  isActive,
+  zoomLevel,
+  pan,
+  suppressTransition,
+  isDragging,
+}) => {
   const [loading, setLoading] = useState<boolean>(true);
+  const [hovering, setHovering] = useState<boolean>(false);
+  const motionRef = useRef<HTMLVideoElement | null>(null);
+  const { transcodes } = useWebSocket();
+
+  // the transcode of a rendition of the video that playback is waiting for, running ones first
+  const transcode =
+    stream && !stream.direct && item
+      ? Object.values(transcodes)
+          .filter((t) => t.hash === item.hash && t.rendition !== 'sprites')
+          .sort((a, b) => (a.status === 'running' ? 0 : 1) - (b.status === 'running' ? 0 : 1))[0]
+      : undefined;
 
   useEffect(() => {
     // Reset loading whenever the item changes
     setLoading(true);
   }, [item?.hash]);
 
+  // play the video of a live or motion photo while hovering over it
+  useEffect(() => {
+    if (!motion || !hovering || !motionRef.current) return;
+    if (typeof window === 'undefined') return;
+
+    const video = motionRef.current;
+    const Hls = window.Hls || (typeof require !== 'undefined' ? require('hls.js') : null);
+    if (Hls && Hls.isSupported && Hls.isSupported()) {
+      const hls = new Hls({
+        debug: false,
+        maxBufferLength: 3,
+      });
+      hls.loadSource(motion.src);
+      hls.attachMedia(video);
+      hls.on(Hls.Events.MANIFEST_PARSED, function () {
+        video.play().catch(() => {});
+      });
+      return () => {
+        hls.destroy();
+      };
+    } else if (video.canPlayType('application/vnd.apple.mpegurl')) {
+      video.src = motion.src;
+      video.play().catch(() => {});
+      return () => {
+        video.pause();
+        video.removeAttribute('src');
+      };
+    }
+  }, [motion, hovering]);
+
   if (!item) return <div className="text-zinc-700">Empty</div>;
 
   const isVideo = item.type === 'video';
@@ -45,22 +101,40 @@ const MediaSlide: React.FC<MediaSlideProps> = ({ item, isActive, zoomLevel, pan,
     if (!isVideo || !item.hash) return;
     if (typeof window === 'undefined') return;
 
+    // videos of neighbouring slides are loaded once they are the current slide
+    if (!stream) {
+      setLoading(false);
+      return;
+    }
+
+    const video = videoRef.current;
+    if (!video) return;
+
+    // the original file is served with range requests, so the browser seeks without HLS
+    if (stream.direct) {
+      video.src = stream.src;
+      return () => {
+        video.removeAttribute('src');
+        video.load();
+      };
+    }
+
     const Hls = window.Hls || (typeof require !== 'undefined' ? require('hls.js') : null);
-    if (Hls && Hls.isSupported && Hls.isSupported() && videoRef.current) {
+    if (Hls && Hls.isSupported && Hls.isSupported()) {
       const hls = new Hls({
         debug: false,
         maxBufferLength: 3,
       });
-      hls.loadSource(`/api/transcode/${item.hash}/index.m3u8`);
-      hls.attachMedia(videoRef.current);
+      hls.loadSource(stream.src);
+      hls.attachMedia(video);
       hls.on(Hls.Events.MEDIA_ATTACHED, function () {});
       return () => {
         hls.destroy();
       };
-    } else if (videoRef.current && videoRef.current.canPlayType('application/vnd.apple.mpegurl')) {
-      videoRef.current.src = `/transcode/${item.hash}/index.m3u8`;
+    } else if (video.canPlayType('application/vnd.apple.mpegurl')) {
+      video.src = stream.src;
     }
-  }, [isVideo, item.hash]);
+  }, [isVideo, item.hash, stream]);
 
   const style: React.CSSProperties = isZoomed
     ? {
@@ -92,6 +166,8 @@ const MediaSlide: React.FC<MediaSlideProps> = ({ item, isActive, zoomLevel, pan,
       ) : (
         <>
           <img
+            onMouseEnter={() => setHovering(true)}
+            onMouseLeave={() => setHovering(false)}
             srcSet={item.srcset}
             // If zoomed, tell browser we might render at full native width (item.width).
             // If not zoomed, it's fitting in the viewport (100vw).
@@ -105,12 +181,25 @@ const MediaSlide: React.FC<MediaSlideProps> = ({ item, isActive, zoomLevel, pan,
             onLoad={() => setLoading(false)}
             onError={() => setLoading(false)}
           />
+          {motion && hovering && !isZoomed && (
+            <video
+              ref={motionRef}
+              className={`pointer-events-none absolute ${className}`}
+              muted
+              loop
+              playsInline
+              width={item.width}
+              height={item.height}
+            />
+          )}
         </>
       )}
 
+      {isVideo && stream?.thumbnails && !isZoomed && <SeekPreview videoRef={videoRef} src={stream.thumbnails} />}
+
       {loading && (
         <div
-          className="pointer-events-none absolute inset-0 flex items-center justify-center"
+          className="pointer-events-none absolute inset-0 flex flex-col items-center justify-center"
           aria-hidden={false}
           role="status"
         >
@@ -123,6 +212,15 @@ const MediaSlide: React.FC<MediaSlideProps> = ({ item, isActive, zoomLevel, pan,
               />
             </svg>
           </div>
+          {transcode && (
+            <div className="text-sm text-white">
+              {transcode.status === 'queued'
+                ? 'Waiting to transcode'
+                : transcode.progress > 0
+                  ? `Transcoding ${Math.round(transcode.progress * 100)}%`
+                  : 'Transcoding'}
+            </div>
+          )}
         </div>
       )}
     </div>
@@ -131,6 +229,8 @@ const MediaSlide: React.FC<MediaSlideProps> = ({ item, isActive, zoomLevel, pan,
 
 const ImageViewer: React.FC<ImageViewerProps> = ({
   media,
+  motion,
+  stream,
   previous,
   next,
   viewMode,
@@ -454,6 +554,8 @@ const ImageViewer: React.FC<ImageViewerProps> = ({
         <div key={media.hash} className={slideClass}>
           <MediaSlide
             item={media}
+            motion={motion}
+            stream={stream}
             isActive={true}
             zoomLevel={zoomLevel}
             pan={pan}
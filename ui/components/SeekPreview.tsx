@@ -0,0 +1,79 @@
+import React, { useEffect, useRef, useState } from 'react';
+import { parseThumbnailsVtt, ThumbnailCue } from '../lib/parseThumbnailsVtt';
+
+interface SeekPreviewProps {
+  videoRef: React.RefObject<HTMLVideoElement | null>;
+  src: string; // WebVTT thumbnails track
+}
+
+// height of the native video controls, which hold the seek bar
+const CONTROLS_HEIGHT = 48;
+
+const SeekPreview: React.FC<SeekPreviewProps> = ({ videoRef, src }) => {
+  const [cues, setCues] = useState<ThumbnailCue[]>([]);
+  const [preview, setPreview] = useState<{ cue: ThumbnailCue; left: number; top: number } | null>(null);
+  const requested = useRef(false);
+
+  useEffect(() => {
+    requested.current = false;
+    setCues([]);
+    setPreview(null);
+  }, [src]);
+
+  useEffect(() => {
+    const video = videoRef.current;
+    if (!video) return;
+
+    const handleMove = (e: MouseEvent) => {
+      // the sprite sheet is created when the video is first hovered
+      if (!requested.current) {
+        requested.current = true;
+        fetch(src)
+          .then((res) => (res.ok ? res.text() : Promise.reject(res.status)))
+          .then((vtt) => setCues(parseThumbnailsVtt(vtt, src)))
+          .catch((err) => console.error('error loading seek preview', err));
+      }
+
+      const rect = video.getBoundingClientRect();
+      const parent = video.parentElement?.getBoundingClientRect();
+      if (!parent || cues.length === 0 || !video.duration || e.clientY < rect.bottom - CONTROLS_HEIGHT) {
+        setPreview(null);
+        return;
+      }
+
+      const time = (Math.min(Math.max(e.clientX - rect.left, 0), rect.width) / rect.width) * video.duration;
+      const cue = cues.find((c) => time >= c.start && time < c.end) ?? cues[cues.length - 1];
+      // keep the preview within the video
+      const x = Math.min(Math.max(e.clientX, rect.left + cue.w / 2), rect.right - cue.w / 2);
+      setPreview({ cue, left: x - parent.left, top: rect.bottom - CONTROLS_HEIGHT - parent.top });
+    };
+    const handleLeave = () => setPreview(null);
+
+    video.addEventListener('mousemove', handleMove);
+    video.addEventListener('mouseleave', handleLeave);
+    return () => {
+      video.removeEventListener('mousemove', handleMove);
+      video.removeEventListener('mouseleave', handleLeave);
+    };
+  }, [videoRef, src, cues]);
+
+  if (!preview) return null;
+  const { cue } = preview;
+
+  return (
+    <div
+      className="pointer-events-none absolute z-10 rounded border border-white/50 shadow-lg"
+      style={{
+        left: preview.left,
+        top: preview.top,
+        width: cue.w,
+        height: cue.h,
+        transform: 'translate(-50%, -100%)',
+        backgroundImage: `url(${cue.src})`,
+        backgroundPosition: `-${cue.x}px -${cue.y}px`,
+      }}
+    />
+  );
+};
+
+export default SeekPreview;
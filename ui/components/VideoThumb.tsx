@@ -1,12 +1,12 @@
 import React, { useRef, useState } from 'react';
 import Hls from 'hls.js';
 interface VideoThumbProps {
-  hlsUrl: string;
+  src: string; // HLS index, or the original file of videos played directly
   poster?: string;
   alt?: string;
 }
 
-const VideoThumb: React.FC<VideoThumbProps> = ({ hlsUrl, poster, alt }) => {
+const VideoThumb: React.FC<VideoThumbProps> = ({ src, poster, alt }) => {
   const videoRef = useRef<HTMLVideoElement | null>(null);
   const hlsInstanceRef = useRef<Hls | null>(null);
   const [isPlaying, setIsPlaying] = useState(false);
@@ -21,6 +21,10 @@ const VideoThumb: React.FC<VideoThumbProps> = ({ hlsUrl, poster, alt }) => {
   const handleMouseEnter = () => {
     setIsHovered(true);
     if (!videoRef.current) return;
+    if (!src.endsWith('.m3u8')) {
+      videoRef.current.src = src;
+      return;
+    }
     if (Hls && Hls.isSupported()) {
       if (!hlsInstanceRef.current) {
         const hls = new Hls({ maxBufferLength: 3, debug: false });
@@ -34,7 +38,7 @@ const VideoThumb: React.FC<VideoThumbProps> = ({ hlsUrl, poster, alt }) => {
           console.error('HLS error', data);
         });
 
-        hls.loadSource(hlsUrl);
+        hls.loadSource(src);
         hls.attachMedia(videoRef.current);
       }
     }
@@ -470,7 +470,7 @@ export const VirtualGrid: React.FC<VirtualGridProps> = ({
                           />
                         ) : photo.type === 'video' ? (
                           <VideoThumb
-                            hlsUrl={`/api/transcode/${photo.id}/index.m3u8`}
+                            src={photo.url}
                             poster={`/api/img/${photo.id}/800`}
                             alt={photo.id}
                           />
@@ -10,10 +10,20 @@ interface WebSocketMessage {
   created_at?: string;
 }
 
+// progress of the transcode of a rendition of a video, which is not a notification
+export interface TranscodeProgress {
+  type: 'transcode';
+  hash: number;
+  rendition: string;
+  status: 'queued' | 'running' | 'completed' | 'failed';
+  progress: number; // 0 to 1, or 0 if the duration of the video is not known
+}
+
 interface WebSocketContextType {
   isConnected: boolean;
   isScanInProgress: boolean;
   lastMessage: WebSocketMessage | null;
+  transcodes: Record<string, TranscodeProgress>; // by hash and rendition, such as 123/720p
   sendMessage: (message: string) => void;
   reconnectWebSocket: () => void;
 }
@@ -37,6 +47,7 @@ export const WebSocketProvider: React.FC<WebSocketProviderProps> = ({ children }
   const [isConnected, setIsConnected] = useState(false);
   const [isScanInProgress, setIsScanInProgress] = useState(false);
   const [lastMessage, setLastMessage] = useState<WebSocketMessage | null>(null);
+  const [transcodes, setTranscodes] = useState<Record<string, TranscodeProgress>>({});
   const wsRef = useRef<WebSocket | null>(null);
   const reconnectTimeoutRef = useRef<NodeJS.Timeout | null>(null);
   const reconnectAttemptsRef = useRef(0);
@@ -60,7 +71,24 @@ export const WebSocketProvider: React.FC<WebSocketProviderProps> = ({ children }
 
       ws.onmessage = (event) => {
         try {
-          const data: WebSocketMessage = JSON.parse(event.data);
+          const parsed = JSON.parse(event.data);
+          if (parsed.type === 'transcode') {
+            const transcode = parsed as TranscodeProgress;
+            const key = `${transcode.hash}/${transcode.rendition}`;
+            setTranscodes((prev) => {
+              const next = { ...prev };
+              if (transcode.status === 'completed' || transcode.status === 'failed') {
+                delete next[key];
+              } else if (transcode.status !== 'running' || transcode.progress >= (prev[key]?.progress ?? 0)) {
+                // progress can arrive out of order
+                next[key] = transcode;
+              }
+              return next;
+            });
+            return;
+          }
+
+          const data: WebSocketMessage = parsed;
           console.log('WebSocket message received:', data);
           setLastMessage(data);
 
@@ -153,6 +181,7 @@ export const WebSocketProvider: React.FC<WebSocketProviderProps> = ({ children }
     isConnected,
     isScanInProgress,
     lastMessage,
+    transcodes,
     sendMessage,
     reconnectWebSocket,
   };
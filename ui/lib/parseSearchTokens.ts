@@ -6,9 +6,13 @@ export function parseSearchTokens(raw: string): {
   software?: string;
   folder?: string;
   focallength35?: number;
+  library?: string;
+  container?: string;
+  codec?: string;
+  audio?: 'yes' | 'no';
 } {
   // Extract a single token:value pattern at the end of the string
-  const tokenPattern = /\b(tag|camera|lens|software|folder|focallength35):(.+?)$/i;
+  const tokenPattern = /\b(tag|camera|lens|software|folder|focallength35|library|container|codec|audio):(.+?)$/i;
   const match = raw.match(tokenPattern);
 
   if (!match) {
@@ -20,6 +24,10 @@ export function parseSearchTokens(raw: string): {
       software: undefined,
       folder: undefined,
       focallength35: undefined,
+      library: undefined,
+      container: undefined,
+      codec: undefined,
+      audio: undefined,
     };
   }
 
@@ -35,5 +43,9 @@ export function parseSearchTokens(raw: string): {
     software: key === 'software' ? value : undefined,
     folder: key === 'folder' ? value : undefined,
     focallength35: key === 'focallength35' ? parseInt(value, 10) : undefined,
+    library: key === 'library' ? value : undefined,
+    container: key === 'container' ? value : undefined,
+    codec: key === 'codec' ? value : undefined,
+    audio: key === 'audio' && (value === 'yes' || value === 'no') ? value : undefined,
   };
 }
@@ -0,0 +1,44 @@
+export interface ThumbnailCue {
+  start: number; // seconds
+  end: number;
+  src: string; // sprite sheet, resolved against the track
+  x: number;
+  y: number;
+  w: number;
+  h: number;
+}
+
+// parse a timestamp such as 00:01:02.500 or 01:02.500 to seconds
+function parseTime(raw: string): number {
+  return raw
+    .trim()
+    .split(':')
+    .reduce((seconds, part) => seconds * 60 + parseFloat(part), 0);
+}
+
+// Parse a WebVTT thumbnails track, whose cues point at regions of a sprite sheet such as sprite.jpg#xywh=0,0,160,90
+export function parseThumbnailsVtt(vtt: string, trackUrl: string): ThumbnailCue[] {
+  const cues: ThumbnailCue[] = [];
+  const lines = vtt.split(/\r?\n/);
+
+  for (let i = 0; i < lines.length - 1; i++) {
+    if (!lines[i].includes('-->')) continue;
+
+    const [start, end] = lines[i].split('-->');
+    const [file, fragment] = lines[i + 1].trim().split('#xywh=');
+    if (!file || !fragment) continue;
+
+    const [x, y, w, h] = fragment.split(',').map(Number);
+    cues.push({
+      start: parseTime(start),
+      end: parseTime(end.trim().split(/\s+/)[0]),
+      src: new URL(file, new URL(trackUrl, 'http://localhost')).pathname,
+      x,
+      y,
+      w,
+      h,
+    });
+  }
+
+  return cues;
+}
@@ -141,6 +141,8 @@ const MediaDetail: React.FC = () => {
           <main className="flex w-full grow flex-col items-center">
             <ImageViewer
               media={data.media}
+              motion={data.motion}
+              stream={data.stream}
               previous={data.previous}
               next={data.next}
               viewMode={viewMode}
@@ -165,7 +167,7 @@ const MediaDetail: React.FC = () => {
 
           {viewMode === ViewMode.NORMAL && (
             <div className="flex w-full flex-grow flex-col items-center bg-white pb-8 dark:bg-zinc-900">
-              <ExifPanel media={data.media} />
+              <ExifPanel media={data.media} alternates={data.alternates} />
             </div>
           )}
         </div>
@@ -32,6 +32,10 @@ const App: React.FC = () => {
       lens: params.get('lens') || undefined,
       software: params.get('software') || undefined,
       focallength35: params.get('focallength35') ? parseInt(params.get('focallength35') || '0') : undefined,
+      library: params.get('library') || undefined,
+      container: params.get('container') || undefined,
+      codec: params.get('codec') || undefined,
+      audio: (params.get('audio') as 'yes' | 'no') || undefined,
       mediaType: (params.get('type') as 'image' | 'video') || 'all',
       sortBy: `${params.get('orderby') || 'date'}-${params.get('direction') || 'desc'}` as
         | 'date-asc'
@@ -61,7 +65,7 @@ const App: React.FC = () => {
       id: p.id.toString(),
       url:
         p.t === 'video'
-          ? `/api/transcode/${p.id}/index.m3u8`
+          ? p.s || `/api/transcode/${p.id}/index.m3u8`
           : (() => {
               // Build a srcset where the largest candidate is not larger than the photo width
               const candidates = [200, 400, 800];
@@ -92,6 +96,10 @@ const App: React.FC = () => {
       software: filters.software || undefined,
       folder: filters.folder || undefined,
       focallength35: filters.focallength35 || undefined,
+      library: filters.library || undefined,
+      container: filters.container || undefined,
+      codec: filters.codec || undefined,
+      audio: filters.audio || undefined,
       type: filters.mediaType !== 'all' ? filters.mediaType : undefined,
       orderby,
       direction,
@@ -113,6 +121,10 @@ const App: React.FC = () => {
     params.delete('lens');
     params.delete('software');
     params.delete('focallength35');
+    params.delete('library');
+    params.delete('container');
+    params.delete('codec');
+    params.delete('audio');
     params.delete('type');
     params.delete('orderby');
     params.delete('direction');
@@ -126,6 +138,10 @@ const App: React.FC = () => {
     if (apiFilters.lens) params.set('lens', apiFilters.lens);
     if (apiFilters.software) params.set('software', apiFilters.software);
     if (apiFilters.focallength35) params.set('focallength35', apiFilters.focallength35.toString());
+    if (apiFilters.library) params.set('library', apiFilters.library);
+    if (apiFilters.container) params.set('container', apiFilters.container);
+    if (apiFilters.codec) params.set('codec', apiFilters.codec);
+    if (apiFilters.audio) params.set('audio', apiFilters.audio);
     if (apiFilters.type) params.set('type', apiFilters.type);
     // Only add orderby/direction if not default values
     if (apiFilters.orderby && apiFilters.orderby !== 'date') params.set('orderby', apiFilters.orderby);
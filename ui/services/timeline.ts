@@ -26,6 +26,10 @@ export const fetchPhotos = async (
     if (filters.subject) url.searchParams.set('subject', filters.subject);
     if (filters.software) url.searchParams.set('software', filters.software);
     if (filters.focallength35) url.searchParams.set('focallength35', filters.focallength35.toString());
+    if (filters.library) url.searchParams.set('library', filters.library);
+    if (filters.container) url.searchParams.set('container', filters.container);
+    if (filters.codec) url.searchParams.set('codec', filters.codec);
+    if (filters.audio) url.searchParams.set('audio', filters.audio);
   }
 
   const res = await fetch(url.toString());
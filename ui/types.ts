@@ -76,6 +76,7 @@ export interface ApiPhoto {
   c: string;
   t: string;
   d: string; // "YYYY-MM-DD"
+  s?: string; // stream of a video, either its original file or HLS index
   path: string;
 }
 
@@ -185,6 +186,13 @@ export interface MediaItem {
   title?: string;
   software?: string;
   offset?: number;
+  container?: string;
+  videoCodec?: string;
+  audioCodec?: string;
+  duration?: number;
+  frameRate?: number;
+  bitrate?: number;
+  hasAudio?: boolean;
   // UI
   id?: string;
   thumbnailUrl?: string;
@@ -200,8 +208,30 @@ export interface MediaNeighbor {
   srcset: string;
 }
 
+export interface MediaAlternate {
+  path: string;
+  size: number;
+  modified: string;
+}
+
+export interface MediaMotion {
+  path: string;
+  embedded: boolean;
+  src: string;
+}
+
+export interface VideoStream {
+  src: string;
+  direct: boolean; // src is the original file rather than an HLS index
+  mimeType?: string;
+  thumbnails?: string; // WebVTT track of the sprite sheet shown while seeking
+}
+
 export interface MediaResponse {
   media: MediaItem;
+  alternates?: MediaAlternate[];
+  motion?: MediaMotion;
+  stream?: VideoStream;
   previous: MediaNeighbor[];
   next: MediaNeighbor[];
   collection: string;
@@ -210,6 +240,9 @@ export interface MediaResponse {
 
 export interface ApiResponse {
   media: MediaItem;
+  alternates?: MediaAlternate[];
+  motion?: MediaMotion;
+  stream?: VideoStream;
   previous: MediaItem[];
   next: MediaItem[];
   collection: string;
@@ -244,6 +277,10 @@ export interface FilterState {
   lens?: string;
   software?: string;
   focallength35?: number;
+  library?: string;
+  container?: string;
+  codec?: string;
+  audio?: 'yes' | 'no';
   mediaType: 'image' | 'video' | 'all';
   sortBy: SortOption;
 }
@@ -261,4 +298,8 @@ export interface TimelineFilters {
   subject?: string;
   software?: string;
   focallength35?: number;
+  library?: string;
+  container?: string;
+  codec?: string;
+  audio?: 'yes' | 'no';
 }